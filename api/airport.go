@@ -2,12 +2,15 @@ package api
 
 import (
 	"errors"
+	"net/url"
 	"strconv"
+	"strings"
 	"sublink/dto"
 	"sublink/models"
 	"sublink/node"
 	"sublink/services/scheduler"
 	"sublink/utils"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/robfig/cron/v3"
@@ -21,6 +24,127 @@ func validateCron(expr string) bool {
 	return err == nil
 }
 
+// validateAirportIdentity 验证节点身份匹配配置
+func validateAirportIdentity(req *dto.AirportRequest) string {
+	if !models.IsValidAirportIdentityMatch(req.IdentityMatch) {
+		return "不支持的节点身份匹配方式"
+	}
+	if req.IdentityMatch != models.AirportIdentityFields {
+		return ""
+	}
+	if strings.TrimSpace(req.IdentityFields) == "" {
+		return "按自定义字段匹配时需要指定匹配字段"
+	}
+	validFields := make(map[string]bool)
+	for _, meta := range models.GetNodeFieldsMeta() {
+		validFields[meta.Name] = true
+	}
+	for _, f := range strings.Split(req.IdentityFields, ",") {
+		if f = strings.TrimSpace(f); f != "" && !validFields[f] {
+			return "不支持的匹配字段: " + f
+		}
+	}
+	return ""
+}
+
+// applyAirportImportRules 将请求中的导入规则写入机场
+func applyAirportImportRules(airport *models.Airport, req *dto.AirportRequest) {
+	airport.ImportIncludeName = strings.TrimSpace(req.ImportIncludeName)
+	airport.ImportExcludeName = strings.TrimSpace(req.ImportExcludeName)
+	airport.ImportIncludeProtocols = req.ImportIncludeProtocols
+	airport.ImportExcludeProtocols = req.ImportExcludeProtocols
+	airport.ImportIncludePorts = req.ImportIncludePorts
+	airport.ImportExcludePorts = req.ImportExcludePorts
+	airport.ImportNamePreprocess = req.ImportNamePreprocess
+	airport.ImportScript = req.ImportScript
+}
+
+// applyAirportURLs 校验并将请求中的备用地址与多地址模式写入机场
+func applyAirportURLs(airport *models.Airport, req *dto.AirportRequest) string {
+	if !models.IsValidAirportURLMode(req.URLMode) {
+		return "不支持的多地址模式"
+	}
+	mirrors := models.ParseAirportMirrorURLs(req.MirrorURLs)
+	for _, m := range mirrors {
+		u, err := url.Parse(m)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "无效的备用地址: " + m
+		}
+	}
+	airport.MirrorURLs = strings.Join(mirrors, "\n")
+	airport.URLMode = req.URLMode
+	if airport.URLMode == "failover" {
+		airport.URLMode = models.AirportURLModeFailover
+	}
+	return ""
+}
+
+// applyAirportUsageAlerts 将请求中的用量告警配置写入机场
+func applyAirportUsageAlerts(airport *models.Airport, req *dto.AirportRequest) {
+	airport.UsageAlertPercent = min(max(req.UsageAlertPercent, -1), 100)
+	airport.ExpireAlertDays = max(req.ExpireAlertDays, -1)
+	airport.DisableForecastAlert = req.DisableForecastAlert
+}
+
+// applyAirportProxy 校验并将请求中的代理下载配置写入机场
+func applyAirportProxy(airport *models.Airport, req *dto.AirportRequest) string {
+	source := strings.TrimSpace(req.ProxySource)
+	if source != "" {
+		var name string
+		switch {
+		case strings.HasPrefix(source, models.ProxySourceTagPrefix):
+			name = strings.TrimPrefix(source, models.ProxySourceTagPrefix)
+		case strings.HasPrefix(source, models.ProxySourceGroupPrefix):
+			name = strings.TrimPrefix(source, models.ProxySourceGroupPrefix)
+		default:
+			return "代理来源格式应为 tag:标签名 或 group:分组名"
+		}
+		if strings.TrimSpace(name) == "" {
+			return "代理来源未指定标签或分组名称"
+		}
+	}
+	airport.ProxySource = source
+	airport.ProxyMaxAttempts = min(max(req.ProxyMaxAttempts, 0), 10)
+	airport.ProxyDirectFallback = req.ProxyDirectFallback
+	return ""
+}
+
+// applyAirportPanel 校验并将请求中的面板账号写入机场
+// 面板类型为空时清除面板账号；密码留空保持不变，账号变化时清除已保存的登录凭证
+func applyAirportPanel(airport *models.Airport, req *dto.AirportRequest) string {
+	panelType := strings.ToLower(strings.TrimSpace(req.PanelType))
+	if !models.IsValidAirportPanelType(panelType) {
+		return "面板类型应为 v2board、xboard 或 sspanel"
+	}
+	airport.PanelNoRotate = req.PanelNoRotate
+	if panelType == models.AirportPanelNone {
+		airport.PanelType, airport.PanelURL, airport.PanelEmail = "", "", ""
+		airport.PanelPassword, airport.PanelAuth = "", ""
+		return ""
+	}
+
+	panelURL := strings.TrimRight(strings.TrimSpace(req.PanelURL), "/")
+	if u, err := url.Parse(panelURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "面板地址无效，需以 http:// 或 https:// 开头"
+	}
+	email := strings.TrimSpace(req.PanelEmail)
+	if email == "" {
+		return "请填写面板登录邮箱"
+	}
+	if panelType != airport.PanelType || panelURL != airport.PanelURL || email != airport.PanelEmail {
+		airport.PanelAuth = ""
+	}
+	airport.PanelType, airport.PanelURL, airport.PanelEmail = panelType, panelURL, email
+	if req.PanelPassword != "" {
+		if err := airport.SetPanelPassword(req.PanelPassword); err != nil {
+			return "加密面板密码失败: " + err.Error()
+		}
+	} else if airport.PanelPassword == "" {
+		return "请填写面板登录密码"
+	}
+	return ""
+}
+
 // AirportWithStats 机场数据（包含节点统计）
 type AirportWithStats struct {
 	models.Airport
@@ -149,6 +273,10 @@ func AirportAdd(c *gin.Context) {
 		utils.FailWithMsg(c, "Cron表达式格式错误")
 		return
 	}
+	if msg := validateAirportIdentity(&req); msg != "" {
+		utils.FailWithMsg(c, msg)
+		return
+	}
 
 	airport := models.Airport{
 		Name:              req.Name,
@@ -163,6 +291,29 @@ func AirportAdd(c *gin.Context) {
 		SkipTLSVerify:     req.SkipTLSVerify,
 		Remark:            req.Remark,
 		Logo:              req.Logo,
+		MaxDeleteRatio:    min(max(req.MaxDeleteRatio, 0), 100),
+		MinNodeCount:      max(req.MinNodeCount, 0),
+		DeleteGraceSyncs:  max(req.DeleteGraceSyncs, 0),
+		IdentityMatch:     req.IdentityMatch,
+		IdentityFields:    req.IdentityFields,
+	}
+	applyAirportImportRules(&airport, &req)
+	applyAirportUsageAlerts(&airport, &req)
+	if err := node.ValidateAirportImportRules(&airport); err != nil {
+		utils.FailWithMsg(c, err.Error())
+		return
+	}
+	if msg := applyAirportURLs(&airport, &req); msg != "" {
+		utils.FailWithMsg(c, msg)
+		return
+	}
+	if msg := applyAirportProxy(&airport, &req); msg != "" {
+		utils.FailWithMsg(c, msg)
+		return
+	}
+	if msg := applyAirportPanel(&airport, &req); msg != "" {
+		utils.FailWithMsg(c, msg)
+		return
 	}
 
 	// 检查是否重复
@@ -211,6 +362,10 @@ func AirportUpdate(c *gin.Context) {
 		utils.FailWithMsg(c, "Cron表达式格式错误")
 		return
 	}
+	if msg := validateAirportIdentity(&req); msg != "" {
+		utils.FailWithMsg(c, msg)
+		return
+	}
 
 	// 检查是否存在
 	existing, err := models.GetAirportByID(id)
@@ -242,11 +397,38 @@ func AirportUpdate(c *gin.Context) {
 	existing.SkipTLSVerify = req.SkipTLSVerify
 	existing.Remark = req.Remark
 	existing.Logo = req.Logo
+	existing.MaxDeleteRatio = min(max(req.MaxDeleteRatio, 0), 100)
+	existing.MinNodeCount = max(req.MinNodeCount, 0)
+	existing.DeleteGraceSyncs = max(req.DeleteGraceSyncs, 0)
+	existing.IdentityMatch = req.IdentityMatch
+	existing.IdentityFields = req.IdentityFields
+	applyAirportImportRules(existing, &req)
+	applyAirportUsageAlerts(existing, &req)
+	if err := node.ValidateAirportImportRules(existing); err != nil {
+		utils.FailWithMsg(c, err.Error())
+		return
+	}
+	if msg := applyAirportURLs(existing, &req); msg != "" {
+		utils.FailWithMsg(c, msg)
+		return
+	}
+	if msg := applyAirportProxy(existing, &req); msg != "" {
+		utils.FailWithMsg(c, msg)
+		return
+	}
+	if msg := applyAirportPanel(existing, &req); msg != "" {
+		utils.FailWithMsg(c, msg)
+		return
+	}
 
 	if err := existing.Update(); err != nil {
 		utils.FailWithMsg(c, "更新失败: "+err.Error())
 		return
 	}
+	// 配置可能影响导入结果，清除条件拉取状态，下次拉取完整处理
+	if err := existing.SaveFetchState("", "", "", ""); err != nil {
+		utils.Warn("清除机场拉取状态失败: %v", err)
+	}
 
 	// 同步更新关联节点的来源名称和分组
 	if err := models.UpdateNodesByAirportID(id, req.Name, req.Group); err != nil {
@@ -349,3 +531,257 @@ func AirportRefreshUsage(c *gin.Context) {
 		"expire":   usageInfo.Expire,
 	})
 }
+
+// AirportChangesetList 获取机场同步变更记录
+func AirportChangesetList(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.FailWithMsg(c, "参数错误")
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	changesets, total, err := models.ListAirportChangesets(id, page, pageSize)
+	if err != nil {
+		utils.FailWithMsg(c, "获取变更记录失败")
+		return
+	}
+	utils.OkDetailed(c, "获取成功", gin.H{
+		"items":    changesets,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// AirportChangesetGet 获取单条变更记录的明细
+func AirportChangesetGet(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.FailWithMsg(c, "参数错误")
+		return
+	}
+	changesetID, err := strconv.Atoi(c.Param("changesetId"))
+	if err != nil {
+		utils.FailWithMsg(c, "参数错误")
+		return
+	}
+
+	changeset, err := models.GetAirportChangeset(id, changesetID)
+	if err != nil {
+		utils.FailWithMsg(c, "变更记录不存在")
+		return
+	}
+	utils.OkDetailed(c, "获取成功", gin.H{
+		"changeset": changeset,
+		"changes":   changeset.Detail(),
+		"snapshot":  changeset.SnapshotNodes(),
+	})
+}
+
+// AirportRollback 将机场节点回滚到指定变更记录的快照
+func AirportRollback(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.FailWithMsg(c, "参数错误")
+		return
+	}
+	var req struct {
+		ChangesetID int `json:"changesetId"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.ChangesetID <= 0 {
+		utils.FailWithMsg(c, "请指定要回滚到的变更记录")
+		return
+	}
+
+	changeset, err := models.RollbackAirport(id, req.ChangesetID)
+	if err != nil {
+		utils.FailWithMsg(c, "回滚失败: "+err.Error())
+		return
+	}
+	utils.OkDetailed(c, "回滚成功", changeset)
+}
+
+// AirportURLHealthList 获取机场各订阅地址的健康状态
+func AirportURLHealthList(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.FailWithMsg(c, "参数错误")
+		return
+	}
+	airport, err := models.GetAirportByID(id)
+	if err != nil {
+		utils.FailWithMsg(c, "机场不存在")
+		return
+	}
+
+	records, err := models.ListAirportURLHealth(airport)
+	if err != nil {
+		utils.FailWithMsg(c, "获取地址状态失败: "+err.Error())
+		return
+	}
+	utils.OkDetailed(c, "获取成功", gin.H{
+		"mode":      airport.URLMode,
+		"activeUrl": airport.ActiveURL,
+		"items":     records,
+	})
+}
+
+// AirportUsageHistory 获取机场用量历史与耗尽预测
+func AirportUsageHistory(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.FailWithMsg(c, "参数错误")
+		return
+	}
+	airport, err := models.GetAirportByID(id)
+	if err != nil {
+		utils.FailWithMsg(c, "机场不存在")
+		return
+	}
+
+	days := 30
+	if d, err := strconv.Atoi(c.Query("days")); err == nil && d > 0 {
+		days = min(d, 90)
+	}
+	records, err := models.ListAirportUsageRecords(id, days)
+	if err != nil {
+		utils.FailWithMsg(c, "获取用量历史失败: "+err.Error())
+		return
+	}
+	forecast, err := models.GetAirportUsageForecast(airport)
+	if err != nil {
+		utils.FailWithMsg(c, "计算用量预测失败: "+err.Error())
+		return
+	}
+	utils.OkDetailed(c, "获取成功", gin.H{
+		"records":  records,
+		"forecast": forecast,
+	})
+}
+
+// AirportHealthList 获取所有机场的拉取健康汇总
+func AirportHealthList(c *gin.Context) {
+	summaries, err := models.ListAirportHealthSummaries()
+	if err != nil {
+		utils.FailWithMsg(c, "获取机场健康状态失败: "+err.Error())
+		return
+	}
+	utils.OkDetailed(c, "获取成功", summaries)
+}
+
+// AirportHealth 获取单个机场的拉取健康汇总、各地址状态和最近拉取记录
+func AirportHealth(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.FailWithMsg(c, "参数错误")
+		return
+	}
+	airport, err := models.GetAirportByID(id)
+	if err != nil {
+		utils.FailWithMsg(c, "机场不存在")
+		return
+	}
+
+	summary, err := models.GetAirportHealthSummary(airport)
+	if err != nil {
+		utils.FailWithMsg(c, "获取机场健康状态失败: "+err.Error())
+		return
+	}
+	urls, err := models.ListAirportURLHealth(airport)
+	if err != nil {
+		utils.FailWithMsg(c, "获取地址状态失败: "+err.Error())
+		return
+	}
+	limit := 20
+	if l, err := strconv.Atoi(c.Query("limit")); err == nil && l > 0 {
+		limit = min(l, 50)
+	}
+	attempts, err := models.ListAirportFetchAttempts(id, limit)
+	if err != nil {
+		utils.FailWithMsg(c, "获取拉取记录失败: "+err.Error())
+		return
+	}
+	utils.OkDetailed(c, "获取成功", gin.H{
+		"summary":  summary,
+		"urls":     urls,
+		"attempts": attempts,
+	})
+}
+
+// AirportPanelTest 测试面板登录，返回面板中的订阅地址、套餐与流量信息，不保存任何数据
+func AirportPanelTest(c *gin.Context) {
+	var req dto.AirportPanelTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.FailWithMsg(c, "参数错误: "+err.Error())
+		return
+	}
+	panelType := strings.ToLower(strings.TrimSpace(req.PanelType))
+	if panelType == models.AirportPanelNone || !models.IsValidAirportPanelType(panelType) {
+		utils.FailWithMsg(c, "面板类型应为 v2board、xboard 或 sspanel")
+		return
+	}
+
+	password := req.PanelPassword
+	proxyOpts := utils.ProxyOptions{Timeout: 20 * time.Second, SkipTLSVerify: req.SkipTLSVerify}
+	if req.ID > 0 {
+		airport, err := models.GetAirportByID(req.ID)
+		if err != nil {
+			utils.FailWithMsg(c, "机场不存在")
+			return
+		}
+		proxyOpts = airport.ProxyOptions(20 * time.Second)
+		if password == "" {
+			if password, err = airport.GetPanelPassword(); err != nil {
+				utils.FailWithMsg(c, "解密面板密码失败: "+err.Error())
+				return
+			}
+		}
+	}
+	if password == "" {
+		utils.FailWithMsg(c, "请填写面板登录密码")
+		return
+	}
+
+	info, err := node.TestAirportPanel(panelType, req.PanelURL, strings.TrimSpace(req.PanelEmail), password, proxyOpts)
+	if err != nil {
+		utils.FailWithMsg(c, "面板测试失败: "+err.Error())
+		return
+	}
+	utils.OkDetailed(c, "面板登录成功", info)
+}
+
+// AirportPanelSync 立即登录面板同步套餐、流量与订阅地址
+func AirportPanelSync(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.FailWithMsg(c, "参数错误")
+		return
+	}
+	airport, err := models.GetAirportByID(id)
+	if err != nil {
+		utils.FailWithMsg(c, "机场不存在")
+		return
+	}
+	if !airport.HasPanel() {
+		utils.FailWithMsg(c, "该机场未配置面板账号")
+		return
+	}
+
+	info, err := node.SyncAirportPanel(airport)
+	if err != nil {
+		utils.FailWithMsg(c, "面板同步失败: "+err.Error())
+		return
+	}
+	utils.OkDetailed(c, "面板同步成功", gin.H{
+		"info": info,
+		"url":  airport.URL,
+	})
+}
@@ -154,6 +154,7 @@ func GetV2ray(c *gin.Context) {
 		return
 	}
 
+	// 分享链接无法表达前置代理，链式代理规则与节点前置代理在此格式下不生效，节点按直连输出（见 models.ChainClientSupports）
 	for idx, v := range sub.Nodes {
 		// 应用预处理规则到 LinkName
 		processedLinkName := utils.PreprocessNodeName(sub.NodeNamePreprocess, v.LinkName)
@@ -171,6 +172,9 @@ func GetV2ray(c *gin.Context) {
 				Index:       idx + 1,
 				Protocol:    utils.GetProtocolFromLink(v.Link),
 				Tags:        v.Tags,
+				LandingASN:  v.LandingASN,
+				LandingISP:  v.LandingISP,
+				LandingCity: v.LandingCity,
 			})
 			nodeLink = utils.RenameNodeLink(v.Link, newName)
 		}
@@ -192,6 +196,9 @@ func GetV2ray(c *gin.Context) {
 						Index:       idx + 1,
 						Protocol:    utils.GetProtocolFromLink(link),
 						Tags:        v.Tags,
+						LandingASN:  v.LandingASN,
+						LandingISP:  v.LandingISP,
+						LandingCity: v.LandingCity,
 					})
 					links[i] = utils.RenameNodeLink(link, newName)
 				}
@@ -222,7 +229,7 @@ func GetV2ray(c *gin.Context) {
 
 	// 执行脚本
 	for _, script := range sub.ScriptsWithSort {
-		res, err := utils.RunScript(script.Content, baselist, "v2ray")
+		res, err := utils.RunScript(script.Source(), baselist, "v2ray")
 		if err != nil {
 			utils.Error("Script execution failed: %v", err)
 			continue
@@ -258,104 +265,20 @@ func GetClash(c *gin.Context) {
 		return
 	}
 
-	// 获取链式代理规则
-	chainRules := models.GetEnabledChainRulesBySubscriptionID(sub.ID)
+	// 解析链式代理规则（与 Surge 共用）
+	chainPlan := models.BuildChainDialerPlan(&sub, models.GetEnabledChainRulesBySubscriptionID(sub.ID))
 
-	// 构建节点ID到最终名称的映射（用于链式代理规则解析）
-	nodeNameMap := make(map[int]string)
-	for idx, v := range sub.Nodes {
-		// 计算节点最终名称
-		processedLinkName := utils.PreprocessNodeName(sub.NodeNamePreprocess, v.LinkName)
-		finalName := v.LinkName // 默认使用原始名称
-		if sub.NodeNameRule != "" {
-			finalName = utils.RenameNode(sub.NodeNameRule, utils.NodeInfo{
-				Name:        v.Name,
-				LinkName:    processedLinkName,
-				LinkCountry: v.LinkCountry,
-				Speed:       v.Speed,
-				DelayTime:   v.DelayTime,
-				Group:       v.Group,
-				Source:      v.Source,
-				Index:       idx + 1,
-				Protocol:    utils.GetProtocolFromLink(v.Link),
-				Tags:        v.Tags,
-			})
-		}
-		nodeNameMap[v.ID] = finalName
-	}
-
-	// 收集自定义代理组
-	customGroups := models.CollectCustomProxyGroups(chainRules, sub.Nodes, nodeNameMap)
-
-	// ========== 第一阶段：预先收集所有链路的中间节点 dialer-proxy 映射 ==========
-	// key: 节点名称, value: 该节点应设置的 dialer-proxy
-	chainNodeDialerMap := make(map[string]string)
-	// 同时记录每个目标节点应使用的 FinalDialer
-	targetNodeDialerMap := make(map[int]string)
-
-	if len(chainRules) > 0 {
-		for _, v := range sub.Nodes {
-			// 检查该节点是否匹配任何链式规则
-			chainResult := models.ApplyChainRulesToNodeV2(v, chainRules, sub.Nodes, nodeNameMap)
-			if chainResult != nil && chainResult.FinalDialer != "" {
-				// 记录目标节点的 dialer-proxy
-				targetNodeDialerMap[v.ID] = chainResult.FinalDialer
-				// 收集链路中间节点的 dialer-proxy 映射
-				for _, link := range chainResult.Links {
-					// 只处理非代理组类型的中间节点（代理组类型的 dialer-proxy 由组本身处理）
-					if !link.IsGroup && link.DialerProxy != "" {
-						// 如果同一节点在多个规则中作为中间节点，使用最先匹配的
-						if _, exists := chainNodeDialerMap[link.ProxyName]; !exists {
-							chainNodeDialerMap[link.ProxyName] = link.DialerProxy
-						}
-					}
-				}
-				// 收集中间节点自定义代理组内节点的 dialer-proxy 映射
-				for memberName, dialerProxy := range chainResult.GroupMemberDialerMap {
-					if _, exists := chainNodeDialerMap[memberName]; !exists {
-						chainNodeDialerMap[memberName] = dialerProxy
-					}
-				}
-			}
-		}
-		utils.Debug("[ChainProxy] 收集完成: 目标节点=%d, 中间节点=%d", len(targetNodeDialerMap), len(chainNodeDialerMap))
-	}
-
-	// ========== 第二阶段：遍历节点生成配置 ==========
 	for idx, v := range sub.Nodes {
 		// 应用预处理规则到 LinkName
 		processedLinkName := utils.PreprocessNodeName(sub.NodeNamePreprocess, v.LinkName)
 		// 应用重命名规则
 		nodeLink := v.Link
 		if sub.NodeNameRule != "" {
-			newName := utils.RenameNode(sub.NodeNameRule, utils.NodeInfo{
-				Name:        v.Name,
-				LinkName:    processedLinkName,
-				LinkCountry: v.LinkCountry,
-				Speed:       v.Speed,
-				DelayTime:   v.DelayTime,
-				Group:       v.Group,
-				Source:      v.Source,
-				Index:       idx + 1,
-				Protocol:    utils.GetProtocolFromLink(v.Link),
-				Tags:        v.Tags,
-			})
-			nodeLink = utils.RenameNodeLink(v.Link, newName)
+			nodeLink = utils.RenameNodeLink(v.Link, chainPlan.NodeNames[v.ID])
 		}
 
 		// 计算 dialer-proxy（链式代理规则）
-		dialerProxy := strings.TrimSpace(v.DialerProxyName)
-
-		// 优先级：中间节点映射 > 目标节点映射 > 节点自身设置
-		finalNodeName := nodeNameMap[v.ID]
-
-		// 检查是否作为链路中间节点（最高优先级）
-		if chainDialer, exists := chainNodeDialerMap[finalNodeName]; exists {
-			dialerProxy = chainDialer
-		} else if targetDialer, exists := targetNodeDialerMap[v.ID]; exists && dialerProxy == "" {
-			// 作为目标节点
-			dialerProxy = targetDialer
-		}
+		dialerProxy := chainPlan.DialerFor(v)
 
 		switch {
 		// 如果包含多条节点
@@ -375,6 +298,9 @@ func GetClash(c *gin.Context) {
 						Index:       idx + 1,
 						Protocol:    utils.GetProtocolFromLink(link),
 						Tags:        v.Tags,
+						LandingASN:  v.LandingASN,
+						LandingISP:  v.LandingISP,
+						LandingCity: v.LandingCity,
 					})
 					renamedLink = utils.RenameNodeLink(link, newName)
 				}
@@ -424,22 +350,7 @@ func GetClash(c *gin.Context) {
 	}
 
 	// 添加自定义代理组到配置
-	if len(customGroups) > 0 {
-		configs.CustomProxyGroups = make([]protocol.CustomProxyGroup, 0, len(customGroups))
-		for _, g := range customGroups {
-			cpg := protocol.CustomProxyGroup{
-				Name:    g.Name,
-				Type:    g.Type,
-				Proxies: g.Proxies,
-			}
-			if g.URLTestConfig != nil {
-				cpg.URL = g.URLTestConfig.URL
-				cpg.Interval = g.URLTestConfig.Interval
-				cpg.Tolerance = g.URLTestConfig.Tolerance
-			}
-			configs.CustomProxyGroups = append(configs.CustomProxyGroups, cpg)
-		}
-	}
+	configs.CustomProxyGroups = toProtocolCustomGroups(chainPlan.CustomGroups)
 
 	DecodeClash, err := protocol.EncodeClash(urls, configs)
 	if err != nil {
@@ -454,7 +365,7 @@ func GetClash(c *gin.Context) {
 
 	// 执行脚本
 	for _, script := range sub.ScriptsWithSort {
-		res, err := utils.RunScript(script.Content, string(DecodeClash), "clash")
+		res, err := utils.RunScript(script.Source(), string(DecodeClash), "clash")
 		if err != nil {
 			utils.Error("Script execution failed: %v", err)
 			continue
@@ -479,7 +390,7 @@ func GetSurge(c *gin.Context) {
 		c.Writer.WriteString("读取错误")
 		return
 	}
-	urls := []string{}
+	urls := []protocol.Urls{}
 
 	// 根据配置决定是否实时刷新用量信息
 	if sub.RefreshUsageOnRequest {
@@ -490,31 +401,24 @@ func GetSurge(c *gin.Context) {
 	if c.Request.Method == "HEAD" {
 		return
 	}
+
+	// 解析链式代理规则，前置代理以 underlying-proxy 输出
+	chainPlan := models.BuildChainDialerPlan(&sub, models.GetEnabledChainRulesBySubscriptionID(sub.ID))
+
 	for idx, v := range sub.Nodes {
 		// 应用预处理规则到 LinkName
 		processedLinkName := utils.PreprocessNodeName(sub.NodeNamePreprocess, v.LinkName)
 		// 应用重命名规则
 		nodeLink := v.Link
 		if sub.NodeNameRule != "" {
-			newName := utils.RenameNode(sub.NodeNameRule, utils.NodeInfo{
-				Name:        v.Name,
-				LinkName:    processedLinkName,
-				LinkCountry: v.LinkCountry,
-				Speed:       v.Speed,
-				DelayTime:   v.DelayTime,
-				Group:       v.Group,
-				Source:      v.Source,
-				Index:       idx + 1,
-				Protocol:    utils.GetProtocolFromLink(v.Link),
-				Tags:        v.Tags,
-			})
-			nodeLink = utils.RenameNodeLink(v.Link, newName)
+			nodeLink = utils.RenameNodeLink(v.Link, chainPlan.NodeNames[v.ID])
 		}
+		dialerProxy := chainPlan.DialerFor(v)
 		switch {
 		// 如果包含多条节点
 		case strings.Contains(v.Link, ","):
 			links := strings.Split(v.Link, ",")
-			for i, link := range links {
+			for _, link := range links {
 				if sub.NodeNameRule != "" {
 					newName := utils.RenameNode(sub.NodeNameRule, utils.NodeInfo{
 						Name:        v.Name,
@@ -527,11 +431,14 @@ func GetSurge(c *gin.Context) {
 						Index:       idx + 1,
 						Protocol:    utils.GetProtocolFromLink(link),
 						Tags:        v.Tags,
+						LandingASN:  v.LandingASN,
+						LandingISP:  v.LandingISP,
+						LandingCity: v.LandingCity,
 					})
-					links[i] = utils.RenameNodeLink(link, newName)
+					link = utils.RenameNodeLink(link, newName)
 				}
+				urls = append(urls, protocol.Urls{Url: link, DialerProxyName: dialerProxy})
 			}
-			urls = append(urls, links...)
 			continue
 		//如果是订阅转换（以 http:// 或 https:// 开头）
 		case strings.HasPrefix(v.Link, "http://") || strings.HasPrefix(v.Link, "https://"):
@@ -543,11 +450,12 @@ func GetSurge(c *gin.Context) {
 			defer resp.Body.Close()
 			body, _ := io.ReadAll(resp.Body)
 			nodes := utils.Base64Decode(string(body))
-			links := strings.Split(nodes, "\n")
-			urls = append(urls, links...)
+			for _, link := range strings.Split(nodes, "\n") {
+				urls = append(urls, protocol.Urls{Url: link, DialerProxyName: dialerProxy})
+			}
 		// 默认
 		default:
-			urls = append(urls, nodeLink)
+			urls = append(urls, protocol.Urls{Url: nodeLink, DialerProxyName: dialerProxy})
 		}
 	}
 
@@ -563,8 +471,11 @@ func GetSurge(c *gin.Context) {
 		configs.HostMap = models.GetHostMap()
 	}
 
+	// 添加自定义代理组到配置
+	configs.CustomProxyGroups = toProtocolCustomGroups(chainPlan.CustomGroups)
+
 	// log.Println("surge路径:", configs)
-	DecodeClash, err := protocol.EncodeSurge(urls, configs)
+	DecodeClash, err := protocol.EncodeSurgeUrls(urls, configs)
 	if err != nil {
 		c.Writer.WriteString(err.Error())
 		return
@@ -586,7 +497,7 @@ func GetSurge(c *gin.Context) {
 	interval := fmt.Sprintf("#!MANAGED-CONFIG %s interval=86400 strict=false", host+url)
 	// 执行脚本
 	for _, script := range sub.ScriptsWithSort {
-		res, err := utils.RunScript(script.Content, DecodeClash, "surge")
+		res, err := utils.RunScript(script.Source(), DecodeClash, "surge")
 		if err != nil {
 			utils.Error("Script execution failed: %v", err)
 			continue
@@ -596,6 +507,28 @@ func GetSurge(c *gin.Context) {
 	c.Writer.WriteString(string(interval + "\n" + DecodeClash))
 }
 
+// toProtocolCustomGroups 将链式代理规则生成的自定义代理组转换为输出配置格式
+func toProtocolCustomGroups(groups []models.CustomProxyGroup) []protocol.CustomProxyGroup {
+	if len(groups) == 0 {
+		return nil
+	}
+	result := make([]protocol.CustomProxyGroup, 0, len(groups))
+	for _, g := range groups {
+		cpg := protocol.CustomProxyGroup{
+			Name:    g.Name,
+			Type:    g.Type,
+			Proxies: g.Proxies,
+		}
+		if g.URLTestConfig != nil {
+			cpg.URL = g.URLTestConfig.URL
+			cpg.Interval = g.URLTestConfig.Interval
+			cpg.Tolerance = g.URLTestConfig.Tolerance
+		}
+		result = append(result, cpg)
+	}
+	return result
+}
+
 // getSubscriptionUsage 计算订阅的流量使用情况
 func getSubscriptionUsage(nodes []models.Node) string {
 	airportIDs := make(map[int]bool)
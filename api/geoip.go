@@ -35,6 +35,7 @@ type GeoIPConfigResponse struct {
 	UseProxy    bool   `json:"useProxy"`    // 是否使用代理
 	ProxyLink   string `json:"proxyLink"`   // 代理节点链接
 	LastUpdate  string `json:"lastUpdate"`  // 上次更新时间
+	ProxySource string `json:"proxySource"` // 代理来源: tag:标签名 / group:分组名
 }
 
 // GeoIPStatusResponse GeoIP 状态响应
@@ -59,6 +60,7 @@ func GetGeoIPConfig(c *gin.Context) {
 
 	useProxy, _ := models.GetSetting("geoip_use_proxy")
 	proxyLink, _ := models.GetSetting("geoip_proxy_link")
+	proxySource, _ := models.GetSetting("geoip_proxy_source")
 	lastUpdate, _ := models.GetSetting("geoip_last_update")
 
 	c.JSON(http.StatusOK, gin.H{
@@ -68,6 +70,7 @@ func GetGeoIPConfig(c *gin.Context) {
 			UseProxy:    useProxy == "true",
 			ProxyLink:   proxyLink,
 			LastUpdate:  lastUpdate,
+			ProxySource: proxySource,
 		},
 	})
 }
@@ -78,6 +81,7 @@ func SaveGeoIPConfig(c *gin.Context) {
 		DownloadURL string `json:"downloadUrl"`
 		UseProxy    bool   `json:"useProxy"`
 		ProxyLink   string `json:"proxyLink"`
+		ProxySource string `json:"proxySource"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -105,6 +109,11 @@ func SaveGeoIPConfig(c *gin.Context) {
 		return
 	}
 
+	if err := models.SetSetting("geoip_proxy_source", req.ProxySource); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"code": 500, "msg": "保存配置失败"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "保存成功"})
 }
 
@@ -164,8 +173,7 @@ func DownloadGeoIP(c *gin.Context) {
 		downloadURL = DefaultGeoIPDownloadURL
 	}
 
-	useProxy, _ := models.GetSetting("geoip_use_proxy")
-	proxyLink, _ := models.GetSetting("geoip_proxy_link")
+	proxyOpts := geoipProxyOptions()
 
 	// 异步下载
 	go func() {
@@ -175,7 +183,7 @@ func DownloadGeoIP(c *gin.Context) {
 			downloadMu.Unlock()
 		}()
 
-		err := downloadGeoIPFile(downloadURL, useProxy == "true", proxyLink)
+		err := downloadGeoIPFile(downloadURL, proxyOpts)
 		if err != nil {
 			downloadMu.Lock()
 			downloadError = err.Error()
@@ -223,8 +231,35 @@ func StopGeoIPDownload(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "已发送停止信号"})
 }
 
+// geoipProxyOptions 读取 GeoIP 下载的代理配置
+// 代理全部失败后回退直连，与原有行为一致
+func geoipProxyOptions() utils.ProxyOptions {
+	useProxy, _ := models.GetSetting("geoip_use_proxy")
+	proxyLink, _ := models.GetSetting("geoip_proxy_link")
+	proxySource, _ := models.GetSetting("geoip_proxy_source")
+	return utils.ProxyOptions{
+		UseProxy:    useProxy == "true",
+		ProxyLink:   proxyLink,
+		ProxySource: proxySource,
+		AllowDirect: true,
+		Timeout:     5 * time.Minute,
+	}
+}
+
+// newGeoIPRequest 创建 GeoIP 下载请求
+func newGeoIPRequest(url string) func() (*http.Request, error) {
+	return func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("创建请求失败: %v", err)
+		}
+		req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; SublinkPro/1.0)")
+		return req, nil
+	}
+}
+
 // downloadGeoIPFile 下载 GeoIP 文件
-func downloadGeoIPFile(url string, useProxy bool, proxyLink string) error {
+func downloadGeoIPFile(url string, proxyOpts utils.ProxyOptions) error {
 	targetPath := config.GetGeoIPPath()
 
 	// 确保目录存在
@@ -233,21 +268,9 @@ func downloadGeoIPFile(url string, useProxy bool, proxyLink string) error {
 		return fmt.Errorf("创建目录失败: %v", err)
 	}
 
-	// 创建 HTTP 客户端
-	client, _, err := utils.CreateProxyHTTPClient(useProxy, proxyLink, 5*time.Minute)
-	if err != nil {
-		return fmt.Errorf("创建 HTTP 客户端失败: %v", err)
-	}
-
-	// 发起请求
+	// 发起请求，使用代理时依次尝试候选代理
 	utils.Info("开始下载 GeoIP 数据库: %s", url)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return fmt.Errorf("创建请求失败: %v", err)
-	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; SublinkPro/1.0)")
-
-	resp, err := client.Do(req)
+	resp, _, err := utils.DoWithProxyFallback(proxyOpts, newGeoIPRequest(url))
 	if err != nil {
 		return fmt.Errorf("下载请求失败: %v", err)
 	}
@@ -394,10 +417,7 @@ func AutoDownloadGeoIP() {
 			downloadURL = DefaultGeoIPDownloadURL
 		}
 
-		useProxy, _ := models.GetSetting("geoip_use_proxy")
-		proxyLink, _ := models.GetSetting("geoip_proxy_link")
-
-		err := downloadGeoIPFileWithProgress(downloadURL, useProxy == "true", proxyLink, true)
+		err := downloadGeoIPFileWithProgress(downloadURL, geoipProxyOptions(), true)
 		if err != nil {
 			downloadMu.Lock()
 			downloadError = err.Error()
@@ -428,7 +448,7 @@ func AutoDownloadGeoIP() {
 }
 
 // downloadGeoIPFileWithProgress 下载 GeoIP 文件（支持停止和控制台进度显示）
-func downloadGeoIPFileWithProgress(url string, useProxy bool, proxyLink string, showConsoleProgress bool) error {
+func downloadGeoIPFileWithProgress(url string, proxyOpts utils.ProxyOptions, showConsoleProgress bool) error {
 	targetPath := config.GetGeoIPPath()
 
 	// 确保目录存在
@@ -437,23 +457,11 @@ func downloadGeoIPFileWithProgress(url string, useProxy bool, proxyLink string,
 		return fmt.Errorf("创建目录失败: %v", err)
 	}
 
-	// 创建 HTTP 客户端
-	client, _, err := utils.CreateProxyHTTPClient(useProxy, proxyLink, 5*time.Minute)
-	if err != nil {
-		return fmt.Errorf("创建 HTTP 客户端失败: %v", err)
-	}
-
-	// 发起请求
+	// 发起请求，使用代理时依次尝试候选代理
 	if showConsoleProgress {
 		utils.Debug("[GeoIP] 下载地址: %s", url)
 	}
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return fmt.Errorf("创建请求失败: %v", err)
-	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; SublinkPro/1.0)")
-
-	resp, err := client.Do(req)
+	resp, _, err := utils.DoWithProxyFallback(proxyOpts, newGeoIPRequest(url))
 	if err != nil {
 		return fmt.Errorf("下载请求失败: %v", err)
 	}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"sublink/models"
 	"sublink/node/protocol"
+	"sublink/services"
 	"sublink/utils"
 	"time"
 
@@ -173,11 +174,12 @@ func NodeUpdadte(c *gin.Context) {
 	}
 
 	// 处理标签
+	oldTagNames := Node.GetTagNames()
 	tags := c.PostForm("tags")
+	var validTagNames []string
 	if tags != "" {
 		tagNames := strings.Split(tags, ",")
 		// 过滤空字符串
-		var validTagNames []string
 		for _, t := range tagNames {
 			t = strings.TrimSpace(t)
 			if t != "" {
@@ -189,6 +191,12 @@ func NodeUpdadte(c *gin.Context) {
 		// 如果 tags 参数为空，清除标签
 		_ = Node.SetTagNames([]string{})
 	}
+	// 手动添加或移除的标签不再按规则有效期到期移除，未改动的标签保留原过期时间
+	if changed := changedTagNames(oldTagNames, validTagNames); len(changed) > 0 {
+		clearManualTagExpiry([]int{Node.ID}, changed...)
+	}
+
+	go services.ApplyAutoTagRules([]models.Node{Node}, models.TagTriggerNodeEdit)
 
 	utils.OkWithMsg(c, "更新成功")
 }
@@ -207,6 +215,8 @@ func NodeGet(c *gin.Context) {
 		DelayStatus: c.Query("delayStatus"),
 		SortBy:      c.Query("sortBy"),
 		SortOrder:   c.Query("sortOrder"),
+		IPType:      c.Query("ipType"),
+		ASN:         c.Query("asn"),
 	}
 
 	// 安全解析数值参数
@@ -296,6 +306,8 @@ func NodeGetIDs(c *gin.Context) {
 		DelayStatus: c.Query("delayStatus"),
 		SortBy:      c.Query("sortBy"),
 		SortOrder:   c.Query("sortOrder"),
+		IPType:      c.Query("ipType"),
+		ASN:         c.Query("asn"),
 	}
 
 	// 安全解析数值参数
@@ -515,6 +527,8 @@ func NodeAdd(c *gin.Context) {
 		_ = Node.SetTagNames(validTagNames)
 	}
 
+	go services.ApplyAutoTagRules([]models.Node{Node}, models.TagTriggerNodeCreated)
+
 	utils.OkWithMsg(c, "添加成功")
 }
 
@@ -599,6 +613,7 @@ func NodeBatchUpdateGroup(c *gin.Context) {
 		utils.FailWithMsg(c, "批量更新分组失败")
 		return
 	}
+	go applyNodeEditTagRules(req.IDs)
 	utils.OkWithMsg(c, "批量更新分组成功")
 }
 
@@ -621,6 +636,7 @@ func NodeBatchUpdateDialerProxy(c *gin.Context) {
 		utils.FailWithMsg(c, "批量更新前置代理失败")
 		return
 	}
+	go applyNodeEditTagRules(req.IDs)
 	utils.OkWithMsg(c, "批量更新前置代理成功")
 }
 
@@ -643,9 +659,20 @@ func NodeBatchUpdateSource(c *gin.Context) {
 		utils.FailWithMsg(c, "批量更新来源失败")
 		return
 	}
+	go applyNodeEditTagRules(req.IDs)
 	utils.OkWithMsg(c, "批量更新来源成功")
 }
 
+// applyNodeEditTagRules 对批量编辑后的节点应用 node_edit 标签规则
+func applyNodeEditTagRules(ids []int) {
+	nodes, err := models.GetNodesByIDs(ids)
+	if err != nil {
+		utils.Warn("获取编辑后的节点失败: %v", err)
+		return
+	}
+	services.ApplyAutoTagRules(nodes, models.TagTriggerNodeEdit)
+}
+
 // 获取所有分组列表
 func GetGroups(c *gin.Context) {
 	var node models.Node
@@ -2,7 +2,9 @@ package api
 
 import (
 	"strconv"
+	"strings"
 	"sublink/models"
+	"sublink/services"
 	"sublink/services/scheduler"
 	"sublink/utils"
 
@@ -62,6 +64,14 @@ func CreateNodeCheckProfile(c *gin.Context) {
 		TrafficByGroup     *bool    `json:"trafficByGroup"`
 		TrafficBySource    *bool    `json:"trafficBySource"`
 		TrafficByNode      *bool    `json:"trafficByNode"`
+		Incremental        bool     `json:"incremental"`
+		IncrementalMinutes int      `json:"incrementalMinutes"`
+		NodeFilter         string   `json:"nodeFilter"`
+
+		// 礼貌限制（0=不限）
+		SourceSpeedConcurrency int `json:"sourceSpeedConcurrency"`
+		HostSpeedConcurrency   int `json:"hostSpeedConcurrency"`
+		SourceTrafficLimitMB   int `json:"sourceTrafficLimitMb"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -69,6 +79,13 @@ func CreateNodeCheckProfile(c *gin.Context) {
 		return
 	}
 
+	if req.NodeFilter != "" {
+		if _, err := models.CompileNodeExpression(req.NodeFilter); err != nil {
+			utils.FailWithMsg(c, "条件表达式错误: "+err.Error())
+			return
+		}
+	}
+
 	// 检查名称是否重复
 	if existing, _ := models.FindNodeCheckProfileByName(req.Name); existing != nil {
 		utils.FailWithMsg(c, "策略名称已存在")
@@ -114,6 +131,10 @@ func CreateNodeCheckProfile(c *gin.Context) {
 	if req.TrafficByNode != nil {
 		trafficByNode = *req.TrafficByNode
 	}
+	incrementalMinutes := req.IncrementalMinutes
+	if incrementalMinutes <= 0 {
+		incrementalMinutes = 60
+	}
 
 	profile := models.NodeCheckProfile{
 		Name:               req.Name,
@@ -133,6 +154,13 @@ func CreateNodeCheckProfile(c *gin.Context) {
 		TrafficByGroup:     trafficByGroup,
 		TrafficBySource:    trafficBySource,
 		TrafficByNode:      trafficByNode,
+		Incremental:        req.Incremental,
+		IncrementalMinutes: incrementalMinutes,
+		NodeFilter:         strings.TrimSpace(req.NodeFilter),
+
+		SourceSpeedConcurrency: max(req.SourceSpeedConcurrency, 0),
+		HostSpeedConcurrency:   max(req.HostSpeedConcurrency, 0),
+		SourceTrafficLimitMB:   max(req.SourceTrafficLimitMB, 0),
 	}
 	profile.SetGroups(req.Groups)
 	profile.SetTags(req.Tags)
@@ -183,6 +211,14 @@ func UpdateNodeCheckProfile(c *gin.Context) {
 		TrafficByGroup     *bool    `json:"trafficByGroup"`
 		TrafficBySource    *bool    `json:"trafficBySource"`
 		TrafficByNode      *bool    `json:"trafficByNode"`
+		Incremental        *bool    `json:"incremental"`
+		IncrementalMinutes int      `json:"incrementalMinutes"`
+		NodeFilter         *string  `json:"nodeFilter"` // 未传时保持原值，空字符串表示清除
+
+		// 礼貌限制（0=不限，未传时保持原值）
+		SourceSpeedConcurrency *int `json:"sourceSpeedConcurrency"`
+		HostSpeedConcurrency   *int `json:"hostSpeedConcurrency"`
+		SourceTrafficLimitMB   *int `json:"sourceTrafficLimitMb"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -190,6 +226,13 @@ func UpdateNodeCheckProfile(c *gin.Context) {
 		return
 	}
 
+	if req.NodeFilter != nil && *req.NodeFilter != "" {
+		if _, err := models.CompileNodeExpression(*req.NodeFilter); err != nil {
+			utils.FailWithMsg(c, "条件表达式错误: "+err.Error())
+			return
+		}
+	}
+
 	profile, err := models.GetNodeCheckProfileByID(id)
 	if err != nil {
 		utils.FailWithMsg(c, "策略不存在")
@@ -243,6 +286,24 @@ func UpdateNodeCheckProfile(c *gin.Context) {
 	if req.TrafficByNode != nil {
 		profile.TrafficByNode = *req.TrafficByNode
 	}
+	if req.SourceSpeedConcurrency != nil {
+		profile.SourceSpeedConcurrency = max(*req.SourceSpeedConcurrency, 0)
+	}
+	if req.HostSpeedConcurrency != nil {
+		profile.HostSpeedConcurrency = max(*req.HostSpeedConcurrency, 0)
+	}
+	if req.SourceTrafficLimitMB != nil {
+		profile.SourceTrafficLimitMB = max(*req.SourceTrafficLimitMB, 0)
+	}
+	if req.Incremental != nil {
+		profile.Incremental = *req.Incremental
+	}
+	if req.IncrementalMinutes > 0 {
+		profile.IncrementalMinutes = req.IncrementalMinutes
+	}
+	if req.NodeFilter != nil {
+		profile.NodeFilter = strings.TrimSpace(*req.NodeFilter)
+	}
 
 	if err := profile.Update(); err != nil {
 		utils.FailWithMsg(c, "更新策略失败")
@@ -334,3 +395,56 @@ func RunNodeCheckWithProfile(c *gin.Context) {
 	go scheduler.ExecuteNodeCheckWithProfile(id, nil)
 	utils.OkWithMsg(c, "节点检测任务已启动")
 }
+
+// ListInterruptedNodeChecks 获取可续测的中断检测任务
+// GET /api/v1/node-check/interrupted
+func ListInterruptedNodeChecks(c *gin.Context) {
+	running := make(map[string]bool)
+	for _, t := range services.GetTaskManager().GetRunningTasksInfo() {
+		running[t.ID] = true
+	}
+
+	list, err := models.ListInterruptedNodeChecks(running)
+	if err != nil {
+		utils.FailWithMsg(c, "获取中断任务失败")
+		return
+	}
+	utils.OkDetailed(c, "获取成功", list)
+}
+
+// ResumeNodeCheck 从断点继续执行中断的检测任务
+// POST /api/v1/node-check/interrupted/:taskId/resume
+func ResumeNodeCheck(c *gin.Context) {
+	taskID := c.Param("taskId")
+	if taskID == "" {
+		utils.FailWithMsg(c, "任务ID不能为空")
+		return
+	}
+
+	if !services.GetTaskManager().IsTaskCancelled(taskID) {
+		utils.FailWithMsg(c, "任务仍在运行中")
+		return
+	}
+
+	if err := scheduler.ResumeNodeCheckTask(taskID); err != nil {
+		utils.FailWithMsg(c, err.Error())
+		return
+	}
+	utils.OkWithMsg(c, "续测任务已启动")
+}
+
+// DiscardInterruptedNodeCheck 放弃续测，删除中断任务的断点记录
+// DELETE /api/v1/node-check/interrupted/:taskId
+func DiscardInterruptedNodeCheck(c *gin.Context) {
+	taskID := c.Param("taskId")
+	if taskID == "" {
+		utils.FailWithMsg(c, "任务ID不能为空")
+		return
+	}
+
+	if err := models.DeleteNodeCheckCheckpoints(taskID); err != nil {
+		utils.FailWithMsg(c, "删除断点记录失败")
+		return
+	}
+	utils.OkWithMsg(c, "删除成功")
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"strconv"
+	"sublink/models"
+	"sublink/services/scheduler"
+	"sublink/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetNodeLifecyclePolicy 获取节点生命周期策略
+// GET /api/v1/node-check/lifecycle/policy
+func GetNodeLifecyclePolicy(c *gin.Context) {
+	utils.OkDetailed(c, "获取成功", models.GetNodeLifecyclePolicy())
+}
+
+// UpdateNodeLifecyclePolicy 更新节点生命周期策略
+// PUT /api/v1/node-check/lifecycle/policy
+func UpdateNodeLifecyclePolicy(c *gin.Context) {
+	var req models.NodeLifecyclePolicy
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.FailWithMsg(c, "参数错误")
+		return
+	}
+
+	if req.FailThreshold <= 0 {
+		req.FailThreshold = models.DefaultNodeFailThreshold
+	}
+	if req.RecheckCron == "" {
+		req.RecheckCron = models.DefaultNodeRecheckCron
+	}
+	if !validateCron(req.RecheckCron) {
+		utils.FailWithMsg(c, "Cron表达式格式错误")
+		return
+	}
+	if req.PurgeDays < 0 {
+		req.PurgeDays = 0
+	}
+	if req.PurgeAction != models.NodePurgeActionDelete {
+		req.PurgeAction = models.NodePurgeActionFlag
+	}
+	if req.RecheckProfileID > 0 {
+		if _, err := models.GetNodeCheckProfileByID(req.RecheckProfileID); err != nil {
+			utils.FailWithMsg(c, "复测使用的检测策略不存在")
+			return
+		}
+	}
+
+	if err := models.SaveNodeLifecyclePolicy(req); err != nil {
+		utils.FailWithMsg(c, "保存失败")
+		return
+	}
+
+	// 按新策略重建复测定时任务
+	if err := scheduler.GetSchedulerManager().StartNodeLifecycleTask(); err != nil {
+		utils.FailWithMsg(c, "策略已保存，但定时任务更新失败: "+err.Error())
+		return
+	}
+	utils.OkWithMsg(c, "保存成功")
+}
+
+// ListLifecycleNodes 获取隔离或标记状态的节点
+// GET /api/v1/node-check/lifecycle/nodes
+func ListLifecycleNodes(c *gin.Context) {
+	utils.OkDetailed(c, "获取成功", models.ListQuarantinedNodes())
+}
+
+// ListNodeLifecycleEvents 获取节点生命周期变更记录
+// GET /api/v1/node-check/lifecycle/events?nodeId=&page=&pageSize=
+func ListNodeLifecycleEvents(c *gin.Context) {
+	nodeID, _ := strconv.Atoi(c.Query("nodeId"))
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	events, total, err := models.ListNodeLifecycleEvents(nodeID, page, pageSize)
+	if err != nil {
+		utils.FailWithMsg(c, "获取变更记录失败")
+		return
+	}
+	utils.OkDetailed(c, "获取成功", gin.H{
+		"items":    events,
+		"total":    total,
+		"page":     page,
+		"pageSize": pageSize,
+	})
+}
+
+// ReviveNodes 手动恢复隔离或标记状态的节点
+// POST /api/v1/node-check/lifecycle/revive
+func ReviveNodes(c *gin.Context) {
+	changeNodesLifecycleState(c, models.NodeStateActive, "手动恢复")
+}
+
+// QuarantineNodes 手动隔离节点
+// POST /api/v1/node-check/lifecycle/quarantine
+func QuarantineNodes(c *gin.Context) {
+	changeNodesLifecycleState(c, models.NodeStateQuarantined, "手动隔离")
+}
+
+// changeNodesLifecycleState 批量变更节点生命周期状态
+func changeNodesLifecycleState(c *gin.Context, state string, reason string) {
+	var req struct {
+		NodeIDs []int `json:"nodeIds"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.NodeIDs) == 0 {
+		utils.FailWithMsg(c, "请选择节点")
+		return
+	}
+
+	changed := scheduler.ChangeNodesLifecycleState(req.NodeIDs, state, reason)
+	utils.OkDetailed(c, "操作成功", gin.H{"changed": changed})
+}
+
+// RunNodeLifecycleTask 立即执行一次隔离节点超期处理与复测
+// POST /api/v1/node-check/lifecycle/run
+func RunNodeLifecycleTask(c *gin.Context) {
+	if !models.GetNodeLifecyclePolicy().Enabled {
+		utils.FailWithMsg(c, "节点生命周期策略未启用")
+		return
+	}
+	go scheduler.ExecuteNodeLifecycleTask()
+	utils.OkWithMsg(c, "复测任务已启动")
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 	"sublink/database"
 	"sublink/models"
 	"sublink/utils"
@@ -19,6 +20,8 @@ type PreviewRequest struct {
 	MinSpeed           float64  `json:"MinSpeed"`           // 最小速度过滤
 	CountryWhitelist   string   `json:"CountryWhitelist"`   // 国家白名单
 	CountryBlacklist   string   `json:"CountryBlacklist"`   // 国家黑名单
+	ASNWhitelist       string   `json:"ASNWhitelist"`       // 落地ASN白名单
+	ASNBlacklist       string   `json:"ASNBlacklist"`       // 落地ASN黑名单
 	TagWhitelist       string   `json:"TagWhitelist"`       // 标签白名单
 	TagBlacklist       string   `json:"TagBlacklist"`       // 标签黑名单
 	ProtocolWhitelist  string   `json:"ProtocolWhitelist"`  // 协议白名单（逗号分隔）
@@ -28,6 +31,7 @@ type PreviewRequest struct {
 	NodeNamePreprocess string   `json:"NodeNamePreprocess"` // 原名预处理规则
 	NodeNameRule       string   `json:"NodeNameRule"`       // 节点命名规则模板
 	DeduplicationRule  string   `json:"DeduplicationRule"`  // 去重规则配置
+	TagExpression      string   `json:"TagExpression"`      // 按标签动态选择节点的条件表达式
 }
 
 // PreviewSubscriptionNodes 预览订阅节点
@@ -42,11 +46,11 @@ func PreviewSubscriptionNodes(c *gin.Context) {
 		return
 	}
 
-	// 验证至少选择了节点或分组
-	if len(req.Nodes) == 0 && len(req.Groups) == 0 {
+	// 验证至少选择了节点、分组或标签表达式
+	if len(req.Nodes) == 0 && len(req.Groups) == 0 && strings.TrimSpace(req.TagExpression) == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code": 400,
-			"msg":  "请至少选择节点或分组",
+			"msg":  "请至少选择节点、分组或设置标签表达式",
 		})
 		return
 	}
@@ -57,6 +61,8 @@ func PreviewSubscriptionNodes(c *gin.Context) {
 		MinSpeed:           req.MinSpeed,
 		CountryWhitelist:   req.CountryWhitelist,
 		CountryBlacklist:   req.CountryBlacklist,
+		ASNWhitelist:       req.ASNWhitelist,
+		ASNBlacklist:       req.ASNBlacklist,
 		TagWhitelist:       req.TagWhitelist,
 		TagBlacklist:       req.TagBlacklist,
 		ProtocolWhitelist:  req.ProtocolWhitelist,
@@ -72,8 +78,28 @@ func PreviewSubscriptionNodes(c *gin.Context) {
 	var allNodes []models.Node
 	totalCount := 0
 
+	// 设置了标签表达式时由表达式定义节点集合，手动选择的节点与分组不参与
+	tagExpression := strings.TrimSpace(req.TagExpression)
+	if tagExpression != "" {
+		exprNodes, err := models.MatchNodesByTagExpression(tagExpression)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": 400,
+				"msg":  err.Error(),
+			})
+			return
+		}
+		nodeMap := make(map[string]bool)
+		for _, n := range exprNodes {
+			if !nodeMap[n.Name] {
+				allNodes = append(allNodes, n)
+				nodeMap[n.Name] = true
+			}
+		}
+	}
+
 	// 从名称获取节点
-	if len(req.Nodes) > 0 {
+	if tagExpression == "" && len(req.Nodes) > 0 {
 		for _, nodeName := range req.Nodes {
 			if node, ok := models.GetNodeByName(nodeName); ok {
 				allNodes = append(allNodes, *node)
@@ -82,7 +108,7 @@ func PreviewSubscriptionNodes(c *gin.Context) {
 	}
 
 	// 从分组获取节点
-	if len(req.Groups) > 0 {
+	if tagExpression == "" && len(req.Groups) > 0 {
 		for _, groupName := range req.Groups {
 			var groupNodes []models.Node
 			node := &models.Node{}
@@ -119,7 +145,7 @@ func PreviewSubscriptionNodes(c *gin.Context) {
 			}
 
 			// 执行 filterNode 脚本（使用 Content 字段）
-			resultJSON, err := utils.RunNodeFilterScript(script.Content, nodesJSON, "preview")
+			resultJSON, err := utils.RunNodeFilterScript(script.Source(), nodesJSON, "preview")
 			if err != nil {
 				// 脚本执行失败，继续使用原始节点
 				continue
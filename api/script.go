@@ -1,8 +1,12 @@
 package api
 
 import (
+	"encoding/json"
 	"strconv"
+	"strings"
+	"sublink/dto"
 	"sublink/models"
+	"sublink/services/scheduler"
 	"sublink/utils"
 
 	"github.com/gin-gonic/gin"
@@ -22,6 +26,10 @@ func ScriptAdd(c *gin.Context) {
 	if data.Version == "" {
 		data.Version = "0.0.0"
 	}
+	if msg := applyScriptHooks(&data); msg != "" {
+		utils.FailWithMsg(c, msg)
+		return
+	}
 
 	if data.CheckNameVersion() {
 		utils.FailWithMsg(c, "该名称和版本的脚本已存在")
@@ -32,9 +40,34 @@ func ScriptAdd(c *gin.Context) {
 		utils.FailWithMsg(c, err.Error())
 		return
 	}
+	if err := scheduler.GetSchedulerManager().UpdateScriptHookJob(&data); err != nil {
+		utils.Warn("添加脚本定时任务失败: %v", err)
+	}
 	utils.OkDetailed(c, "添加成功", data)
 }
 
+// applyScriptHooks 校验脚本头部信息块，并规范化钩子配置，返回错误信息（为空表示通过）
+func applyScriptHooks(data *models.Script) string {
+	if _, err := models.ParseScriptHeader(data.Content); err != nil {
+		return "脚本头部信息块有误: " + err.Error()
+	}
+	hooks, err := models.NormalizeScriptHooks(data.Hooks)
+	if err != nil {
+		return err.Error()
+	}
+	data.Hooks = hooks
+	data.CronExpr = strings.TrimSpace(data.CronExpr)
+	if data.HasHook(models.ScriptHookCron) {
+		if data.CronExpr == "" {
+			return "启用 cron 钩子时必须填写 Cron 表达式"
+		}
+		if !validateCron(data.CronExpr) {
+			return "Cron表达式格式错误"
+		}
+	}
+	return ""
+}
+
 // ScriptDel 删除脚本
 func ScriptDel(c *gin.Context) {
 	var data models.Script
@@ -46,6 +79,7 @@ func ScriptDel(c *gin.Context) {
 		utils.FailWithMsg(c, err.Error())
 		return
 	}
+	scheduler.GetSchedulerManager().RemoveScriptHookJob(data.ID)
 	utils.OkWithMsg(c, "删除成功")
 }
 
@@ -56,6 +90,17 @@ func ScriptUpdate(c *gin.Context) {
 		utils.FailWithMsg(c, err.Error())
 		return
 	}
+	if data.Name == "" || data.Content == "" {
+		utils.FailWithMsg(c, "名称和内容不能为空")
+		return
+	}
+	if data.Version == "" {
+		data.Version = "0.0.0"
+	}
+	if msg := applyScriptHooks(&data); msg != "" {
+		utils.FailWithMsg(c, msg)
+		return
+	}
 	if data.CheckNameVersion() {
 		utils.FailWithMsg(c, "该名称和版本的脚本已存在")
 		return
@@ -64,6 +109,9 @@ func ScriptUpdate(c *gin.Context) {
 		utils.FailWithMsg(c, err.Error())
 		return
 	}
+	if err := scheduler.GetSchedulerManager().UpdateScriptHookJob(&data); err != nil {
+		utils.Warn("更新脚本定时任务失败: %v", err)
+	}
 	utils.OkDetailed(c, "更新成功", data)
 }
 
@@ -114,3 +162,253 @@ func ScriptList(c *gin.Context) {
 	}
 	utils.OkDetailed(c, "获取成功", list)
 }
+
+// ScriptSettingsGet 获取脚本运行限制设置
+func ScriptSettingsGet(c *gin.Context) {
+	limits := models.GetScriptLimits()
+	utils.OkDetailed(c, "获取成功", gin.H{
+		"timeout":        int(limits.Timeout.Seconds()),
+		"maxMemoryMB":    limits.MaxMemory >> 20,
+		"maxOutputMB":    limits.MaxOutput >> 20,
+		"fetchAllowlist": strings.Join(limits.FetchAllowlist, "\n"),
+	})
+}
+
+// ScriptSettingsUpdate 更新脚本运行限制设置
+func ScriptSettingsUpdate(c *gin.Context) {
+	var req dto.ScriptSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.FailWithMsg(c, "参数错误: "+err.Error())
+		return
+	}
+	if req.Timeout < 1 || req.Timeout > 300 {
+		utils.FailWithMsg(c, "执行超时应为 1-300 秒")
+		return
+	}
+	if req.MaxMemoryMB < 0 || req.MaxOutputMB < 0 {
+		utils.FailWithMsg(c, "内存与输出上限不能为负数")
+		return
+	}
+	settings := map[string]string{
+		models.SettingScriptTimeout:        strconv.Itoa(req.Timeout),
+		models.SettingScriptMaxMemory:      strconv.Itoa(req.MaxMemoryMB),
+		models.SettingScriptMaxOutput:      strconv.Itoa(req.MaxOutputMB),
+		models.SettingScriptFetchAllowlist: strings.Join(models.ParseScriptFetchAllowlist(req.FetchAllowlist), "\n"),
+	}
+	for key, value := range settings {
+		if err := models.SetSetting(key, value); err != nil {
+			utils.FailWithMsg(c, "保存设置失败: "+err.Error())
+			return
+		}
+	}
+	utils.OkWithMsg(c, "保存成功")
+}
+
+// ScriptKVList 获取脚本的 KV 存储内容
+func ScriptKVList(c *gin.Context) {
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil || id <= 0 {
+		utils.FailWithMsg(c, "参数错误")
+		return
+	}
+	entries, err := models.ListScriptKV(models.ScriptKVNamespace(id))
+	if err != nil {
+		utils.FailWithMsg(c, err.Error())
+		return
+	}
+	utils.OkDetailed(c, "获取成功", entries)
+}
+
+// ScriptKVClear 清空脚本的 KV 存储
+func ScriptKVClear(c *gin.Context) {
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil || id <= 0 {
+		utils.FailWithMsg(c, "参数错误")
+		return
+	}
+	if err := models.DeleteScriptKV(models.ScriptKVNamespace(id)); err != nil {
+		utils.FailWithMsg(c, err.Error())
+		return
+	}
+	utils.OkWithMsg(c, "已清空")
+}
+
+// ScriptTest 试运行脚本
+// 使用指定订阅过滤后的节点（或提供的节点样本）执行脚本，返回输出、节点差异、日志与耗时
+// 试运行不会写入 KV 存储
+func ScriptTest(c *gin.Context) {
+	var req dto.ScriptTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.FailWithMsg(c, "参数错误: "+err.Error())
+		return
+	}
+	if req.ClientType == "" {
+		req.ClientType = "clash"
+	}
+	switch req.ClientType {
+	case "clash", "surge", "v2ray":
+	default:
+		utils.FailWithMsg(c, "不支持的客户端类型: "+req.ClientType)
+		return
+	}
+
+	var src utils.ScriptSource
+	if req.ScriptID > 0 {
+		script, err := models.GetScriptByID(req.ScriptID)
+		if err != nil {
+			utils.FailWithMsg(c, "脚本不存在")
+			return
+		}
+		src = script.Source()
+		if req.Content != "" {
+			// 未保存的内容按内联脚本编译，不影响已缓存的编译结果，KV 仍读取该脚本的命名空间
+			src = utils.InlineScript(req.Content, src.Namespace)
+		}
+	} else if req.Content != "" {
+		src = utils.InlineScript(req.Content, "")
+	} else {
+		utils.FailWithMsg(c, "请选择脚本或提供脚本内容")
+		return
+	}
+
+	var nodes []models.Node
+	switch {
+	case len(req.Nodes) > 0 && string(req.Nodes) != "null":
+		if err := json.Unmarshal(req.Nodes, &nodes); err != nil {
+			utils.FailWithMsg(c, "节点样本格式错误: "+err.Error())
+			return
+		}
+	case req.SubscriptionID > 0:
+		sub := models.Subcription{ID: req.SubscriptionID}
+		if err := sub.Find(); err != nil {
+			utils.FailWithMsg(c, "订阅不存在")
+			return
+		}
+		if err := sub.LoadFilteredNodes(); err != nil {
+			utils.FailWithMsg(c, "读取订阅节点失败: "+err.Error())
+			return
+		}
+		nodes = sub.Nodes
+	default:
+		utils.FailWithMsg(c, "请选择订阅或提供节点样本")
+		return
+	}
+	if nodes == nil {
+		nodes = []models.Node{}
+	}
+
+	utils.OkDetailed(c, "执行完成", models.DryRunScript(src, nodes, req.ClientType, req.Input))
+}
+
+// ScriptHookRun 立即执行脚本的 cron 钩子 onCron()，执行记录见任务列表
+func ScriptHookRun(c *gin.Context) {
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil || id <= 0 {
+		utils.FailWithMsg(c, "参数错误")
+		return
+	}
+	if _, err := models.GetScriptByID(id); err != nil {
+		utils.FailWithMsg(c, "脚本不存在")
+		return
+	}
+	go scheduler.ExecuteScriptCronHook(id, models.TaskTriggerManual)
+	utils.OkWithMsg(c, "已开始执行")
+}
+
+// scriptFromQuery 根据查询参数 id 获取脚本
+func scriptFromQuery(c *gin.Context) (*models.Script, bool) {
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil || id <= 0 {
+		utils.FailWithMsg(c, "参数错误")
+		return nil, false
+	}
+	script, err := models.GetScriptByID(id)
+	if err != nil {
+		utils.FailWithMsg(c, "脚本不存在")
+		return nil, false
+	}
+	return script, true
+}
+
+// ScriptInstall 从地址安装脚本
+func ScriptInstall(c *gin.Context) {
+	var req dto.ScriptInstallRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.FailWithMsg(c, "参数错误: "+err.Error())
+		return
+	}
+	script, err := models.InstallScriptFromURL(req.URL, req.UseProxy)
+	if err != nil {
+		utils.FailWithMsg(c, err.Error())
+		return
+	}
+	if err := scheduler.GetSchedulerManager().UpdateScriptHookJob(script); err != nil {
+		utils.Warn("添加脚本定时任务失败: %v", err)
+	}
+	utils.OkDetailed(c, "安装成功", script)
+}
+
+// ScriptCheckUpdate 检查脚本来源是否有新版本
+func ScriptCheckUpdate(c *gin.Context) {
+	script, ok := scriptFromQuery(c)
+	if !ok {
+		return
+	}
+	available, err := script.CheckSourceUpdate()
+	if err != nil {
+		utils.FailWithMsg(c, err.Error())
+		return
+	}
+	utils.OkDetailed(c, "检查完成", gin.H{
+		"available":      available,
+		"version":        script.Version,
+		"pendingVersion": script.PendingVersion,
+	})
+}
+
+// ScriptUpdateDiff 获取当前版本与待升级版本的差异
+func ScriptUpdateDiff(c *gin.Context) {
+	script, ok := scriptFromQuery(c)
+	if !ok {
+		return
+	}
+	if script.PendingContent == "" {
+		utils.FailWithMsg(c, "没有可升级的版本")
+		return
+	}
+	utils.OkDetailed(c, "获取成功", gin.H{
+		"version":        script.Version,
+		"pendingVersion": script.PendingVersion,
+		"diff":           utils.DiffLines(script.Content, script.PendingContent),
+	})
+}
+
+// ScriptUpgrade 升级到待升级的版本
+func ScriptUpgrade(c *gin.Context) {
+	script, ok := scriptFromQuery(c)
+	if !ok {
+		return
+	}
+	if err := script.ApplySourceUpdate(); err != nil {
+		utils.FailWithMsg(c, err.Error())
+		return
+	}
+	if err := scheduler.GetSchedulerManager().UpdateScriptHookJob(script); err != nil {
+		utils.Warn("更新脚本定时任务失败: %v", err)
+	}
+	utils.OkDetailed(c, "升级成功", script)
+}
+
+// ScriptVersions 获取脚本的历史版本
+func ScriptVersions(c *gin.Context) {
+	script, ok := scriptFromQuery(c)
+	if !ok {
+		return
+	}
+	versions, err := models.ListScriptVersions(script.ID)
+	if err != nil {
+		utils.FailWithMsg(c, err.Error())
+		return
+	}
+	utils.OkDetailed(c, "获取成功", versions)
+}
@@ -169,3 +169,49 @@ func UpdateBaseTemplate(c *gin.Context) {
 	}
 	utils.OkWithMsg(c, categoryName+" 基础模板保存成功")
 }
+
+// GetIPInfoProviders 获取IP信息提供商配置（顺序即回退顺序）
+// GET /api/v1/settings/ip-providers
+func GetIPInfoProviders(c *gin.Context) {
+	utils.OkDetailed(c, "获取成功", models.GetIPInfoProviderConfigs())
+}
+
+// UpdateIPInfoProviders 更新IP信息提供商配置
+// POST /api/v1/settings/ip-providers
+func UpdateIPInfoProviders(c *gin.Context) {
+	var req []models.IPInfoProviderConfig
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.FailWithMsg(c, "参数错误")
+		return
+	}
+	if err := models.SaveIPInfoProviderConfigs(req); err != nil {
+		utils.FailWithMsg(c, "保存失败: "+err.Error())
+		return
+	}
+	utils.OkWithMsg(c, "保存成功")
+}
+
+// TestIPInfoProvider 使用指定的提供商配置查询IP（不使用缓存）
+// POST /api/v1/settings/ip-providers/test
+func TestIPInfoProvider(c *gin.Context) {
+	var req struct {
+		IP       string                      `json:"ip"`
+		Provider models.IPInfoProviderConfig `json:"provider"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.IP == "" {
+		utils.FailWithMsg(c, "参数错误")
+		return
+	}
+
+	info, err := models.TestIPInfoProvider(req.Provider, req.IP)
+	if err != nil {
+		utils.FailWithMsg(c, "查询失败: "+err.Error())
+		return
+	}
+	utils.OkDetailed(c, "查询成功", gin.H{
+		"info":   info,
+		"asn":    info.ASNumber(),
+		"raw":    info.RawResponse,
+		"ipType": info.IPType,
+	})
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/json"
 	"strconv"
 	"strings"
 	"sublink/dto"
@@ -87,6 +88,8 @@ func SubAdd(c *gin.Context) {
 	minSpeed, _ := strconv.ParseFloat(minSpeedStr, 64)
 	countryWhitelist := c.PostForm("CountryWhitelist")
 	countryBlacklist := c.PostForm("CountryBlacklist")
+	asnWhitelist := c.PostForm("ASNWhitelist")
+	asnBlacklist := c.PostForm("ASNBlacklist")
 	nodeNameRule := c.PostForm("NodeNameRule")
 	nodeNamePreprocess := c.PostForm("NodeNamePreprocess")
 	nodeNameWhitelist := c.PostForm("NodeNameWhitelist")
@@ -98,11 +101,18 @@ func SubAdd(c *gin.Context) {
 	deduplicationRule := c.PostForm("DeduplicationRule")
 	refreshUsageOnRequestStr := c.PostForm("RefreshUsageOnRequest")
 	refreshUsageOnRequest := refreshUsageOnRequestStr != "false" // 默认为 true
+	tagExpression := strings.TrimSpace(c.PostForm("TagExpression"))
 
-	if name == "" || (nodeIds == "" && groups == "") {
-		utils.FailWithMsg(c, "订阅名称不能为空，且节点或分组至少选择一项")
+	if name == "" || (nodeIds == "" && groups == "" && tagExpression == "") {
+		utils.FailWithMsg(c, "订阅名称不能为空，且节点、分组或标签表达式至少设置一项")
 		return
 	}
+	if tagExpression != "" {
+		if _, err := models.CompileNodeExpression(tagExpression); err != nil {
+			utils.FailWithMsg(c, "标签表达式错误: "+err.Error())
+			return
+		}
+	}
 	if ipWhitelist != "" {
 		ok := utils.IpFormatValidation(ipWhitelist)
 		if !ok {
@@ -160,6 +170,8 @@ func SubAdd(c *gin.Context) {
 	sub.MinSpeed = minSpeed
 	sub.CountryWhitelist = countryWhitelist
 	sub.CountryBlacklist = countryBlacklist
+	sub.ASNWhitelist = asnWhitelist
+	sub.ASNBlacklist = asnBlacklist
 	sub.NodeNameRule = nodeNameRule
 	sub.NodeNamePreprocess = nodeNamePreprocess
 	sub.NodeNameWhitelist = nodeNameWhitelist
@@ -170,6 +182,7 @@ func SubAdd(c *gin.Context) {
 	sub.ProtocolBlacklist = protocolBlacklist
 	sub.DeduplicationRule = deduplicationRule
 	sub.RefreshUsageOnRequest = refreshUsageOnRequest
+	sub.TagExpression = tagExpression
 	sub.CreateDate = time.Now().Format("2006-01-02 15:04:05")
 
 	err := sub.Add()
@@ -234,6 +247,8 @@ func SubUpdate(c *gin.Context) {
 	minSpeed, _ := strconv.ParseFloat(minSpeedStr, 64)
 	countryWhitelist := c.PostForm("CountryWhitelist")
 	countryBlacklist := c.PostForm("CountryBlacklist")
+	asnWhitelist := c.PostForm("ASNWhitelist")
+	asnBlacklist := c.PostForm("ASNBlacklist")
 	nodeNameRule := c.PostForm("NodeNameRule")
 	nodeNamePreprocess := c.PostForm("NodeNamePreprocess")
 	nodeNameWhitelist := c.PostForm("NodeNameWhitelist")
@@ -245,11 +260,18 @@ func SubUpdate(c *gin.Context) {
 	deduplicationRule := c.PostForm("DeduplicationRule")
 	refreshUsageOnRequestStr := c.PostForm("RefreshUsageOnRequest")
 	refreshUsageOnRequest := refreshUsageOnRequestStr != "false" // 默认为 true
+	tagExpression := strings.TrimSpace(c.PostForm("TagExpression"))
 
-	if name == "" || (nodeIds == "" && groups == "") {
-		utils.FailWithMsg(c, "订阅名称不能为空，且节点或分组至少选择一项")
+	if name == "" || (nodeIds == "" && groups == "" && tagExpression == "") {
+		utils.FailWithMsg(c, "订阅名称不能为空，且节点、分组或标签表达式至少设置一项")
 		return
 	}
+	if tagExpression != "" {
+		if _, err := models.CompileNodeExpression(tagExpression); err != nil {
+			utils.FailWithMsg(c, "标签表达式错误: "+err.Error())
+			return
+		}
+	}
 	if ipWhitelist != "" {
 		ok := utils.IpFormatValidation(ipWhitelist)
 		if !ok {
@@ -317,6 +339,8 @@ func SubUpdate(c *gin.Context) {
 	sub.MinSpeed = minSpeed
 	sub.CountryWhitelist = countryWhitelist
 	sub.CountryBlacklist = countryBlacklist
+	sub.ASNWhitelist = asnWhitelist
+	sub.ASNBlacklist = asnBlacklist
 	sub.NodeNameRule = nodeNameRule
 	sub.NodeNamePreprocess = nodeNamePreprocess
 	sub.NodeNameWhitelist = nodeNameWhitelist
@@ -327,6 +351,7 @@ func SubUpdate(c *gin.Context) {
 	sub.ProtocolBlacklist = protocolBlacklist
 	sub.DeduplicationRule = deduplicationRule
 	sub.RefreshUsageOnRequest = refreshUsageOnRequest
+	sub.TagExpression = tagExpression
 	err = sub.Update()
 	if err != nil {
 		utils.FailWithMsg(c, "更新失败")
@@ -494,3 +519,56 @@ func GetNodeFieldsMeta(c *gin.Context) {
 	meta := models.GetNodeFieldsMeta()
 	utils.OkDetailed(c, "获取成功", meta)
 }
+
+// SubScriptSettings 设置订阅中脚本的固定版本与参数值
+func SubScriptSettings(c *gin.Context) {
+	subID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.FailWithMsg(c, "无效的订阅ID")
+		return
+	}
+	scriptID, err := strconv.Atoi(c.Param("scriptId"))
+	if err != nil {
+		utils.FailWithMsg(c, "无效的脚本ID")
+		return
+	}
+	var req dto.SubScriptSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.FailWithMsg(c, "参数错误: "+err.Error())
+		return
+	}
+
+	sub := models.Subcription{ID: subID}
+	if err := sub.Find(); err != nil {
+		utils.FailWithMsg(c, "订阅不存在")
+		return
+	}
+
+	paramValues := ""
+	if len(req.ParamValues) > 0 {
+		if script, err := models.GetScriptByID(scriptID); err == nil {
+			declared := make(map[string]bool)
+			for _, p := range script.ParamList() {
+				declared[p.Name] = true
+			}
+			for name := range req.ParamValues {
+				if len(declared) > 0 && !declared[name] {
+					utils.FailWithMsg(c, "脚本未声明参数: "+name)
+					return
+				}
+			}
+		}
+		data, err := json.Marshal(req.ParamValues)
+		if err != nil {
+			utils.FailWithMsg(c, err.Error())
+			return
+		}
+		paramValues = string(data)
+	}
+
+	if err := sub.UpdateScriptSettings(scriptID, strings.TrimSpace(req.PinVersion), paramValues); err != nil {
+		utils.FailWithMsg(c, err.Error())
+		return
+	}
+	utils.OkWithMsg(c, "保存成功")
+}
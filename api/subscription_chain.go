@@ -10,7 +10,10 @@ import (
 	"strings"
 	"sublink/cache"
 	"sublink/models"
+	"sublink/node/protocol"
+	"sublink/services/mihomo"
 	"sublink/utils"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gopkg.in/yaml.v3"
@@ -52,6 +55,10 @@ func CreateChainRule(c *gin.Context) {
 	}
 
 	rule.SubscriptionID = subID
+	if err := rule.ValidateConditions(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// 设置默认排序值（最后一个）
 	existingRules := models.GetChainRulesBySubscriptionID(subID)
@@ -62,7 +69,7 @@ func CreateChainRule(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": rule})
+	c.JSON(http.StatusOK, gin.H{"data": rule, "validation": chainValidationForSubscription(subID)})
 }
 
 // UpdateChainRule 更新链式代理规则
@@ -106,6 +113,10 @@ func UpdateChainRule(c *gin.Context) {
 	existingRule.Enabled = updateData.Enabled
 	existingRule.ChainConfig = updateData.ChainConfig
 	existingRule.TargetConfig = updateData.TargetConfig
+	if err := existingRule.ValidateConditions(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// 调试日志：记录更新的数据
 	utils.Debug("[ChainRule] 更新规则 ID=%d, 名称=%s", existingRule.ID, existingRule.Name)
@@ -118,7 +129,7 @@ func UpdateChainRule(c *gin.Context) {
 	}
 
 	utils.Debug("[ChainRule] 规则更新成功，返回数据: ID=%d", existingRule.ID)
-	c.JSON(http.StatusOK, gin.H{"data": existingRule})
+	c.JSON(http.StatusOK, gin.H{"data": existingRule, "validation": chainValidationForSubscription(subID)})
 }
 
 // DeleteChainRule 删除链式代理规则
@@ -238,6 +249,10 @@ func GetChainOptions(c *gin.Context) {
 		{"value": "name", "label": "节点名称"},
 		{"value": "link_name", "label": "原始名称"},
 		{"value": "link_country", "label": "国家/地区"},
+		{"value": "landing_asn", "label": "落地ASN"},
+		{"value": "landing_isp", "label": "落地运营商"},
+		{"value": "landing_city", "label": "落地城市"},
+		{"value": "landing_ip_type", "label": "落地IP类型"},
 		{"value": "protocol", "label": "协议类型"},
 		{"value": "group", "label": "分组"},
 		{"value": "source", "label": "来源"},
@@ -321,15 +336,29 @@ func ToggleChainRule(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": existingRule})
+	c.JSON(http.StatusOK, gin.H{"data": existingRule, "validation": chainValidationForSubscription(subID)})
 }
 
 // parseTemplateProxyGroups 从订阅配置中解析模板代理组列表
 // configStr: 订阅的 Config 字段（JSON 格式）
 // 返回: 代理组名称列表
 func parseTemplateProxyGroups(configStr string) []string {
+	groups := parseTemplateProxyGroupDetails(configStr)
+	groupNames := make([]string, 0, len(groups))
+	for _, g := range groups {
+		groupNames = append(groupNames, g.Name)
+	}
+	return groupNames
+}
+
+// chainTemplateFetchTimeout 读取远程 Clash 模板的超时时间
+const chainTemplateFetchTimeout = 10 * time.Second
+
+// parseTemplateProxyGroupDetails 从订阅配置中解析 Clash 模板的代理组（含类型与成员）
+// 成员为空或设置了 include-all 的组在输出时会包含订阅的全部节点
+func parseTemplateProxyGroupDetails(configStr string) []models.ChainTemplateGroup {
 	if configStr == "" {
-		return []string{}
+		return []models.ChainTemplateGroup{}
 	}
 
 	// 解析订阅配置 JSON
@@ -338,27 +367,28 @@ func parseTemplateProxyGroups(configStr string) []string {
 		Surge string `json:"surge"`
 	}
 	if err := json.Unmarshal([]byte(configStr), &config); err != nil {
-		return []string{}
+		return []models.ChainTemplateGroup{}
 	}
 
 	// 获取 Clash 模板路径
 	clashTemplate := config.Clash
 	if clashTemplate == "" {
-		return []string{}
+		return []models.ChainTemplateGroup{}
 	}
 
 	// 读取模板内容
 	var templateContent string
 	if strings.Contains(clashTemplate, "://") {
 		// 远程模板，通过 HTTP 获取
-		resp, err := http.Get(clashTemplate)
+		client := &http.Client{Timeout: chainTemplateFetchTimeout}
+		resp, err := client.Get(clashTemplate)
 		if err != nil {
-			return []string{}
+			return []models.ChainTemplateGroup{}
 		}
 		defer resp.Body.Close()
 		data, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return []string{}
+			return []models.ChainTemplateGroup{}
 		}
 		templateContent = string(data)
 	} else {
@@ -369,7 +399,7 @@ func parseTemplateProxyGroups(configStr string) []string {
 		} else {
 			data, err := os.ReadFile(clashTemplate)
 			if err != nil {
-				return []string{}
+				return []models.ChainTemplateGroup{}
 			}
 			templateContent = string(data)
 		}
@@ -378,25 +408,144 @@ func parseTemplateProxyGroups(configStr string) []string {
 	// 解析 YAML 获取代理组列表
 	var clashConfig map[string]interface{}
 	if err := yaml.Unmarshal([]byte(templateContent), &clashConfig); err != nil {
-		return []string{}
+		return []models.ChainTemplateGroup{}
 	}
 
-	// 提取 proxy-groups 中的 name 字段
+	// 提取 proxy-groups 中的名称、类型与成员
 	proxyGroups, ok := clashConfig["proxy-groups"].([]interface{})
 	if !ok {
-		return []string{}
+		return []models.ChainTemplateGroup{}
 	}
 
-	var groupNames []string
+	var groups []models.ChainTemplateGroup
 	for _, pg := range proxyGroups {
-		if group, ok := pg.(map[string]interface{}); ok {
-			if name, ok := group["name"].(string); ok && name != "" {
-				groupNames = append(groupNames, name)
+		group, ok := pg.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, ok := group["name"].(string)
+		if !ok || name == "" {
+			continue
+		}
+		tg := models.ChainTemplateGroup{Name: name}
+		tg.Type, _ = group["type"].(string)
+		if proxies, ok := group["proxies"].([]interface{}); ok {
+			for _, p := range proxies {
+				if proxyName, ok := p.(string); ok && proxyName != "" {
+					tg.Proxies = append(tg.Proxies, proxyName)
+				}
 			}
 		}
+		includeAll, _ := group["include-all"].(bool)
+		tg.IncludeAll = includeAll || (len(tg.Proxies) == 0 && tg.Type != "relay")
+		groups = append(groups, tg)
 	}
 
-	return groupNames
+	return groups
+}
+
+// loadChainSubscription 加载订阅及其输出节点（已应用过滤条件，不执行节点过滤脚本），用于链路校验与拨号测试
+func loadChainSubscription(subID int) (*models.Subcription, error) {
+	var sub models.Subcription
+	sub.ID = subID
+	if err := sub.Find(); err != nil {
+		return nil, err
+	}
+	// 不执行节点过滤脚本：校验随规则的每次增删改触发，脚本的 $http 请求与 $store 写入不应因此产生
+	if err := sub.LoadFilteredNodes(); err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// chainValidationForSubscription 校验订阅的前置代理拓扑，随规则变更的响应返回；订阅加载失败时返回 nil
+func chainValidationForSubscription(subID int) *models.ChainValidation {
+	sub, err := loadChainSubscription(subID)
+	if err != nil {
+		utils.Warn("[ChainRule] 加载订阅 %d 失败，跳过链路校验: %v", subID, err)
+		return nil
+	}
+	return models.ValidateChainGraph(sub, models.GetEnabledChainRulesBySubscriptionID(subID), parseTemplateProxyGroupDetails(sub.Config))
+}
+
+// ValidateChainRules 校验订阅的前置代理拓扑：循环、悬空引用与链路深度
+func ValidateChainRules(c *gin.Context) {
+	subID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的订阅ID"})
+		return
+	}
+
+	sub, err := loadChainSubscription(subID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "订阅不存在或节点加载失败: " + err.Error()})
+		return
+	}
+
+	result := models.ValidateChainGraph(sub, models.GetEnabledChainRulesBySubscriptionID(subID), parseTemplateProxyGroupDetails(sub.Config))
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// ChainDialTestRequest 链路拨号测试请求
+type ChainDialTestRequest struct {
+	NodeID  int    `json:"nodeId"`
+	TestURL string `json:"testUrl"`
+	Timeout int    `json:"timeout"` // 超时(秒)，默认 10
+}
+
+// ChainDialTestResult 链路拨号测试结果
+type ChainDialTestResult struct {
+	Hops    []models.ChainDialHop `json:"hops"`    // 按流量经过的顺序（入口 -> 落地）
+	Success bool                  `json:"success"` // 是否连通
+	Latency int                   `json:"latency"` // 经完整链路的延迟(ms)
+	Error   string                `json:"error"`
+}
+
+// DialTestChainRule 按订阅输出的链路，使用 mihomo 逐跳组装拨号器连接测试地址并测量延迟
+// 代理组按客户端默认选择取成员（url-test 等取延迟最低的节点，select 取第一个）
+func DialTestChainRule(c *gin.Context) {
+	subID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的订阅ID"})
+		return
+	}
+
+	var req ChainDialTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.NodeID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请指定要测试的节点"})
+		return
+	}
+	timeout := req.Timeout
+	if timeout <= 0 || timeout > 60 {
+		timeout = 10
+	}
+
+	sub, err := loadChainSubscription(subID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "订阅不存在或节点加载失败: " + err.Error()})
+		return
+	}
+
+	hops, err := models.ResolveChainDialPath(sub, models.GetEnabledChainRulesBySubscriptionID(subID), parseTemplateProxyGroupDetails(sub.Config), req.NodeID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "解析链路失败: " + err.Error()})
+		return
+	}
+
+	links := make([]string, 0, len(hops))
+	for _, hop := range hops {
+		links = append(links, hop.Link)
+	}
+
+	result := ChainDialTestResult{Hops: hops}
+	latency, err := mihomo.MihomoChainDelayTest(links, req.TestURL, time.Duration(timeout)*time.Second)
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Success = true
+		result.Latency = latency
+	}
+	c.JSON(http.StatusOK, gin.H{"data": result})
 }
 
 // ChainLinkPreviewNode 链路预览中的节点信息
@@ -440,6 +589,22 @@ type SubscriptionChainPreviewResult struct {
 	TotalNodes       int                  `json:"totalNodes"`   // 订阅总节点数
 	Rules            []ChainPreviewResult `json:"rules"`        // 所有规则预览
 	MatchSummary     []NodeMatchSummary   `json:"matchSummary"` // 节点匹配摘要
+	Clients          []ChainClientPreview `json:"clients"`      // 各客户端格式的输出结果
+}
+
+// ChainClientNodePreview 节点在某客户端格式下输出的前置代理
+type ChainClientNodePreview struct {
+	NodeName string `json:"nodeName"` // 输出名称（已应用重命名规则）
+	Dialer   string `json:"dialer"`   // 输出的前置代理，不支持链式代理的格式为空
+}
+
+// ChainClientPreview 链式代理在某客户端格式下的输出结果
+type ChainClientPreview struct {
+	models.ChainClientSupport
+	ChainedCount int                      `json:"chainedCount"` // 输出了前置代理的节点数
+	DroppedCount int                      `json:"droppedCount"` // 因格式不支持或前置代理不存在而按直连输出的节点数
+	CustomGroups []string                 `json:"customGroups"` // 输出的自定义代理组
+	Nodes        []ChainClientNodePreview `json:"nodes"`        // 设置了前置代理的节点
 }
 
 // NodeMatchSummary 节点匹配摘要
@@ -543,11 +708,79 @@ func PreviewChainLinks(c *gin.Context) {
 		TotalNodes:       len(sub.Nodes),
 		Rules:            rulesPreview,
 		MatchSummary:     matchSummary,
+		Clients:          buildChainClientPreviews(&sub, enabledRules),
 	}
 
 	c.JSON(http.StatusOK, gin.H{"data": result})
 }
 
+// buildChainClientPreviews 按各客户端格式的支持情况，展示订阅输出时每个节点实际使用的前置代理
+func buildChainClientPreviews(sub *models.Subcription, enabledRules []models.SubscriptionChainRule) []ChainClientPreview {
+	plan := models.BuildChainDialerPlan(sub, enabledRules)
+
+	var chained []ChainClientNodePreview
+	for _, node := range sub.Nodes {
+		if dialer := plan.DialerFor(node); dialer != "" {
+			chained = append(chained, ChainClientNodePreview{NodeName: plan.NodeNames[node.ID], Dialer: dialer})
+		}
+	}
+	groupNames := make([]string, 0, len(plan.CustomGroups))
+	for _, g := range plan.CustomGroups {
+		groupNames = append(groupNames, g.Name)
+	}
+	surgeKnown := surgeKnownDialers(sub, plan)
+
+	clients := make([]ChainClientPreview, 0, len(models.ChainClientSupports))
+	for _, support := range models.ChainClientSupports {
+		preview := ChainClientPreview{
+			ChainClientSupport: support,
+			CustomGroups:       []string{},
+			Nodes:              []ChainClientNodePreview{},
+		}
+		if support.Supported {
+			preview.CustomGroups = groupNames
+			for _, n := range chained {
+				// Surge 输出时忽略模板中不存在的前置代理，节点按直连输出
+				if support.Client == "surge" && surgeKnown != nil && !surgeKnown[n.Dialer] {
+					preview.DroppedCount++
+					preview.Nodes = append(preview.Nodes, ChainClientNodePreview{NodeName: n.NodeName})
+					continue
+				}
+				preview.ChainedCount++
+				preview.Nodes = append(preview.Nodes, n)
+			}
+		} else {
+			preview.DroppedCount = len(chained)
+			for _, n := range chained {
+				preview.Nodes = append(preview.Nodes, ChainClientNodePreview{NodeName: n.NodeName})
+			}
+		}
+		clients = append(clients, preview)
+	}
+	return clients
+}
+
+// surgeKnownDialers Surge 输出中可作为前置代理的名称：订阅节点、Surge 模板代理组与自定义代理组
+// 订阅未配置 Surge 模板或模板读取失败时返回 nil（不检查）
+func surgeKnownDialers(sub *models.Subcription, plan *models.ChainDialerPlan) map[string]bool {
+	var configs protocol.OutputConfig
+	if err := json.Unmarshal([]byte(sub.Config), &configs); err != nil || configs.Surge == "" {
+		return nil
+	}
+	known, err := protocol.SurgeTemplateGroupNames(configs.Surge)
+	if err != nil {
+		utils.Warn("读取 Surge 模板失败: %v", err)
+		return nil
+	}
+	for _, name := range plan.NodeNames {
+		known[name] = true
+	}
+	for _, g := range plan.CustomGroups {
+		known[g.Name] = true
+	}
+	return known
+}
+
 // buildRulePreviewData 构建单条规则的预览数据
 func buildRulePreviewData(rule models.SubscriptionChainRule, nodes []models.Node, nodeNameMap map[int]string, nodeInfoMap map[int]models.Node) ChainPreviewResult {
 	data := ChainPreviewResult{
@@ -4,6 +4,7 @@ import (
 	"strconv"
 	"sublink/models"
 	"sublink/services"
+	"sublink/services/scheduler"
 	"sublink/utils"
 
 	"github.com/gin-gonic/gin"
@@ -104,6 +105,42 @@ func TagRuleGet(c *gin.Context) {
 	utils.OkWithData(c, rules)
 }
 
+// validateTagRuleSchedule 校验规则的触发类型、定时表达式与有效期
+func validateTagRuleSchedule(rule *models.TagRule) string {
+	if rule.TriggerType == "" {
+		rule.TriggerType = models.TagTriggerSubscriptionUpdate
+	}
+	if !models.IsValidTagTrigger(rule.TriggerType) {
+		return "不支持的触发类型: " + rule.TriggerType
+	}
+	if rule.TriggerType == models.TagTriggerCron {
+		if rule.CronExpr == "" || !validateCron(rule.CronExpr) {
+			return "定时触发需要有效的 Cron 表达式"
+		}
+	} else {
+		rule.CronExpr = ""
+	}
+	if rule.ExpireMinutes < 0 {
+		return "标签有效期不能为负数"
+	}
+	return ""
+}
+
+// tagHasExpiringRule 是否还有设置了有效期的规则关联指定标签
+func tagHasExpiringRule(tagName string) bool {
+	var rule models.TagRule
+	rules, err := rule.List()
+	if err != nil {
+		return true
+	}
+	for _, r := range rules {
+		if r.TagName == tagName && r.ExpireMinutes > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 // TagRuleAdd 添加规则
 func TagRuleAdd(c *gin.Context) {
 	var rule models.TagRule
@@ -131,10 +168,17 @@ func TagRuleAdd(c *gin.Context) {
 			return
 		}
 	}
+	if msg := validateTagRuleSchedule(&rule); msg != "" {
+		utils.FailWithMsg(c, msg)
+		return
+	}
 	if err := rule.Add(); err != nil {
 		utils.FailWithMsg(c, "添加规则失败: "+err.Error())
 		return
 	}
+	if err := scheduler.GetSchedulerManager().UpdateTagRuleJob(&rule); err != nil {
+		utils.Warn("注册标签规则定时任务失败: %v", err)
+	}
 	utils.OkWithData(c, rule)
 }
 
@@ -161,10 +205,25 @@ func TagRuleUpdate(c *gin.Context) {
 			return
 		}
 	}
+	if msg := validateTagRuleSchedule(&rule); msg != "" {
+		utils.FailWithMsg(c, msg)
+		return
+	}
+	var old models.TagRule
+	hadExpiry := old.GetByID(rule.ID) == nil && old.ExpireMinutes > 0
 	if err := rule.Update(); err != nil {
 		utils.FailWithMsg(c, "更新规则失败: "+err.Error())
 		return
 	}
+	if err := scheduler.GetSchedulerManager().UpdateTagRuleJob(&rule); err != nil {
+		utils.Warn("更新标签规则定时任务失败: %v", err)
+	}
+	// 有效期改为长期保留：已打上的标签不再到期移除（同一标签的其他规则仍设置有效期时保留过期记录）
+	if hadExpiry && rule.ExpireMinutes <= 0 && !tagHasExpiringRule(old.TagName) {
+		if err := models.ClearTagExpiry(old.TagName); err != nil {
+			utils.Warn("清除标签 %s 的过期记录失败: %v", old.TagName, err)
+		}
+	}
 	utils.OkWithData(c, rule)
 }
 
@@ -182,6 +241,7 @@ func TagRuleDelete(c *gin.Context) {
 		utils.FailWithMsg(c, "删除规则失败: "+err.Error())
 		return
 	}
+	scheduler.GetSchedulerManager().RemoveTagRuleJob(id)
 	utils.Ok(c)
 }
 
@@ -201,8 +261,89 @@ func TagRuleTrigger(c *gin.Context) {
 	utils.OkWithMsg(c, "规则已开始执行")
 }
 
+// TagRulePreview 试运行规则：对当前所有节点评估规则（已保存的规则或未保存的条件），返回将获得/失去标签的节点与互斥冲突
+func TagRulePreview(c *gin.Context) {
+	var req struct {
+		ID            int    `json:"id"`            // 已保存的规则ID（可选）
+		TagName       string `json:"tagName"`       // 关联标签，未提供时使用已保存规则的标签
+		Conditions    string `json:"conditions"`    // 条件，未提供时使用已保存规则的条件
+		ExpireMinutes *int   `json:"expireMinutes"` // 标签有效期，未提供时使用已保存规则的设置
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.FailWithMsg(c, "参数错误")
+		return
+	}
+	var rule models.TagRule
+	if req.ID > 0 {
+		if err := rule.GetByID(req.ID); err != nil {
+			utils.FailWithCode(c, 404, "规则不存在")
+			return
+		}
+	}
+	if req.TagName != "" {
+		rule.TagName = req.TagName
+	}
+	if req.Conditions != "" {
+		rule.Conditions = req.Conditions
+	}
+	if req.ExpireMinutes != nil {
+		rule.ExpireMinutes = *req.ExpireMinutes
+	}
+	if rule.TagName == "" {
+		utils.FailWithMsg(c, "关联标签不能为空")
+		return
+	}
+	conditions, err := models.ParseConditions(rule.Conditions)
+	if err != nil {
+		utils.FailWithMsg(c, "条件格式错误: "+err.Error())
+		return
+	}
+
+	var node models.Node
+	nodes, err := node.List()
+	if err != nil {
+		utils.FailWithMsg(c, "获取节点列表失败")
+		return
+	}
+	preview, err := models.PreviewTagRule(rule, conditions, nodes)
+	if err != nil {
+		utils.FailWithMsg(c, err.Error())
+		return
+	}
+	utils.OkWithData(c, preview)
+}
+
 // ========== Node Tag API ==========
 
+// clearManualTagExpiry 手动设置或移除标签后清除对应的过期记录，避免手动设置的标签被规则的有效期移除
+func clearManualTagExpiry(nodeIDs []int, tagNames ...string) {
+	if err := models.ClearNodeTagExpiry(nodeIDs, tagNames...); err != nil {
+		utils.Warn("清除标签过期记录失败: %v", err)
+	}
+}
+
+// changedTagNames 返回新旧标签列表中被添加或移除的标签
+func changedTagNames(oldNames, newNames []string) []string {
+	oldSet := make(map[string]bool, len(oldNames))
+	for _, name := range oldNames {
+		oldSet[name] = true
+	}
+	newSet := make(map[string]bool, len(newNames))
+	changed := make([]string, 0)
+	for _, name := range newNames {
+		newSet[name] = true
+		if !oldSet[name] {
+			changed = append(changed, name)
+		}
+	}
+	for _, name := range oldNames {
+		if !newSet[name] {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
 // NodeAddTag 给节点添加标签
 func NodeAddTag(c *gin.Context) {
 	var req struct {
@@ -223,6 +364,7 @@ func NodeAddTag(c *gin.Context) {
 		utils.FailWithMsg(c, "添加标签失败")
 		return
 	}
+	clearManualTagExpiry([]int{node.ID}, req.TagName)
 	utils.Ok(c)
 }
 
@@ -246,6 +388,7 @@ func NodeRemoveTag(c *gin.Context) {
 		utils.FailWithMsg(c, "移除标签失败")
 		return
 	}
+	clearManualTagExpiry([]int{node.ID}, req.TagName)
 	utils.Ok(c)
 }
 
@@ -263,6 +406,7 @@ func NodeBatchAddTag(c *gin.Context) {
 		utils.FailWithMsg(c, "批量添加标签失败")
 		return
 	}
+	clearManualTagExpiry(req.NodeIDs, req.TagName)
 	utils.Ok(c)
 }
 
@@ -280,6 +424,8 @@ func NodeBatchSetTags(c *gin.Context) {
 		utils.FailWithMsg(c, "批量设置标签失败")
 		return
 	}
+	// 覆盖模式下节点的全部标签均为手动设置
+	clearManualTagExpiry(req.NodeIDs)
 	utils.Ok(c)
 }
 
@@ -301,6 +447,7 @@ func NodeBatchRemoveTags(c *gin.Context) {
 		utils.FailWithMsg(c, "批量移除标签失败")
 		return
 	}
+	clearManualTagExpiry(req.NodeIDs, req.TagNames...)
 	utils.Ok(c)
 }
 
@@ -1,6 +1,9 @@
 package dto
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // 订阅节点排序请求体结构
 type SubcriptionNodeSortUpdate struct {
@@ -37,6 +40,49 @@ type AirportRequest struct {
 	SkipTLSVerify     bool   `json:"skipTLSVerify"`  // 是否跳过TLS证书验证
 	Remark            string `json:"remark"`         // 备注信息
 	Logo              string `json:"logo"`           // Logo配置
+
+	MaxDeleteRatio   int `json:"maxDeleteRatio"`   // 单次同步最多删除的节点比例(%)
+	MinNodeCount     int `json:"minNodeCount"`     // 同步结果的最少节点数
+	DeleteGraceSyncs int `json:"deleteGraceSyncs"` // 删除宽限次数
+
+	IdentityMatch  string `json:"identityMatch"`  // 节点身份匹配方式
+	IdentityFields string `json:"identityFields"` // 自定义匹配字段
+
+	ImportIncludeName      string `json:"importIncludeName"`      // 导入名称保留规则（正则）
+	ImportExcludeName      string `json:"importExcludeName"`      // 导入名称排除规则（正则）
+	ImportIncludeProtocols string `json:"importIncludeProtocols"` // 导入协议白名单
+	ImportExcludeProtocols string `json:"importExcludeProtocols"` // 导入协议黑名单
+	ImportIncludePorts     string `json:"importIncludePorts"`     // 导入端口白名单
+	ImportExcludePorts     string `json:"importExcludePorts"`     // 导入端口黑名单
+	ImportNamePreprocess   string `json:"importNamePreprocess"`   // 导入名称预处理规则
+	ImportScript           string `json:"importScript"`           // 导入转换脚本
+
+	MirrorURLs string `json:"mirrorUrls"` // 备用地址（每行一个）
+	URLMode    string `json:"urlMode"`    // 多地址模式: failover / merge
+
+	UsageAlertPercent    int  `json:"usageAlertPercent"`    // 已用流量告警阈值(%)，0=默认，-1=关闭
+	ExpireAlertDays      int  `json:"expireAlertDays"`      // 到期提醒天数，0=默认，-1=关闭
+	DisableForecastAlert bool `json:"disableForecastAlert"` // 关闭耗尽预测告警
+
+	ProxySource         string `json:"proxySource"`         // 代理来源: tag:标签名 / group:分组名
+	ProxyMaxAttempts    int    `json:"proxyMaxAttempts"`    // 最多尝试的代理数
+	ProxyDirectFallback bool   `json:"proxyDirectFallback"` // 代理全部失败后允许直连
+
+	PanelType     string `json:"panelType"`     // 面板类型: 空=不启用, v2board, xboard, sspanel
+	PanelURL      string `json:"panelUrl"`      // 面板地址
+	PanelEmail    string `json:"panelEmail"`    // 登录邮箱
+	PanelPassword string `json:"panelPassword"` // 登录密码，留空保持不变
+	PanelNoRotate bool   `json:"panelNoRotate"` // 订阅地址变化时不自动更新主地址
+}
+
+// AirportPanelTestRequest 机场面板登录测试请求
+type AirportPanelTestRequest struct {
+	ID            int    `json:"id"`                           // 已有机场ID，密码留空时使用已保存的密码及代理设置
+	PanelType     string `json:"panelType" binding:"required"` // 面板类型
+	PanelURL      string `json:"panelUrl" binding:"required"`  // 面板地址
+	PanelEmail    string `json:"panelEmail" binding:"required"`
+	PanelPassword string `json:"panelPassword"`
+	SkipTLSVerify bool   `json:"skipTLSVerify"` // 新机场测试时是否跳过TLS证书验证
 }
 
 // BatchSortRequest 批量排序请求
@@ -45,3 +91,33 @@ type BatchSortRequest struct {
 	SortBy    string `json:"sortBy" binding:"required"`    // 排序字段: source, name, protocol, delay, speed, country
 	SortOrder string `json:"sortOrder" binding:"required"` // 排序方向: asc, desc
 }
+
+// ScriptSettingsRequest 脚本运行限制设置
+type ScriptSettingsRequest struct {
+	Timeout        int    `json:"timeout"`        // 单次执行超时（秒）
+	MaxMemoryMB    int    `json:"maxMemoryMB"`    // 执行期间进程存活堆增长上限（MB，进程级软限制），0=不限制
+	MaxOutputMB    int    `json:"maxOutputMB"`    // 返回结果上限（MB），0=不限制
+	FetchAllowlist string `json:"fetchAllowlist"` // $http.get 允许访问的域名（每行一个，*.example.com 匹配子域名）
+}
+
+// ScriptTestRequest 脚本试运行请求
+type ScriptTestRequest struct {
+	ScriptID       int             `json:"scriptId"`       // 脚本库中的脚本 ID
+	Content        string          `json:"content"`        // 可选，覆盖脚本内容（用于测试未保存的修改）
+	SubscriptionID int             `json:"subscriptionId"` // 使用该订阅过滤后的节点作为输入
+	Nodes          json.RawMessage `json:"nodes"`          // 或直接提供节点样本（JSON 数组，字段同节点列表接口）
+	ClientType     string          `json:"clientType"`     // 客户端类型: clash, surge, v2ray，默认 clash
+	Input          string          `json:"input"`          // 可选，subMod 的输入内容
+}
+
+// ScriptInstallRequest 从地址安装脚本请求
+type ScriptInstallRequest struct {
+	URL      string `json:"url" binding:"required"` // 脚本地址（原始文件、GitHub 文件页或 Gist）
+	UseProxy bool   `json:"useProxy"`               // 下载时是否使用代理
+}
+
+// SubScriptSettingsRequest 订阅中脚本的版本与参数配置
+type SubScriptSettingsRequest struct {
+	PinVersion  string                 `json:"pinVersion"`  // 固定使用的版本，为空表示跟随最新版本
+	ParamValues map[string]interface{} `json:"paramValues"` // 参数值
+}
@@ -13,6 +13,7 @@ import (
 	"sublink/config"
 	"sublink/database"
 	"sublink/models"
+	"sublink/node"
 	"sublink/node/protocol"
 	"sublink/routers"
 	"sublink/services"
@@ -292,6 +293,40 @@ func Run() {
 		}
 		return node.Link, node.Name, nil
 	}
+	utils.GetProxyCandidatesFunc = func(source string) []utils.ProxyCandidate {
+		nodes := models.ListProxyCandidates(source)
+		candidates := make([]utils.ProxyCandidate, 0, len(nodes))
+		for _, n := range nodes {
+			candidates = append(candidates, utils.ProxyCandidate{Name: n.Name, Link: n.Link})
+		}
+		return candidates
+	}
+
+	// 初始化脚本宿主 API
+	utils.ScriptLimitsFunc = models.GetScriptLimits
+	utils.ScriptGeoIPFunc = func(ip string) (map[string]interface{}, error) {
+		result, err := geoip.Lookup(ip)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"countryCode": result.CountryCode,
+			"country":     result.Country,
+			"regionCode":  result.RegionCode,
+			"region":      result.RegionName,
+			"city":        result.City,
+			"asn":         result.ASN,
+			"asOrg":       result.ASOrganization,
+			"isp":         result.ISP,
+		}, nil
+	}
+	utils.ScriptTagsFunc = models.ListScriptTags
+	utils.ScriptTagNodesFunc = models.ListScriptTagNodeNames
+	utils.ScriptKVGetFunc = models.GetScriptKV
+	utils.ScriptKVSetFunc = models.SetScriptKV
+	utils.ScriptKVDeleteFunc = models.DeleteScriptKVKey
+	node.AirportSyncHookFunc = scheduler.RunAirportSyncHooks
+	node.ApplyTagRulesFunc = services.ApplyAutoTagRules
 
 	// 初始化 GeoIP 数据库
 	if err := geoip.InitGeoIP(); err != nil {
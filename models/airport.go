@@ -36,6 +36,60 @@ type Airport struct {
 	SkipTLSVerify  bool   `gorm:"default:false" json:"skipTLSVerify"`  // 是否跳过TLS证书验证
 	Remark         string `json:"remark"`                              // 备注信息
 	Logo           string `json:"logo"`                                // Logo：URL、icon:图标名、或emoji字符
+
+	// 安全同步保护
+	MaxDeleteRatio   int `gorm:"default:0" json:"maxDeleteRatio"`   // 单次同步最多删除的节点比例(%)，0=不限制
+	MinNodeCount     int `gorm:"default:0" json:"minNodeCount"`     // 同步结果的最少节点数，0=不限制
+	DeleteGraceSyncs int `gorm:"default:0" json:"deleteGraceSyncs"` // 节点连续缺失超过多少次同步才删除，0=立即删除
+
+	// 节点身份匹配：链接变化时识别同一节点并原地更新
+	IdentityMatch  string `json:"identityMatch"`  // 匹配方式: 空=按链接, name=按原始名称, server=按服务器地址+端口, fields=按自定义字段
+	IdentityFields string `json:"identityFields"` // 自定义匹配字段（逗号分隔的节点字段名，如 LinkName,Protocol）
+
+	// 导入规则：节点写入数据库前执行（过滤 -> 名称预处理 -> 转换脚本）
+	ImportIncludeName      string `json:"importIncludeName"`                     // 名称保留规则（正则），为空不限制
+	ImportExcludeName      string `json:"importExcludeName"`                     // 名称排除规则（正则）
+	ImportIncludeProtocols string `json:"importIncludeProtocols"`                // 协议白名单（逗号分隔）
+	ImportExcludeProtocols string `json:"importExcludeProtocols"`                // 协议黑名单（逗号分隔）
+	ImportIncludePorts     string `json:"importIncludePorts"`                    // 端口白名单（逗号分隔，支持范围如 8000-9000）
+	ImportExcludePorts     string `json:"importExcludePorts"`                    // 端口黑名单（逗号分隔，支持范围）
+	ImportNamePreprocess   string `gorm:"type:text" json:"importNamePreprocess"` // 名称预处理规则(JSON数组，格式同订阅原名预处理)
+	ImportScript           string `gorm:"type:text" json:"importScript"`         // 导入转换脚本，需定义 transformProxies(proxies, airportName)
+
+	// 多地址：URL 为主地址，以下为镜像/附加地址
+	MirrorURLs string `gorm:"type:text" json:"mirrorUrls"` // 备用地址（每行一个）
+	URLMode    string `json:"urlMode"`                     // 多地址模式: 空/failover=故障转移, merge=合并所有地址的节点
+	ActiveURL  string `json:"activeUrl"`                   // 最近一次拉取成功的地址，故障转移时优先使用
+
+	// 条件拉取：记录上次成功处理的内容，未变化时跳过节点处理
+	FetchETag         string `json:"fetchETag"`         // 上次响应的 ETag（对应 ActiveURL）
+	FetchLastModified string `json:"fetchLastModified"` // 上次响应的 Last-Modified（对应 ActiveURL）
+	ContentHash       string `json:"contentHash"`       // 上次成功处理的订阅内容哈希(SHA-256)
+	FetchConfigHash   string `json:"fetchConfigHash"`   // 上次成功处理时导入规则与同步钩子的配置哈希，变化后需完整处理
+
+	// 用量告警（需开启获取用量信息）
+	UsageAlertPercent    int    `json:"usageAlertPercent"`    // 已用流量达到该比例(%)时告警，0=默认80，-1=关闭
+	ExpireAlertDays      int    `json:"expireAlertDays"`      // 距离到期不足该天数时告警，0=默认3，-1=关闭
+	DisableForecastAlert bool   `json:"disableForecastAlert"` // 关闭「预计到期前耗尽」告警
+	UsageAlertCycle      int64  `json:"-"`                    // 告警周期（到期时间），续费后重新计算
+	UsageAlertSent       string `json:"-"`                    // 本周期已发送的告警类型（逗号分隔）
+
+	// 代理下载：可从标签或分组中选取代理并依次重试
+	ProxySource         string `json:"proxySource"`         // 代理来源: tag:标签名 / group:分组名，空=指定代理或自动选择最佳节点
+	ProxyMaxAttempts    int    `json:"proxyMaxAttempts"`    // 最多尝试的代理数，0=默认3
+	ProxyDirectFallback bool   `json:"proxyDirectFallback"` // 代理全部失败后允许直连
+
+	// 面板账号：登录 V2Board/Xboard/SSPanel 面板获取订阅地址、流量与套餐信息
+	PanelType     string     `json:"panelType"`     // 面板类型: 空=不启用, v2board, xboard, sspanel
+	PanelURL      string     `json:"panelUrl"`      // 面板地址（如 https://panel.example.com）
+	PanelEmail    string     `json:"panelEmail"`    // 登录邮箱
+	PanelPassword string     `json:"-"`             // 登录密码（加密存储）
+	PanelAuth     string     `json:"-"`             // 登录凭证（加密存储，失效后重新登录）
+	PanelPlan     string     `json:"panelPlan"`     // 当前套餐名称
+	PanelSyncAt   *time.Time `json:"panelSyncAt"`   // 最近一次成功同步面板的时间
+	PanelError    string     `json:"panelError"`    // 最近一次同步面板失败的原因，成功后清空
+	PanelResetDay int        `json:"panelResetDay"` // 距离流量重置的天数，-1 表示未知
+	PanelNoRotate bool       `json:"panelNoRotate"` // 面板订阅地址变化时不自动更新主地址
 }
 
 // TableName 指定表名
@@ -83,6 +137,14 @@ func (a *Airport) Update() error {
 		"Name", "URL", "CronExpr", "Enabled", "LastRunTime", "NextRunTime",
 		"SuccessCount", "Group", "DownloadWithProxy", "ProxyLink", "UserAgent",
 		"FetchUsageInfo", "SkipTLSVerify", "Remark", "Logo",
+		"MaxDeleteRatio", "MinNodeCount", "DeleteGraceSyncs",
+		"IdentityMatch", "IdentityFields",
+		"ImportIncludeName", "ImportExcludeName", "ImportIncludeProtocols", "ImportExcludeProtocols",
+		"ImportIncludePorts", "ImportExcludePorts", "ImportNamePreprocess", "ImportScript",
+		"MirrorURLs", "URLMode",
+		"UsageAlertPercent", "ExpireAlertDays", "DisableForecastAlert",
+		"ProxySource", "ProxyMaxAttempts", "ProxyDirectFallback",
+		"PanelType", "PanelURL", "PanelEmail", "PanelPassword", "PanelAuth", "PanelNoRotate",
 	).Updates(a).Error
 	if err != nil {
 		return err
@@ -95,6 +157,19 @@ func (a *Airport) Update() error {
 	return nil
 }
 
+// ProxyOptions 机场拉取订阅使用的代理选项
+func (a *Airport) ProxyOptions(timeout time.Duration) utils.ProxyOptions {
+	return utils.ProxyOptions{
+		UseProxy:      a.DownloadWithProxy,
+		ProxyLink:     a.ProxyLink,
+		ProxySource:   a.ProxySource,
+		MaxAttempts:   a.ProxyMaxAttempts,
+		AllowDirect:   a.ProxyDirectFallback,
+		Timeout:       timeout,
+		SkipTLSVerify: a.SkipTLSVerify,
+	}
+}
+
 // Find 查找机场是否重复（按URL或名称）
 func (a *Airport) Find() error {
 	// 先查缓存
@@ -202,6 +277,21 @@ func (a *Airport) Del() error {
 	if err != nil {
 		return err
 	}
+	if err := DeleteAirportChangesets(a.ID); err != nil {
+		utils.Warn("删除机场 %d 的变更记录失败: %v", a.ID, err)
+	}
+	if err := DeleteAirportURLHealth(a.ID); err != nil {
+		utils.Warn("删除机场 %d 的地址健康记录失败: %v", a.ID, err)
+	}
+	if err := DeleteAirportUsageRecords(a.ID); err != nil {
+		utils.Warn("删除机场 %d 的用量历史失败: %v", a.ID, err)
+	}
+	if err := DeleteAirportFetchAttempts(a.ID); err != nil {
+		utils.Warn("删除机场 %d 的拉取记录失败: %v", a.ID, err)
+	}
+	if err := DeleteScriptKV(AirportScriptKVNamespace(a.ID)); err != nil {
+		utils.Warn("删除机场 %d 的脚本 KV 存储失败: %v", a.ID, err)
+	}
 	airportCache.Delete(a.ID)
 	return nil
 }
@@ -275,6 +365,7 @@ func UpdateNodesByAirportID(airportID int, name string, group string) error {
 }
 
 // UpdateUsageInfo 更新用量信息 (Write-Through)
+// 有效的用量信息同时记录到用量历史
 func (a *Airport) UpdateUsageInfo(upload, download, total, expire int64) error {
 	err := database.DB.Model(a).Select("UsageUpload", "UsageDownload", "UsageTotal", "UsageExpire").Updates(map[string]interface{}{
 		"UsageUpload":   upload,
@@ -293,6 +384,11 @@ func (a *Airport) UpdateUsageInfo(upload, download, total, expire int64) error {
 		cached.UsageExpire = expire
 		airportCache.Set(a.ID, cached)
 	}
+	if total != -1 {
+		if err := recordAirportUsage(a.ID, upload, download, total, expire); err != nil {
+			utils.Warn("记录机场 %d 用量历史失败: %v", a.ID, err)
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,499 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"sublink/database"
+	"sublink/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 机场变更记录类型
+const (
+	AirportChangeBaseline = "baseline" // 首次记录前的节点基线
+	AirportChangeSync     = "sync"     // 订阅同步
+	AirportChangeRollback = "rollback" // 回滚
+)
+
+// airportChangesetKeep 每个机场保留的变更记录数量
+const airportChangesetKeep = 20
+
+// AirportChangeset 机场节点同步变更记录
+// 每条记录保存本次变更的明细以及变更后的完整节点快照，用于回滚
+type AirportChangeset struct {
+	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	AirportID int       `gorm:"index" json:"airportId"`
+	Type      string    `gorm:"size:20" json:"type"`  // baseline / sync / rollback
+	Note      string    `gorm:"size:256" json:"note"` // 说明（如回滚目标）
+	Added     int       `json:"added"`                // 新增节点数
+	Removed   int       `json:"removed"`              // 删除节点数
+	Modified  int       `json:"modified"`             // 修改节点数（同一节点链接变化）
+	NodeCount int       `json:"nodeCount"`            // 变更后的节点总数
+	Changes   string    `gorm:"type:text" json:"-"`   // 变更明细(JSON)
+	Snapshot  string    `gorm:"type:text" json:"-"`   // 变更后的节点快照(JSON)
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"createdAt"`
+}
+
+// TableName 指定表名
+func (AirportChangeset) TableName() string {
+	return "airport_changesets"
+}
+
+// AirportNodeChange 单个节点的变更
+type AirportNodeChange struct {
+	Name   string `json:"name"`
+	Before string `json:"before,omitempty"` // 变更前链接
+	After  string `json:"after,omitempty"`  // 变更后链接
+}
+
+// AirportChangeDetail 变更明细
+type AirportChangeDetail struct {
+	Added    []AirportNodeChange `json:"added"`
+	Removed  []AirportNodeChange `json:"removed"`
+	Modified []AirportNodeChange `json:"modified"`
+}
+
+// IsEmpty 是否没有任何变更
+func (d AirportChangeDetail) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+// AirportNodeMembership 节点在订阅中的直接选择关系
+type AirportNodeMembership struct {
+	SubcriptionID int `json:"subId"`
+	Sort          int `json:"sort"`
+}
+
+// AirportNodeSnapshot 节点快照（回滚时用于重建节点，保留标签、检测结果和订阅关联）
+type AirportNodeSnapshot struct {
+	Link            string  `json:"link"`
+	Name            string  `json:"name"`
+	LinkName        string  `json:"linkName"`
+	LinkAddress     string  `json:"linkAddress"`
+	LinkHost        string  `json:"linkHost"`
+	LinkPort        string  `json:"linkPort"`
+	LinkCountry     string  `json:"linkCountry,omitempty"`
+	Protocol        string  `json:"protocol"`
+	Group           string  `json:"group,omitempty"`
+	Tags            string  `json:"tags,omitempty"`
+	DialerProxyName string  `json:"dialerProxyName,omitempty"`
+	Speed           float64 `json:"speed,omitempty"`
+	SpeedStatus     string  `json:"speedStatus,omitempty"`
+	DelayTime       int     `json:"delayTime,omitempty"`
+	DelayStatus     string  `json:"delayStatus,omitempty"`
+	LatencyCheckAt  string  `json:"latencyCheckAt,omitempty"`
+	SpeedCheckAt    string  `json:"speedCheckAt,omitempty"`
+
+	Subscriptions []AirportNodeMembership `json:"subscriptions,omitempty"` // 直接选择该节点的订阅
+}
+
+// newAirportNodeSnapshot 从节点生成快照
+func newAirportNodeSnapshot(n Node, memberships []AirportNodeMembership) AirportNodeSnapshot {
+	return AirportNodeSnapshot{
+		Link:            n.Link,
+		Name:            n.Name,
+		LinkName:        n.LinkName,
+		LinkAddress:     n.LinkAddress,
+		LinkHost:        n.LinkHost,
+		LinkPort:        n.LinkPort,
+		LinkCountry:     n.LinkCountry,
+		Protocol:        n.Protocol,
+		Group:           n.Group,
+		Tags:            n.Tags,
+		DialerProxyName: n.DialerProxyName,
+		Speed:           n.Speed,
+		SpeedStatus:     n.SpeedStatus,
+		DelayTime:       n.DelayTime,
+		DelayStatus:     n.DelayStatus,
+		LatencyCheckAt:  n.LatencyCheckAt,
+		SpeedCheckAt:    n.SpeedCheckAt,
+		Subscriptions:   memberships,
+	}
+}
+
+// toNode 由快照重建节点
+func (s AirportNodeSnapshot) toNode(airport *Airport) Node {
+	return Node{
+		Link:            s.Link,
+		Name:            s.Name,
+		LinkName:        s.LinkName,
+		LinkAddress:     s.LinkAddress,
+		LinkHost:        s.LinkHost,
+		LinkPort:        s.LinkPort,
+		LinkCountry:     s.LinkCountry,
+		Protocol:        s.Protocol,
+		Group:           s.Group,
+		Tags:            s.Tags,
+		DialerProxyName: s.DialerProxyName,
+		Speed:           s.Speed,
+		SpeedStatus:     s.SpeedStatus,
+		DelayTime:       s.DelayTime,
+		DelayStatus:     s.DelayStatus,
+		LatencyCheckAt:  s.LatencyCheckAt,
+		SpeedCheckAt:    s.SpeedCheckAt,
+		Source:          airport.Name,
+		SourceID:        airport.ID,
+		LifecycleState:  NodeStateActive,
+	}
+}
+
+// Detail 解析变更明细
+func (c *AirportChangeset) Detail() AirportChangeDetail {
+	var detail AirportChangeDetail
+	if c.Changes != "" {
+		if err := json.Unmarshal([]byte(c.Changes), &detail); err != nil {
+			utils.Warn("解析机场变更明细失败 (ID=%d): %v", c.ID, err)
+		}
+	}
+	return detail
+}
+
+// SnapshotNodes 解析节点快照
+func (c *AirportChangeset) SnapshotNodes() []AirportNodeSnapshot {
+	var nodes []AirportNodeSnapshot
+	if c.Snapshot != "" {
+		if err := json.Unmarshal([]byte(c.Snapshot), &nodes); err != nil {
+			utils.Warn("解析机场节点快照失败 (ID=%d): %v", c.ID, err)
+		}
+	}
+	return nodes
+}
+
+// DiffAirportNodes 对比变更前后的节点列表（以链接为准）
+// 同一节点（ID 相同）链接变化，或同名节点被删除又新增时视为修改
+func DiffAirportNodes(before, after []Node) AirportChangeDetail {
+	beforeLinks := make(map[string]bool, len(before))
+	beforeByID := make(map[int]Node, len(before))
+	for _, n := range before {
+		beforeLinks[n.Link] = true
+		beforeByID[n.ID] = n
+	}
+	afterLinks := make(map[string]bool, len(after))
+	for _, n := range after {
+		afterLinks[n.Link] = true
+	}
+
+	// 按原始名称收集被删除的节点，用于匹配修改
+	removedByName := make(map[string][]Node)
+	var removedOrder []Node
+	for _, n := range before {
+		if !afterLinks[n.Link] {
+			removedByName[n.LinkName] = append(removedByName[n.LinkName], n)
+			removedOrder = append(removedOrder, n)
+		}
+	}
+
+	detail := AirportChangeDetail{}
+	paired := make(map[string]bool)
+	for _, n := range after {
+		if beforeLinks[n.Link] {
+			continue
+		}
+		if old, ok := beforeByID[n.ID]; ok && !paired[old.Link] && !afterLinks[old.Link] {
+			paired[old.Link] = true
+			detail.Modified = append(detail.Modified, AirportNodeChange{Name: n.LinkName, Before: old.Link, After: n.Link})
+			continue
+		}
+		if candidates := removedByName[n.LinkName]; len(candidates) > 0 {
+			old := candidates[0]
+			removedByName[n.LinkName] = candidates[1:]
+			paired[old.Link] = true
+			detail.Modified = append(detail.Modified, AirportNodeChange{Name: n.LinkName, Before: old.Link, After: n.Link})
+			continue
+		}
+		detail.Added = append(detail.Added, AirportNodeChange{Name: n.LinkName, After: n.Link})
+	}
+	for _, n := range removedOrder {
+		if paired[n.Link] {
+			continue
+		}
+		detail.Removed = append(detail.Removed, AirportNodeChange{Name: n.LinkName, Before: n.Link})
+	}
+	return detail
+}
+
+// GetNodeSubscriptionMemberships 获取节点的订阅关联（节点ID -> 订阅列表）
+// 删除节点前调用，删除后关联记录不复存在
+func GetNodeSubscriptionMemberships(nodeIDs []int) map[int][]AirportNodeMembership {
+	result := make(map[int][]AirportNodeMembership)
+	if len(nodeIDs) == 0 {
+		return result
+	}
+	var rows []SubcriptionNode
+	if err := database.DB.Where("node_id IN ?", nodeIDs).Order("subcription_id, sort").Find(&rows).Error; err != nil {
+		utils.Warn("获取节点订阅关联失败: %v", err)
+		return result
+	}
+	for _, r := range rows {
+		result[r.NodeID] = append(result[r.NodeID], AirportNodeMembership{SubcriptionID: r.SubcriptionID, Sort: r.Sort})
+	}
+	return result
+}
+
+// RecordAirportChangeset 记录一次机场节点变更
+// before 为变更前的节点，after 为变更后的节点；同步没有任何变更时不记录
+// 机场首次记录时，先保存变更前的节点作为基线，保证第一次同步也能回滚
+// removedMemberships 为本次已删除节点的订阅关联（删除前通过 GetNodeSubscriptionMemberships 获取），用于基线快照
+func RecordAirportChangeset(airportID int, changeType string, before, after []Node, removedMemberships map[int][]AirportNodeMembership, note string) (*AirportChangeset, error) {
+	detail := DiffAirportNodes(before, after)
+	if detail.IsEmpty() && changeType == AirportChangeSync {
+		return nil, nil
+	}
+
+	var existing int64
+	if err := database.DB.Model(&AirportChangeset{}).Where("airport_id = ?", airportID).Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing == 0 && len(before) > 0 {
+		baseline := AirportChangeDetail{}
+		if _, err := saveAirportChangeset(airportID, AirportChangeBaseline, "同步前的节点", baseline, before, removedMemberships); err != nil {
+			return nil, err
+		}
+	}
+
+	changeset, err := saveAirportChangeset(airportID, changeType, note, detail, after, nil)
+	if err != nil {
+		return nil, err
+	}
+	pruneAirportChangesets(airportID)
+	return changeset, nil
+}
+
+// saveAirportChangeset 保存变更记录
+// 节点的订阅关联从数据库读取，已删除的节点使用 removedMemberships 中的记录
+func saveAirportChangeset(airportID int, changeType string, note string, detail AirportChangeDetail, nodes []Node, removedMemberships map[int][]AirportNodeMembership) (*AirportChangeset, error) {
+	nodeIDs := make([]int, 0, len(nodes))
+	for _, n := range nodes {
+		nodeIDs = append(nodeIDs, n.ID)
+	}
+	memberships := GetNodeSubscriptionMemberships(nodeIDs)
+	snapshot := make([]AirportNodeSnapshot, 0, len(nodes))
+	for _, n := range nodes {
+		nodeMemberships, ok := memberships[n.ID]
+		if !ok {
+			nodeMemberships = removedMemberships[n.ID]
+		}
+		snapshot = append(snapshot, newAirportNodeSnapshot(n, nodeMemberships))
+	}
+	changesJSON, err := json.Marshal(detail)
+	if err != nil {
+		return nil, err
+	}
+	snapshotJSON, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	changeset := &AirportChangeset{
+		AirportID: airportID,
+		Type:      changeType,
+		Note:      note,
+		Added:     len(detail.Added),
+		Removed:   len(detail.Removed),
+		Modified:  len(detail.Modified),
+		NodeCount: len(nodes),
+		Changes:   string(changesJSON),
+		Snapshot:  string(snapshotJSON),
+	}
+	if err := database.DB.Create(changeset).Error; err != nil {
+		return nil, err
+	}
+	return changeset, nil
+}
+
+// pruneAirportChangesets 只保留最近的变更记录
+func pruneAirportChangesets(airportID int) {
+	var keepIDs []int
+	if err := database.DB.Model(&AirportChangeset{}).Where("airport_id = ?", airportID).
+		Order("id DESC").Limit(airportChangesetKeep).Pluck("id", &keepIDs).Error; err != nil || len(keepIDs) < airportChangesetKeep {
+		return
+	}
+	minID := keepIDs[len(keepIDs)-1]
+	if err := database.DB.Where("airport_id = ? AND id < ?", airportID, minID).Delete(&AirportChangeset{}).Error; err != nil {
+		utils.Warn("清理机场 %d 的旧变更记录失败: %v", airportID, err)
+	}
+}
+
+// ListAirportChangesets 分页获取机场变更记录（不含明细和快照）
+func ListAirportChangesets(airportID int, page, pageSize int) ([]AirportChangeset, int64, error) {
+	var changesets []AirportChangeset
+	var total int64
+
+	query := database.DB.Model(&AirportChangeset{}).Where("airport_id = ?", airportID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	offset := (page - 1) * pageSize
+	if err := query.Omit("changes", "snapshot").Order("id DESC").Offset(offset).Limit(pageSize).Find(&changesets).Error; err != nil {
+		return nil, 0, err
+	}
+	return changesets, total, nil
+}
+
+// GetAirportChangeset 获取单条变更记录
+func GetAirportChangeset(airportID, id int) (*AirportChangeset, error) {
+	var changeset AirportChangeset
+	if err := database.DB.Where("id = ? AND airport_id = ?", id, airportID).First(&changeset).Error; err != nil {
+		return nil, err
+	}
+	return &changeset, nil
+}
+
+// DeleteAirportChangesets 删除机场的全部变更记录
+func DeleteAirportChangesets(airportID int) error {
+	return database.DB.Where("airport_id = ?", airportID).Delete(&AirportChangeset{}).Error
+}
+
+// RollbackAirport 将机场节点回滚到指定变更记录的快照
+// 快照中有而当前没有的节点会按快照重建（保留标签、检测结果和订阅关联），当前多出的节点会被删除
+// 回滚本身也会记录为一条变更
+func RollbackAirport(airportID, changesetID int) (*AirportChangeset, error) {
+	airport, err := GetAirportByID(airportID)
+	if err != nil {
+		return nil, fmt.Errorf("机场不存在")
+	}
+	target, err := GetAirportChangeset(airportID, changesetID)
+	if err != nil {
+		return nil, fmt.Errorf("变更记录不存在")
+	}
+
+	current, err := ListBySourceID(airportID)
+	if err != nil {
+		return nil, err
+	}
+	currentLinks := make(map[string]bool, len(current))
+	for _, n := range current {
+		currentLinks[n.Link] = true
+	}
+
+	snapshot := target.SnapshotNodes()
+	snapshotLinks := make(map[string]bool, len(snapshot))
+	snapshotMemberships := make(map[string][]AirportNodeMembership)
+	nodesToAdd := make([]Node, 0)
+	for _, s := range snapshot {
+		snapshotLinks[s.Link] = true
+		if len(s.Subscriptions) > 0 {
+			snapshotMemberships[s.Link] = s.Subscriptions
+		}
+		if !currentLinks[s.Link] {
+			nodesToAdd = append(nodesToAdd, s.toNode(airport))
+		}
+	}
+	nodesToDelete := make([]Node, 0)
+	for _, n := range current {
+		if !snapshotLinks[n.Link] {
+			nodesToDelete = append(nodesToDelete, n)
+		}
+	}
+
+	// 按机场的身份匹配方式原地恢复链接变化的节点，保留订阅关联
+	matched, nodesToDelete, nodesToAdd := airport.MatchNodeIdentity(nodesToDelete, nodesToAdd)
+
+	idsToDelete := make([]int, 0, len(nodesToDelete))
+	for _, n := range nodesToDelete {
+		idsToDelete = append(idsToDelete, n.ID)
+	}
+
+	// 原地还原、删除、重建与订阅关联恢复在同一事务中完成，任一步失败时节点保持回滚前的状态
+	restoredMemberships := 0
+	now := time.Now()
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range matched {
+			matched[i].UpdatedAt = now
+			if err := tx.Model(&matched[i]).Select(airportNodeInPlaceFields).Updates(&matched[i]).Error; err != nil {
+				return fmt.Errorf("还原节点 [%s] 失败: %w", matched[i].Name, err)
+			}
+		}
+		if len(idsToDelete) > 0 {
+			if err := tx.Exec("DELETE FROM subcription_nodes WHERE node_id IN ?", idsToDelete).Error; err != nil {
+				return fmt.Errorf("删除节点失败: %w", err)
+			}
+			if err := deleteNodeTagExpiry(tx, idsToDelete); err != nil {
+				return fmt.Errorf("删除节点失败: %w", err)
+			}
+			if err := tx.Where("id IN ?", idsToDelete).Delete(&Node{}).Error; err != nil {
+				return fmt.Errorf("删除节点失败: %w", err)
+			}
+		}
+		for _, chunk := range chunkNodes(nodesToAdd, database.BatchSize) {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "link"}},
+				DoNothing: true,
+			}).Create(&chunk).Error; err != nil {
+				return fmt.Errorf("恢复节点失败: %w", err)
+			}
+		}
+		// 重建的节点ID已变化，按快照恢复其订阅关联
+		for _, n := range nodesToAdd {
+			if n.ID == 0 {
+				continue
+			}
+			count, err := restoreNodeSubscriptionMemberships(tx, n.ID, snapshotMemberships[n.Link])
+			if err != nil {
+				return fmt.Errorf("恢复节点 [%s] 的订阅关联失败: %w", n.Name, err)
+			}
+			restoredMemberships += count
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 事务成功后更新缓存
+	for i := range matched {
+		setNodeCacheInPlace(&matched[i])
+	}
+	for _, id := range idsToDelete {
+		nodeCache.Delete(id)
+	}
+	for _, n := range nodesToAdd {
+		if n.ID > 0 {
+			nodeCache.Set(n.ID, n)
+		}
+	}
+
+	after, err := ListBySourceID(airportID)
+	if err != nil {
+		return nil, err
+	}
+
+	utils.Info("机场【%s】已回滚到变更记录 #%d：恢复 %d 个节点（订阅关联 %d 条），原地还原 %d 个节点，删除 %d 个节点", airport.Name, target.ID, len(nodesToAdd), restoredMemberships, len(matched), len(idsToDelete))
+	return RecordAirportChangeset(airportID, AirportChangeRollback, current, after, nil, fmt.Sprintf("回滚至 #%d", target.ID))
+}
+
+// restoreNodeSubscriptionMemberships 为重建的节点恢复订阅关联，跳过已删除的订阅，返回恢复的条数
+func restoreNodeSubscriptionMemberships(tx *gorm.DB, nodeID int, memberships []AirportNodeMembership) (int, error) {
+	if len(memberships) == 0 {
+		return 0, nil
+	}
+	subIDs := make([]int, 0, len(memberships))
+	for _, m := range memberships {
+		subIDs = append(subIDs, m.SubcriptionID)
+	}
+	var existingIDs []int
+	if err := tx.Model(&Subcription{}).Where("id IN ?", subIDs).Pluck("id", &existingIDs).Error; err != nil {
+		return 0, err
+	}
+	existing := make(map[int]bool, len(existingIDs))
+	for _, id := range existingIDs {
+		existing[id] = true
+	}
+
+	rows := make([]SubcriptionNode, 0, len(memberships))
+	for _, m := range memberships {
+		if existing[m.SubcriptionID] {
+			rows = append(rows, SubcriptionNode{SubcriptionID: m.SubcriptionID, NodeID: nodeID, Sort: m.Sort})
+		}
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+		return 0, err
+	}
+	return len(rows), nil
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"sublink/database"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupModelsTestDB 初始化内存数据库，name 区分不同测试的数据库
+func setupModelsTestDB(t *testing.T, name string) *gorm.DB {
+	t.Helper()
+	t.Setenv("SUBLINK_API_ENCRYPTION_KEY", "models-test-key")
+	db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	database.DB = db
+	database.IsInitialized = false
+	RunMigrations()
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+// airportNodeLinks 机场当前节点的链接（数据库中的记录）
+func airportNodeLinks(t *testing.T, airportID int) []string {
+	t.Helper()
+	var links []string
+	if err := database.DB.Model(&Node{}).Where("source_id = ?", airportID).Order("link").Pluck("link", &links).Error; err != nil {
+		t.Fatalf("查询节点失败: %v", err)
+	}
+	return links
+}
+
+// TestRollbackAirport 测试回滚在同一事务中完成：重建节点失败时不丢失节点，成功时恢复订阅关联
+func TestRollbackAirport(t *testing.T) {
+	db := setupModelsTestDB(t, "rollback_test")
+
+	airport := &Airport{Name: "回滚测试机场", URL: "https://example.com/sub"}
+	if err := airport.Add(); err != nil {
+		t.Fatalf("添加机场失败: %v", err)
+	}
+	sub := &Subcription{Name: "回滚测试订阅"}
+	if err := db.Create(sub).Error; err != nil {
+		t.Fatalf("添加订阅失败: %v", err)
+	}
+
+	const linkA, linkB, linkC = "trojan://p@1.1.1.1:443#A", "trojan://p@2.2.2.2:443#B", "trojan://p@3.3.3.3:443#C"
+	newNode := func(name, link string) Node {
+		return Node{Name: name, LinkName: name, Link: link, Source: airport.Name, SourceID: airport.ID}
+	}
+	if err := BatchAddNodes([]Node{newNode("A", linkA), newNode("B", linkB)}); err != nil {
+		t.Fatalf("添加节点失败: %v", err)
+	}
+	before, _ := ListBySourceID(airport.ID)
+	var nodeB Node
+	for _, n := range before {
+		if n.Link == linkB {
+			nodeB = n
+		}
+	}
+	if err := db.Create(&SubcriptionNode{SubcriptionID: sub.ID, NodeID: nodeB.ID, Sort: 3}).Error; err != nil {
+		t.Fatalf("添加订阅关联失败: %v", err)
+	}
+	target, err := RecordAirportChangeset(airport.ID, AirportChangeSync, nil, before, nil, "")
+	if err != nil || target == nil {
+		t.Fatalf("记录变更失败: %v", err)
+	}
+
+	// 模拟一次同步：B 被删除，新增 C
+	if err := BatchDel([]int{nodeB.ID}); err != nil {
+		t.Fatalf("删除节点失败: %v", err)
+	}
+	if err := BatchAddNodes([]Node{newNode("C", linkC)}); err != nil {
+		t.Fatalf("添加节点失败: %v", err)
+	}
+
+	// 重建节点时写入失败：整个回滚撤销，C 不会被删除
+	const failCallback = "test:fail_node_create"
+	if err := db.Callback().Create().Before("gorm:create").Register(failCallback, func(tx *gorm.DB) {
+		if tx.Statement.Table == "nodes" {
+			tx.AddError(errors.New("注入的写入错误"))
+		}
+	}); err != nil {
+		t.Fatalf("注册回调失败: %v", err)
+	}
+	if _, err := RollbackAirport(airport.ID, target.ID); err == nil {
+		t.Fatal("重建节点失败时回滚应返回错误")
+	}
+	if got := airportNodeLinks(t, airport.ID); len(got) != 2 || got[0] != linkA || got[1] != linkC {
+		t.Fatalf("回滚失败后节点应保持不变, 实际: %v", got)
+	}
+	if cached, _ := ListBySourceID(airport.ID); len(cached) != 2 {
+		t.Errorf("回滚失败后缓存应保持不变, 实际 %d 个节点", len(cached))
+	}
+	if err := db.Callback().Create().Remove(failCallback); err != nil {
+		t.Fatalf("移除回调失败: %v", err)
+	}
+
+	// 正常回滚：恢复 B 及其订阅关联，删除 C
+	if _, err := RollbackAirport(airport.ID, target.ID); err != nil {
+		t.Fatalf("回滚失败: %v", err)
+	}
+	if got := airportNodeLinks(t, airport.ID); len(got) != 2 || got[0] != linkA || got[1] != linkB {
+		t.Fatalf("回滚后节点 = %v, 期望 [A B]", got)
+	}
+	after, _ := ListBySourceID(airport.ID)
+	sort.Slice(after, func(i, j int) bool { return after[i].Link < after[j].Link })
+	if len(after) != 2 || after[1].Link != linkB || after[1].ID == nodeB.ID {
+		t.Fatalf("缓存中应为重建的 B 节点, 实际: %+v", after)
+	}
+	memberships := GetNodeSubscriptionMemberships([]int{after[1].ID})[after[1].ID]
+	if len(memberships) != 1 || memberships[0].SubcriptionID != sub.ID || memberships[0].Sort != 3 {
+		t.Errorf("重建的 B 应恢复订阅关联, 实际: %+v", memberships)
+	}
+}
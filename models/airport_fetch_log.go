@@ -0,0 +1,190 @@
+package models
+
+import (
+	"sublink/database"
+	"sublink/utils"
+	"time"
+)
+
+// airportFetchAttemptKeep 每个机场保留的拉取记录数量
+const airportFetchAttemptKeep = 50
+
+// 拉取失败分类
+const (
+	FetchErrorDNS        = "dns"        // 域名解析失败
+	FetchErrorTLS        = "tls"        // TLS 握手或证书错误
+	FetchErrorTimeout    = "timeout"    // 超时
+	FetchErrorConnection = "connection" // 连接被拒绝、重置等
+	FetchErrorProxy      = "proxy"      // 代理连接失败
+	FetchErrorHTTP4xx    = "http_4xx"   // HTTP 4xx
+	FetchErrorHTTP5xx    = "http_5xx"   // HTTP 5xx
+	FetchErrorRead       = "read"       // 读取响应失败
+	FetchErrorParse      = "parse"      // 解析失败或未找到节点
+	FetchErrorOther      = "other"      // 其他错误
+)
+
+// 机场健康状态
+const (
+	AirportHealthUnknown  = "unknown"  // 暂无拉取记录
+	AirportHealthHealthy  = "healthy"  // 正常
+	AirportHealthDegraded = "degraded" // 成功率偏低
+	AirportHealthFailing  = "failing"  // 连续失败
+)
+
+// AirportFetchAttempt 机场订阅拉取记录（每次请求一条）
+type AirportFetchAttempt struct {
+	ID         int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	AirportID  int       `gorm:"index" json:"airportId"`
+	URL        string    `json:"url"`
+	Success    bool      `json:"success"`
+	Unchanged  bool      `json:"unchanged"`                 // 内容未变化（304 或哈希一致）
+	StatusCode int       `json:"statusCode"`                // HTTP 状态码，请求未完成时为 0
+	Bytes      int64     `json:"bytes"`                     // 响应大小
+	Duration   int64     `json:"duration"`                  // 耗时(ms)
+	NodeCount  int       `json:"nodeCount"`                 // 解析出的节点数
+	Proxy      string    `json:"proxy"`                     // 使用的代理，空表示直连
+	ErrorClass string    `gorm:"size:20" json:"errorClass"` // 失败分类
+	Error      string    `gorm:"type:text" json:"error"`
+	CreatedAt  time.Time `gorm:"autoCreateTime;index" json:"createdAt"`
+}
+
+// TableName 指定表名
+func (AirportFetchAttempt) TableName() string {
+	return "airport_fetch_attempts"
+}
+
+// RecordAirportFetchAttempt 记录一次拉取，并只保留最近的记录
+func RecordAirportFetchAttempt(attempt *AirportFetchAttempt) error {
+	if err := database.DB.Create(attempt).Error; err != nil {
+		return err
+	}
+	pruneAirportFetchAttempts(attempt.AirportID)
+	return nil
+}
+
+// pruneAirportFetchAttempts 只保留最近的拉取记录
+func pruneAirportFetchAttempts(airportID int) {
+	var keepIDs []int
+	if err := database.DB.Model(&AirportFetchAttempt{}).Where("airport_id = ?", airportID).
+		Order("id DESC").Limit(airportFetchAttemptKeep).Pluck("id", &keepIDs).Error; err != nil || len(keepIDs) < airportFetchAttemptKeep {
+		return
+	}
+	minID := keepIDs[len(keepIDs)-1]
+	if err := database.DB.Where("airport_id = ? AND id < ?", airportID, minID).Delete(&AirportFetchAttempt{}).Error; err != nil {
+		utils.Warn("清理机场 %d 的旧拉取记录失败: %v", airportID, err)
+	}
+}
+
+// ListAirportFetchAttempts 获取机场最近的拉取记录（按时间倒序）
+func ListAirportFetchAttempts(airportID int, limit int) ([]AirportFetchAttempt, error) {
+	var attempts []AirportFetchAttempt
+	err := database.DB.Where("airport_id = ?", airportID).Order("id DESC").Limit(limit).Find(&attempts).Error
+	return attempts, err
+}
+
+// DeleteAirportFetchAttempts 删除机场的拉取记录
+func DeleteAirportFetchAttempts(airportID int) error {
+	return database.DB.Where("airport_id = ?", airportID).Delete(&AirportFetchAttempt{}).Error
+}
+
+// AirportHealthSummary 机场拉取健康汇总
+type AirportHealthSummary struct {
+	AirportID        int            `json:"airportId"`
+	Name             string         `json:"name"`
+	Enabled          bool           `json:"enabled"`
+	Status           string         `json:"status"`           // unknown / healthy / degraded / failing
+	Attempts         int            `json:"attempts"`         // 统计的拉取次数
+	Successes        int            `json:"successes"`        // 成功次数
+	SuccessRate      float64        `json:"successRate"`      // 成功率(%)
+	AvgDuration      int64          `json:"avgDuration"`      // 成功拉取的平均耗时(ms)
+	ConsecutiveFails int            `json:"consecutiveFails"` // 最近连续失败次数
+	LastAttemptAt    *time.Time     `json:"lastAttemptAt"`
+	LastSuccessAt    *time.Time     `json:"lastSuccessAt"`
+	LastError        string         `json:"lastError"`
+	TopErrorClass    string         `json:"topErrorClass"` // 最常见的失败分类
+	TopErrorCount    int            `json:"topErrorCount"`
+	ErrorClasses     map[string]int `json:"errorClasses"` // 各失败分类的次数
+}
+
+// GetAirportHealthSummary 根据最近的拉取记录汇总机场健康状况
+func GetAirportHealthSummary(a *Airport) (*AirportHealthSummary, error) {
+	attempts, err := ListAirportFetchAttempts(a.ID, airportFetchAttemptKeep)
+	if err != nil {
+		return nil, err
+	}
+	return summarizeAirportFetchAttempts(a, attempts), nil
+}
+
+// summarizeAirportFetchAttempts 汇总拉取记录，attempts 按时间倒序
+func summarizeAirportFetchAttempts(a *Airport, attempts []AirportFetchAttempt) *AirportHealthSummary {
+	s := &AirportHealthSummary{
+		AirportID:    a.ID,
+		Name:         a.Name,
+		Enabled:      a.Enabled,
+		Status:       AirportHealthUnknown,
+		Attempts:     len(attempts),
+		ErrorClasses: make(map[string]int),
+	}
+	if len(attempts) == 0 {
+		return s
+	}
+	s.LastAttemptAt = &attempts[0].CreatedAt
+
+	var totalDuration int64
+	countingFails := true
+	for i := range attempts {
+		at := &attempts[i]
+		if at.Success {
+			s.Successes++
+			totalDuration += at.Duration
+			if s.LastSuccessAt == nil {
+				s.LastSuccessAt = &at.CreatedAt
+			}
+			countingFails = false
+			continue
+		}
+		if countingFails {
+			s.ConsecutiveFails++
+		}
+		if s.LastError == "" {
+			s.LastError = at.Error
+		}
+		s.ErrorClasses[at.ErrorClass]++
+	}
+	s.SuccessRate = float64(s.Successes) * 100 / float64(s.Attempts)
+	if s.Successes > 0 {
+		s.AvgDuration = totalDuration / int64(s.Successes)
+	}
+	for class, count := range s.ErrorClasses {
+		if count > s.TopErrorCount || (count == s.TopErrorCount && class < s.TopErrorClass) {
+			s.TopErrorClass, s.TopErrorCount = class, count
+		}
+	}
+
+	switch {
+	case s.ConsecutiveFails >= 3:
+		s.Status = AirportHealthFailing
+	case s.SuccessRate < 80:
+		s.Status = AirportHealthDegraded
+	default:
+		s.Status = AirportHealthHealthy
+	}
+	return s
+}
+
+// ListAirportHealthSummaries 汇总所有机场的健康状况
+func ListAirportHealthSummaries() ([]AirportHealthSummary, error) {
+	airports, err := new(Airport).List()
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]AirportHealthSummary, 0, len(airports))
+	for i := range airports {
+		s, err := GetAirportHealthSummary(&airports[i])
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, *s)
+	}
+	return summaries, nil
+}
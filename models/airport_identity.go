@@ -0,0 +1,152 @@
+package models
+
+import (
+	"strings"
+	"sublink/database"
+	"sublink/utils"
+	"time"
+)
+
+// 机场同步时识别同一节点的方式
+const (
+	AirportIdentityLink   = ""       // 按链接（默认，链接变化视为新节点）
+	AirportIdentityName   = "name"   // 按原始名称
+	AirportIdentityServer = "server" // 按服务器地址+端口
+	AirportIdentityFields = "fields" // 按自定义字段组合
+)
+
+// IsValidAirportIdentityMatch 检查身份匹配方式是否有效
+func IsValidAirportIdentityMatch(mode string) bool {
+	switch mode {
+	case AirportIdentityLink, AirportIdentityName, AirportIdentityServer, AirportIdentityFields:
+		return true
+	}
+	return false
+}
+
+// identityFieldList 解析自定义匹配字段
+func (a *Airport) identityFieldList() []string {
+	var fields []string
+	for _, f := range strings.Split(a.IdentityFields, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// NodeIdentityKey 按机场配置的匹配方式生成节点身份标识
+// 无法生成（未启用或字段为空）时返回空字符串
+func (a *Airport) NodeIdentityKey(n *Node) string {
+	if a == nil {
+		return ""
+	}
+	switch a.IdentityMatch {
+	case AirportIdentityName:
+		return n.LinkName
+	case AirportIdentityServer:
+		return strings.ToLower(n.LinkAddress)
+	case AirportIdentityFields:
+		fields := a.identityFieldList()
+		parts := make([]string, 0, len(fields))
+		hasValue := false
+		for _, field := range fields {
+			value := n.GetFieldValue(field)
+			if value != "" {
+				hasValue = true
+			}
+			parts = append(parts, field+":"+value)
+		}
+		if !hasValue {
+			return ""
+		}
+		return strings.Join(parts, "|")
+	}
+	return ""
+}
+
+// MatchNodeIdentity 将本次缺失的旧节点与新增节点按身份标识配对
+// 配对成功的旧节点更新为新链接（保留 ID、名称、标签、前置代理、订阅关联和检测记录）
+// 返回待原地更新的节点，以及未配对的缺失节点和新增节点
+func (a *Airport) MatchNodeIdentity(missing []Node, incoming []Node) (matched []Node, restMissing []Node, restIncoming []Node) {
+	if a == nil || a.IdentityMatch == AirportIdentityLink || len(missing) == 0 || len(incoming) == 0 {
+		return nil, missing, incoming
+	}
+
+	candidates := make(map[string][]Node)
+	for _, n := range missing {
+		if key := a.NodeIdentityKey(&n); key != "" {
+			candidates[key] = append(candidates[key], n)
+		}
+	}
+
+	matchedIDs := make(map[int]bool)
+	for _, n := range incoming {
+		key := a.NodeIdentityKey(&n)
+		queue := candidates[key]
+		if key == "" || len(queue) == 0 {
+			restIncoming = append(restIncoming, n)
+			continue
+		}
+		old := queue[0]
+		candidates[key] = queue[1:]
+		matchedIDs[old.ID] = true
+
+		// 未手动改名的节点跟随机场更新名称
+		if old.Name == old.LinkName {
+			old.Name = n.Name
+		}
+		old.Link = n.Link
+		old.LinkName = n.LinkName
+		old.LinkAddress = n.LinkAddress
+		old.LinkHost = n.LinkHost
+		old.LinkPort = n.LinkPort
+		old.Protocol = n.Protocol
+		old.SyncMissCount = 0
+		matched = append(matched, old)
+	}
+
+	for _, n := range missing {
+		if !matchedIDs[n.ID] {
+			restMissing = append(restMissing, n)
+		}
+	}
+	return matched, restMissing, restIncoming
+}
+
+// UpdateAirportNodesInPlace 原地更新身份匹配成功的节点链接
+// 返回成功更新的数量，单个节点失败只记录日志
+func UpdateAirportNodesInPlace(nodes []Node) int {
+	updated := 0
+	for i := range nodes {
+		n := &nodes[i]
+		n.UpdatedAt = time.Now()
+		err := database.DB.Model(n).Select(airportNodeInPlaceFields).Updates(n).Error
+		if err != nil {
+			utils.Error("节点 [%s] 原地更新失败: %v", n.Name, err)
+			continue
+		}
+		setNodeCacheInPlace(n)
+		updated++
+	}
+	return updated
+}
+
+// airportNodeInPlaceFields 原地更新时写入的节点字段
+var airportNodeInPlaceFields = []string{"Name", "Link", "LinkName", "LinkAddress", "LinkHost", "LinkPort", "Protocol", "SyncMissCount", "UpdatedAt"}
+
+// setNodeCacheInPlace 原地更新写入数据库后同步缓存
+func setNodeCacheInPlace(n *Node) {
+	if cachedNode, ok := nodeCache.Get(n.ID); ok {
+		cachedNode.Name = n.Name
+		cachedNode.Link = n.Link
+		cachedNode.LinkName = n.LinkName
+		cachedNode.LinkAddress = n.LinkAddress
+		cachedNode.LinkHost = n.LinkHost
+		cachedNode.LinkPort = n.LinkPort
+		cachedNode.Protocol = n.Protocol
+		cachedNode.SyncMissCount = n.SyncMissCount
+		cachedNode.UpdatedAt = n.UpdatedAt
+		nodeCache.Set(n.ID, cachedNode)
+	}
+}
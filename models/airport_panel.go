@@ -0,0 +1,178 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"sublink/config"
+	"sublink/database"
+	"sublink/utils"
+	"time"
+)
+
+// 机场面板类型
+const (
+	AirportPanelNone    = ""        // 未启用
+	AirportPanelV2Board = "v2board" // V2Board
+	AirportPanelXboard  = "xboard"  // Xboard（接口与 V2Board 兼容）
+	AirportPanelSSPanel = "sspanel" // SSPanel / SSPanel-Uim
+)
+
+// IsValidAirportPanelType 检查面板类型是否有效
+func IsValidAirportPanelType(t string) bool {
+	switch t {
+	case AirportPanelNone, AirportPanelV2Board, AirportPanelXboard, AirportPanelSSPanel:
+		return true
+	}
+	return false
+}
+
+// HasPanel 是否启用了面板账号
+func (a *Airport) HasPanel() bool {
+	return a.PanelType != AirportPanelNone && a.PanelURL != "" && a.PanelEmail != "" && a.PanelPassword != ""
+}
+
+// panelSecretKey 面板凭证的加密密钥
+func panelSecretKey() []byte {
+	return []byte(config.GetAPIEncryptionKey())
+}
+
+// SetPanelPassword 加密并设置面板密码，同时清除已保存的登录凭证（不写库）
+func (a *Airport) SetPanelPassword(plain string) error {
+	encrypted, err := utils.EncryptString(plain, panelSecretKey())
+	if err != nil {
+		return err
+	}
+	a.PanelPassword = encrypted
+	a.PanelAuth = ""
+	return nil
+}
+
+// GetPanelPassword 解密面板密码
+func (a *Airport) GetPanelPassword() (string, error) {
+	return utils.DecryptString(a.PanelPassword, panelSecretKey())
+}
+
+// GetPanelAuth 解密已保存的登录凭证，解密失败视为无凭证
+func (a *Airport) GetPanelAuth() string {
+	auth, err := utils.DecryptString(a.PanelAuth, panelSecretKey())
+	if err != nil {
+		return ""
+	}
+	return auth
+}
+
+// SavePanelAuth 加密保存登录凭证 (Write-Through)
+func (a *Airport) SavePanelAuth(auth string) error {
+	encrypted, err := utils.EncryptString(auth, panelSecretKey())
+	if err != nil {
+		return err
+	}
+	if err := database.DB.Model(&Airport{}).Where("id = ?", a.ID).Update("panel_auth", encrypted).Error; err != nil {
+		return err
+	}
+	a.PanelAuth = encrypted
+	if cached, ok := airportCache.Get(a.ID); ok {
+		cached.PanelAuth = encrypted
+		airportCache.Set(a.ID, cached)
+	}
+	return nil
+}
+
+// SavePanelState 保存面板同步结果 (Write-Through)
+// syncErr 为空表示同步成功，此时更新套餐信息与同步时间；失败时只记录原因
+func (a *Airport) SavePanelState(plan string, resetDay int, syncErr string) error {
+	updates := map[string]interface{}{"PanelError": syncErr}
+	fields := []string{"PanelError"}
+	var now time.Time
+	if syncErr == "" {
+		now = time.Now()
+		updates["PanelPlan"] = plan
+		updates["PanelResetDay"] = resetDay
+		updates["PanelSyncAt"] = &now
+		fields = append(fields, "PanelPlan", "PanelResetDay", "PanelSyncAt")
+	}
+	if err := database.DB.Model(a).Select(fields).Updates(updates).Error; err != nil {
+		return err
+	}
+	apply := func(t *Airport) {
+		t.PanelError = syncErr
+		if syncErr == "" {
+			t.PanelPlan, t.PanelResetDay, t.PanelSyncAt = plan, resetDay, &now
+		}
+	}
+	apply(a)
+	if cached, ok := airportCache.Get(a.ID); ok {
+		apply(&cached)
+		airportCache.Set(a.ID, cached)
+	}
+	return nil
+}
+
+// RotateURL 将主地址替换为面板返回的新订阅地址 (Write-Through)
+// 原主地址保留为第一个备用地址，新地址不可用时仍可回退；同时清除可用地址与条件拉取状态，下次拉取将完整处理
+func (a *Airport) RotateURL(newURL string) error {
+	mirrors := []string{}
+	if old := strings.TrimSpace(a.URL); old != "" && old != newURL {
+		mirrors = append(mirrors, old)
+	}
+	for _, u := range ParseAirportMirrorURLs(a.MirrorURLs) {
+		if u != newURL && (len(mirrors) == 0 || u != mirrors[0]) {
+			mirrors = append(mirrors, u)
+		}
+	}
+	mirrorURLs := strings.Join(mirrors, "\n")
+
+	err := database.DB.Model(a).Select("URL", "MirrorURLs", "ActiveURL", "FetchETag", "FetchLastModified", "ContentHash", "FetchConfigHash").Updates(map[string]interface{}{
+		"URL":               newURL,
+		"MirrorURLs":        mirrorURLs,
+		"ActiveURL":         "",
+		"FetchETag":         "",
+		"FetchLastModified": "",
+		"ContentHash":       "",
+		"FetchConfigHash":   "",
+	}).Error
+	if err != nil {
+		return err
+	}
+	apply := func(t *Airport) {
+		t.URL, t.MirrorURLs, t.ActiveURL = newURL, mirrorURLs, ""
+		t.FetchETag, t.FetchLastModified, t.ContentHash, t.FetchConfigHash = "", "", "", ""
+	}
+	apply(a)
+	if cached, ok := airportCache.Get(a.ID); ok {
+		apply(&cached)
+		airportCache.Set(a.ID, cached)
+	}
+	return nil
+}
+
+// MergePanelSubscribeURL 合并面板返回的订阅地址与当前主地址
+// 面板地址中没有的查询参数（如 flag=clash）从当前地址保留，返回合并后的地址
+// 与当前地址等价（仅参数顺序不同）时原样返回当前地址；面板地址不是有效的 http(s) 地址时返回错误
+func MergePanelSubscribeURL(current, panelURL string) (string, error) {
+	next, err := url.Parse(strings.TrimSpace(panelURL))
+	if err != nil || (next.Scheme != "http" && next.Scheme != "https") || next.Host == "" {
+		return "", fmt.Errorf("面板返回的订阅地址无效: %s", panelURL)
+	}
+	cur, err := url.Parse(strings.TrimSpace(current))
+	if err != nil || current == "" {
+		return next.String(), nil
+	}
+	query := next.Query()
+	changed := false
+	for k, v := range cur.Query() {
+		if _, ok := query[k]; !ok {
+			query[k] = v
+			changed = true
+		}
+	}
+	if changed {
+		next.RawQuery = query.Encode()
+	}
+	if next.Scheme == cur.Scheme && next.Host == cur.Host && next.Path == cur.Path && reflect.DeepEqual(query, cur.Query()) {
+		return current, nil
+	}
+	return next.String(), nil
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"fmt"
+	"sublink/database"
+
+	"gorm.io/gorm"
+)
+
+// CheckSyncGuard 检查本次同步结果是否触发安全同步保护
+// existing: 同步前的节点数，incoming: 本次获取到的节点数，missing: 本次缺失（将被删除）的节点数
+// 触发时返回原因，未触发返回空字符串
+func (a *Airport) CheckSyncGuard(existing, incoming, missing int) string {
+	if a == nil {
+		return ""
+	}
+	if a.MinNodeCount > 0 && incoming < a.MinNodeCount {
+		return fmt.Sprintf("本次获取到 %d 个节点，少于设定的最少节点数 %d", incoming, a.MinNodeCount)
+	}
+	if a.MaxDeleteRatio > 0 && existing > 0 && missing*100 > existing*a.MaxDeleteRatio {
+		return fmt.Sprintf("本次将删除 %d/%d 个节点（%.0f%%），超过设定的最大删除比例 %d%%",
+			missing, existing, float64(missing)*100/float64(existing), a.MaxDeleteRatio)
+	}
+	return ""
+}
+
+// SplitHeldDeletions 按删除宽限拆分本次缺失的节点
+// 连续缺失次数未超过宽限次数的节点暂不删除
+// 返回可删除的节点ID与暂缓删除的节点ID
+func (a *Airport) SplitHeldDeletions(missing []Node) (deletable []int, held []int) {
+	grace := 0
+	if a != nil {
+		grace = a.DeleteGraceSyncs
+	}
+	for _, n := range missing {
+		if n.SyncMissCount+1 > grace {
+			deletable = append(deletable, n.ID)
+		} else {
+			held = append(held, n.ID)
+		}
+	}
+	return deletable, held
+}
+
+// HasHeldDeletions 机场是否有处于删除宽限期（已缺失但暂未删除）的节点
+func HasHeldDeletions(airportID int) bool {
+	nodes, err := ListBySourceID(airportID)
+	if err != nil {
+		return false
+	}
+	for _, n := range nodes {
+		if n.SyncMissCount > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// IncrementNodeSyncMissCount 节点连续缺失次数加一
+func IncrementNodeSyncMissCount(ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	err := database.DB.Model(&Node{}).Where("id IN ?", ids).
+		Update("sync_miss_count", gorm.Expr("sync_miss_count + 1")).Error
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if cachedNode, ok := nodeCache.Get(id); ok {
+			cachedNode.SyncMissCount++
+			nodeCache.Set(id, cachedNode)
+		}
+	}
+	return nil
+}
+
+// ResetNodeSyncMissCount 节点重新出现后清零连续缺失次数
+func ResetNodeSyncMissCount(ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	err := database.DB.Model(&Node{}).Where("id IN ?", ids).Update("sync_miss_count", 0).Error
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if cachedNode, ok := nodeCache.Get(id); ok {
+			cachedNode.SyncMissCount = 0
+			nodeCache.Set(id, cachedNode)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sublink/database"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 机场多地址模式
+const (
+	AirportURLModeFailover = ""      // 故障转移（默认）：按顺序尝试，成功即止
+	AirportURLModeMerge    = "merge" // 合并：拉取所有地址并合并节点
+)
+
+// IsValidAirportURLMode 检查多地址模式是否有效
+func IsValidAirportURLMode(mode string) bool {
+	return mode == AirportURLModeFailover || mode == "failover" || mode == AirportURLModeMerge
+}
+
+// ParseAirportMirrorURLs 解析备用地址列表（换行或逗号分隔），去除空行
+func ParseAirportMirrorURLs(s string) []string {
+	var urls []string
+	for _, line := range strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == '\r' || r == ',' }) {
+		if line = strings.TrimSpace(line); line != "" {
+			urls = append(urls, line)
+		}
+	}
+	return urls
+}
+
+// AllURLs 返回主地址与备用地址（按配置顺序，去重）
+func (a *Airport) AllURLs() []string {
+	seen := make(map[string]bool)
+	var urls []string
+	for _, u := range append([]string{strings.TrimSpace(a.URL)}, ParseAirportMirrorURLs(a.MirrorURLs)...) {
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+		urls = append(urls, u)
+	}
+	return urls
+}
+
+// IsMergeURLMode 是否为合并模式
+func (a *Airport) IsMergeURLMode() bool {
+	return a.URLMode == AirportURLModeMerge
+}
+
+// OrderedURLs 返回本次拉取的尝试顺序
+// 故障转移模式下优先使用最近一次成功的地址（粘性），其余按配置顺序
+func (a *Airport) OrderedURLs() []string {
+	urls := a.AllURLs()
+	if a.IsMergeURLMode() || a.ActiveURL == "" {
+		return urls
+	}
+	for i, u := range urls {
+		if u == a.ActiveURL {
+			ordered := append([]string{u}, urls[:i]...)
+			return append(ordered, urls[i+1:]...)
+		}
+	}
+	return urls
+}
+
+// SetActiveURL 记录最近一次拉取成功的地址 (Write-Through)
+func (a *Airport) SetActiveURL(u string) error {
+	if err := database.DB.Model(&Airport{}).Where("id = ?", a.ID).Update("active_url", u).Error; err != nil {
+		return err
+	}
+	a.ActiveURL = u
+	if cached, ok := airportCache.Get(a.ID); ok {
+		cached.ActiveURL = u
+		airportCache.Set(a.ID, cached)
+	}
+	return nil
+}
+
+// SaveFetchState 保存条件拉取状态 (Write-Through)
+// 传入空值即清除，下次拉取将完整处理
+func (a *Airport) SaveFetchState(etag, lastModified, contentHash, configHash string) error {
+	err := database.DB.Model(a).Select("FetchETag", "FetchLastModified", "ContentHash", "FetchConfigHash").Updates(map[string]interface{}{
+		"FetchETag":         etag,
+		"FetchLastModified": lastModified,
+		"ContentHash":       contentHash,
+		"FetchConfigHash":   configHash,
+	}).Error
+	if err != nil {
+		return err
+	}
+	apply := func(t *Airport) {
+		t.FetchETag, t.FetchLastModified, t.ContentHash, t.FetchConfigHash = etag, lastModified, contentHash, configHash
+	}
+	apply(a)
+	if cached, ok := airportCache.Get(a.ID); ok {
+		apply(&cached)
+		airportCache.Set(a.ID, cached)
+	}
+	return nil
+}
+
+// SyncConfigHash 计算影响节点导入结果的配置哈希：机场导入规则与启用了 airport_sync 钩子的脚本
+// 订阅内容未变化但配置变化时，同样需要完整处理
+func (a *Airport) SyncConfigHash() string {
+	h := sha256.New()
+	for _, v := range []string{
+		a.ImportIncludeName, a.ImportExcludeName, a.ImportIncludeProtocols, a.ImportExcludeProtocols,
+		a.ImportIncludePorts, a.ImportExcludePorts, a.ImportNamePreprocess, a.ImportScript,
+	} {
+		h.Write([]byte(v))
+		h.Write([]byte{0})
+	}
+	for _, script := range ListScriptsByHook(ScriptHookAirportSync) {
+		src := script.Source()
+		params, _ := json.Marshal(src.Params)
+		fmt.Fprintf(h, "%d\x00%s\x00", script.ID, script.Version)
+		h.Write([]byte(script.Content))
+		h.Write([]byte{0})
+		h.Write(params)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// AirportURLHealth 机场单个订阅地址的健康状态
+type AirportURLHealth struct {
+	ID               int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	AirportID        int        `gorm:"uniqueIndex:idx_airport_url_health" json:"airportId"`
+	URL              string     `gorm:"uniqueIndex:idx_airport_url_health" json:"url"`
+	SuccessCount     int        `gorm:"default:0" json:"successCount"`     // 累计成功次数
+	FailCount        int        `gorm:"default:0" json:"failCount"`        // 累计失败次数
+	ConsecutiveFails int        `gorm:"default:0" json:"consecutiveFails"` // 连续失败次数
+	LastError        string     `json:"lastError"`                         // 最近一次失败原因
+	LastDuration     int64      `json:"lastDuration"`                      // 最近一次拉取耗时(ms)
+	LastSuccessAt    *time.Time `json:"lastSuccessAt"`
+	LastFailAt       *time.Time `json:"lastFailAt"`
+	UpdatedAt        time.Time  `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (AirportURLHealth) TableName() string {
+	return "airport_url_health"
+}
+
+// RecordAirportURLResult 记录一次地址拉取结果
+func RecordAirportURLResult(airportID int, u string, fetchErr error, duration time.Duration) error {
+	now := time.Now()
+	h := AirportURLHealth{AirportID: airportID, URL: u, LastDuration: duration.Milliseconds()}
+	updates := map[string]interface{}{
+		"last_duration": h.LastDuration,
+		"updated_at":    now,
+	}
+	if fetchErr == nil {
+		h.SuccessCount = 1
+		h.LastSuccessAt = &now
+		updates["success_count"] = gorm.Expr("success_count + 1")
+		updates["consecutive_fails"] = 0
+		updates["last_success_at"] = now
+	} else {
+		h.FailCount = 1
+		h.ConsecutiveFails = 1
+		h.LastError = fetchErr.Error()
+		h.LastFailAt = &now
+		updates["fail_count"] = gorm.Expr("fail_count + 1")
+		updates["consecutive_fails"] = gorm.Expr("consecutive_fails + 1")
+		updates["last_error"] = h.LastError
+		updates["last_fail_at"] = now
+	}
+	return database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "airport_id"}, {Name: "url"}},
+		DoUpdates: clause.Assignments(updates),
+	}).Create(&h).Error
+}
+
+// ListAirportURLHealth 获取机场各地址的健康状态，按当前配置的地址顺序返回
+// 尚未拉取过的地址返回空记录
+func ListAirportURLHealth(airport *Airport) ([]AirportURLHealth, error) {
+	var records []AirportURLHealth
+	if err := database.DB.Where("airport_id = ?", airport.ID).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	byURL := make(map[string]AirportURLHealth, len(records))
+	for _, r := range records {
+		byURL[r.URL] = r
+	}
+	result := make([]AirportURLHealth, 0, len(records))
+	for _, u := range airport.AllURLs() {
+		if r, ok := byURL[u]; ok {
+			result = append(result, r)
+		} else {
+			result = append(result, AirportURLHealth{AirportID: airport.ID, URL: u})
+		}
+	}
+	return result, nil
+}
+
+// DeleteAirportURLHealth 删除机场的地址健康记录
+func DeleteAirportURLHealth(airportID int) error {
+	return database.DB.Where("airport_id = ?", airportID).Delete(&AirportURLHealth{}).Error
+}
@@ -0,0 +1,241 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"sublink/database"
+	"sublink/utils"
+	"time"
+)
+
+const (
+	airportUsageKeepDays     = 90               // 用量历史保留天数
+	airportUsageMinInterval  = 30 * time.Minute // 用量未重置时两条记录的最小间隔
+	airportUsageBurnWindow   = 7 * 24 * time.Hour
+	airportUsageMinBurnSpan  = 6 * time.Hour // 计算消耗速度所需的最短记录跨度
+	defaultUsageAlertPercent = 80
+	defaultExpireAlertDays   = 3
+)
+
+// 机场用量告警类型
+const (
+	AirportUsageAlertPercent  = "usage_percent"    // 已用流量达到阈值
+	AirportUsageAlertForecast = "forecast_exhaust" // 预计在到期前耗尽
+	AirportUsageAlertExpire   = "expire_soon"      // 即将到期
+)
+
+// AirportUsageRecord 机场用量历史记录
+type AirportUsageRecord struct {
+	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	AirportID int       `gorm:"index" json:"airportId"`
+	Upload    int64     `json:"upload"`
+	Download  int64     `json:"download"`
+	Total     int64     `json:"total"`
+	Expire    int64     `json:"expire"`
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"createdAt"`
+}
+
+// TableName 指定表名
+func (AirportUsageRecord) TableName() string {
+	return "airport_usage_records"
+}
+
+// Used 已用流量
+func (r AirportUsageRecord) Used() int64 {
+	return r.Upload + r.Download
+}
+
+// recordAirportUsage 追加一条用量历史
+// 距上次记录不足最小间隔且未发生重置/续费时跳过，避免频繁拉取产生大量记录
+func recordAirportUsage(airportID int, upload, download, total, expire int64) error {
+	var last AirportUsageRecord
+	err := database.DB.Where("airport_id = ?", airportID).Order("created_at DESC").Limit(1).Find(&last).Error
+	if err != nil {
+		return err
+	}
+	if last.ID > 0 && time.Since(last.CreatedAt) < airportUsageMinInterval &&
+		upload+download >= last.Used() && total == last.Total && expire == last.Expire {
+		return nil
+	}
+
+	record := AirportUsageRecord{AirportID: airportID, Upload: upload, Download: download, Total: total, Expire: expire}
+	if err := database.DB.Create(&record).Error; err != nil {
+		return err
+	}
+	cutoff := time.Now().AddDate(0, 0, -airportUsageKeepDays)
+	return database.DB.Where("airport_id = ? AND created_at < ?", airportID, cutoff).Delete(&AirportUsageRecord{}).Error
+}
+
+// ListAirportUsageRecords 获取机场最近若干天的用量历史（按时间升序）
+func ListAirportUsageRecords(airportID int, days int) ([]AirportUsageRecord, error) {
+	var records []AirportUsageRecord
+	since := time.Now().AddDate(0, 0, -days)
+	err := database.DB.Where("airport_id = ? AND created_at >= ?", airportID, since).
+		Order("created_at ASC").Find(&records).Error
+	return records, err
+}
+
+// DeleteAirportUsageRecords 删除机场的用量历史
+func DeleteAirportUsageRecords(airportID int) error {
+	return database.DB.Where("airport_id = ?", airportID).Delete(&AirportUsageRecord{}).Error
+}
+
+// AirportUsageForecast 机场用量预测
+type AirportUsageForecast struct {
+	Used                int64      `json:"used"`                // 已用流量（字节）
+	Total               int64      `json:"total"`               // 总流量（字节），0 表示不限
+	Remaining           int64      `json:"remaining"`           // 剩余流量（字节）
+	UsedPercent         float64    `json:"usedPercent"`         // 已用比例(%)
+	DailyBurn           int64      `json:"dailyBurn"`           // 日均消耗（字节/天），0 表示数据不足
+	SampleHours         float64    `json:"sampleHours"`         // 计算消耗速度使用的记录跨度（小时）
+	DaysLeft            float64    `json:"daysLeft"`            // 按当前速度可用天数，-1 表示无法预测
+	ExhaustAt           *time.Time `json:"exhaustAt"`           // 预计耗尽时间
+	ExpireAt            *time.Time `json:"expireAt"`            // 到期时间
+	ExhaustBeforeExpire bool       `json:"exhaustBeforeExpire"` // 是否预计在到期前耗尽
+}
+
+// GetAirportUsageForecast 根据用量历史计算消耗速度并预测耗尽时间
+func GetAirportUsageForecast(a *Airport) (*AirportUsageForecast, error) {
+	records, err := ListAirportUsageRecords(a.ID, int(airportUsageBurnWindow.Hours()/24))
+	if err != nil {
+		return nil, err
+	}
+	return computeAirportUsageForecast(a, records, time.Now()), nil
+}
+
+// computeAirportUsageForecast 计算用量预测
+// 消耗速度取最近一次流量重置之后、窗口期内首尾两条记录的差值
+func computeAirportUsageForecast(a *Airport, records []AirportUsageRecord, now time.Time) *AirportUsageForecast {
+	f := &AirportUsageForecast{
+		Used:     a.UsageUpload + a.UsageDownload,
+		Total:    a.UsageTotal,
+		DaysLeft: -1,
+	}
+	if f.Total > 0 {
+		f.Remaining = max(f.Total-f.Used, 0)
+		f.UsedPercent = float64(f.Used) * 100 / float64(f.Total)
+	}
+	if a.UsageExpire > 0 {
+		expireAt := time.Unix(a.UsageExpire, 0)
+		f.ExpireAt = &expireAt
+	}
+
+	start := 0
+	for i := 1; i < len(records); i++ {
+		if records[i].Used() < records[i-1].Used() {
+			start = i
+		}
+	}
+	if len(records)-start >= 2 {
+		first, last := records[start], records[len(records)-1]
+		span := last.CreatedAt.Sub(first.CreatedAt)
+		f.SampleHours = span.Hours()
+		if span >= airportUsageMinBurnSpan && last.Used() > first.Used() {
+			f.DailyBurn = int64(float64(last.Used()-first.Used()) / span.Hours() * 24)
+		}
+	}
+
+	if f.Total > 0 && f.DailyBurn > 0 {
+		f.DaysLeft = float64(f.Remaining) / float64(f.DailyBurn)
+		exhaustAt := now.Add(time.Duration(f.DaysLeft * 24 * float64(time.Hour)))
+		f.ExhaustAt = &exhaustAt
+		if f.ExpireAt != nil && exhaustAt.Before(*f.ExpireAt) {
+			f.ExhaustBeforeExpire = true
+		}
+	}
+	return f
+}
+
+// AirportUsageAlert 待发送的用量告警
+type AirportUsageAlert struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// usageAlertPercent 实际生效的已用比例阈值，0 表示关闭
+func (a *Airport) usageAlertPercent() int {
+	switch {
+	case a.UsageAlertPercent < 0:
+		return 0
+	case a.UsageAlertPercent == 0:
+		return defaultUsageAlertPercent
+	}
+	return a.UsageAlertPercent
+}
+
+// expireAlertDays 实际生效的到期提醒天数，0 表示关闭
+func (a *Airport) expireAlertDays() int {
+	switch {
+	case a.ExpireAlertDays < 0:
+		return 0
+	case a.ExpireAlertDays == 0:
+		return defaultExpireAlertDays
+	}
+	return a.ExpireAlertDays
+}
+
+// CheckUsageAlerts 检查用量告警，返回本次需要发送的告警并记录已发送状态
+// 同一周期（到期时间不变）内每类告警只发送一次；续费或流量重置后重新计算
+func (a *Airport) CheckUsageAlerts(f *AirportUsageForecast, prevUsed int64) ([]AirportUsageAlert, error) {
+	sent := make(map[string]bool)
+	if a.UsageAlertCycle == a.UsageExpire && f.Used >= prevUsed {
+		for _, t := range strings.Split(a.UsageAlertSent, ",") {
+			if t != "" {
+				sent[t] = true
+			}
+		}
+	}
+
+	active := make(map[string]string)
+	if threshold := a.usageAlertPercent(); threshold > 0 && f.Total > 0 && f.UsedPercent >= float64(threshold) {
+		active[AirportUsageAlertPercent] = fmt.Sprintf("已用流量 %.1f%%，超过告警阈值 %d%%", f.UsedPercent, threshold)
+	}
+	if !a.DisableForecastAlert && f.ExhaustBeforeExpire {
+		active[AirportUsageAlertForecast] = fmt.Sprintf("按近期日均消耗 %s/天，预计 %s 耗尽，早于到期时间 %s",
+			utils.FormatBytes(f.DailyBurn), f.ExhaustAt.Format("2006-01-02"), f.ExpireAt.Format("2006-01-02"))
+	}
+	if days := a.expireAlertDays(); days > 0 && f.ExpireAt != nil {
+		if remain := time.Until(*f.ExpireAt); remain > 0 && remain <= time.Duration(days)*24*time.Hour {
+			active[AirportUsageAlertExpire] = fmt.Sprintf("订阅将于 %s 到期（剩余 %.1f 天）", f.ExpireAt.Format("2006-01-02 15:04"), remain.Hours()/24)
+		}
+	}
+
+	var alerts []AirportUsageAlert
+	var newSent []string
+	for _, t := range []string{AirportUsageAlertPercent, AirportUsageAlertForecast, AirportUsageAlertExpire} {
+		msg, ok := active[t]
+		if !ok {
+			// 条件解除后允许再次触发
+			continue
+		}
+		newSent = append(newSent, t)
+		if !sent[t] {
+			alerts = append(alerts, AirportUsageAlert{Type: t, Message: msg})
+		}
+	}
+
+	state := strings.Join(newSent, ",")
+	if state != a.UsageAlertSent || a.UsageAlertCycle != a.UsageExpire {
+		if err := a.saveUsageAlertState(a.UsageExpire, state); err != nil {
+			return alerts, err
+		}
+	}
+	return alerts, nil
+}
+
+// saveUsageAlertState 保存告警发送状态 (Write-Through)
+func (a *Airport) saveUsageAlertState(cycle int64, sent string) error {
+	err := database.DB.Model(a).Select("UsageAlertCycle", "UsageAlertSent").Updates(map[string]interface{}{
+		"UsageAlertCycle": cycle,
+		"UsageAlertSent":  sent,
+	}).Error
+	if err != nil {
+		return err
+	}
+	a.UsageAlertCycle, a.UsageAlertSent = cycle, sent
+	if cached, ok := airportCache.Get(a.ID); ok {
+		cached.UsageAlertCycle, cached.UsageAlertSent = cycle, sent
+		airportCache.Set(a.ID, cached)
+	}
+	return nil
+}
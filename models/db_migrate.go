@@ -148,6 +148,51 @@ func RunMigrations() {
 	} else {
 		utils.Info("数据表NodeCheckProfile创建成功")
 	}
+	if err := db.AutoMigrate(&NodeCheckCheckpoint{}); err != nil {
+		utils.Error("基础数据表NodeCheckCheckpoint迁移失败: %v", err)
+	} else {
+		utils.Info("数据表NodeCheckCheckpoint创建成功")
+	}
+	if err := db.AutoMigrate(&NodeLifecycleEvent{}); err != nil {
+		utils.Error("基础数据表NodeLifecycleEvent迁移失败: %v", err)
+	} else {
+		utils.Info("数据表NodeLifecycleEvent创建成功")
+	}
+	if err := db.AutoMigrate(&AirportChangeset{}); err != nil {
+		utils.Error("基础数据表AirportChangeset迁移失败: %v", err)
+	} else {
+		utils.Info("数据表AirportChangeset创建成功")
+	}
+	if err := db.AutoMigrate(&AirportURLHealth{}); err != nil {
+		utils.Error("基础数据表AirportURLHealth迁移失败: %v", err)
+	} else {
+		utils.Info("数据表AirportURLHealth创建成功")
+	}
+	if err := db.AutoMigrate(&AirportUsageRecord{}); err != nil {
+		utils.Error("基础数据表AirportUsageRecord迁移失败: %v", err)
+	} else {
+		utils.Info("数据表AirportUsageRecord创建成功")
+	}
+	if err := db.AutoMigrate(&AirportFetchAttempt{}); err != nil {
+		utils.Error("基础数据表AirportFetchAttempt迁移失败: %v", err)
+	} else {
+		utils.Info("数据表AirportFetchAttempt创建成功")
+	}
+	if err := db.AutoMigrate(&ScriptKV{}); err != nil {
+		utils.Error("基础数据表ScriptKV迁移失败: %v", err)
+	} else {
+		utils.Info("数据表ScriptKV创建成功")
+	}
+	if err := db.AutoMigrate(&ScriptVersion{}); err != nil {
+		utils.Error("基础数据表ScriptVersion迁移失败: %v", err)
+	} else {
+		utils.Info("数据表ScriptVersion创建成功")
+	}
+	if err := db.AutoMigrate(&NodeTagExpiry{}); err != nil {
+		utils.Error("基础数据表NodeTagExpiry迁移失败: %v", err)
+	} else {
+		utils.Info("数据表NodeTagExpiry创建成功")
+	}
 
 	// 检查并删除 idx_name_id 索引
 	// 0000_drop_idx_name_id
@@ -782,6 +827,23 @@ DIRECT = direct
 		utils.Error("执行迁移 0019_fill_empty_node_protocol 失败: %v", err)
 	}
 
+	// 0020_seed_script_versions - 为已有脚本保存当前版本记录，供订阅固定版本
+	if err := database.RunCustomMigration("0020_seed_script_versions", func() error {
+		var scripts []Script
+		if err := db.Find(&scripts).Error; err != nil {
+			return fmt.Errorf("查询脚本失败: %w", err)
+		}
+		for i := range scripts {
+			if err := saveScriptVersion(&scripts[i]); err != nil {
+				return fmt.Errorf("保存脚本 %d 的版本记录失败: %w", scripts[i].ID, err)
+			}
+		}
+		utils.Info("已为 %d 个脚本保存版本记录", len(scripts))
+		return nil
+	}); err != nil {
+		utils.Error("执行迁移 0020_seed_script_versions 失败: %v", err)
+	}
+
 	// 初始化用户数据
 	err := db.First(&User{}).Error
 	if err == gorm.ErrRecordNotFound {
@@ -1,10 +1,7 @@
 package models
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"sublink/cache"
 	"sublink/database"
 	"sublink/utils"
@@ -28,6 +25,7 @@ type IPInfo struct {
 	ISP         string    `json:"isp"`                           // ISP提供商
 	Org         string    `json:"org"`                           // 组织
 	AS          string    `json:"as"`                            // AS号
+	IPType      string    `json:"ipType"`                        // IP类型: residential, datacenter, mobile
 	RawResponse string    `gorm:"type:text" json:"-"`            // 原始JSON响应
 	Provider    string    `json:"provider"`                      // 数据提供商
 	CreatedAt   time.Time `gorm:"autoCreateTime" json:"createdAt"`
@@ -132,8 +130,8 @@ func GetIPInfo(ip string) (*IPInfo, error) {
 		close(lockChan)
 	}()
 
-	// 4. 从第三方API获取
-	info, err := fetchIPInfoFromAPI(ip)
+	// 4. 按配置的提供商顺序查询（失败或超出限速时自动回退）
+	info, err := lookupIPInfoWithProviders(ip)
 	if err != nil {
 		return nil, err
 	}
@@ -157,73 +155,3 @@ func GetIPInfo(ip string) (*IPInfo, error) {
 
 	return info, nil
 }
-
-// ipAPIResponse ip-api.com API响应结构
-type ipAPIResponse struct {
-	Status      string  `json:"status"`
-	Message     string  `json:"message"`
-	Country     string  `json:"country"`
-	CountryCode string  `json:"countryCode"`
-	Region      string  `json:"region"`
-	RegionName  string  `json:"regionName"`
-	City        string  `json:"city"`
-	Zip         string  `json:"zip"`
-	Lat         float64 `json:"lat"`
-	Lon         float64 `json:"lon"`
-	Timezone    string  `json:"timezone"`
-	ISP         string  `json:"isp"`
-	Org         string  `json:"org"`
-	AS          string  `json:"as"`
-	Query       string  `json:"query"`
-}
-
-// fetchIPInfoFromAPI 从第三方API获取IP信息
-func fetchIPInfoFromAPI(ip string) (*IPInfo, error) {
-	// 使用 ip-api.com（支持中文，免费）
-	url := fmt.Sprintf("http://ip-api.com/json/%s?lang=zh-CN&fields=status,message,country,countryCode,region,regionName,city,zip,lat,lon,timezone,isp,org,as,query", ip)
-
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	resp, err := client.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("请求IP信息API失败: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("读取API响应失败: %w", err)
-	}
-
-	var apiResp ipAPIResponse
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return nil, fmt.Errorf("解析API响应失败: %w", err)
-	}
-
-	if apiResp.Status != "success" {
-		return nil, fmt.Errorf("API返回错误: %s", apiResp.Message)
-	}
-
-	// 转换为IPInfo结构
-	info := &IPInfo{
-		IP:          ip,
-		Country:     apiResp.Country,
-		CountryCode: apiResp.CountryCode,
-		Region:      apiResp.Region,
-		RegionName:  apiResp.RegionName,
-		City:        apiResp.City,
-		Zip:         apiResp.Zip,
-		Lat:         apiResp.Lat,
-		Lon:         apiResp.Lon,
-		Timezone:    apiResp.Timezone,
-		ISP:         apiResp.ISP,
-		Org:         apiResp.Org,
-		AS:          apiResp.AS,
-		RawResponse: string(body),
-		Provider:    "ip-api.com",
-	}
-
-	return info, nil
-}
@@ -0,0 +1,595 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sublink/services/geoip"
+	"sublink/utils"
+	"sync"
+	"time"
+)
+
+// IP 类型
+const (
+	IPTypeResidential = "residential" // 住宅/家宽
+	IPTypeDatacenter  = "datacenter"  // 机房/云服务商
+	IPTypeMobile      = "mobile"      // 移动网络
+)
+
+// IP 信息提供商类型
+const (
+	IPProviderMaxMind = "maxmind" // 本地 MaxMind 数据库（services/geoip）
+	IPProviderIPAPI   = "ip-api"  // ip-api.com
+	IPProviderIPInfo  = "ipinfo"  // ipinfo.io
+	IPProviderCustom  = "custom"  // 自定义 JSON 接口
+)
+
+// settingIPInfoProviders IP 信息提供商配置在系统设置中的键名（JSON 数组，顺序即回退顺序）
+const settingIPInfoProviders = "ip_info_providers"
+
+// IPInfoProvider IP 信息提供商
+type IPInfoProvider interface {
+	// Name 提供商名称（用于日志与限速）
+	Name() string
+	// Lookup 查询 IP 信息
+	Lookup(ip string) (*IPInfo, error)
+}
+
+// IPInfoProviderConfig 单个提供商的配置
+type IPInfoProviderConfig struct {
+	Type      string            `json:"type"`              // maxmind / ip-api / ipinfo / custom
+	Name      string            `json:"name"`              // 显示名称（custom 类型用于区分多个接口）
+	Enabled   bool              `json:"enabled"`           // 是否启用
+	RateLimit int               `json:"rateLimit"`         // 每分钟最大请求数(0=不限)
+	Token     string            `json:"token,omitempty"`   // ipinfo 访问令牌
+	URL       string            `json:"url,omitempty"`     // custom: 请求地址，{ip} 为 IP 占位符
+	Headers   map[string]string `json:"headers,omitempty"` // custom: 额外请求头
+	Fields    map[string]string `json:"fields,omitempty"`  // custom: IPInfo 字段 -> JSON 路径（如 data.geo.country_code）
+}
+
+// DisplayName 获取提供商显示名称
+func (c IPInfoProviderConfig) DisplayName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Type
+}
+
+// DefaultIPInfoProviderConfigs 默认提供商配置：仅使用本地数据库，落地IP不会发送给第三方
+// 在线接口（ip-api 为明文 HTTP）需用户手动启用，作为本地数据库查询失败时的回退
+func DefaultIPInfoProviderConfigs() []IPInfoProviderConfig {
+	return []IPInfoProviderConfig{
+		{Type: IPProviderMaxMind, Enabled: true},
+		{Type: IPProviderIPAPI, Enabled: false, RateLimit: 40},
+		{Type: IPProviderIPInfo, Enabled: false, RateLimit: 50},
+	}
+}
+
+// GetIPInfoProviderConfigs 读取提供商配置，未配置时返回默认配置
+func GetIPInfoProviderConfigs() []IPInfoProviderConfig {
+	raw, _ := GetSetting(settingIPInfoProviders)
+	if raw == "" {
+		return DefaultIPInfoProviderConfigs()
+	}
+	var configs []IPInfoProviderConfig
+	if err := json.Unmarshal([]byte(raw), &configs); err != nil {
+		utils.Warn("解析IP信息提供商配置失败，使用默认配置: %v", err)
+		return DefaultIPInfoProviderConfigs()
+	}
+	return configs
+}
+
+// SaveIPInfoProviderConfigs 校验并保存提供商配置
+func SaveIPInfoProviderConfigs(configs []IPInfoProviderConfig) error {
+	for _, c := range configs {
+		if _, err := newIPInfoProvider(c); err != nil {
+			return fmt.Errorf("%s: %v", c.DisplayName(), err)
+		}
+	}
+	data, err := json.Marshal(configs)
+	if err != nil {
+		return err
+	}
+	return SetSetting(settingIPInfoProviders, string(data))
+}
+
+// TestIPInfoProvider 使用指定配置直接查询（不使用缓存，不计入限速）
+func TestIPInfoProvider(config IPInfoProviderConfig, ip string) (*IPInfo, error) {
+	provider, err := newIPInfoProvider(config)
+	if err != nil {
+		return nil, err
+	}
+	info, err := provider.Lookup(ip)
+	if err != nil {
+		return nil, err
+	}
+	fillIPType(info)
+	return info, nil
+}
+
+// newIPInfoProvider 根据配置创建提供商实例
+func newIPInfoProvider(config IPInfoProviderConfig) (IPInfoProvider, error) {
+	switch config.Type {
+	case IPProviderMaxMind:
+		return maxMindProvider{}, nil
+	case IPProviderIPAPI:
+		return ipAPIProvider{}, nil
+	case IPProviderIPInfo:
+		return ipInfoIOProvider{token: config.Token}, nil
+	case IPProviderCustom:
+		if !strings.Contains(config.URL, "{ip}") {
+			return nil, fmt.Errorf("自定义接口地址必须包含 {ip} 占位符")
+		}
+		if len(config.Fields) == 0 {
+			return nil, fmt.Errorf("自定义接口必须配置字段映射")
+		}
+		return customJSONProvider{config: config}, nil
+	default:
+		return nil, fmt.Errorf("不支持的提供商类型: %s", config.Type)
+	}
+}
+
+// lookupIPInfoWithProviders 按配置顺序依次查询，失败或超出限速时回退到下一个提供商
+func lookupIPInfoWithProviders(ip string) (*IPInfo, error) {
+	var errs []string
+	for _, config := range GetIPInfoProviderConfigs() {
+		if !config.Enabled {
+			continue
+		}
+		provider, err := newIPInfoProvider(config)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", config.DisplayName(), err))
+			continue
+		}
+		if !getProviderRateLimiter(config.DisplayName()).allow(config.RateLimit) {
+			utils.Debug("IP信息提供商 %s 已达到限速，尝试下一个", config.DisplayName())
+			errs = append(errs, fmt.Sprintf("%s: 超出限速", config.DisplayName()))
+			continue
+		}
+		info, err := provider.Lookup(ip)
+		if err != nil {
+			utils.Debug("IP信息提供商 %s 查询 %s 失败: %v", config.DisplayName(), ip, err)
+			errs = append(errs, fmt.Sprintf("%s: %v", config.DisplayName(), err))
+			continue
+		}
+		info.IP = ip
+		fillIPType(info)
+		return info, nil
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("没有可用的IP信息提供商")
+	}
+	return nil, fmt.Errorf("所有IP信息提供商均查询失败: %s", strings.Join(errs, "; "))
+}
+
+// ================================================================================
+// 限速
+// ================================================================================
+
+// providerRateLimiter 按分钟计数的固定窗口限速器
+type providerRateLimiter struct {
+	mu          sync.Mutex
+	windowStart time.Time
+	count       int
+}
+
+var providerRateLimiters sync.Map // 提供商名称 -> *providerRateLimiter
+
+func getProviderRateLimiter(name string) *providerRateLimiter {
+	limiter, _ := providerRateLimiters.LoadOrStore(name, &providerRateLimiter{})
+	return limiter.(*providerRateLimiter)
+}
+
+// allow 当前窗口是否还有额度（limit<=0 表示不限）
+func (l *providerRateLimiter) allow(limit int) bool {
+	if limit <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if now.Sub(l.windowStart) >= time.Minute {
+		l.windowStart = now
+		l.count = 0
+	}
+	if l.count >= limit {
+		return false
+	}
+	l.count++
+	return true
+}
+
+// ================================================================================
+// IP 类型判断
+// ================================================================================
+
+// datacenterASNs 常见云服务商/机房的 AS 号（提供商未给出类型时用于推断）
+var datacenterASNs = map[string]bool{
+	"AS16509":  true, // Amazon
+	"AS14618":  true, // Amazon
+	"AS15169":  true, // Google
+	"AS396982": true, // Google Cloud
+	"AS8075":   true, // Microsoft
+	"AS31898":  true, // Oracle Cloud
+	"AS45102":  true, // Alibaba Cloud
+	"AS37963":  true, // Alibaba Cloud
+	"AS132203": true, // Tencent Cloud
+	"AS45090":  true, // Tencent Cloud
+	"AS14061":  true, // DigitalOcean
+	"AS63949":  true, // Linode / Akamai
+	"AS20473":  true, // Vultr (Choopa)
+	"AS16276":  true, // OVH
+	"AS24940":  true, // Hetzner
+	"AS60781":  true, // Leaseweb
+	"AS28753":  true, // Leaseweb
+	"AS51167":  true, // Contabo
+	"AS9009":   true, // M247
+	"AS13335":  true, // Cloudflare
+	"AS54113":  true, // Fastly
+	"AS25820":  true, // IT7 Networks (Bandwagon)
+	"AS906":    true, // DMIT
+}
+
+// datacenterOrgPattern 机房/云服务商的组织名称（按完整单词匹配，避免 "cloud"、"server" 这类宽泛词误判运营商）
+var datacenterOrgPattern = regexp.MustCompile(`(?i)\b(` + strings.Join([]string{
+	`hosting`, `data ?cent(er|re)`, `colocation`, `vps`,
+	`amazon(\.com| technologies| web services)`, `aws`, `google (llc|cloud)`, `microsoft (corporation|azure)`, `azure`,
+	`oracle (corporation|cloud)`, `alibaba cloud`, `aliyun`, `tencent cloud`, `digitalocean`, `linode`, `akamai`,
+	`vultr`, `choopa`, `ovh`, `hetzner`, `leaseweb`, `contabo`, `m247`, `cloudflare`, `fastly`,
+	`it7 networks`, `dmit`, `kirino`,
+}, "|") + `)\b`)
+
+// asnPattern 从 "AS13335 Cloudflare, Inc." 中提取 AS 号
+var asnPattern = regexp.MustCompile(`(?i)^AS(\d+)`)
+
+// ASNumber 获取规范化的 AS 号，如 AS13335
+func (info *IPInfo) ASNumber() string {
+	if m := asnPattern.FindStringSubmatch(strings.TrimSpace(info.AS)); m != nil {
+		return "AS" + m[1]
+	}
+	return ""
+}
+
+// fillIPType 提供商未给出 IP 类型时，按 AS 号与 ISP/组织名称推断是否为机房 IP
+// 无法判断时保持为空，不推断为住宅
+func fillIPType(info *IPInfo) {
+	if info.IPType != "" {
+		return
+	}
+	if datacenterASNs[info.ASNumber()] || datacenterOrgPattern.MatchString(info.ISP+" "+info.Org+" "+info.AS) {
+		info.IPType = IPTypeDatacenter
+	}
+}
+
+// ================================================================================
+// 提供商实现
+// ================================================================================
+
+// ipInfoHTTPClient 各在线提供商共用的 HTTP 客户端
+var ipInfoHTTPClient = &http.Client{Timeout: 5 * time.Second}
+
+// fetchJSON 请求接口并返回响应体
+func fetchJSON(url string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := ipInfoHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+// maxMindProvider 本地 MaxMind 数据库
+type maxMindProvider struct{}
+
+func (maxMindProvider) Name() string { return IPProviderMaxMind }
+
+func (maxMindProvider) Lookup(ip string) (*IPInfo, error) {
+	result, err := geoip.Lookup(ip)
+	if err != nil {
+		return nil, err
+	}
+	if result.CountryCode == "" && result.ASN == 0 {
+		return nil, fmt.Errorf("数据库中没有该IP的信息")
+	}
+	info := &IPInfo{
+		IP:          ip,
+		Country:     result.Country,
+		CountryCode: result.CountryCode,
+		Region:      result.RegionCode,
+		RegionName:  result.RegionName,
+		City:        result.City,
+		Lat:         result.Lat,
+		Lon:         result.Lon,
+		Timezone:    result.Timezone,
+		ISP:         result.ISP,
+		Org:         result.ASOrganization,
+		Provider:    "maxmind",
+	}
+	if result.ASN > 0 {
+		info.AS = fmt.Sprintf("AS%d %s", result.ASN, result.ASOrganization)
+	}
+	switch result.UserType {
+	case "residential":
+		info.IPType = IPTypeResidential
+	case "cellular":
+		info.IPType = IPTypeMobile
+	case "hosting", "content_delivery_network":
+		info.IPType = IPTypeDatacenter
+	}
+	return info, nil
+}
+
+// ipAPIProvider ip-api.com（免费接口仅支持 HTTP，限速 45 次/分钟）
+type ipAPIProvider struct{}
+
+func (ipAPIProvider) Name() string { return IPProviderIPAPI }
+
+// ipAPIResponse ip-api.com API响应结构
+type ipAPIResponse struct {
+	Status      string  `json:"status"`
+	Message     string  `json:"message"`
+	Country     string  `json:"country"`
+	CountryCode string  `json:"countryCode"`
+	Region      string  `json:"region"`
+	RegionName  string  `json:"regionName"`
+	City        string  `json:"city"`
+	Zip         string  `json:"zip"`
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
+	Timezone    string  `json:"timezone"`
+	ISP         string  `json:"isp"`
+	Org         string  `json:"org"`
+	AS          string  `json:"as"`
+	Mobile      bool    `json:"mobile"`
+	Hosting     bool    `json:"hosting"`
+	Query       string  `json:"query"`
+}
+
+func (ipAPIProvider) Lookup(ip string) (*IPInfo, error) {
+	url := fmt.Sprintf("http://ip-api.com/json/%s?lang=zh-CN&fields=status,message,country,countryCode,region,regionName,city,zip,lat,lon,timezone,isp,org,as,mobile,hosting,query", ip)
+	body, err := fetchJSON(url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var apiResp ipAPIResponse
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, fmt.Errorf("解析API响应失败: %w", err)
+	}
+	if apiResp.Status != "success" {
+		return nil, fmt.Errorf("API返回错误: %s", apiResp.Message)
+	}
+
+	info := &IPInfo{
+		IP:          ip,
+		Country:     apiResp.Country,
+		CountryCode: apiResp.CountryCode,
+		Region:      apiResp.Region,
+		RegionName:  apiResp.RegionName,
+		City:        apiResp.City,
+		Zip:         apiResp.Zip,
+		Lat:         apiResp.Lat,
+		Lon:         apiResp.Lon,
+		Timezone:    apiResp.Timezone,
+		ISP:         apiResp.ISP,
+		Org:         apiResp.Org,
+		AS:          apiResp.AS,
+		RawResponse: string(body),
+		Provider:    "ip-api.com",
+	}
+	// 接口只给出移动网络与机房标记，两者都不是时无法确定为住宅，类型留空
+	switch {
+	case apiResp.Mobile:
+		info.IPType = IPTypeMobile
+	case apiResp.Hosting:
+		info.IPType = IPTypeDatacenter
+	}
+	return info, nil
+}
+
+// ipInfoIOProvider ipinfo.io
+type ipInfoIOProvider struct {
+	token string
+}
+
+func (ipInfoIOProvider) Name() string { return IPProviderIPInfo }
+
+// ipInfoIOResponse ipinfo.io API响应结构（asn/privacy 仅付费计划返回）
+type ipInfoIOResponse struct {
+	IP       string `json:"ip"`
+	City     string `json:"city"`
+	Region   string `json:"region"`
+	Country  string `json:"country"`
+	Loc      string `json:"loc"`
+	Org      string `json:"org"`
+	Postal   string `json:"postal"`
+	Timezone string `json:"timezone"`
+	ASN      *struct {
+		ASN  string `json:"asn"`
+		Name string `json:"name"`
+		Type string `json:"type"`
+	} `json:"asn"`
+	Privacy *struct {
+		Hosting bool `json:"hosting"`
+	} `json:"privacy"`
+}
+
+func (p ipInfoIOProvider) Lookup(ip string) (*IPInfo, error) {
+	url := fmt.Sprintf("https://ipinfo.io/%s/json", ip)
+	if p.token != "" {
+		url += "?token=" + p.token
+	}
+	body, err := fetchJSON(url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var apiResp ipInfoIOResponse
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, fmt.Errorf("解析API响应失败: %w", err)
+	}
+	if apiResp.Country == "" {
+		return nil, fmt.Errorf("API未返回国家信息")
+	}
+
+	info := &IPInfo{
+		IP:          ip,
+		Country:     apiResp.Country,
+		CountryCode: apiResp.Country,
+		RegionName:  apiResp.Region,
+		City:        apiResp.City,
+		Zip:         apiResp.Postal,
+		Timezone:    apiResp.Timezone,
+		Org:         apiResp.Org,
+		AS:          apiResp.Org, // 免费接口的 org 形如 "AS13335 Cloudflare, Inc."
+		RawResponse: string(body),
+		Provider:    "ipinfo.io",
+	}
+	if lat, lon, ok := strings.Cut(apiResp.Loc, ","); ok {
+		info.Lat, _ = strconv.ParseFloat(lat, 64)
+		info.Lon, _ = strconv.ParseFloat(lon, 64)
+	}
+	if apiResp.ASN != nil {
+		info.AS = strings.TrimSpace(apiResp.ASN.ASN + " " + apiResp.ASN.Name)
+		info.ISP = apiResp.ASN.Name
+		switch apiResp.ASN.Type {
+		case "isp":
+			info.IPType = IPTypeResidential
+		case "hosting":
+			info.IPType = IPTypeDatacenter
+		}
+	}
+	if apiResp.Privacy != nil && apiResp.Privacy.Hosting {
+		info.IPType = IPTypeDatacenter
+	}
+	return info, nil
+}
+
+// customJSONProvider 自定义 JSON 接口，通过字段映射提取信息
+type customJSONProvider struct {
+	config IPInfoProviderConfig
+}
+
+func (p customJSONProvider) Name() string { return p.config.DisplayName() }
+
+func (p customJSONProvider) Lookup(ip string) (*IPInfo, error) {
+	body, err := fetchJSON(strings.ReplaceAll(p.config.URL, "{ip}", ip), p.config.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("解析API响应失败: %w", err)
+	}
+
+	info := &IPInfo{IP: ip, RawResponse: string(body), Provider: p.config.DisplayName()}
+	get := func(field string) string {
+		path, ok := p.config.Fields[field]
+		if !ok || path == "" {
+			return ""
+		}
+		if v, ok := jsonPathValue(data, path); ok {
+			return jsonValueString(v)
+		}
+		return ""
+	}
+
+	info.Country = get("country")
+	info.CountryCode = strings.ToUpper(get("countryCode"))
+	info.Region = get("region")
+	info.RegionName = get("regionName")
+	info.City = get("city")
+	info.Zip = get("zip")
+	info.Timezone = get("timezone")
+	info.ISP = get("isp")
+	info.Org = get("org")
+	info.AS = get("as")
+	info.Lat, _ = strconv.ParseFloat(get("lat"), 64)
+	info.Lon, _ = strconv.ParseFloat(get("lon"), 64)
+	if asn := get("asn"); asn != "" && info.AS == "" {
+		// 仅提供纯数字 AS 号时补全前缀
+		if _, err := strconv.Atoi(asn); err == nil {
+			asn = "AS" + asn
+		}
+		info.AS = asn
+	}
+	switch strings.ToLower(get("ipType")) {
+	case IPTypeResidential, "isp", "residential_proxy":
+		info.IPType = IPTypeResidential
+	case IPTypeDatacenter, "hosting", "dc":
+		info.IPType = IPTypeDatacenter
+	case IPTypeMobile, "cellular":
+		info.IPType = IPTypeMobile
+	}
+	if get("hosting") == "true" && info.IPType == "" {
+		info.IPType = IPTypeDatacenter
+	}
+
+	if info.CountryCode == "" && info.AS == "" {
+		return nil, fmt.Errorf("字段映射未提取到国家或AS信息")
+	}
+	return info, nil
+}
+
+// jsonPathValue 按点分路径读取 JSON 值，支持数组下标（如 data.list.0.country）
+func jsonPathValue(data interface{}, path string) (interface{}, bool) {
+	current := data
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			v, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			current = v
+		case []interface{}:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			current = node[idx]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// jsonValueString 将 JSON 值转换为字符串
+func jsonValueString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case bool:
+		return strconv.FormatBool(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		b, _ := json.Marshal(val)
+		return string(b)
+	}
+}
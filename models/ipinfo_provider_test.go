@@ -0,0 +1,31 @@
+package models
+
+import "testing"
+
+// TestFillIPType 测试按 AS 号与组织名称推断机房 IP，无法判断时类型留空
+func TestFillIPType(t *testing.T) {
+	tests := []struct {
+		name string
+		info IPInfo
+		want string
+	}{
+		{"机房 AS 号", IPInfo{AS: "AS16509 Amazon.com, Inc."}, IPTypeDatacenter},
+		{"机房 AS 号无名称", IPInfo{AS: "as14061"}, IPTypeDatacenter},
+		{"组织名称", IPInfo{ISP: "Hetzner Online GmbH"}, IPTypeDatacenter},
+		{"组织名称 hosting", IPInfo{Org: "Example Hosting Ltd"}, IPTypeDatacenter},
+		{"含 cloud 的运营商", IPInfo{ISP: "Cloud Broadband Ltd", AS: "AS9381 HKBN"}, ""},
+		{"含 server 的运营商", IPInfo{Org: "Server Kommunikation AB"}, ""},
+		{"包含关键字片段", IPInfo{ISP: "Hostinger Movement"}, ""},
+		{"家宽运营商", IPInfo{ISP: "China Telecom", AS: "AS4134 CHINANET-BACKBONE"}, ""},
+		{"已有类型不覆盖", IPInfo{AS: "AS13335 Cloudflare, Inc.", IPType: IPTypeMobile}, IPTypeMobile},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := tt.info
+			fillIPType(&info)
+			if info.IPType != tt.want {
+				t.Errorf("IPType = %q, 期望 %q", info.IPType, tt.want)
+			}
+		})
+	}
+}
@@ -27,6 +27,10 @@ type Node struct {
 	LinkPort        string //节点原始端口
 	LinkCountry     string //节点所属国家、落地IP国家
 	LandingIP       string //落地IP地址
+	LandingASN      string //落地IP所属AS号，如 AS13335
+	LandingISP      string //落地IP运营商
+	LandingIPType   string //落地IP类型: residential, datacenter, mobile
+	LandingCity     string //落地IP所在城市
 	DialerProxyName string
 	Source          string `gorm:"default:'manual'"`
 	SourceID        int
@@ -37,9 +41,16 @@ type Node struct {
 	DelayStatus     string    `gorm:"default:'untested'"` // 延迟测试状态: untested, success, timeout, error
 	LatencyCheckAt  string    // 延迟测试时间
 	SpeedCheckAt    string    // 测速时间
+	LifecycleState  string    `gorm:"default:'active';index"` // 生命周期状态: active, quarantined, flagged
+	FailStreak      int       `gorm:"default:0"`              // 连续检测失败次数
+	SyncMissCount   int       `gorm:"default:0"`              // 机场同步中连续缺失的次数（删除宽限）
+	QuarantinedAt   string    // 进入隔离状态的时间
 	CreatedAt       time.Time `gorm:"autoCreateTime" json:"CreatedAt"` // 创建时间
 	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"UpdatedAt"` // 更新时间
 	Tags            string    // 标签ID，逗号分隔，如 "1,3,5"
+
+	// 脚本钩子
+	ScriptScore float64 `gorm:"default:0"` // 节点检测钩子脚本计算的自定义评分
 }
 
 // nodeCache 使用新的泛型缓存，支持二级索引
@@ -127,7 +138,7 @@ func (node *Node) Update() error {
 
 // UpdateSpeed 更新节点测速结果
 func (node *Node) UpdateSpeed() error {
-	err := database.DB.Model(node).Select("Speed", "SpeedStatus", "LinkCountry", "LandingIP", "DelayTime", "DelayStatus", "LatencyCheckAt", "SpeedCheckAt").Updates(node).Error
+	err := database.DB.Model(node).Select("Speed", "SpeedStatus", "LinkCountry", "LandingIP", "LandingASN", "LandingISP", "LandingIPType", "LandingCity", "DelayTime", "DelayStatus", "LatencyCheckAt", "SpeedCheckAt").Updates(node).Error
 	if err != nil {
 		return err
 	}
@@ -141,6 +152,10 @@ func (node *Node) UpdateSpeed() error {
 		cachedNode.SpeedCheckAt = node.SpeedCheckAt
 		cachedNode.LinkCountry = node.LinkCountry
 		cachedNode.LandingIP = node.LandingIP
+		cachedNode.LandingASN = node.LandingASN
+		cachedNode.LandingISP = node.LandingISP
+		cachedNode.LandingCity = node.LandingCity
+		cachedNode.LandingIPType = node.LandingIPType
 		nodeCache.Set(node.ID, cachedNode)
 	}
 	return nil
@@ -157,6 +172,10 @@ type SpeedTestResult struct {
 	SpeedCheckAt   string
 	LinkCountry    string
 	LandingIP      string
+	LandingASN     string
+	LandingISP     string
+	LandingIPType  string
+	LandingCity    string
 }
 
 // BatchAddNodes 批量添加节点（高效 + 容错）
@@ -277,6 +296,10 @@ var speedResultFields = []speedResultField{
 	{"speed_check_at", func(r SpeedTestResult) string { return fmt.Sprintf("'%s'", escapeSQL(r.SpeedCheckAt)) }},
 	{"link_country", func(r SpeedTestResult) string { return fmt.Sprintf("'%s'", escapeSQL(r.LinkCountry)) }},
 	{"landing_ip", func(r SpeedTestResult) string { return fmt.Sprintf("'%s'", escapeSQL(r.LandingIP)) }},
+	{"landing_asn", func(r SpeedTestResult) string { return fmt.Sprintf("'%s'", escapeSQL(r.LandingASN)) }},
+	{"landing_isp", func(r SpeedTestResult) string { return fmt.Sprintf("'%s'", escapeSQL(r.LandingISP)) }},
+	{"landing_city", func(r SpeedTestResult) string { return fmt.Sprintf("'%s'", escapeSQL(r.LandingCity)) }},
+	{"landing_ip_type", func(r SpeedTestResult) string { return fmt.Sprintf("'%s'", escapeSQL(r.LandingIPType)) }},
 }
 
 // tryBatchUpdateWithCaseWhen 使用 CASE WHEN 批量更新（高效）
@@ -339,6 +362,10 @@ func batchUpdateNodeCache(chunk []SpeedTestResult) {
 			cachedNode.SpeedCheckAt = r.SpeedCheckAt
 			cachedNode.LinkCountry = r.LinkCountry
 			cachedNode.LandingIP = r.LandingIP
+			cachedNode.LandingASN = r.LandingASN
+			cachedNode.LandingISP = r.LandingISP
+			cachedNode.LandingCity = r.LandingCity
+			cachedNode.LandingIPType = r.LandingIPType
 			nodeCache.Set(r.NodeID, cachedNode)
 		}
 	}
@@ -357,6 +384,10 @@ func fallbackToIndividualSpeedUpdate(chunk []SpeedTestResult) int {
 			"speed_check_at":   r.SpeedCheckAt,
 			"link_country":     r.LinkCountry,
 			"landing_ip":       r.LandingIP,
+			"landing_asn":      r.LandingASN,
+			"landing_isp":      r.LandingISP,
+			"landing_ip_type":  r.LandingIPType,
+			"landing_city":     r.LandingCity,
 		}).Error
 
 		if err != nil {
@@ -375,6 +406,10 @@ func fallbackToIndividualSpeedUpdate(chunk []SpeedTestResult) int {
 			cachedNode.SpeedCheckAt = r.SpeedCheckAt
 			cachedNode.LinkCountry = r.LinkCountry
 			cachedNode.LandingIP = r.LandingIP
+			cachedNode.LandingASN = r.LandingASN
+			cachedNode.LandingISP = r.LandingISP
+			cachedNode.LandingCity = r.LandingCity
+			cachedNode.LandingIPType = r.LandingIPType
 			nodeCache.Set(r.NodeID, cachedNode)
 		}
 	}
@@ -530,6 +565,9 @@ type NodeFilter struct {
 	Tags        []string // 标签过滤（匹配任一标签的节点）
 	SortBy      string   // 排序字段: "delay" 或 "speed"
 	SortOrder   string   // 排序顺序: "asc" 或 "desc"
+
+	IPType string // 落地 IP 类型过滤: residential, datacenter, mobile
+	ASN    string // 落地 ASN 过滤（如 AS13335，不区分大小写）
 }
 
 // ListWithFilters 根据过滤条件获取节点列表
@@ -609,6 +647,16 @@ func (node *Node) ListWithFilters(filter NodeFilter) ([]Node, error) {
 			}
 		}
 
+		// 落地 IP 类型过滤
+		if filter.IPType != "" && n.LandingIPType != filter.IPType {
+			return false
+		}
+
+		// 落地 ASN 过滤
+		if filter.ASN != "" && !strings.EqualFold(n.LandingASN, filter.ASN) {
+			return false
+		}
+
 		// 标签过滤：节点需要包含至少一个所选标签
 		if len(tagMap) > 0 {
 			nodeTags := strings.Split(n.Tags, ",")
@@ -827,6 +875,9 @@ func (node *Node) Del() error {
 	if err := database.DB.Exec("DELETE FROM subcription_nodes WHERE node_id = ?", node.ID).Error; err != nil {
 		return err
 	}
+	if err := deleteNodeTagExpiry(database.DB, []int{node.ID}); err != nil {
+		return err
+	}
 	// Write-Through: 先删除数据库
 	err := database.DB.Delete(node).Error
 	if err != nil {
@@ -871,6 +922,9 @@ func DeleteAutoSubscriptionNodes(sourceId int) error {
 		if err := database.DB.Exec("DELETE FROM subcription_nodes WHERE node_id IN ?", nodeIDs).Error; err != nil {
 			return err
 		}
+		if err := deleteNodeTagExpiry(database.DB, nodeIDs); err != nil {
+			return err
+		}
 	}
 
 	// Write-Through: 先删除数据库
@@ -899,6 +953,9 @@ func BatchDel(ids []int) error {
 			if err := tx.Exec("DELETE FROM subcription_nodes WHERE node_id IN ?", ids).Error; err != nil {
 				return err
 			}
+			if err := deleteNodeTagExpiry(tx, ids); err != nil {
+				return err
+			}
 		}
 
 		// 删除节点
@@ -1038,6 +1095,55 @@ func GetBestProxyNode() (*Node, error) {
 	return &dbNodes[0], nil
 }
 
+// 代理来源前缀
+const (
+	ProxySourceTagPrefix   = "tag:"
+	ProxySourceGroupPrefix = "group:"
+)
+
+// ListProxyCandidates 按代理来源获取候选代理节点
+// source 格式: tag:标签名 / group:分组名
+// 延迟检测通过的节点按延迟升序排在前面，未检测的按 ID 排在后面，检测失败或已隔离的节点不参与
+func ListProxyCandidates(source string) []Node {
+	var match func(n Node) bool
+	switch {
+	case strings.HasPrefix(source, ProxySourceTagPrefix):
+		tag := strings.TrimSpace(strings.TrimPrefix(source, ProxySourceTagPrefix))
+		match = func(n Node) bool {
+			for _, t := range n.GetTagNames() {
+				if t == tag {
+					return true
+				}
+			}
+			return false
+		}
+	case strings.HasPrefix(source, ProxySourceGroupPrefix):
+		group := strings.TrimSpace(strings.TrimPrefix(source, ProxySourceGroupPrefix))
+		match = func(n Node) bool { return n.Group == group }
+	default:
+		return nil
+	}
+
+	return nodeCache.FilterSorted(
+		func(n Node) bool {
+			if n.DelayStatus == "timeout" || n.DelayStatus == "error" || n.LifecycleState == NodeStateQuarantined {
+				return false
+			}
+			return match(n)
+		},
+		func(a, b Node) bool {
+			aOK, bOK := a.DelayTime > 0, b.DelayTime > 0
+			if aOK != bOK {
+				return aOK
+			}
+			if aOK && a.DelayTime != b.DelayTime {
+				return a.DelayTime < b.DelayTime
+			}
+			return a.ID < b.ID
+		},
+	)
+}
+
 // ListBySourceID 根据订阅ID查询节点列表
 func ListBySourceID(sourceID int) ([]Node, error) {
 	// 使用二级索引查询
@@ -1275,6 +1381,7 @@ func InitNodeFieldsMeta() {
 		"ID": true, "Link": true, "CreatedAt": true, "UpdatedAt": true,
 		"Tags": true, "SpeedCheckAt": true, "LatencyCheckAt": true,
 		"Speed": true, "DelayTime": true, "SpeedStatus": true, "DelayStatus": true,
+		"LifecycleState": true, "FailStreak": true, "QuarantinedAt": true, "SyncMissCount": true,
 	}
 
 	// 字段中文标签映射
@@ -1286,6 +1393,10 @@ func InitNodeFieldsMeta() {
 		"LinkPort":        "端口",
 		"LinkCountry":     "国家代码",
 		"LandingIP":       "落地IP",
+		"LandingASN":      "落地ASN",
+		"LandingISP":      "落地运营商",
+		"LandingIPType":   "落地IP类型",
+		"LandingCity":     "落地城市",
 		"DialerProxyName": "前置代理",
 		"Source":          "来源",
 		"SourceID":        "来源ID",
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"sublink/database"
+	"time"
+)
+
+// NodeCheckCheckpoint 节点检测断点记录
+// 检测任务开始时为每个待测节点写入一条记录，节点结果落库后标记为已完成
+// 服务重启等原因导致任务中断时，可根据未完成的记录继续检测
+type NodeCheckCheckpoint struct {
+	ID          int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	TaskID      string    `gorm:"size:64;index" json:"taskId"`     // 所属任务ID
+	ProfileID   int       `gorm:"index" json:"profileId"`          // 检测策略ID（0表示无策略）
+	ProfileName string    `gorm:"size:128" json:"profileName"`     // 检测策略名称
+	NodeID      int       `json:"nodeId"`                          // 节点ID
+	Done        bool      `gorm:"default:false;index" json:"done"` // 是否已完成检测
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"createdAt"` // 创建时间
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updatedAt"` // 更新时间
+}
+
+// TableName 指定表名
+func (NodeCheckCheckpoint) TableName() string {
+	return "node_check_checkpoints"
+}
+
+// InterruptedNodeCheck 中断的节点检测任务摘要
+type InterruptedNodeCheck struct {
+	TaskID      string    `json:"taskId"`
+	ProfileID   int       `json:"profileId"`
+	ProfileName string    `json:"profileName"`
+	Total       int       `json:"total"`     // 计划检测节点数
+	Completed   int       `json:"completed"` // 已完成节点数
+	Remaining   int       `json:"remaining"` // 剩余节点数
+	CreatedAt   time.Time `json:"createdAt"` // 任务开始时间
+}
+
+// CreateNodeCheckCheckpoints 为检测任务批量创建断点记录
+func CreateNodeCheckCheckpoints(taskID string, profileID int, profileName string, nodeIDs []int) error {
+	if len(nodeIDs) == 0 {
+		return nil
+	}
+	checkpoints := make([]NodeCheckCheckpoint, 0, len(nodeIDs))
+	for _, id := range nodeIDs {
+		checkpoints = append(checkpoints, NodeCheckCheckpoint{
+			TaskID:      taskID,
+			ProfileID:   profileID,
+			ProfileName: profileName,
+			NodeID:      id,
+		})
+	}
+	return database.DB.CreateInBatches(&checkpoints, database.BatchSize).Error
+}
+
+// MarkNodeCheckCheckpointsDone 将指定节点的断点记录标记为已完成
+func MarkNodeCheckCheckpointsDone(taskID string, nodeIDs []int) error {
+	for _, chunk := range database.ChunkIntSlice(nodeIDs, database.BatchSize) {
+		err := database.DB.Model(&NodeCheckCheckpoint{}).
+			Where("task_id = ? AND node_id IN ?", taskID, chunk).
+			Update("done", true).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteNodeCheckCheckpoints 删除任务的所有断点记录（任务正常结束或放弃续测时调用）
+func DeleteNodeCheckCheckpoints(taskID string) error {
+	return database.DB.Where("task_id = ?", taskID).Delete(&NodeCheckCheckpoint{}).Error
+}
+
+// GetPendingCheckpointNodeIDs 获取任务中尚未完成检测的节点ID
+func GetPendingCheckpointNodeIDs(taskID string) ([]int, error) {
+	var ids []int
+	err := database.DB.Model(&NodeCheckCheckpoint{}).
+		Where("task_id = ? AND done = ?", taskID, false).
+		Order("id ASC").
+		Pluck("node_id", &ids).Error
+	return ids, err
+}
+
+// ListInterruptedNodeChecks 列出可续测的中断任务
+// 仅返回已不在运行中的任务（运行中的任务由 runningTaskIDs 排除）
+func ListInterruptedNodeChecks(runningTaskIDs map[string]bool) ([]InterruptedNodeCheck, error) {
+	var checkpoints []NodeCheckCheckpoint
+	if err := database.DB.Order("id ASC").Find(&checkpoints).Error; err != nil {
+		return nil, err
+	}
+
+	summaries := make(map[string]*InterruptedNodeCheck)
+	order := make([]string, 0)
+	for _, cp := range checkpoints {
+		if runningTaskIDs[cp.TaskID] {
+			continue
+		}
+		s, ok := summaries[cp.TaskID]
+		if !ok {
+			s = &InterruptedNodeCheck{
+				TaskID:      cp.TaskID,
+				ProfileID:   cp.ProfileID,
+				ProfileName: cp.ProfileName,
+				CreatedAt:   cp.CreatedAt,
+			}
+			summaries[cp.TaskID] = s
+			order = append(order, cp.TaskID)
+		}
+		s.Total++
+		if cp.Done {
+			s.Completed++
+		}
+	}
+
+	// 按任务开始时间倒序返回
+	result := make([]InterruptedNodeCheck, 0, len(order))
+	for i := len(order) - 1; i >= 0; i-- {
+		s := summaries[order[i]]
+		s.Remaining = s.Total - s.Completed
+		if s.Remaining <= 0 {
+			continue
+		}
+		result = append(result, *s)
+	}
+	return result, nil
+}
+
+// GetInterruptedNodeCheck 获取单个中断任务的摘要
+func GetInterruptedNodeCheck(taskID string) (*InterruptedNodeCheck, error) {
+	var first NodeCheckCheckpoint
+	if err := database.DB.Where("task_id = ?", taskID).Order("id ASC").First(&first).Error; err != nil {
+		return nil, err
+	}
+	var total, completed int64
+	database.DB.Model(&NodeCheckCheckpoint{}).Where("task_id = ?", taskID).Count(&total)
+	database.DB.Model(&NodeCheckCheckpoint{}).Where("task_id = ? AND done = ?", taskID, true).Count(&completed)
+	return &InterruptedNodeCheck{
+		TaskID:      taskID,
+		ProfileID:   first.ProfileID,
+		ProfileName: first.ProfileName,
+		Total:       int(total),
+		Completed:   int(completed),
+		Remaining:   int(total - completed),
+		CreatedAt:   first.CreatedAt,
+	}, nil
+}
+
+// CleanupNodeCheckCheckpoints 清理过期的断点记录
+func CleanupNodeCheckCheckpoints(before time.Time) (int64, error) {
+	result := database.DB.Where("created_at < ?", before).Delete(&NodeCheckCheckpoint{})
+	return result.RowsAffected, result.Error
+}
@@ -31,6 +31,11 @@ type NodeCheckProfile struct {
 	LatencyConcurrency int `gorm:"default:0" json:"latencyConcurrency"` // 延迟检测并发(0=自动)
 	SpeedConcurrency   int `gorm:"default:0" json:"speedConcurrency"`   // 速度检测并发(0=自动)
 
+	// 礼貌限制：避免短时间内对同一机场/服务器发起过多检测连接
+	SourceSpeedConcurrency int `gorm:"default:0" json:"sourceSpeedConcurrency"` // 每个来源的最大检测并发(0=不限)
+	HostSpeedConcurrency   int `gorm:"default:0" json:"hostSpeedConcurrency"`   // 每个服务器地址的最大检测并发(0=不限)
+	SourceTrafficLimitMB   int `gorm:"default:0" json:"sourceTrafficLimitMb"`   // 每个来源单次检测的流量上限(MB，0=不限)
+
 	// 高级选项
 	DetectCountry      bool   `gorm:"default:false" json:"detectCountry"`       // 检测落地IP国家
 	LandingIPURL       string `json:"landingIpUrl"`                             // IP查询接口URL
@@ -43,6 +48,13 @@ type NodeCheckProfile struct {
 	TrafficBySource bool `gorm:"default:true" json:"trafficBySource"`
 	TrafficByNode   bool `gorm:"default:false" json:"trafficByNode"`
 
+	// 增量检测：仅检测上次检测时间早于 IncrementalMinutes 分钟的节点，以及上次执行后新增的节点
+	Incremental        bool `gorm:"default:false" json:"incremental"`
+	IncrementalMinutes int  `gorm:"default:60" json:"incrementalMinutes"` // 增量检测的过期时间(分钟)
+
+	// 条件过滤：在分组/标签范围基础上，按条件表达式进一步筛选节点（为空则不过滤）
+	NodeFilter string `gorm:"type:text" json:"nodeFilter"`
+
 	// 执行时间记录
 	LastRunTime *time.Time `gorm:"type:datetime" json:"lastRunTime"` // 上次执行时间
 	NextRunTime *time.Time `gorm:"type:datetime" json:"nextRunTime"` // 下次执行时间
@@ -98,9 +110,12 @@ func (p *NodeCheckProfile) Update() error {
 		"Mode", "TestURL", "LatencyURL", "Timeout",
 		"Groups", "Tags",
 		"LatencyConcurrency", "SpeedConcurrency",
+		"SourceSpeedConcurrency", "HostSpeedConcurrency", "SourceTrafficLimitMB",
 		"DetectCountry", "LandingIPURL", "IncludeHandshake",
 		"SpeedRecordMode", "PeakSampleInterval",
 		"TrafficByGroup", "TrafficBySource", "TrafficByNode",
+		"Incremental", "IncrementalMinutes",
+		"NodeFilter",
 	).Updates(p).Error
 	if err != nil {
 		return err
@@ -219,6 +234,24 @@ func (p *NodeCheckProfile) GetTags() []string {
 	return strings.Split(p.Tags, ",")
 }
 
+// FilterNodes 按条件表达式筛选节点，未设置表达式时原样返回
+func (p *NodeCheckProfile) FilterNodes(nodes []Node) ([]Node, error) {
+	if strings.TrimSpace(p.NodeFilter) == "" {
+		return nodes, nil
+	}
+	expr, err := CompileNodeExpression(p.NodeFilter)
+	if err != nil {
+		return nil, err
+	}
+	filtered := make([]Node, 0, len(nodes))
+	for _, n := range nodes {
+		if expr.Match(n) {
+			filtered = append(filtered, n)
+		}
+	}
+	return filtered, nil
+}
+
 // SetGroups 设置分组列表（转换为逗号分隔字符串）
 func (p *NodeCheckProfile) SetGroups(groups []string) {
 	p.Groups = strings.Join(groups, ",")
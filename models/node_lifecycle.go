@@ -0,0 +1,345 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"sublink/constants"
+	"sublink/database"
+	"sublink/utils"
+	"time"
+)
+
+// 节点生命周期状态
+const (
+	NodeStateActive      = "active"      // 正常
+	NodeStateQuarantined = "quarantined" // 隔离（连续检测失败，不出现在订阅中，按较慢频率复测）
+	NodeStateFlagged     = "flagged"     // 标记待处理（隔离超期且未恢复，仍不出现在订阅中）
+	NodeStateDeleted     = "deleted"     // 已删除（仅用于状态变更记录）
+)
+
+// 隔离超期后的处理方式
+const (
+	NodePurgeActionFlag   = "flag"   // 标记为待处理
+	NodePurgeActionDelete = "delete" // 直接删除节点
+)
+
+// IsNodeExcluded 节点是否因生命周期状态被排除在订阅之外
+func (node *Node) IsNodeExcluded() bool {
+	return node.LifecycleState == NodeStateQuarantined || node.LifecycleState == NodeStateFlagged
+}
+
+// NodeLifecyclePolicy 节点生命周期策略（保存在系统设置中）
+type NodeLifecyclePolicy struct {
+	Enabled          bool   `json:"enabled"`          // 是否启用自动隔离
+	FailThreshold    int    `json:"failThreshold"`    // 连续失败多少次后隔离
+	RecheckCron      string `json:"recheckCron"`      // 隔离节点复测的Cron表达式
+	RecheckProfileID int    `json:"recheckProfileId"` // 复测使用的检测策略ID(0=默认配置)
+	PurgeDays        int    `json:"purgeDays"`        // 隔离超过多少天后处理(0=不处理)
+	PurgeAction      string `json:"purgeAction"`      // 超期处理方式: flag / delete
+}
+
+// 生命周期策略在系统设置中的键名
+const (
+	settingNodeLifecycleEnabled          = "node_lifecycle_enabled"
+	settingNodeLifecycleFailThreshold    = "node_lifecycle_fail_threshold"
+	settingNodeLifecycleRecheckCron      = "node_lifecycle_recheck_cron"
+	settingNodeLifecycleRecheckProfileID = "node_lifecycle_recheck_profile_id"
+	settingNodeLifecyclePurgeDays        = "node_lifecycle_purge_days"
+	settingNodeLifecyclePurgeAction      = "node_lifecycle_purge_action"
+)
+
+// 生命周期策略默认值
+const (
+	DefaultNodeFailThreshold = 3
+	DefaultNodeRecheckCron   = "0 */6 * * *"
+	DefaultNodePurgeDays     = 7
+)
+
+// GetNodeLifecyclePolicy 读取节点生命周期策略，未配置的项使用默认值
+func GetNodeLifecyclePolicy() NodeLifecyclePolicy {
+	policy := NodeLifecyclePolicy{
+		FailThreshold: DefaultNodeFailThreshold,
+		RecheckCron:   DefaultNodeRecheckCron,
+		PurgeDays:     DefaultNodePurgeDays,
+		PurgeAction:   NodePurgeActionFlag,
+	}
+
+	if v, _ := GetSetting(settingNodeLifecycleEnabled); v == "true" {
+		policy.Enabled = true
+	}
+	if v, _ := GetSetting(settingNodeLifecycleFailThreshold); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			policy.FailThreshold = n
+		}
+	}
+	if v, _ := GetSetting(settingNodeLifecycleRecheckCron); v != "" {
+		policy.RecheckCron = v
+	}
+	if v, _ := GetSetting(settingNodeLifecycleRecheckProfileID); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			policy.RecheckProfileID = n
+		}
+	}
+	if v, _ := GetSetting(settingNodeLifecyclePurgeDays); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			policy.PurgeDays = n
+		}
+	}
+	if v, _ := GetSetting(settingNodeLifecyclePurgeAction); v == NodePurgeActionDelete {
+		policy.PurgeAction = NodePurgeActionDelete
+	}
+	return policy
+}
+
+// SaveNodeLifecyclePolicy 保存节点生命周期策略
+func SaveNodeLifecyclePolicy(policy NodeLifecyclePolicy) error {
+	settings := map[string]string{
+		settingNodeLifecycleEnabled:          strconv.FormatBool(policy.Enabled),
+		settingNodeLifecycleFailThreshold:    strconv.Itoa(policy.FailThreshold),
+		settingNodeLifecycleRecheckCron:      policy.RecheckCron,
+		settingNodeLifecycleRecheckProfileID: strconv.Itoa(policy.RecheckProfileID),
+		settingNodeLifecyclePurgeDays:        strconv.Itoa(policy.PurgeDays),
+		settingNodeLifecyclePurgeAction:      policy.PurgeAction,
+	}
+	for key, value := range settings {
+		if err := SetSetting(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NodeLifecycleEvent 节点生命周期状态变更记录
+type NodeLifecycleEvent struct {
+	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	NodeID    int       `gorm:"index" json:"nodeId"`
+	NodeName  string    `gorm:"size:256" json:"nodeName"`
+	Source    string    `gorm:"size:128" json:"source"`
+	FromState string    `gorm:"size:20" json:"fromState"`
+	ToState   string    `gorm:"size:20;index" json:"toState"`
+	Reason    string    `gorm:"size:256" json:"reason"`
+	CreatedAt time.Time `gorm:"autoCreateTime;index" json:"createdAt"`
+}
+
+// TableName 指定表名
+func (NodeLifecycleEvent) TableName() string {
+	return "node_lifecycle_events"
+}
+
+// ListNodeLifecycleEvents 分页获取状态变更记录（nodeID 为 0 时返回全部）
+func ListNodeLifecycleEvents(nodeID int, page, pageSize int) ([]NodeLifecycleEvent, int64, error) {
+	var events []NodeLifecycleEvent
+	var total int64
+
+	query := database.DB.Model(&NodeLifecycleEvent{})
+	if nodeID > 0 {
+		query = query.Where("node_id = ?", nodeID)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	offset := (page - 1) * pageSize
+	if err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+	return events, total, nil
+}
+
+// CleanupNodeLifecycleEvents 清理过期的状态变更记录
+func CleanupNodeLifecycleEvents(before time.Time) (int64, error) {
+	result := database.DB.Where("created_at < ?", before).Delete(&NodeLifecycleEvent{})
+	return result.RowsAffected, result.Error
+}
+
+// Alive 检测结果是否表示节点可用
+// 测速模式以速度结果为准，仅测延迟时以延迟结果为准
+func (r SpeedTestResult) Alive() bool {
+	if r.SpeedStatus == constants.StatusSuccess {
+		return true
+	}
+	return r.SpeedStatus == constants.StatusUntested && r.DelayStatus == constants.StatusSuccess
+}
+
+// nodeLifecycleUpdate 单个节点的生命周期字段更新
+type nodeLifecycleUpdate struct {
+	state         string
+	failStreak    int
+	quarantinedAt string
+}
+
+// ApplyNodeCheckResults 根据检测结果更新节点的连续失败次数和生命周期状态
+// 检测成功的隔离/标记节点恢复为正常；策略启用时，连续失败达到阈值的正常节点进入隔离
+// 返回本次产生的状态变更记录
+func ApplyNodeCheckResults(results []SpeedTestResult) []NodeLifecycleEvent {
+	if len(results) == 0 {
+		return nil
+	}
+	policy := GetNodeLifecyclePolicy()
+	now := time.Now().Format("2006-01-02 15:04:05")
+
+	// 按更新后的字段值分组，相同值的节点合并为一条 UPDATE
+	groups := make(map[nodeLifecycleUpdate][]int)
+	var events []NodeLifecycleEvent
+	for _, r := range results {
+		n, ok := nodeCache.Get(r.NodeID)
+		if !ok {
+			continue
+		}
+		state := n.LifecycleState
+		if state == "" {
+			state = NodeStateActive
+		}
+		update := nodeLifecycleUpdate{state: state, failStreak: n.FailStreak, quarantinedAt: n.QuarantinedAt}
+
+		if r.Alive() {
+			update.failStreak = 0
+			if state != NodeStateActive {
+				update.state = NodeStateActive
+				update.quarantinedAt = ""
+				events = append(events, newNodeLifecycleEvent(n, state, NodeStateActive, "检测恢复可用"))
+			}
+		} else {
+			update.failStreak++
+			if policy.Enabled && state == NodeStateActive && update.failStreak >= policy.FailThreshold {
+				update.state = NodeStateQuarantined
+				update.quarantinedAt = now
+				events = append(events, newNodeLifecycleEvent(n, state, NodeStateQuarantined,
+					fmt.Sprintf("连续检测失败 %d 次", update.failStreak)))
+			}
+		}
+
+		if update.state == n.LifecycleState && update.failStreak == n.FailStreak && update.quarantinedAt == n.QuarantinedAt {
+			continue
+		}
+		groups[update] = append(groups[update], r.NodeID)
+	}
+
+	for update, ids := range groups {
+		if err := batchUpdateNodeLifecycle(ids, update); err != nil {
+			utils.Error("更新节点生命周期状态失败: %v", err)
+		}
+	}
+	saveNodeLifecycleEvents(events)
+	return events
+}
+
+// SetNodesLifecycleState 手动或按策略批量设置节点生命周期状态
+// 仅对状态实际发生变化的节点生效，返回产生的状态变更记录
+func SetNodesLifecycleState(ids []int, state string, reason string) []NodeLifecycleEvent {
+	now := time.Now().Format("2006-01-02 15:04:05")
+	update := nodeLifecycleUpdate{state: state}
+	if state == NodeStateQuarantined {
+		update.quarantinedAt = now
+	}
+
+	groups := make(map[nodeLifecycleUpdate][]int)
+	var events []NodeLifecycleEvent
+	for _, id := range ids {
+		n, ok := nodeCache.Get(id)
+		if !ok {
+			continue
+		}
+		from := n.LifecycleState
+		if from == "" {
+			from = NodeStateActive
+		}
+		if from == state {
+			continue
+		}
+		u := update
+		// 标记状态保留原有的隔离时间和失败次数
+		if state == NodeStateFlagged {
+			u.quarantinedAt = n.QuarantinedAt
+			u.failStreak = n.FailStreak
+		}
+		groups[u] = append(groups[u], id)
+		events = append(events, newNodeLifecycleEvent(n, from, state, reason))
+	}
+
+	for u, groupIDs := range groups {
+		if err := batchUpdateNodeLifecycle(groupIDs, u); err != nil {
+			utils.Error("更新节点生命周期状态失败: %v", err)
+		}
+	}
+	saveNodeLifecycleEvents(events)
+	return events
+}
+
+// RecordNodesDeleted 为已删除的节点记录状态变更，nodes 为删除前的节点
+// 节点删除成功后再调用，删除失败时节点保持原状态
+func RecordNodesDeleted(nodes []Node, reason string) []NodeLifecycleEvent {
+	events := make([]NodeLifecycleEvent, 0, len(nodes))
+	for _, n := range nodes {
+		from := n.LifecycleState
+		if from == "" {
+			from = NodeStateActive
+		}
+		events = append(events, newNodeLifecycleEvent(n, from, NodeStateDeleted, reason))
+	}
+	saveNodeLifecycleEvents(events)
+	return events
+}
+
+// ListQuarantinedNodes 获取所有隔离或标记状态的节点
+func ListQuarantinedNodes() []Node {
+	return nodeCache.FilterSorted(func(n Node) bool {
+		return n.IsNodeExcluded()
+	}, func(a, b Node) bool { return a.ID < b.ID })
+}
+
+// ListNodesQuarantinedBefore 获取隔离时间早于指定时间且仍处于隔离状态的节点
+func ListNodesQuarantinedBefore(before time.Time) []Node {
+	cutoff := before.Format("2006-01-02 15:04:05")
+	return nodeCache.FilterSorted(func(n Node) bool {
+		return n.LifecycleState == NodeStateQuarantined && n.QuarantinedAt != "" && n.QuarantinedAt < cutoff
+	}, func(a, b Node) bool { return a.ID < b.ID })
+}
+
+// batchUpdateNodeLifecycle 批量写入生命周期字段并同步缓存
+func batchUpdateNodeLifecycle(ids []int, update nodeLifecycleUpdate) error {
+	for _, chunk := range database.ChunkIntSlice(ids, database.BatchSize) {
+		err := database.DB.Model(&Node{}).Where("id IN ?", chunk).Updates(map[string]interface{}{
+			"lifecycle_state": update.state,
+			"fail_streak":     update.failStreak,
+			"quarantined_at":  update.quarantinedAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+		for _, id := range chunk {
+			if cachedNode, ok := nodeCache.Get(id); ok {
+				cachedNode.LifecycleState = update.state
+				cachedNode.FailStreak = update.failStreak
+				cachedNode.QuarantinedAt = update.quarantinedAt
+				nodeCache.Set(id, cachedNode)
+			}
+		}
+	}
+	return nil
+}
+
+// newNodeLifecycleEvent 构建状态变更记录
+func newNodeLifecycleEvent(n Node, from, to, reason string) NodeLifecycleEvent {
+	return NodeLifecycleEvent{
+		NodeID:    n.ID,
+		NodeName:  n.Name,
+		Source:    n.Source,
+		FromState: from,
+		ToState:   to,
+		Reason:    reason,
+	}
+}
+
+// saveNodeLifecycleEvents 批量保存状态变更记录
+func saveNodeLifecycleEvents(events []NodeLifecycleEvent) {
+	if len(events) == 0 {
+		return
+	}
+	if err := database.DB.CreateInBatches(&events, database.BatchSize).Error; err != nil {
+		utils.Error("保存节点生命周期记录失败: %v", err)
+		return
+	}
+	for _, e := range events {
+		utils.Info("节点生命周期变更: [%s] %s -> %s (%s)", e.NodeName, e.FromState, e.ToState, e.Reason)
+	}
+}
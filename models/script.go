@@ -15,6 +15,19 @@ type Script struct {
 	Content   string    `json:"content" gorm:"not null"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// 生命周期钩子
+	Hooks    string `json:"hooks"`    // 启用的钩子，逗号分隔: airport_sync, node_check, cron
+	CronExpr string `json:"cronExpr"` // cron 钩子的执行计划（5 字段 Cron 表达式）
+
+	// 来源与参数
+	SourceURL       string     `json:"sourceUrl"`       // 安装来源地址，为空表示本地创建
+	SourceUseProxy  bool       `json:"sourceUseProxy"`  // 下载来源时是否使用代理
+	SourceCheckedAt *time.Time `json:"sourceCheckedAt"` // 最近一次检查更新的时间
+	SourceError     string     `json:"sourceError"`     // 最近一次检查更新失败的原因
+	PendingVersion  string     `json:"pendingVersion"`  // 来源中可升级的新版本
+	PendingContent  string     `json:"-"`               // 新版本的内容，升级前可查看差异
+	Parameters      string     `json:"parameters"`      // 头部声明的参数（JSON 数组）
 }
 
 // scriptCache 使用新的泛型缓存
@@ -42,25 +55,34 @@ func InitScriptCache() error {
 
 // Add 添加脚本 (Write-Through)
 func (s *Script) Add() error {
+	s.syncParameters()
 	err := database.DB.Create(s).Error
 	if err != nil {
 		return err
 	}
 	scriptCache.Set(s.ID, *s)
+	if err := saveScriptVersion(s); err != nil {
+		utils.Warn("保存脚本 %d 的版本记录失败: %v", s.ID, err)
+	}
 	return nil
 }
 
 // Update 更新脚本 (Write-Through)
 func (s *Script) Update() error {
-	err := database.DB.Model(s).Updates(s).Error
+	s.syncParameters()
+	err := database.DB.Model(s).Select("Name", "Version", "Content", "Hooks", "CronExpr", "Parameters").Updates(s).Error
 	if err != nil {
 		return err
 	}
+	if err := saveScriptVersion(s); err != nil {
+		utils.Warn("保存脚本 %d 的版本记录失败: %v", s.ID, err)
+	}
 	// 从DB读取完整数据后更新缓存
 	var updated Script
 	if err := database.DB.First(&updated, s.ID).Error; err == nil {
 		scriptCache.Set(s.ID, updated)
 	}
+	utils.InvalidateScriptProgram(s.ID)
 	return nil
 }
 
@@ -71,9 +93,27 @@ func (s *Script) Del() error {
 		return err
 	}
 	scriptCache.Delete(s.ID)
+	utils.InvalidateScriptProgram(s.ID)
+	if err := DeleteScriptKV(ScriptKVNamespace(s.ID)); err != nil {
+		utils.Warn("删除脚本 %d 的 KV 存储失败: %v", s.ID, err)
+	}
+	if err := database.DB.Where("script_id = ?", s.ID).Delete(&ScriptVersion{}).Error; err != nil {
+		utils.Warn("删除脚本 %d 的版本记录失败: %v", s.ID, err)
+	}
 	return nil
 }
 
+// Source 返回用于执行的脚本（编译缓存按 ID+版本）
+func (s *Script) Source() utils.ScriptSource {
+	return utils.ScriptSource{
+		ID:        s.ID,
+		Version:   s.Version,
+		Content:   s.Content,
+		Namespace: ScriptKVNamespace(s.ID),
+		Params:    ResolveScriptParams(s.ParamList(), ""),
+	}
+}
+
 // List 获取脚本列表
 func (s *Script) List() ([]Script, error) {
 	scripts := scriptCache.GetAllSorted(func(a, b Script) bool {
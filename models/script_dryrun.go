@@ -0,0 +1,209 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sublink/utils"
+	"time"
+)
+
+// scriptDryRunMaxOutput 试运行返回的 subMod 输出上限，超出部分截断
+const scriptDryRunMaxOutput = 256 << 10
+
+// ScriptDiffNode 差异中的节点标识
+type ScriptDiffNode struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// ScriptNodeRename 被重命名的节点
+type ScriptNodeRename struct {
+	ID   int    `json:"id"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ScriptNodeChange 字段被修改的节点（不含名称）
+type ScriptNodeChange struct {
+	ID     int      `json:"id"`
+	Name   string   `json:"name"`
+	Fields []string `json:"fields"`
+}
+
+// ScriptNodeDiff filterNode 执行前后的节点差异
+type ScriptNodeDiff struct {
+	InputCount  int                `json:"inputCount"`
+	OutputCount int                `json:"outputCount"`
+	Removed     []ScriptDiffNode   `json:"removed"`
+	Added       []ScriptDiffNode   `json:"added"`
+	Renamed     []ScriptNodeRename `json:"renamed"`
+	Modified    []ScriptNodeChange `json:"modified"`
+	Reordered   bool               `json:"reordered"` // 保留下来的节点顺序是否改变
+}
+
+// ScriptDryRunResult 脚本试运行结果
+type ScriptDryRunResult struct {
+	ClientType      string             `json:"clientType"`
+	Nodes           []Node             `json:"nodes"`                 // filterNode 输出的节点（未定义时为输入节点）
+	Diff            *ScriptNodeDiff    `json:"diff,omitempty"`        // 未定义 filterNode 时为空
+	FilterError     string             `json:"filterError,omitempty"` // filterNode 执行失败的原因
+	Output          string             `json:"output"`                // subMod 的输出
+	OutputSize      int                `json:"outputSize"`
+	OutputTruncated bool               `json:"outputTruncated"`
+	SubModError     string             `json:"subModError,omitempty"`
+	Logs            []string           `json:"logs"`
+	DurationMs      int64              `json:"durationMs"`         // 脚本执行总耗时（filterNode + subMod）
+	KVWrites        map[string]*string `json:"kvWrites,omitempty"` // 脚本尝试写入的 KV（未实际保存，null 表示删除）
+	Notes           []string           `json:"notes"`
+}
+
+// DryRunScript 对给定节点列表试运行脚本：依次执行 filterNode 与 subMod，记录差异、日志与耗时
+// subModInput 为空时，v2ray 使用 filterNode 输出节点的链接（每行一个）作为输入，其他客户端跳过 subMod
+// 试运行不会写入 KV 存储，也不会影响订阅输出
+func DryRunScript(src utils.ScriptSource, nodes []Node, clientType, subModInput string) *ScriptDryRunResult {
+	trace := &utils.ScriptTrace{}
+	result := &ScriptDryRunResult{ClientType: clientType, Nodes: nodes, Notes: []string{}}
+
+	nodesJSON, err := json.Marshal(nodes)
+	if err != nil {
+		result.FilterError = "序列化节点失败: " + err.Error()
+	} else {
+		resJSON, err := utils.RunNodeFilterScriptTraced(src, nodesJSON, clientType, trace)
+		switch {
+		case errors.Is(err, utils.ErrScriptFunctionNotFound):
+			result.Notes = append(result.Notes, "脚本未定义 filterNode，节点列表保持不变")
+		case err != nil:
+			result.FilterError = err.Error()
+		default:
+			var newNodes []Node
+			if err := json.Unmarshal(resJSON, &newNodes); err != nil {
+				// 与订阅输出一致：结果无法解析时忽略该脚本的过滤结果
+				result.FilterError = "filterNode 返回值不是有效的节点数组: " + err.Error()
+			} else {
+				result.Diff = DiffScriptNodes(nodes, newNodes)
+				result.Nodes = newNodes
+			}
+		}
+	}
+
+	if subModInput == "" {
+		if clientType == "v2ray" {
+			links := make([]string, 0, len(result.Nodes))
+			for _, n := range result.Nodes {
+				links = append(links, n.Link)
+			}
+			subModInput = strings.Join(links, "\n")
+		} else {
+			result.Notes = append(result.Notes, "未提供 subMod 输入，已跳过 subMod（"+clientType+" 需要提供完整配置作为输入）")
+		}
+	}
+	if subModInput != "" {
+		output, err := utils.RunScriptTraced(src, subModInput, clientType, trace)
+		switch {
+		case errors.Is(err, utils.ErrScriptFunctionNotFound):
+			result.Notes = append(result.Notes, "脚本未定义 subMod，输出保持不变")
+		case err != nil:
+			result.SubModError = err.Error()
+		default:
+			result.OutputSize = len(output)
+			if len(output) > scriptDryRunMaxOutput {
+				output = utils.TruncateUTF8(output, scriptDryRunMaxOutput)
+				result.OutputTruncated = true
+			}
+			result.Output = output
+		}
+	}
+
+	result.Logs = trace.Logs
+	if result.Logs == nil {
+		result.Logs = []string{}
+	}
+	result.DurationMs = trace.Duration.Milliseconds()
+	result.KVWrites = trace.KVWrites
+	return result
+}
+
+// scriptDiffKey 节点在差异比较中的标识：优先 ID，其次链接，最后名称
+func scriptDiffKey(n Node) string {
+	if n.ID > 0 {
+		return "id:" + strconv.Itoa(n.ID)
+	}
+	if n.Link != "" {
+		return "link:" + n.Link
+	}
+	return "name:" + n.Name
+}
+
+// DiffScriptNodes 比较脚本执行前后的节点列表
+func DiffScriptNodes(before, after []Node) *ScriptNodeDiff {
+	diff := &ScriptNodeDiff{
+		InputCount:  len(before),
+		OutputCount: len(after),
+		Removed:     []ScriptDiffNode{},
+		Added:       []ScriptDiffNode{},
+		Renamed:     []ScriptNodeRename{},
+		Modified:    []ScriptNodeChange{},
+	}
+
+	afterIndex := make(map[string]int, len(after))
+	for i, n := range after {
+		if _, ok := afterIndex[scriptDiffKey(n)]; !ok {
+			afterIndex[scriptDiffKey(n)] = i
+		}
+	}
+	beforeKeys := make(map[string]bool, len(before))
+	var keptOrder []int
+	for _, old := range before {
+		key := scriptDiffKey(old)
+		beforeKeys[key] = true
+		idx, ok := afterIndex[key]
+		if !ok {
+			diff.Removed = append(diff.Removed, ScriptDiffNode{ID: old.ID, Name: old.Name})
+			continue
+		}
+		keptOrder = append(keptOrder, idx)
+		cur := after[idx]
+		if cur.Name != old.Name {
+			diff.Renamed = append(diff.Renamed, ScriptNodeRename{ID: old.ID, From: old.Name, To: cur.Name})
+		}
+		if fields := changedNodeFields(old, cur); len(fields) > 0 {
+			diff.Modified = append(diff.Modified, ScriptNodeChange{ID: old.ID, Name: cur.Name, Fields: fields})
+		}
+	}
+	for _, n := range after {
+		if !beforeKeys[scriptDiffKey(n)] {
+			diff.Added = append(diff.Added, ScriptDiffNode{ID: n.ID, Name: n.Name})
+		}
+	}
+	diff.Reordered = !sort.IntsAreSorted(keptOrder)
+	return diff
+}
+
+// changedNodeFields 返回两个节点之间除名称外发生变化的字段名
+func changedNodeFields(a, b Node) []string {
+	var fields []string
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	t := va.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Name
+		if name == "Name" || !t.Field(i).IsExported() {
+			continue
+		}
+		x, y := va.Field(i).Interface(), vb.Field(i).Interface()
+		// 时间经 JSON 往返后时区表示可能不同，按时刻比较
+		if tx, ok := x.(time.Time); ok {
+			if !tx.Equal(y.(time.Time)) {
+				fields = append(fields, name)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(x, y) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"sublink/database"
+)
+
+// 脚本钩子类型
+const (
+	ScriptHookAirportSync = "airport_sync" // 机场同步后、节点入库前：onAirportSync(proxies, airport)
+	ScriptHookNodeCheck   = "node_check"   // 节点检测完成后：onNodeCheck(nodes)
+	ScriptHookCron        = "cron"         // 按脚本自己的 Cron 表达式执行：onCron()
+)
+
+// ScriptHookFunctions 各钩子对应的脚本函数名
+var ScriptHookFunctions = map[string]string{
+	ScriptHookAirportSync: "onAirportSync",
+	ScriptHookNodeCheck:   "onNodeCheck",
+	ScriptHookCron:        "onCron",
+}
+
+// NormalizeScriptHooks 校验并规范化钩子列表（去重、去空格），返回逗号分隔的字符串
+func NormalizeScriptHooks(hooks string) (string, error) {
+	seen := make(map[string]bool)
+	var result []string
+	for _, h := range strings.Split(hooks, ",") {
+		h = strings.TrimSpace(strings.ToLower(h))
+		if h == "" || seen[h] {
+			continue
+		}
+		if _, ok := ScriptHookFunctions[h]; !ok {
+			return "", fmt.Errorf("不支持的钩子类型: %s", h)
+		}
+		seen[h] = true
+		result = append(result, h)
+	}
+	return strings.Join(result, ","), nil
+}
+
+// HasHook 脚本是否启用了指定钩子
+func (s *Script) HasHook(hook string) bool {
+	for _, h := range strings.Split(s.Hooks, ",") {
+		if strings.TrimSpace(h) == hook {
+			return true
+		}
+	}
+	return false
+}
+
+// ListScriptsByHook 获取启用了指定钩子的脚本（按 ID 排序）
+func ListScriptsByHook(hook string) []Script {
+	return scriptCache.FilterSorted(func(s Script) bool { return s.HasHook(hook) }, func(a, b Script) bool {
+		return a.ID < b.ID
+	})
+}
+
+// UpdateNodeScriptScores 批量更新节点的脚本评分 (Write-Through)
+func UpdateNodeScriptScores(scores map[int]float64) error {
+	for id, score := range scores {
+		if err := database.DB.Model(&Node{}).Where("id = ?", id).Update("script_score", score).Error; err != nil {
+			return err
+		}
+		if cached, ok := nodeCache.Get(id); ok {
+			cached.ScriptScore = score
+			nodeCache.Set(id, cached)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sublink/database"
+	"sublink/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 脚本 KV 存储限制
+const (
+	scriptKVMaxKeyLen   = 128
+	scriptKVMaxValueLen = 64 << 10
+	scriptKVMaxKeys     = 200
+)
+
+// 脚本运行限制的设置项
+const (
+	SettingScriptTimeout        = "script_timeout"         // 单次执行超时（秒）
+	SettingScriptMaxMemory      = "script_max_memory_mb"   // 堆内存增长上限（MB），0=不限制
+	SettingScriptMaxOutput      = "script_max_output_mb"   // 返回结果上限（MB），0=不限制
+	SettingScriptFetchAllowlist = "script_fetch_allowlist" // $http.get 允许访问的域名（逗号或换行分隔）
+)
+
+// ScriptKV 脚本 KV 存储，每个脚本（或机场导入脚本）一个命名空间
+type ScriptKV struct {
+	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	Namespace string    `gorm:"size:64;uniqueIndex:idx_script_kv" json:"namespace"`
+	Key       string    `gorm:"size:128;uniqueIndex:idx_script_kv" json:"key"`
+	Value     string    `gorm:"type:text" json:"value"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (ScriptKV) TableName() string {
+	return "script_kv_entries"
+}
+
+// ScriptKVNamespace 脚本库中脚本的 KV 命名空间
+func ScriptKVNamespace(scriptID int) string {
+	return "script:" + strconv.Itoa(scriptID)
+}
+
+// AirportScriptKVNamespace 机场导入脚本的 KV 命名空间
+func AirportScriptKVNamespace(airportID int) string {
+	return "airport:" + strconv.Itoa(airportID)
+}
+
+// GetScriptKV 读取 KV，不存在时 ok 为 false
+func GetScriptKV(namespace, key string) (string, bool, error) {
+	var entry ScriptKV
+	err := database.DB.Where("namespace = ? AND key = ?", namespace, key).First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return entry.Value, true, nil
+}
+
+// SetScriptKV 写入 KV，超出键长、值大小或键数量限制时返回错误
+func SetScriptKV(namespace, key, value string) error {
+	if key == "" || len(key) > scriptKVMaxKeyLen {
+		return fmt.Errorf("键长度应为 1-%d 字节", scriptKVMaxKeyLen)
+	}
+	if len(value) > scriptKVMaxValueLen {
+		return fmt.Errorf("值不能超过 %s", utils.FormatBytes(scriptKVMaxValueLen))
+	}
+	if _, exists, err := GetScriptKV(namespace, key); err != nil {
+		return err
+	} else if !exists {
+		var count int64
+		if err := database.DB.Model(&ScriptKV{}).Where("namespace = ?", namespace).Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= scriptKVMaxKeys {
+			return fmt.Errorf("每个脚本最多保存 %d 个键", scriptKVMaxKeys)
+		}
+	}
+	entry := ScriptKV{Namespace: namespace, Key: key, Value: value}
+	return database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "namespace"}, {Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&entry).Error
+}
+
+// DeleteScriptKVKey 删除单个键
+func DeleteScriptKVKey(namespace, key string) error {
+	return database.DB.Where("namespace = ? AND key = ?", namespace, key).Delete(&ScriptKV{}).Error
+}
+
+// ListScriptKV 列出命名空间下的全部键值
+func ListScriptKV(namespace string) ([]ScriptKV, error) {
+	var entries []ScriptKV
+	err := database.DB.Where("namespace = ?", namespace).Order("key ASC").Find(&entries).Error
+	return entries, err
+}
+
+// DeleteScriptKV 删除命名空间下的全部键值
+func DeleteScriptKV(namespace string) error {
+	return database.DB.Where("namespace = ?", namespace).Delete(&ScriptKV{}).Error
+}
+
+// GetScriptLimits 读取脚本运行限制设置，未设置的项使用默认值
+func GetScriptLimits() utils.ScriptLimits {
+	limits := utils.DefaultScriptLimits()
+	if v, err := GetSetting(SettingScriptTimeout); err == nil {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limits.Timeout = time.Duration(n) * time.Second
+		}
+	}
+	if v, err := GetSetting(SettingScriptMaxMemory); err == nil {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			limits.MaxMemory = int64(n) << 20
+		}
+	}
+	if v, err := GetSetting(SettingScriptMaxOutput); err == nil {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			limits.MaxOutput = n << 20
+		}
+	}
+	if v, err := GetSetting(SettingScriptFetchAllowlist); err == nil {
+		limits.FetchAllowlist = ParseScriptFetchAllowlist(v)
+	}
+	return limits
+}
+
+// ParseScriptFetchAllowlist 解析域名白名单（逗号或换行分隔）
+func ParseScriptFetchAllowlist(s string) []string {
+	var hosts []string
+	for _, h := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' || r == ' ' }) {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// ListScriptTags 提供给脚本的标签列表
+func ListScriptTags() []utils.ScriptTag {
+	tags := tagCache.GetAll()
+	result := make([]utils.ScriptTag, 0, len(tags))
+	for _, t := range tags {
+		result = append(result, utils.ScriptTag{Name: t.Name, Group: t.GroupName, Color: t.Color, Description: t.Description})
+	}
+	return result
+}
+
+// ListScriptTagNodeNames 提供给脚本的带有指定标签的节点名称
+func ListScriptTagNodeNames(tagName string) []string {
+	nodes := nodeCache.Filter(func(n Node) bool { return n.HasTagName(tagName) })
+	names := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		names = append(names, n.Name)
+	}
+	return names
+}
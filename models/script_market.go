@@ -0,0 +1,502 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sublink/database"
+	"sublink/utils"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 脚本头部信息块标记
+const (
+	ScriptHeaderBegin = "==SublinkScript=="
+	ScriptHeaderEnd   = "==/SublinkScript=="
+)
+
+// 远程脚本下载限制
+const (
+	scriptSourceMaxSize = 1 << 20
+	scriptSourceTimeout = 30 * time.Second
+)
+
+// ScriptParam 脚本头部声明的参数
+type ScriptParam struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"` // string, number, boolean
+	Default     interface{} `json:"default"`
+	Description string      `json:"description"`
+}
+
+// ScriptHeader 脚本头部信息块
+//
+//	// ==SublinkScript==
+//	// @name        香港节点筛选
+//	// @version     1.2.0
+//	// @description 只保留香港节点
+//	// @hooks       airport_sync,cron
+//	// @cron        0 */6 * * *
+//	// @param       keyword string "香港" 保留的关键字
+//	// ==/SublinkScript==
+type ScriptHeader struct {
+	Name        string        `json:"name"`
+	Version     string        `json:"version"`
+	Description string        `json:"description"`
+	Hooks       string        `json:"hooks"`
+	CronExpr    string        `json:"cronExpr"`
+	Params      []ScriptParam `json:"params"`
+}
+
+// ScriptVersion 脚本历史版本，订阅可以固定使用其中的版本
+type ScriptVersion struct {
+	ID        int       `gorm:"primaryKey;autoIncrement" json:"id"`
+	ScriptID  int       `gorm:"uniqueIndex:idx_script_version" json:"scriptId"`
+	Version   string    `gorm:"size:64;uniqueIndex:idx_script_version" json:"version"`
+	Content   string    `gorm:"type:text" json:"content,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ParseScriptHeader 解析脚本头部信息块，没有信息块时返回 nil
+func ParseScriptHeader(content string) (*ScriptHeader, error) {
+	begin := strings.Index(content, ScriptHeaderBegin)
+	if begin < 0 {
+		return nil, nil
+	}
+	rest := content[begin+len(ScriptHeaderBegin):]
+	end := strings.Index(rest, ScriptHeaderEnd)
+	if end < 0 {
+		return nil, fmt.Errorf("头部信息块缺少结束标记 %s", ScriptHeaderEnd)
+	}
+
+	header := &ScriptHeader{}
+	for _, line := range strings.Split(rest[:end], "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimSpace(strings.TrimLeft(line, "/*"))
+		if !strings.HasPrefix(line, "@") {
+			continue
+		}
+		key, value, _ := strings.Cut(line[1:], " ")
+		value = strings.TrimSpace(value)
+		switch key {
+		case "name":
+			header.Name = value
+		case "version":
+			header.Version = value
+		case "description":
+			header.Description = value
+		case "hooks":
+			hooks, err := NormalizeScriptHooks(value)
+			if err != nil {
+				return nil, err
+			}
+			header.Hooks = hooks
+		case "cron":
+			header.CronExpr = value
+		case "param":
+			param, err := parseScriptParam(value)
+			if err != nil {
+				return nil, err
+			}
+			header.Params = append(header.Params, param)
+		}
+	}
+	return header, nil
+}
+
+// parseScriptParam 解析 @param 行：名称 类型 默认值 描述，默认值含空格时使用双引号
+func parseScriptParam(s string) (ScriptParam, error) {
+	tokens := splitScriptParamTokens(s, 4)
+	if len(tokens) < 2 {
+		return ScriptParam{}, fmt.Errorf("参数声明格式错误: %s", s)
+	}
+	param := ScriptParam{Name: tokens[0], Type: tokens[1]}
+	raw := ""
+	if len(tokens) > 2 {
+		raw = tokens[2]
+	}
+	if len(tokens) > 3 {
+		param.Description = tokens[3]
+	}
+	switch param.Type {
+	case "string":
+		param.Default = raw
+	case "number":
+		n := 0.0
+		if raw != "" {
+			var err error
+			if n, err = strconv.ParseFloat(raw, 64); err != nil {
+				return ScriptParam{}, fmt.Errorf("参数 %s 的默认值不是数字", param.Name)
+			}
+		}
+		param.Default = n
+	case "boolean":
+		b := false
+		if raw != "" {
+			var err error
+			if b, err = strconv.ParseBool(raw); err != nil {
+				return ScriptParam{}, fmt.Errorf("参数 %s 的默认值不是布尔值", param.Name)
+			}
+		}
+		param.Default = b
+	default:
+		return ScriptParam{}, fmt.Errorf("参数 %s 的类型 %s 不支持（string, number, boolean）", param.Name, param.Type)
+	}
+	return param, nil
+}
+
+// splitScriptParamTokens 按空白拆分，支持双引号；最后一段保留剩余内容
+func splitScriptParamTokens(s string, max int) []string {
+	var tokens []string
+	for len(tokens) < max-1 {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		if s == "" {
+			return tokens
+		}
+		if s[0] == '"' {
+			if end := strings.Index(s[1:], `"`); end >= 0 {
+				tokens = append(tokens, s[1:end+1])
+				s = s[end+2:]
+				continue
+			}
+		}
+		idx := strings.IndexFunc(s, unicode.IsSpace)
+		if idx < 0 {
+			return append(tokens, s)
+		}
+		tokens = append(tokens, s[:idx])
+		s = s[idx:]
+	}
+	if s = strings.TrimSpace(s); s != "" {
+		tokens = append(tokens, strings.Trim(s, `"`))
+	}
+	return tokens
+}
+
+// CompareScriptVersions 比较版本号，返回 -1、0、1
+// 按点分隔的数字逐段比较；主版本相同时带预发布后缀（如 1.0.0-beta）的版本较旧
+func CompareScriptVersions(a, b string) int {
+	mainA, preA, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(a), "v"), "-")
+	mainB, preB, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(b), "v"), "-")
+	pa, pb := strings.Split(mainA, "."), strings.Split(mainB, ".")
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y string
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if c := compareVersionSegment(x, y); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	case preA < preB:
+		return -1
+	}
+	return 1
+}
+
+// compareVersionSegment 比较版本号的一段，都是数字时按数值比较，缺失视为 0
+func compareVersionSegment(x, y string) int {
+	if x == "" {
+		x = "0"
+	}
+	if y == "" {
+		y = "0"
+	}
+	nx, errX := strconv.Atoi(x)
+	ny, errY := strconv.Atoi(y)
+	if errX == nil && errY == nil {
+		switch {
+		case nx < ny:
+			return -1
+		case nx > ny:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(x, y)
+}
+
+// NormalizeScriptSourceURL 校验脚本地址，并将 GitHub/Gist 页面地址转换为原始文件地址
+func NormalizeScriptSourceURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("脚本地址必须是 http(s) 地址")
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch strings.ToLower(u.Host) {
+	case "github.com":
+		// github.com/{owner}/{repo}/blob/{ref}/{path...}
+		if len(parts) >= 5 && parts[2] == "blob" {
+			return "https://raw.githubusercontent.com/" + strings.Join(append(parts[:2:2], parts[3:]...), "/"), nil
+		}
+	case "gist.github.com":
+		// gist.github.com/{user}/{id}
+		if len(parts) == 2 {
+			return "https://gist.githubusercontent.com/" + parts[0] + "/" + parts[1] + "/raw", nil
+		}
+	}
+	return u.String(), nil
+}
+
+// FetchScriptSource 下载远程脚本并解析头部信息块（必须声明 name 与 version）
+// useProxy 为 true 时依次尝试候选代理，没有可用代理时不会回退直连
+func FetchScriptSource(sourceURL string, useProxy bool) (string, *ScriptHeader, error) {
+	proxyOpts := utils.ProxyOptions{UseProxy: useProxy, Timeout: scriptSourceTimeout}
+	resp, _, err := utils.DoWithProxyFallback(proxyOpts, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodGet, sourceURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", "sublink")
+		return req, nil
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("下载脚本失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("下载脚本失败: HTTP %d", resp.StatusCode)
+	}
+	// 多读 1 字节用于判断是否超出大小限制，避免读取完整的超大响应
+	data, err := io.ReadAll(io.LimitReader(resp.Body, scriptSourceMaxSize+1))
+	if err != nil {
+		return "", nil, fmt.Errorf("读取脚本失败: %w", err)
+	}
+	if len(data) > scriptSourceMaxSize {
+		return "", nil, fmt.Errorf("脚本不能超过 %s", utils.FormatBytes(scriptSourceMaxSize))
+	}
+	content := string(data)
+	header, err := ParseScriptHeader(content)
+	if err != nil {
+		return "", nil, err
+	}
+	if header == nil || header.Name == "" || header.Version == "" {
+		return "", nil, fmt.Errorf("脚本缺少头部信息块或未声明 @name / @version")
+	}
+	return content, header, nil
+}
+
+// InstallScriptFromURL 从远程地址安装脚本
+func InstallScriptFromURL(rawURL string, useProxy bool) (*Script, error) {
+	sourceURL, err := NormalizeScriptSourceURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if existing := scriptCache.Filter(func(s Script) bool { return s.SourceURL == sourceURL }); len(existing) > 0 {
+		return nil, fmt.Errorf("该地址的脚本已安装（%s），请使用检查更新", existing[0].Name)
+	}
+	content, header, err := FetchScriptSource(sourceURL, useProxy)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	script := &Script{
+		Name:            header.Name,
+		Version:         header.Version,
+		Content:         content,
+		Hooks:           header.Hooks,
+		CronExpr:        header.CronExpr,
+		SourceURL:       sourceURL,
+		SourceUseProxy:  useProxy,
+		SourceCheckedAt: &now,
+	}
+	if script.CheckNameVersion() {
+		return nil, fmt.Errorf("脚本 %s %s 已存在", script.Name, script.Version)
+	}
+	if err := script.Add(); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+// CheckSourceUpdate 检查来源地址是否有新版本，有则保存为待升级内容 (Write-Through)
+// 返回是否有可用的新版本
+func (s *Script) CheckSourceUpdate() (bool, error) {
+	if s.SourceURL == "" {
+		return false, errors.New("该脚本不是从地址安装的")
+	}
+	content, header, fetchErr := FetchScriptSource(s.SourceURL, s.SourceUseProxy)
+
+	now := time.Now()
+	updates := map[string]interface{}{"SourceCheckedAt": &now, "SourceError": ""}
+	available := false
+	if fetchErr != nil {
+		updates["SourceError"] = fetchErr.Error()
+	} else if CompareScriptVersions(header.Version, s.Version) > 0 {
+		updates["PendingVersion"] = header.Version
+		updates["PendingContent"] = content
+		available = true
+	} else {
+		updates["PendingVersion"] = ""
+		updates["PendingContent"] = ""
+	}
+
+	fields := make([]string, 0, len(updates))
+	for k := range updates {
+		fields = append(fields, k)
+	}
+	if err := database.DB.Model(s).Select(fields).Updates(updates).Error; err != nil {
+		return false, err
+	}
+	apply := func(t *Script) {
+		t.SourceCheckedAt = &now
+		t.SourceError, _ = updates["SourceError"].(string)
+		if v, ok := updates["PendingVersion"].(string); ok {
+			t.PendingVersion = v
+			t.PendingContent = updates["PendingContent"].(string)
+		}
+	}
+	apply(s)
+	if cached, ok := scriptCache.Get(s.ID); ok {
+		apply(&cached)
+		scriptCache.Set(s.ID, cached)
+	}
+	return available, fetchErr
+}
+
+// ApplySourceUpdate 升级到待升级的版本，头部声明的钩子与 Cron 表达式同步更新
+func (s *Script) ApplySourceUpdate() error {
+	if s.PendingContent == "" {
+		return errors.New("没有可升级的版本")
+	}
+	header, err := ParseScriptHeader(s.PendingContent)
+	if err != nil {
+		return err
+	}
+	if header == nil || header.Version == "" {
+		return errors.New("待升级的脚本缺少版本信息")
+	}
+
+	updated := *s
+	updated.Content = s.PendingContent
+	updated.Version = header.Version
+	if header.Hooks != "" {
+		updated.Hooks = header.Hooks
+	}
+	if header.CronExpr != "" {
+		updated.CronExpr = header.CronExpr
+	}
+	if updated.CheckNameVersion() {
+		return fmt.Errorf("脚本 %s %s 已存在", updated.Name, updated.Version)
+	}
+	if err := updated.Update(); err != nil {
+		return err
+	}
+	if err := database.DB.Model(&updated).Select("PendingVersion", "PendingContent").Updates(map[string]interface{}{
+		"PendingVersion": "",
+		"PendingContent": "",
+	}).Error; err != nil {
+		return err
+	}
+	updated.PendingVersion, updated.PendingContent = "", ""
+	if cached, ok := scriptCache.Get(s.ID); ok {
+		cached.PendingVersion, cached.PendingContent = "", ""
+		scriptCache.Set(s.ID, cached)
+	}
+	*s = updated
+	return nil
+}
+
+// ParamList 脚本头部声明的参数
+func (s *Script) ParamList() []ScriptParam {
+	if s.Parameters == "" {
+		return nil
+	}
+	var params []ScriptParam
+	if err := json.Unmarshal([]byte(s.Parameters), &params); err != nil {
+		return nil
+	}
+	return params
+}
+
+// syncParameters 根据脚本内容的头部信息块更新参数声明
+func (s *Script) syncParameters() {
+	s.Parameters = ""
+	header, err := ParseScriptHeader(s.Content)
+	if err != nil || header == nil || len(header.Params) == 0 {
+		return
+	}
+	if data, err := json.Marshal(header.Params); err == nil {
+		s.Parameters = string(data)
+	}
+}
+
+// ResolveScriptParams 合并参数默认值与订阅中配置的参数值（JSON 对象）
+// 脚本声明了参数时只接受声明过的参数
+func ResolveScriptParams(declared []ScriptParam, values string) map[string]interface{} {
+	params := make(map[string]interface{}, len(declared))
+	for _, p := range declared {
+		params[p.Name] = p.Default
+	}
+	if values == "" {
+		return params
+	}
+	var custom map[string]interface{}
+	if err := json.Unmarshal([]byte(values), &custom); err != nil {
+		return params
+	}
+	for k, v := range custom {
+		if _, ok := params[k]; ok || len(declared) == 0 {
+			params[k] = v
+		}
+	}
+	return params
+}
+
+// saveScriptVersion 保存当前内容为历史版本（同一版本覆盖）
+func saveScriptVersion(s *Script) error {
+	version := ScriptVersion{ScriptID: s.ID, Version: s.Version, Content: s.Content}
+	return database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "script_id"}, {Name: "version"}},
+		DoUpdates: clause.AssignmentColumns([]string{"content", "updated_at"}),
+	}).Create(&version).Error
+}
+
+// ListScriptVersions 获取脚本的历史版本（不含内容），按保存时间倒序
+func ListScriptVersions(scriptID int) ([]ScriptVersion, error) {
+	var versions []ScriptVersion
+	err := database.DB.Select("id", "script_id", "version", "created_at", "updated_at").
+		Where("script_id = ?", scriptID).Order("created_at DESC").Find(&versions).Error
+	return versions, err
+}
+
+// GetScriptVersion 获取脚本的指定历史版本
+func GetScriptVersion(scriptID int, version string) (*ScriptVersion, error) {
+	var v ScriptVersion
+	err := database.DB.Where("script_id = ? AND version = ?", scriptID, version).First(&v).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("版本 %s 不存在", version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// ListScriptsWithSource 获取从地址安装的脚本
+func ListScriptsWithSource() []Script {
+	return scriptCache.FilterSorted(func(s Script) bool { return s.SourceURL != "" }, func(a, b Script) bool {
+		return a.ID < b.ID
+	})
+}
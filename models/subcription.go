@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -41,6 +42,8 @@ type Subcription struct {
 	MinSpeed              float64          `json:"MinSpeed"`                                  // 最小速度(MB/s)
 	CountryWhitelist      string           `json:"CountryWhitelist"`                          // 国家白名单（逗号分隔）
 	CountryBlacklist      string           `json:"CountryBlacklist"`                          // 国家黑名单（逗号分隔）
+	ASNWhitelist          string           `json:"ASNWhitelist"`                              // 落地ASN白名单（逗号分隔，如 AS13335）
+	ASNBlacklist          string           `json:"ASNBlacklist"`                              // 落地ASN黑名单（逗号分隔）
 	NodeNameRule          string           `json:"NodeNameRule"`                              // 节点命名规则模板
 	NodeNamePreprocess    string           `json:"NodeNamePreprocess"`                        // 原名预处理规则 (JSON数组)
 	NodeNameWhitelist     string           `json:"NodeNameWhitelist"`                         // 节点名称白名单 (JSON数组)
@@ -54,6 +57,9 @@ type Subcription struct {
 	CreatedAt             time.Time        `json:"CreatedAt"`
 	UpdatedAt             time.Time        `json:"UpdatedAt"`
 	DeletedAt             gorm.DeletedAt   `gorm:"index" json:"DeletedAt"`
+
+	// 按标签动态选择节点
+	TagExpression string `gorm:"type:text" json:"TagExpression"` // 条件表达式（语法同标签规则），设置后由匹配的节点定义订阅的节点集合，随标签变化实时更新
 }
 
 type GroupWithSort struct {
@@ -64,6 +70,24 @@ type GroupWithSort struct {
 type ScriptWithSort struct {
 	Script
 	Sort int `json:"Sort"`
+
+	// 订阅中的脚本配置
+	PinVersion  string `json:"PinVersion"`  // 固定使用的版本，为空表示跟随最新版本
+	ParamValues string `json:"ParamValues"` // 参数值（JSON 对象）
+}
+
+// Source 返回订阅中执行的脚本：固定了版本时使用该版本的内容，并注入订阅中配置的参数
+func (s *ScriptWithSort) Source() utils.ScriptSource {
+	src := s.Script.Source()
+	if s.PinVersion != "" && s.PinVersion != s.Version {
+		if v, err := GetScriptVersion(s.ID, s.PinVersion); err == nil {
+			src.Version, src.Content = v.Version, v.Content
+		} else {
+			utils.Warn("脚本【%s】固定的版本 %s 不可用，使用当前版本 %s: %v", s.Name, s.PinVersion, s.Version, err)
+		}
+	}
+	src.Params = ResolveScriptParams(s.ParamList(), s.ParamValues)
+	return src
 }
 
 type SubcriptionNode struct {
@@ -84,6 +108,10 @@ type SubcriptionScript struct {
 	SubcriptionID int `gorm:"primaryKey"`
 	ScriptID      int `gorm:"primaryKey"`
 	Sort          int `gorm:"default:0"`
+
+	// 订阅中的脚本配置
+	PinVersion  string // 固定使用的版本，为空表示跟随最新版本
+	ParamValues string `gorm:"type:text"` // 参数值（JSON 对象）
 }
 
 type NodeWithSort struct {
@@ -177,6 +205,8 @@ func (sub *Subcription) Update() error {
 		"min_speed":                sub.MinSpeed,
 		"country_whitelist":        sub.CountryWhitelist,
 		"country_blacklist":        sub.CountryBlacklist,
+		"asn_whitelist":            sub.ASNWhitelist,
+		"asn_blacklist":            sub.ASNBlacklist,
 		"node_name_rule":           sub.NodeNameRule,
 		"node_name_preprocess":     sub.NodeNamePreprocess,
 		"node_name_whitelist":      sub.NodeNameWhitelist,
@@ -187,6 +217,7 @@ func (sub *Subcription) Update() error {
 		"protocol_blacklist":       sub.ProtocolBlacklist,
 		"deduplication_rule":       sub.DeduplicationRule,
 		"refresh_usage_on_request": sub.RefreshUsageOnRequest,
+		"tag_expression":           sub.TagExpression,
 	}
 	err := database.DB.Model(&Subcription{}).Where("id = ? or name = ?", sub.ID, sub.Name).Updates(updates).Error
 	if err != nil {
@@ -245,6 +276,15 @@ func (sub *Subcription) UpdateGroups(groups []string) error {
 
 // UpdateScripts 更新脚本关联
 func (sub *Subcription) UpdateScripts(scriptIDs []int) error {
+	// 保留仍然关联的脚本的版本与参数配置
+	var existing []SubcriptionScript
+	if err := database.DB.Where("subcription_id = ?", sub.ID).Find(&existing).Error; err != nil {
+		return err
+	}
+	settings := make(map[int]SubcriptionScript, len(existing))
+	for _, ss := range existing {
+		settings[ss.ScriptID] = ss
+	}
 	// 先删除旧的关联
 	if err := database.DB.Where("subcription_id = ?", sub.ID).Delete(&SubcriptionScript{}).Error; err != nil {
 		return err
@@ -255,6 +295,8 @@ func (sub *Subcription) UpdateScripts(scriptIDs []int) error {
 			SubcriptionID: sub.ID,
 			ScriptID:      scriptID,
 			Sort:          i,
+			PinVersion:    settings[scriptID].PinVersion,
+			ParamValues:   settings[scriptID].ParamValues,
 		}
 		if err := database.DB.Create(&subScript).Error; err != nil {
 			return err
@@ -263,6 +305,35 @@ func (sub *Subcription) UpdateScripts(scriptIDs []int) error {
 	return nil
 }
 
+// UpdateScriptSettings 更新订阅中某个脚本的固定版本与参数值
+func (sub *Subcription) UpdateScriptSettings(scriptID int, pinVersion, paramValues string) error {
+	script, err := GetScriptByID(scriptID)
+	if err != nil {
+		return errors.New("脚本不存在")
+	}
+	if pinVersion != "" && pinVersion != script.Version {
+		if _, err := GetScriptVersion(scriptID, pinVersion); err != nil {
+			return err
+		}
+	}
+	if paramValues != "" {
+		var values map[string]interface{}
+		if err := json.Unmarshal([]byte(paramValues), &values); err != nil {
+			return fmt.Errorf("参数值必须是 JSON 对象: %w", err)
+		}
+	}
+	result := database.DB.Model(&SubcriptionScript{}).
+		Where("subcription_id = ? AND script_id = ?", sub.ID, scriptID).
+		Updates(map[string]interface{}{"pin_version": pinVersion, "param_values": paramValues})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("订阅未关联该脚本")
+	}
+	return nil
+}
+
 // 查找订阅（优先从缓存查找）
 func (sub *Subcription) Find() error {
 	// 优先从缓存查找
@@ -295,6 +366,16 @@ func (sub *Subcription) Find() error {
 func (sub *Subcription) ApplyFilters(nodes []Node) []Node {
 	result := nodes
 
+	// 0. 排除隔离/标记状态的节点（节点生命周期）
+	activeNodes := make([]Node, 0, len(result))
+	for _, node := range result {
+		if node.IsNodeExcluded() {
+			continue
+		}
+		activeNodes = append(activeNodes, node)
+	}
+	result = activeNodes
+
 	// 1. 延迟和速度过滤
 	if sub.DelayTime > 0 || sub.MinSpeed > 0 {
 		var filteredNodes []Node
@@ -353,28 +434,31 @@ func (sub *Subcription) ApplyFilters(nodes []Node) []Node {
 		result = filteredNodes
 	}
 
-	// 3. 标签过滤
-	if sub.TagWhitelist != "" || sub.TagBlacklist != "" {
-		whitelistTags := make(map[string]bool)
-		blacklistTags := make(map[string]bool)
-
-		if sub.TagWhitelist != "" {
-			for _, t := range strings.Split(sub.TagWhitelist, ",") {
-				t = strings.TrimSpace(t)
-				if t != "" {
-					whitelistTags[t] = true
-				}
-			}
-		}
+	// 3. 落地 ASN 过滤
+	if sub.ASNWhitelist != "" || sub.ASNBlacklist != "" {
+		whitelistASN := parseASNList(sub.ASNWhitelist)
+		blacklistASN := parseASNList(sub.ASNBlacklist)
 
-		if sub.TagBlacklist != "" {
-			for _, t := range strings.Split(sub.TagBlacklist, ",") {
-				t = strings.TrimSpace(t)
-				if t != "" {
-					blacklistTags[t] = true
-				}
+		var filteredNodes []Node
+		for _, node := range result {
+			asn := normalizeASN(node.LandingASN)
+			// 黑名单优先
+			if len(blacklistASN) > 0 && blacklistASN[asn] {
+				continue
+			}
+			// 白名单
+			if len(whitelistASN) > 0 && (asn == "" || !whitelistASN[asn]) {
+				continue
 			}
+			filteredNodes = append(filteredNodes, node)
 		}
+		result = filteredNodes
+	}
+
+	// 4. 标签过滤（父标签包含其全部子孙标签）
+	if sub.TagWhitelist != "" || sub.TagBlacklist != "" {
+		whitelistTags := ExpandTagNames(splitTagList(sub.TagWhitelist))
+		blacklistTags := ExpandTagNames(splitTagList(sub.TagBlacklist))
 
 		var filteredNodes []Node
 		for _, node := range result {
@@ -413,7 +497,7 @@ func (sub *Subcription) ApplyFilters(nodes []Node) []Node {
 		result = filteredNodes
 	}
 
-	// 4. 节点名称过滤
+	// 5. 节点名称过滤
 	hasWhitelistRules := utils.HasActiveNodeNameFilter(sub.NodeNameWhitelist)
 	hasBlacklistRules := utils.HasActiveNodeNameFilter(sub.NodeNameBlacklist)
 
@@ -433,7 +517,7 @@ func (sub *Subcription) ApplyFilters(nodes []Node) []Node {
 		result = filteredNodes
 	}
 
-	// 5. 协议过滤
+	// 6. 协议过滤
 	if sub.ProtocolWhitelist != "" || sub.ProtocolBlacklist != "" {
 		whitelistProtos := make(map[string]bool)
 		blacklistProtos := make(map[string]bool)
@@ -472,14 +556,92 @@ func (sub *Subcription) ApplyFilters(nodes []Node) []Node {
 		result = filteredNodes
 	}
 
-	// 6. 应用去重规则
+	// 7. 应用去重规则
 	result = sub.ApplyDeduplication(result)
 
 	return result
 }
 
+// splitTagList 解析逗号分隔的标签列表
+func splitTagList(list string) []string {
+	var names []string
+	for _, t := range strings.Split(list, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			names = append(names, t)
+		}
+	}
+	return names
+}
+
+// parseASNList 解析逗号分隔的 ASN 列表，兼容 "AS13335" 和 "13335" 两种写法
+func parseASNList(list string) map[string]bool {
+	result := make(map[string]bool)
+	for _, a := range strings.Split(list, ",") {
+		if asn := normalizeASN(a); asn != "" {
+			result[asn] = true
+		}
+	}
+	return result
+}
+
+// normalizeASN 统一 ASN 格式为大写 AS 前缀形式
+func normalizeASN(asn string) string {
+	asn = strings.ToUpper(strings.TrimSpace(asn))
+	if asn == "" {
+		return ""
+	}
+	if !strings.HasPrefix(asn, "AS") {
+		asn = "AS" + asn
+	}
+	return asn
+}
+
 // 读取订阅
 func (sub *Subcription) GetSub(clientType string) error {
+	if err := sub.LoadFilteredNodes(); err != nil {
+		return err
+	}
+
+	// 获取脚本信息及其排序
+	var scriptsWithSort []ScriptWithSort
+	err := database.DB.Table("scripts").
+		Select("scripts.*, subcription_scripts.sort, subcription_scripts.pin_version, subcription_scripts.param_values").
+		Joins("LEFT JOIN subcription_scripts ON subcription_scripts.script_id = scripts.id").
+		Where("subcription_scripts.subcription_id = ?", sub.ID).
+		Order("subcription_scripts.sort ASC").
+		Scan(&scriptsWithSort).Error
+	if err != nil {
+		return err
+	}
+	sub.ScriptsWithSort = scriptsWithSort
+
+	// 执行节点过滤脚本
+	sub.Nodes = sub.ApplyNodeFilterScripts(sub.Nodes, scriptsWithSort, clientType)
+
+	return nil
+}
+
+// LoadFilteredNodes 收集订阅的节点并应用过滤条件，结果写入 sub.Nodes
+// 设置了标签表达式时节点集合由表达式定义，否则按排序收集直接选择的节点与分组
+// 不执行节点过滤脚本，脚本试运行以此作为输入
+func (sub *Subcription) LoadFilteredNodes() error {
+	if strings.TrimSpace(sub.TagExpression) != "" {
+		exprNodes, err := MatchNodesByTagExpression(sub.TagExpression)
+		if err != nil {
+			return err
+		}
+		nodeMap := make(map[string]bool) // 用于去重
+		sub.Nodes = make([]Node, 0, len(exprNodes))
+		for _, node := range exprNodes {
+			if !nodeMap[node.Name] {
+				sub.Nodes = append(sub.Nodes, node)
+				nodeMap[node.Name] = true
+			}
+		}
+		sub.Nodes = sub.ApplyFilters(sub.Nodes)
+		return nil
+	}
+
 	// 定义节点排序项结构
 	type NodeSortItem struct {
 		Node
@@ -591,23 +753,22 @@ func (sub *Subcription) GetSub(clientType string) error {
 	// 调用共用的过滤方法
 	sub.Nodes = sub.ApplyFilters(sub.Nodes)
 
-	// 获取脚本信息及其排序
-	var scriptsWithSort []ScriptWithSort
-	err = database.DB.Table("scripts").
-		Select("scripts.*, subcription_scripts.sort").
-		Joins("LEFT JOIN subcription_scripts ON subcription_scripts.script_id = scripts.id").
-		Where("subcription_scripts.subcription_id = ?", sub.ID).
-		Order("subcription_scripts.sort ASC").
-		Scan(&scriptsWithSort).Error
+	return nil
+}
+
+// MatchNodesByTagExpression 返回匹配订阅标签表达式的全部节点（按节点 ID 排序），表达式为空时返回空列表
+// 每次读取订阅时实时求值，节点标签变化后无需修改订阅即可生效
+func MatchNodesByTagExpression(expression string) ([]Node, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, nil
+	}
+	expr, err := CompileNodeExpression(expression)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("订阅标签表达式错误: %w", err)
 	}
-	sub.ScriptsWithSort = scriptsWithSort
-
-	// 执行节点过滤脚本
-	sub.Nodes = sub.ApplyNodeFilterScripts(sub.Nodes, scriptsWithSort, clientType)
-
-	return nil
+	return nodeCache.FilterSorted(expr.Match, func(a, b Node) bool {
+		return a.ID < b.ID
+	}), nil
 }
 
 // 订阅列表（从缓存获取，批量加载关联数据解决 N+1）
@@ -746,15 +907,9 @@ func batchLoadSubcriptionRelations(subs []Subcription) error {
 	}
 
 	// 按订阅 ID 分组脚本 ID
-	subScriptIDs := make(map[int][]struct {
-		ScriptID int
-		Sort     int
-	})
+	subScriptIDs := make(map[int][]SubcriptionScript)
 	for _, ss := range subScripts {
-		subScriptIDs[ss.SubcriptionID] = append(subScriptIDs[ss.SubcriptionID], struct {
-			ScriptID int
-			Sort     int
-		}{ss.ScriptID, ss.Sort})
+		subScriptIDs[ss.SubcriptionID] = append(subScriptIDs[ss.SubcriptionID], ss)
 	}
 
 	// 使用脚本缓存获取脚本详情
@@ -764,8 +919,10 @@ func batchLoadSubcriptionRelations(subs []Subcription) error {
 		for _, si := range scriptInfos {
 			if script, err := GetScriptByID(si.ScriptID); err == nil {
 				scriptsWithSort = append(scriptsWithSort, ScriptWithSort{
-					Script: *script,
-					Sort:   si.Sort,
+					Script:      *script,
+					Sort:        si.Sort,
+					PinVersion:  si.PinVersion,
+					ParamValues: si.ParamValues,
 				})
 			}
 		}
@@ -830,6 +987,8 @@ func (sub *Subcription) Copy() (*Subcription, error) {
 		MinSpeed:              sub.MinSpeed,
 		CountryWhitelist:      sub.CountryWhitelist,
 		CountryBlacklist:      sub.CountryBlacklist,
+		ASNWhitelist:          sub.ASNWhitelist,
+		ASNBlacklist:          sub.ASNBlacklist,
 		NodeNameRule:          sub.NodeNameRule,
 		NodeNamePreprocess:    sub.NodeNamePreprocess,
 		NodeNameWhitelist:     sub.NodeNameWhitelist,
@@ -840,6 +999,7 @@ func (sub *Subcription) Copy() (*Subcription, error) {
 		ProtocolBlacklist:     sub.ProtocolBlacklist,
 		DeduplicationRule:     sub.DeduplicationRule,
 		RefreshUsageOnRequest: sub.RefreshUsageOnRequest,
+		TagExpression:         sub.TagExpression,
 	}
 
 	// 使用事务确保数据一致性
@@ -901,6 +1061,8 @@ func (sub *Subcription) Copy() (*Subcription, error) {
 			SubcriptionID: newSub.ID,
 			ScriptID:      script.ScriptID,
 			Sort:          script.Sort,
+			PinVersion:    script.PinVersion,
+			ParamValues:   script.ParamValues,
 		}
 		if err := tx.Create(&newScript).Error; err != nil {
 			tx.Rollback()
@@ -1129,6 +1291,9 @@ func (sub *Subcription) PreviewSub() (*PreviewResult, error) {
 				Index:       idx + 1,
 				Protocol:    utils.GetProtocolFromLink(node.Link),
 				Tags:        node.Tags,
+				LandingASN:  node.LandingASN,
+				LandingISP:  node.LandingISP,
+				LandingCity: node.LandingCity,
 			})
 			previewLink = utils.RenameNodeLink(node.Link, previewName)
 		}
@@ -1216,10 +1381,10 @@ func (sub *Subcription) ApplyNodeFilterScripts(nodes []Node, scripts []ScriptWit
 	}
 
 	for _, script := range scripts {
-		resJSON, err := utils.RunNodeFilterScript(script.Content, nodesJSON, clientType)
+		resJSON, err := utils.RunNodeFilterScript(script.Source(), nodesJSON, clientType)
 		if err != nil {
 			// filterNode 函数不存在时跳过，不报错（脚本可能只定义了 subMod）
-			if strings.Contains(err.Error(), "filterNode function not found") {
+			if errors.Is(err, utils.ErrScriptFunctionNotFound) {
 				continue
 			}
 			utils.Error("节点过滤脚本执行失败: %v", err)
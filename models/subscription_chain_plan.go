@@ -0,0 +1,110 @@
+package models
+
+import (
+	"strings"
+	"sublink/utils"
+)
+
+// ChainClientSupport 客户端格式对链式代理的支持情况
+type ChainClientSupport struct {
+	Client    string `json:"client"`    // 客户端类型，与 GetSub 的 clientType 一致
+	Field     string `json:"field"`     // 输出配置中表示前置代理的字段，不支持时为空
+	Supported bool   `json:"supported"` // 是否支持链式代理
+	Fallback  string `json:"fallback"`  // 不支持时的处理方式
+}
+
+// ChainClientSupports 各输出格式的链式代理支持情况
+// v2ray 输出为 base64 分享链接列表，链接格式无法表达前置代理，节点按直连输出
+var ChainClientSupports = []ChainClientSupport{
+	{Client: "clash", Field: "dialer-proxy", Supported: true},
+	{Client: "surge", Field: "underlying-proxy", Supported: true},
+	{Client: "v2ray", Supported: false, Fallback: "分享链接无法表达前置代理，节点按直连输出，链式代理规则与节点前置代理均被忽略"},
+}
+
+// ChainDialerPlan 订阅级链式代理解析结果，供各客户端格式共用
+type ChainDialerPlan struct {
+	NodeNames    map[int]string     // 节点ID -> 输出名称（已应用预处理与重命名规则）
+	CustomGroups []CustomProxyGroup // 需要输出的自定义代理组
+
+	chainNodeDialers map[string]string // 链路中间节点（含中间自定义代理组成员）名称 -> 前置代理
+	targetDialers    map[int]string    // 规则目标节点ID -> 前置代理
+}
+
+// BuildChainDialerPlan 按订阅节点与启用的链式代理规则解析每个节点的前置代理
+// sub.Nodes 需已加载（GetSub 之后调用）
+func BuildChainDialerPlan(sub *Subcription, rules []SubscriptionChainRule) *ChainDialerPlan {
+	plan := &ChainDialerPlan{
+		NodeNames:        make(map[int]string, len(sub.Nodes)),
+		chainNodeDialers: make(map[string]string),
+		targetDialers:    make(map[int]string),
+	}
+	for idx, v := range sub.Nodes {
+		plan.NodeNames[v.ID] = sub.OutputNodeName(v, idx)
+	}
+
+	plan.CustomGroups = CollectCustomProxyGroups(rules, sub.Nodes, plan.NodeNames)
+	if len(rules) == 0 {
+		return plan
+	}
+
+	for _, v := range sub.Nodes {
+		chainResult := ApplyChainRulesToNodeV2(v, rules, sub.Nodes, plan.NodeNames)
+		if chainResult == nil || chainResult.FinalDialer == "" {
+			continue
+		}
+		// 记录目标节点的前置代理
+		plan.targetDialers[v.ID] = chainResult.FinalDialer
+		// 收集链路中间节点的前置代理（代理组类型的由组本身处理）
+		for _, link := range chainResult.Links {
+			if !link.IsGroup && link.DialerProxy != "" {
+				// 如果同一节点在多个规则中作为中间节点，使用最先匹配的
+				if _, exists := plan.chainNodeDialers[link.ProxyName]; !exists {
+					plan.chainNodeDialers[link.ProxyName] = link.DialerProxy
+				}
+			}
+		}
+		// 收集中间节点自定义代理组内节点的前置代理
+		for memberName, dialerProxy := range chainResult.GroupMemberDialerMap {
+			if _, exists := plan.chainNodeDialers[memberName]; !exists {
+				plan.chainNodeDialers[memberName] = dialerProxy
+			}
+		}
+	}
+	utils.Debug("[ChainProxy] 收集完成: 目标节点=%d, 中间节点=%d", len(plan.targetDialers), len(plan.chainNodeDialers))
+	return plan
+}
+
+// DialerFor 返回节点输出时应使用的前置代理
+// 优先级：链路中间节点 > 节点自身设置 > 规则目标节点
+func (p *ChainDialerPlan) DialerFor(node Node) string {
+	if chainDialer, exists := p.chainNodeDialers[p.NodeNames[node.ID]]; exists {
+		return chainDialer
+	}
+	if dialerProxy := strings.TrimSpace(node.DialerProxyName); dialerProxy != "" {
+		return dialerProxy
+	}
+	return p.targetDialers[node.ID]
+}
+
+// OutputNodeName 计算节点在订阅输出中的名称（预处理 + 重命名规则），idx 为节点在订阅中的下标
+func (sub *Subcription) OutputNodeName(v Node, idx int) string {
+	if sub.NodeNameRule == "" {
+		return v.LinkName
+	}
+	processedLinkName := utils.PreprocessNodeName(sub.NodeNamePreprocess, v.LinkName)
+	return utils.RenameNode(sub.NodeNameRule, utils.NodeInfo{
+		Name:        v.Name,
+		LinkName:    processedLinkName,
+		LinkCountry: v.LinkCountry,
+		Speed:       v.Speed,
+		DelayTime:   v.DelayTime,
+		Group:       v.Group,
+		Source:      v.Source,
+		Index:       idx + 1,
+		Protocol:    utils.GetProtocolFromLink(v.Link),
+		Tags:        v.Tags,
+		LandingASN:  v.LandingASN,
+		LandingISP:  v.LandingISP,
+		LandingCity: v.LandingCity,
+	})
+}
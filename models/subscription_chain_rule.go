@@ -217,6 +217,27 @@ func (r *SubscriptionChainRule) ParseTargetConfig() (*TargetConfig, error) {
 	return &target, nil
 }
 
+// ValidateConditions 校验链路配置与目标配置中的条件表达式
+func (r *SubscriptionChainRule) ValidateConditions() error {
+	items, err := r.ParseChainConfig()
+	if err != nil {
+		return fmt.Errorf("代理链配置格式错误: %w", err)
+	}
+	for i, item := range items {
+		if err := item.NodeConditions.Validate(); err != nil {
+			return fmt.Errorf("代理链第 %d 项条件错误: %w", i+1, err)
+		}
+	}
+	target, err := r.ParseTargetConfig()
+	if err != nil {
+		return fmt.Errorf("目标配置格式错误: %w", err)
+	}
+	if err := target.Conditions.Validate(); err != nil {
+		return fmt.Errorf("目标条件错误: %w", err)
+	}
+	return nil
+}
+
 // MatchTargetCondition 判断节点是否匹配目标条件
 func (r *SubscriptionChainRule) MatchTargetCondition(node Node) bool {
 	target, err := r.ParseTargetConfig()
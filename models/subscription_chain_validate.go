@@ -0,0 +1,468 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChainMaxDepth 前置代理链允许的最大层数（不含落地节点本身）
+const ChainMaxDepth = 8
+
+// chainValidateMaxIssues 校验结果中每类问题的最大条数
+const chainValidateMaxIssues = 100
+
+// chainBuiltinProxies 客户端内置的代理名称，可以作为前置代理但不会继续向上拨号
+var chainBuiltinProxies = map[string]bool{
+	"DIRECT":      true,
+	"REJECT":      true,
+	"REJECT-DROP": true,
+	"PASS":        true,
+	"COMPATIBLE":  true,
+}
+
+// 链路问题类型
+const (
+	ChainIssueCycle    = "cycle"    // 循环拨号
+	ChainIssueDangling = "dangling" // 前置代理不存在
+	ChainIssueDepth    = "depth"    // 链路过深
+	ChainIssueRule     = "rule"     // 规则无法解析，输出时会被跳过
+)
+
+// 链路问题级别
+const (
+	ChainIssueError   = "error"   // 客户端会拒绝配置或无法连接
+	ChainIssueWarning = "warning" // 取决于代理组当前选择的节点
+)
+
+// ChainTemplateGroup 模板中的代理组
+type ChainTemplateGroup struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	Proxies    []string `json:"proxies"`    // 模板中显式列出的成员
+	IncludeAll bool     `json:"includeAll"` // 是否包含订阅全部节点（include-all 或成员为空时由系统追加全部节点）
+}
+
+// ChainIssue 链路校验发现的问题
+type ChainIssue struct {
+	Level   string   `json:"level"`          // error, warning
+	Type    string   `json:"type"`           // cycle, dangling, depth, rule
+	Proxy   string   `json:"proxy"`          // 出问题的代理或规则名称
+	Path    []string `json:"path,omitempty"` // 相关链路，按拨号方向排列（节点 -> 前置代理 -> ...）
+	Message string   `json:"message"`
+}
+
+// ChainValidation 订阅前置代理拓扑的校验结果
+type ChainValidation struct {
+	Valid      bool         `json:"valid"`      // 没有 error 级问题
+	ProxyCount int          `json:"proxyCount"` // 订阅输出的节点数
+	GroupCount int          `json:"groupCount"` // 模板代理组与自定义代理组数
+	EdgeCount  int          `json:"edgeCount"`  // 设置了前置代理的节点数
+	MaxDepth   int          `json:"maxDepth"`   // 最长前置代理链层数
+	DepthLimit int          `json:"depthLimit"`
+	Issues     []ChainIssue `json:"issues"`
+}
+
+// chainGraph 前置代理拓扑：节点指向其前置代理，代理组指向其成员
+type chainGraph struct {
+	dialers   map[string]string   // 节点输出名称 -> 前置代理
+	groups    map[string][]string // 代理组名称 -> 成员
+	groupType map[string]string   // 代理组名称 -> 类型
+	nodes     map[string]Node     // 节点输出名称 -> 节点
+	order     []string            // 节点输出名称（订阅顺序）
+}
+
+// buildChainGraph 按订阅输出的节点、自定义代理组与模板代理组构建前置代理拓扑
+func buildChainGraph(sub *Subcription, plan *ChainDialerPlan, templateGroups []ChainTemplateGroup) *chainGraph {
+	g := &chainGraph{
+		dialers:   make(map[string]string),
+		groups:    make(map[string][]string),
+		groupType: make(map[string]string),
+		nodes:     make(map[string]Node),
+	}
+	for _, node := range sub.Nodes {
+		name := plan.NodeNames[node.ID]
+		if _, exists := g.nodes[name]; exists {
+			continue
+		}
+		g.nodes[name] = node
+		g.order = append(g.order, name)
+		if dialer := plan.DialerFor(node); dialer != "" {
+			g.dialers[name] = dialer
+		}
+	}
+	for _, tg := range templateGroups {
+		members := append([]string{}, tg.Proxies...)
+		if tg.IncludeAll {
+			members = append(members, g.order...)
+		}
+		g.groups[tg.Name] = members
+		g.groupType[tg.Name] = tg.Type
+	}
+	for _, cg := range plan.CustomGroups {
+		g.groups[cg.Name] = cg.Proxies
+		g.groupType[cg.Name] = cg.Type
+	}
+	return g
+}
+
+// next 返回拓扑中某个名称的后继：节点为其前置代理，代理组为其成员
+func (g *chainGraph) next(name string) []string {
+	if members, ok := g.groups[name]; ok {
+		return members
+	}
+	if dialer, ok := g.dialers[name]; ok {
+		return []string{dialer}
+	}
+	return nil
+}
+
+// exists 名称是否为可引用的代理
+func (g *chainGraph) exists(name string) bool {
+	if chainBuiltinProxies[strings.ToUpper(name)] {
+		return true
+	}
+	if _, ok := g.nodes[name]; ok {
+		return true
+	}
+	_, ok := g.groups[name]
+	return ok
+}
+
+// ValidateChainGraph 校验订阅的前置代理拓扑（节点自身的前置代理 + 启用的链式代理规则）
+// sub.Nodes 需已加载；templateGroups 为 Clash 模板中的代理组
+// 报告循环拨号、引用不存在的代理或代理组、超过 ChainMaxDepth 的链路，以及解析失败会被跳过的规则
+func ValidateChainGraph(sub *Subcription, rules []SubscriptionChainRule, templateGroups []ChainTemplateGroup) *ChainValidation {
+	plan := BuildChainDialerPlan(sub, rules)
+	g := buildChainGraph(sub, plan, templateGroups)
+
+	result := &ChainValidation{
+		ProxyCount: len(g.order),
+		GroupCount: len(g.groups),
+		EdgeCount:  len(g.dialers),
+		DepthLimit: ChainMaxDepth,
+		Issues:     []ChainIssue{},
+	}
+	counts := make(map[string]int)
+	addIssue := func(issue ChainIssue) {
+		if counts[issue.Type] >= chainValidateMaxIssues {
+			return
+		}
+		counts[issue.Type]++
+		result.Issues = append(result.Issues, issue)
+	}
+
+	// 1. 规则解析：解析失败的规则在输出时会被跳过，目标节点回退为直连或节点自身的前置代理
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		if _, err := rule.ResolveChainLinks(sub.Nodes, plan.NodeNames); err != nil {
+			addIssue(ChainIssue{
+				Level:   ChainIssueWarning,
+				Type:    ChainIssueRule,
+				Proxy:   rule.Name,
+				Message: fmt.Sprintf("规则 %s 解析失败，输出时将被跳过: %v", rule.Name, err),
+			})
+		}
+	}
+
+	// 2. 悬空引用：前置代理既不是订阅中的节点，也不是模板或自定义代理组
+	for _, name := range g.order {
+		dialer, ok := g.dialers[name]
+		if !ok || g.exists(dialer) {
+			continue
+		}
+		source := "链式代理规则"
+		if strings.TrimSpace(g.nodes[name].DialerProxyName) == dialer {
+			source = "节点前置代理设置"
+		}
+		addIssue(ChainIssue{
+			Level:   ChainIssueError,
+			Type:    ChainIssueDangling,
+			Proxy:   name,
+			Path:    []string{name, dialer},
+			Message: fmt.Sprintf("节点 %s 的前置代理 %s 不存在（来自%s），客户端会拒绝加载配置", name, dialer, source),
+		})
+	}
+
+	// 3. 循环：沿 节点 -> 前置代理、代理组 -> 成员 深度优先搜索回边
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	seenCycles := make(map[string]bool)
+	var stack []string
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, next := range g.next(name) {
+			switch state[next] {
+			case unvisited:
+				if g.exists(next) {
+					visit(next)
+				}
+			case visiting:
+				start := len(stack) - 1
+				for start >= 0 && stack[start] != next {
+					start--
+				}
+				cycle := append(append([]string{}, stack[start:]...), next)
+				key := chainCycleKey(cycle[:len(cycle)-1])
+				if seenCycles[key] {
+					continue
+				}
+				seenCycles[key] = true
+				addIssue(g.cycleIssue(cycle))
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = done
+	}
+	for _, name := range g.order {
+		if state[name] == unvisited {
+			visit(name)
+		}
+	}
+
+	// 4. 链路深度：前置代理层数，代理组取成员中最深的一条
+	depthMemo := make(map[string]int)
+	inProgress := make(map[string]bool)
+	var depth func(name string) int
+	depth = func(name string) int {
+		if d, ok := depthMemo[name]; ok {
+			return d
+		}
+		if inProgress[name] {
+			return 0 // 循环已在上一步报告
+		}
+		inProgress[name] = true
+		d := 0
+		if members, ok := g.groups[name]; ok {
+			for _, m := range members {
+				if md := depth(m); md > d {
+					d = md
+				}
+			}
+		} else if dialer, ok := g.dialers[name]; ok && !chainBuiltinProxies[strings.ToUpper(dialer)] {
+			d = 1 + depth(dialer)
+		}
+		inProgress[name] = false
+		depthMemo[name] = d
+		return d
+	}
+	for _, name := range g.order {
+		d := depth(name)
+		if d > result.MaxDepth {
+			result.MaxDepth = d
+		}
+		if d > ChainMaxDepth {
+			addIssue(ChainIssue{
+				Level:   ChainIssueError,
+				Type:    ChainIssueDepth,
+				Proxy:   name,
+				Path:    g.deepestPath(name, depthMemo),
+				Message: fmt.Sprintf("节点 %s 的前置代理链有 %d 层，超过上限 %d 层", name, d, ChainMaxDepth),
+			})
+		}
+	}
+
+	result.Valid = true
+	for _, issue := range result.Issues {
+		if issue.Level == ChainIssueError {
+			result.Valid = false
+			break
+		}
+	}
+	return result
+}
+
+// cycleIssue 生成循环问题：只经过节点的循环必然发生，经过代理组的循环取决于组当前选择的成员
+func (g *chainGraph) cycleIssue(cycle []string) ChainIssue {
+	level := ChainIssueError
+	for _, name := range cycle {
+		if _, isGroup := g.groups[name]; isGroup {
+			level = ChainIssueWarning
+			break
+		}
+	}
+	message := fmt.Sprintf("前置代理形成循环: %s", strings.Join(cycle, " -> "))
+	if level == ChainIssueWarning {
+		message += "（代理组选中循环内的节点时无法连接）"
+	}
+	return ChainIssue{
+		Level:   level,
+		Type:    ChainIssueCycle,
+		Proxy:   cycle[0],
+		Path:    cycle,
+		Message: message,
+	}
+}
+
+// deepestPath 沿最深的分支还原链路
+func (g *chainGraph) deepestPath(name string, depthMemo map[string]int) []string {
+	path := []string{name}
+	visited := map[string]bool{name: true}
+	for {
+		var next string
+		if members, ok := g.groups[name]; ok {
+			best := -1
+			for _, m := range members {
+				if d, ok := depthMemo[m]; ok && d > best && !visited[m] {
+					best, next = d, m
+				}
+			}
+		} else {
+			next = g.dialers[name]
+		}
+		if next == "" || visited[next] {
+			return path
+		}
+		visited[next] = true
+		path = append(path, next)
+		name = next
+	}
+}
+
+// chainCycleKey 循环的规范化标识（按最小名称旋转），用于去重
+func chainCycleKey(cycle []string) string {
+	if len(cycle) == 0 {
+		return ""
+	}
+	minIdx := 0
+	for i, name := range cycle {
+		if name < cycle[minIdx] {
+			minIdx = i
+		}
+	}
+	rotated := append(append([]string{}, cycle[minIdx:]...), cycle[:minIdx]...)
+	return strings.Join(rotated, "\x00")
+}
+
+// ChainDialHop 拨号测试链路中的一跳
+type ChainDialHop struct {
+	NodeID   int    `json:"nodeId"`
+	Name     string `json:"name"`     // 节点输出名称
+	Protocol string `json:"protocol"` // 节点协议
+	Via      string `json:"via"`      // 通过哪个代理组选中（直接引用节点时为空）
+	Link     string `json:"-"`
+}
+
+// ResolveChainDialPath 解析节点实际的拨号链路，按流量经过的顺序返回（第一跳为入口节点，最后一跳为该节点本身）
+// 代理组按客户端的默认选择取成员：url-test / fallback / load-balance 取延迟最低的节点，其余取第一个节点
+func ResolveChainDialPath(sub *Subcription, rules []SubscriptionChainRule, templateGroups []ChainTemplateGroup, nodeID int) ([]ChainDialHop, error) {
+	plan := BuildChainDialerPlan(sub, rules)
+	g := buildChainGraph(sub, plan, templateGroups)
+
+	name, ok := plan.NodeNames[nodeID]
+	if !ok {
+		return nil, fmt.Errorf("节点 %d 不在订阅中", nodeID)
+	}
+
+	visited := map[string]bool{name: true}
+	hops := []ChainDialHop{g.dialHop(name, "")}
+	for {
+		dialer, ok := g.dialers[name]
+		if !ok || chainBuiltinProxies[strings.ToUpper(dialer)] {
+			break
+		}
+		if len(hops)-1 >= ChainMaxDepth {
+			return nil, fmt.Errorf("前置代理链超过 %d 层", ChainMaxDepth)
+		}
+		next, via, err := g.resolveDialer(dialer, visited)
+		if err != nil {
+			return nil, err
+		}
+		if next == "" {
+			break // 代理组选择了 DIRECT 等内置代理
+		}
+		visited[next] = true
+		hops = append(hops, g.dialHop(next, via))
+		name = next
+	}
+
+	// 反转为流量经过的顺序
+	for i, j := 0, len(hops)-1; i < j; i, j = i+1, j-1 {
+		hops[i], hops[j] = hops[j], hops[i]
+	}
+	return hops, nil
+}
+
+// resolveDialer 将前置代理名称解析为具体节点；代理组按默认选择取成员，嵌套代理组逐层展开
+// 返回空名称表示最终选中了内置代理（直连）
+func (g *chainGraph) resolveDialer(dialer string, visited map[string]bool) (string, string, error) {
+	via := ""
+	seenGroups := make(map[string]bool)
+	for {
+		if _, ok := g.nodes[dialer]; ok {
+			if visited[dialer] {
+				return "", "", fmt.Errorf("前置代理 %s 已在链路中，存在循环", dialer)
+			}
+			return dialer, via, nil
+		}
+		if chainBuiltinProxies[strings.ToUpper(dialer)] {
+			return "", via, nil
+		}
+		members, ok := g.groups[dialer]
+		if !ok {
+			return "", "", fmt.Errorf("前置代理 %s 不存在", dialer)
+		}
+		if seenGroups[dialer] {
+			return "", "", fmt.Errorf("代理组 %s 嵌套循环", dialer)
+		}
+		seenGroups[dialer] = true
+		if via == "" {
+			via = dialer
+		}
+		picked := g.pickGroupMember(dialer, members, visited)
+		if picked == "" {
+			return "", "", fmt.Errorf("代理组 %s 没有可用于拨号的成员", dialer)
+		}
+		dialer = picked
+	}
+}
+
+// pickGroupMember 按代理组类型选择成员，跳过已在链路中的节点
+func (g *chainGraph) pickGroupMember(group string, members []string, visited map[string]bool) string {
+	switch g.groupType[group] {
+	case "url-test", "fallback", "load-balance":
+		best := ""
+		bestDelay := 0
+		for _, m := range members {
+			node, ok := g.nodes[m]
+			if !ok || visited[m] || node.DelayTime <= 0 {
+				continue
+			}
+			if best == "" || node.DelayTime < bestDelay {
+				best, bestDelay = m, node.DelayTime
+			}
+		}
+		if best != "" {
+			return best
+		}
+	}
+	for _, m := range members {
+		if !visited[m] {
+			return m
+		}
+	}
+	return ""
+}
+
+// dialHop 构建拨号链路中的一跳
+func (g *chainGraph) dialHop(name, via string) ChainDialHop {
+	node := g.nodes[name]
+	link := node.Link
+	if idx := strings.Index(link, ","); idx >= 0 {
+		link = link[:idx] // 多链接节点取第一条
+	}
+	return ChainDialHop{
+		NodeID:   node.ID,
+		Name:     name,
+		Protocol: node.Protocol,
+		Via:      via,
+		Link:     link,
+	}
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// chainTestNode 链路校验测试用节点，输出名称为 LinkName
+func chainTestNode(id int, name, dialer string) Node {
+	return Node{ID: id, Name: name, LinkName: name, DialerProxyName: dialer, Link: fmt.Sprintf("socks5://127.0.0.1:%d#%s", 10000+id, name)}
+}
+
+// chainTestLine 生成 N0 -> N1 -> ... -> N(n-1) 的前置代理链
+func chainTestLine(n int) []Node {
+	nodes := make([]Node, n)
+	for i := 0; i < n; i++ {
+		dialer := ""
+		if i < n-1 {
+			dialer = fmt.Sprintf("N%d", i+1)
+		}
+		nodes[i] = chainTestNode(i+1, fmt.Sprintf("N%d", i), dialer)
+	}
+	return nodes
+}
+
+// TestValidateChainGraph 测试前置代理拓扑校验：循环、悬空引用、链路深度与代理组循环
+func TestValidateChainGraph(t *testing.T) {
+	tests := []struct {
+		name      string
+		nodes     []Node
+		rules     []SubscriptionChainRule
+		groups    []ChainTemplateGroup
+		wantValid bool
+		wantIssue []string // 期望的问题，格式为 "级别/类型/代理"
+		wantDepth int      // 存在循环时深度取决于遍历顺序，-1 表示不检查
+	}{
+		{
+			name:      "正常链路",
+			nodes:     []Node{chainTestNode(1, "A", "B"), chainTestNode(2, "B", "C"), chainTestNode(3, "C", "")},
+			wantValid: true,
+			wantDepth: 2,
+		},
+		{
+			name:      "内置代理",
+			nodes:     []Node{chainTestNode(1, "A", "DIRECT"), chainTestNode(2, "B", "reject")},
+			wantValid: true,
+			wantDepth: 0,
+		},
+		{
+			name:      "悬空引用",
+			nodes:     []Node{chainTestNode(1, "A", "不存在的节点"), chainTestNode(2, "B", "A")},
+			wantIssue: []string{"error/dangling/A"},
+			wantDepth: 2,
+		},
+		{
+			name:      "节点循环",
+			nodes:     []Node{chainTestNode(1, "A", "B"), chainTestNode(2, "B", "C"), chainTestNode(3, "C", "A")},
+			wantIssue: []string{"error/cycle/A"},
+			wantDepth: -1,
+		},
+		{
+			name:      "自身循环",
+			nodes:     []Node{chainTestNode(1, "A", "A")},
+			wantIssue: []string{"error/cycle/A"},
+			wantDepth: -1,
+		},
+		{
+			name:      "代理组循环",
+			nodes:     []Node{chainTestNode(1, "A", "中转组"), chainTestNode(2, "B", "")},
+			groups:    []ChainTemplateGroup{{Name: "中转组", Type: "select", Proxies: []string{"B", "A"}}},
+			wantValid: true,
+			wantIssue: []string{"warning/cycle/A"},
+			wantDepth: 1,
+		},
+		{
+			name:      "include-all 代理组循环",
+			nodes:     []Node{chainTestNode(1, "A", "全部节点"), chainTestNode(2, "B", "")},
+			groups:    []ChainTemplateGroup{{Name: "全部节点", Type: "url-test", IncludeAll: true}},
+			wantValid: true,
+			wantIssue: []string{"warning/cycle/A"},
+			wantDepth: 1,
+		},
+		{
+			name:      "代理组之间循环",
+			nodes:     []Node{chainTestNode(1, "A", "G1")},
+			groups:    []ChainTemplateGroup{{Name: "G1", Type: "select", Proxies: []string{"G2"}}, {Name: "G2", Type: "select", Proxies: []string{"G1", "DIRECT"}}},
+			wantValid: true,
+			wantIssue: []string{"warning/cycle/G1"},
+			wantDepth: -1,
+		},
+		{
+			name:      "深度等于上限",
+			nodes:     chainTestLine(ChainMaxDepth + 1),
+			wantValid: true,
+			wantDepth: ChainMaxDepth,
+		},
+		{
+			name:      "深度超过上限",
+			nodes:     chainTestLine(ChainMaxDepth + 3),
+			wantIssue: []string{"error/depth/N0", "error/depth/N1"},
+			wantDepth: ChainMaxDepth + 2,
+		},
+		{
+			name:  "规则形成循环",
+			nodes: []Node{chainTestNode(1, "A", ""), chainTestNode(2, "B", "A")},
+			rules: []SubscriptionChainRule{{
+				Name:         "A 经 B",
+				Enabled:      true,
+				ChainConfig:  `[{"type":"specified_node","nodeId":2}]`,
+				TargetConfig: `{"type":"specified_node","nodeId":1}`,
+			}},
+			wantIssue: []string{"error/cycle/A"},
+			wantDepth: -1,
+		},
+		{
+			name:  "规则无法解析",
+			nodes: []Node{chainTestNode(1, "A", "")},
+			rules: []SubscriptionChainRule{
+				{Name: "坏规则", Enabled: true, ChainConfig: `[{"type":"specified_node","nodeId":99}]`},
+				{Name: "停用规则", Enabled: false, ChainConfig: `[{"type":"unknown"}]`},
+			},
+			wantValid: true,
+			wantIssue: []string{"warning/rule/坏规则"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &Subcription{Nodes: tt.nodes}
+			result := ValidateChainGraph(sub, tt.rules, tt.groups)
+
+			got := make([]string, 0, len(result.Issues))
+			for _, issue := range result.Issues {
+				got = append(got, issue.Level+"/"+issue.Type+"/"+issue.Proxy)
+			}
+			want := tt.wantIssue
+			if want == nil {
+				want = []string{}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("问题 = %v, 期望 %v", got, want)
+				for _, issue := range result.Issues {
+					t.Logf("  %s", issue.Message)
+				}
+			}
+			if result.Valid != tt.wantValid {
+				t.Errorf("Valid = %v, 期望 %v", result.Valid, tt.wantValid)
+			}
+			if tt.wantDepth >= 0 && result.MaxDepth != tt.wantDepth {
+				t.Errorf("MaxDepth = %d, 期望 %d", result.MaxDepth, tt.wantDepth)
+			}
+			if result.ProxyCount != len(tt.nodes) || result.DepthLimit != ChainMaxDepth {
+				t.Errorf("统计错误: ProxyCount=%d, DepthLimit=%d", result.ProxyCount, result.DepthLimit)
+			}
+		})
+	}
+}
+
+// TestValidateChainGraphIssuePath 测试问题链路按拨号方向排列
+func TestValidateChainGraphIssuePath(t *testing.T) {
+	sub := &Subcription{Nodes: []Node{chainTestNode(1, "A", "B"), chainTestNode(2, "B", "C"), chainTestNode(3, "C", "B")}}
+	result := ValidateChainGraph(sub, nil, nil)
+	if len(result.Issues) != 1 {
+		t.Fatalf("应只报告一个循环, 实际: %+v", result.Issues)
+	}
+	if want := []string{"B", "C", "B"}; !reflect.DeepEqual(result.Issues[0].Path, want) {
+		t.Errorf("循环链路 = %v, 期望 %v", result.Issues[0].Path, want)
+	}
+
+	sub = &Subcription{Nodes: chainTestLine(ChainMaxDepth + 2)}
+	result = ValidateChainGraph(sub, nil, nil)
+	if len(result.Issues) != 1 || len(result.Issues[0].Path) != ChainMaxDepth+2 || result.Issues[0].Path[0] != "N0" {
+		t.Errorf("深度问题应给出完整链路, 实际: %+v", result.Issues)
+	}
+}
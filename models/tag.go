@@ -22,6 +22,9 @@ type Tag struct {
 	Description string    `json:"description"`                     // 标签描述
 	CreatedAt   time.Time `gorm:"autoCreateTime" json:"createdAt"`
 	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+
+	// 层级
+	ParentName string `gorm:"size:100;index" json:"parentName"` // 父标签名称，为空表示顶级标签；按父标签筛选时包含全部子孙标签
 }
 
 // TagRule 自动标签规则
@@ -30,16 +33,53 @@ type TagRule struct {
 	TagName     string    `gorm:"index;size:100" json:"tagName"` // 关联的标签名称
 	Name        string    `json:"name"`                          // 规则名称
 	Enabled     bool      `gorm:"default:true" json:"enabled"`   // 是否启用
-	TriggerType string    `json:"triggerType"`                   // 触发类型: subscription_update, speed_test
+	TriggerType string    `json:"triggerType"`                   // 触发类型，见 TagTrigger* 常量
 	Conditions  string    `gorm:"type:text" json:"conditions"`   // JSON条件表达式
 	CreatedAt   time.Time `gorm:"autoCreateTime" json:"createdAt"`
 	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updatedAt"`
+
+	// 定时与过期
+	CronExpr      string `json:"cronExpr"`                       // 触发类型为 cron 时的执行计划
+	ExpireMinutes int    `gorm:"default:0" json:"expireMinutes"` // 标签有效期(分钟)，0=不过期；设置后不满足条件不会移除标签，而是到期自动移除
+}
+
+// 标签规则触发类型
+const (
+	TagTriggerSubscriptionUpdate = "subscription_update" // 机场订阅更新后
+	TagTriggerSpeedTest          = "speed_test"          // 节点检测完成后
+	TagTriggerCron               = "cron"                // 按 CronExpr 定时对全部节点执行
+	TagTriggerNodeCreated        = "node_created"        // 新节点入库后（手动添加或机场同步新增）
+	TagTriggerNodeEdit           = "node_edit"           // 手动编辑节点后
+	TagTriggerLandingIPChange    = "landing_ip_change"   // 节点检测发现落地IP变化后
+)
+
+// TagTriggerTypes 支持的标签规则触发类型
+var TagTriggerTypes = []string{
+	TagTriggerSubscriptionUpdate,
+	TagTriggerSpeedTest,
+	TagTriggerCron,
+	TagTriggerNodeCreated,
+	TagTriggerNodeEdit,
+	TagTriggerLandingIPChange,
+}
+
+// IsValidTagTrigger 是否为支持的触发类型
+func IsValidTagTrigger(triggerType string) bool {
+	for _, t := range TagTriggerTypes {
+		if t == triggerType {
+			return true
+		}
+	}
+	return false
 }
 
 // TagConditions 条件表达式结构
 type TagConditions struct {
 	Logic      string         `json:"logic"`      // "and" 或 "or"
 	Conditions []TagCondition `json:"conditions"` // 条件列表
+
+	// 布尔表达式（设置后优先于 Logic/Conditions），语法见 CompileNodeExpression
+	Expression string `json:"expression,omitempty"`
 }
 
 // TagCondition 单个条件
@@ -102,6 +142,9 @@ func (t *Tag) Add() error {
 	if t.Name == "" {
 		return fmt.Errorf("标签名称不能为空")
 	}
+	if err := ValidateTagParent(t.Name, t.ParentName); err != nil {
+		return err
+	}
 	if err := database.DB.Create(t).Error; err != nil {
 		return err
 	}
@@ -109,13 +152,17 @@ func (t *Tag) Add() error {
 	return nil
 }
 
-// Update 更新标签（可更新颜色、描述和标签组，不能修改名称）
+// Update 更新标签（可更新颜色、描述、标签组和父标签，不能修改名称）
 func (t *Tag) Update() error {
+	if err := ValidateTagParent(t.Name, t.ParentName); err != nil {
+		return err
+	}
 	t.UpdatedAt = time.Now()
 	if err := database.DB.Model(t).Where("name = ?", t.Name).Updates(map[string]interface{}{
 		"group_name":  t.GroupName,
 		"color":       t.Color,
 		"description": t.Description,
+		"parent_name": t.ParentName,
 		"updated_at":  t.UpdatedAt,
 	}).Error; err != nil {
 		return err
@@ -132,6 +179,14 @@ func (t *Tag) Delete() error {
 	}
 	// 清除节点上的此标签
 	ClearTagFromAllNodes(t.Name)
+	// 清除此标签的过期记录
+	if err := database.DB.Where("tag_name = ?", t.Name).Delete(&NodeTagExpiry{}).Error; err != nil {
+		return err
+	}
+	// 子标签挂到被删除标签的父标签下，保持层级连续
+	if err := reparentChildTags(t.Name, t.ParentName); err != nil {
+		return err
+	}
 	// 删除标签
 	if err := database.DB.Where("name = ?", t.Name).Delete(&Tag{}).Error; err != nil {
 		return err
@@ -291,20 +346,47 @@ func ListByTriggerType(triggerType string) []TagRule {
 
 // ========== 条件评估 ==========
 
-// ParseConditions 解析JSON条件表达式
+// ParseConditions 解析条件：JSON 格式的 TagConditions，或直接书写的布尔表达式
 func ParseConditions(conditionsJSON string) (*TagConditions, error) {
-	if conditionsJSON == "" {
+	trimmed := strings.TrimSpace(conditionsJSON)
+	if trimmed == "" {
 		return nil, fmt.Errorf("empty conditions")
 	}
+	if !strings.HasPrefix(trimmed, "{") {
+		if _, err := CompileNodeExpression(trimmed); err != nil {
+			return nil, err
+		}
+		return &TagConditions{Expression: trimmed}, nil
+	}
 	var conditions TagConditions
-	if err := json.Unmarshal([]byte(conditionsJSON), &conditions); err != nil {
+	if err := json.Unmarshal([]byte(trimmed), &conditions); err != nil {
+		return nil, err
+	}
+	if err := conditions.Validate(); err != nil {
 		return nil, err
 	}
 	return &conditions, nil
 }
 
+// Validate 校验条件中的表达式语法
+func (tc *TagConditions) Validate() error {
+	if tc == nil || strings.TrimSpace(tc.Expression) == "" {
+		return nil
+	}
+	_, err := CompileNodeExpression(tc.Expression)
+	return err
+}
+
 // EvaluateNode 对节点评估条件
 func (tc *TagConditions) EvaluateNode(node Node) bool {
+	if strings.TrimSpace(tc.Expression) != "" {
+		expr, err := CompileNodeExpression(tc.Expression)
+		if err != nil {
+			utils.Error("条件表达式解析失败: %s, error: %v", tc.Expression, err)
+			return false
+		}
+		return expr.Match(node)
+	}
 	if len(tc.Conditions) == 0 {
 		return false
 	}
@@ -383,6 +465,16 @@ func getNodeFieldValue(node Node, field string) interface{} {
 		return node.LinkPort
 	case "link_country":
 		return node.LinkCountry
+	case "landing_ip":
+		return node.LandingIP
+	case "landing_asn":
+		return node.LandingASN
+	case "landing_isp":
+		return node.LandingISP
+	case "landing_city":
+		return node.LandingCity
+	case "landing_ip_type":
+		return node.LandingIPType
 	case "protocol":
 		return node.Protocol
 	case "source":
@@ -459,7 +551,7 @@ func (n *Node) GetTagNames() []string {
 	return names
 }
 
-// HasTagName 检查节点是否有指定标签
+// HasTagName 检查节点是否有指定标签（精确匹配，不含子标签）
 func (n *Node) HasTagName(tagName string) bool {
 	for _, name := range n.GetTagNames() {
 		if name == tagName {
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"sublink/database"
+	"sublink/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NodeTagExpiry 节点标签的过期时间（由设置了有效期的标签规则写入）
+type NodeTagExpiry struct {
+	ID        int       `gorm:"primaryKey" json:"id"`
+	NodeID    int       `gorm:"uniqueIndex:idx_node_tag_expiry" json:"nodeId"`
+	TagName   string    `gorm:"uniqueIndex:idx_node_tag_expiry;size:100;index" json:"tagName"`
+	ExpiresAt time.Time `gorm:"index" json:"expiresAt"`
+}
+
+// SetNodeTagExpiry 设置（或刷新）节点标签的过期时间
+func SetNodeTagExpiry(nodeIDs []int, tagName string, expiresAt time.Time) error {
+	if len(nodeIDs) == 0 {
+		return nil
+	}
+	records := make([]NodeTagExpiry, 0, len(nodeIDs))
+	for _, id := range nodeIDs {
+		records = append(records, NodeTagExpiry{NodeID: id, TagName: tagName, ExpiresAt: expiresAt})
+	}
+	return database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "node_id"}, {Name: "tag_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at"}),
+	}).CreateInBatches(records, database.BatchSize).Error
+}
+
+// ClearNodeTagExpiry 清除节点标签的过期记录（手动设置或移除标签时调用，手动设置的标签长期保留）
+// 未指定标签时清除节点的全部过期记录
+func ClearNodeTagExpiry(nodeIDs []int, tagNames ...string) error {
+	if len(nodeIDs) == 0 {
+		return nil
+	}
+	if len(tagNames) == 0 {
+		return deleteNodeTagExpiry(database.DB, nodeIDs)
+	}
+	return database.DB.Where("tag_name IN ? AND node_id IN ?", tagNames, nodeIDs).Delete(&NodeTagExpiry{}).Error
+}
+
+// ClearTagExpiry 清除指定标签在全部节点上的过期记录（规则改为长期保留时调用）
+func ClearTagExpiry(tagName string) error {
+	return database.DB.Where("tag_name = ?", tagName).Delete(&NodeTagExpiry{}).Error
+}
+
+// deleteNodeTagExpiry 删除节点的全部标签过期记录（节点删除时调用）
+func deleteNodeTagExpiry(tx *gorm.DB, nodeIDs []int) error {
+	if len(nodeIDs) == 0 {
+		return nil
+	}
+	return tx.Where("node_id IN ?", nodeIDs).Delete(&NodeTagExpiry{}).Error
+}
+
+// ExpireNodeTags 移除已到期的节点标签，返回移除的标签数
+// 查询与移除之间规则可能刷新了到期时间，移除标签和删除记录前都重新按到期时间过滤
+func ExpireNodeTags() (int, error) {
+	now := time.Now()
+	var expired []NodeTagExpiry
+	if err := database.DB.Where("expires_at <= ?", now).Find(&expired).Error; err != nil {
+		return 0, err
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+
+	byTag := make(map[string][]int)
+	for _, e := range expired {
+		byTag[e.TagName] = append(byTag[e.TagName], e.ID)
+	}
+
+	removed := 0
+	for tagName, recordIDs := range byTag {
+		// 只处理仍未刷新的记录
+		var records []NodeTagExpiry
+		if err := database.DB.Where("id IN ? AND expires_at <= ?", recordIDs, now).Find(&records).Error; err != nil {
+			return removed, err
+		}
+		if len(records) == 0 {
+			continue
+		}
+		nodeIDs := make([]int, 0, len(records))
+		stillIDs := make([]int, 0, len(records))
+		for _, r := range records {
+			nodeIDs = append(nodeIDs, r.NodeID)
+			stillIDs = append(stillIDs, r.ID)
+		}
+		// 移除失败的保留过期记录，下次重试
+		if err := BatchRemoveTagFromNodes(nodeIDs, tagName); err != nil {
+			utils.Error("移除过期标签 %s 失败: %v", tagName, err)
+			continue
+		}
+		removed += len(nodeIDs)
+		if err := database.DB.Where("id IN ? AND expires_at <= ?", stillIDs, now).Delete(&NodeTagExpiry{}).Error; err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
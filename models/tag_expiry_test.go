@@ -0,0 +1,68 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"sublink/database"
+
+	"gorm.io/gorm"
+)
+
+// TestExpireNodeTagsRefreshed 测试到期清理期间被规则刷新的标签不会被移除，刷新后的记录也不会被删除
+func TestExpireNodeTagsRefreshed(t *testing.T) {
+	db := setupModelsTestDB(t, "tag_expiry_test")
+
+	tag := &Tag{Name: "最近失败"}
+	if err := tag.Add(); err != nil {
+		t.Fatalf("添加标签失败: %v", err)
+	}
+	if err := BatchAddNodes([]Node{
+		{Name: "N1", Link: "trojan://p@1.1.1.1:443#N1", Tags: "最近失败"},
+		{Name: "N2", Link: "trojan://p@2.2.2.2:443#N2", Tags: "最近失败"},
+	}); err != nil {
+		t.Fatalf("添加节点失败: %v", err)
+	}
+	var ids []int
+	db.Model(&Node{}).Order("id").Pluck("id", &ids)
+	if err := SetNodeTagExpiry(ids, tag.Name, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatalf("设置过期时间失败: %v", err)
+	}
+
+	// 第一次查询到期记录后，规则重新匹配 N2 并刷新到期时间
+	refreshed := time.Now().Add(time.Hour)
+	const refreshCallback = "test:refresh_tag_expiry"
+	queried := false
+	if err := db.Callback().Query().After("gorm:query").Register(refreshCallback, func(tx *gorm.DB) {
+		if queried || tx.Statement.Table != "node_tag_expiries" {
+			return
+		}
+		queried = true
+		if err := SetNodeTagExpiry(ids[1:], tag.Name, refreshed); err != nil {
+			t.Errorf("刷新过期时间失败: %v", err)
+		}
+	}); err != nil {
+		t.Fatalf("注册回调失败: %v", err)
+	}
+	defer db.Callback().Query().Remove(refreshCallback)
+
+	removed, err := ExpireNodeTags()
+	if err != nil {
+		t.Fatalf("清理过期标签失败: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("移除数量 = %d, 期望 1", removed)
+	}
+
+	nodes, _ := GetNodesByIDs(ids)
+	for _, n := range nodes {
+		if has := n.HasTagName(tag.Name); has != (n.ID == ids[1]) {
+			t.Errorf("节点 %s 标签 = %q, 只有被刷新的 N2 应保留标签", n.Name, n.Tags)
+		}
+	}
+	var rows []NodeTagExpiry
+	database.DB.Find(&rows)
+	if len(rows) != 1 || rows[0].NodeID != ids[1] || !rows[0].ExpiresAt.After(time.Now()) {
+		t.Errorf("应只保留 N2 刷新后的过期记录, 实际: %+v", rows)
+	}
+}
@@ -0,0 +1,1041 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// 条件表达式示例：
+//
+//	(country in [HK, TW] and delay < 200) or has_tag("premium")
+//	protocol not in ["ss", "ssr"] and speed between 5 and 50
+//	created_at >= "2024-06-01" and age_days() < 30 and name ~ "(?i)iplc"
+//
+// 表达式只做字段读取、比较与内置函数调用，没有循环与赋值，解析后的语法树按表达式文本缓存
+
+const (
+	nodeExprMaxLength = 4096 // 表达式最大长度
+	nodeExprMaxDepth  = 64   // 最大嵌套深度
+	nodeExprCacheSize = 512  // 语法树缓存条目上限
+)
+
+// nodeExprTimeLayouts 日期字符串支持的格式
+var nodeExprTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// NodeExpression 已解析的条件表达式
+type NodeExpression struct {
+	source string
+	root   exprNode
+}
+
+// Source 表达式原文
+func (e *NodeExpression) Source() string {
+	return e.source
+}
+
+// Match 对节点求值，结果按真值判断
+func (e *NodeExpression) Match(node Node) bool {
+	env := &exprEnv{node: node, now: time.Now()}
+	return exprTruthy(e.root.eval(env))
+}
+
+var (
+	nodeExprCache   = make(map[string]*NodeExpression)
+	nodeExprCacheMu sync.RWMutex
+)
+
+// CompileNodeExpression 解析条件表达式，结果按表达式文本缓存
+func CompileNodeExpression(src string) (*NodeExpression, error) {
+	src = strings.TrimSpace(src)
+
+	nodeExprCacheMu.RLock()
+	cached, ok := nodeExprCache[src]
+	nodeExprCacheMu.RUnlock()
+	if ok {
+		return cached, nil
+	}
+
+	if src == "" {
+		return nil, fmt.Errorf("表达式为空")
+	}
+	if len(src) > nodeExprMaxLength {
+		return nil, fmt.Errorf("表达式过长（最多 %d 个字符）", nodeExprMaxLength)
+	}
+	tokens, err := lexNodeExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != exprTokEOF {
+		return nil, fmt.Errorf("第 %d 个字符附近存在多余内容: %s", tok.pos+1, tok.text)
+	}
+	if err := checkBooleanOperand(root); err != nil {
+		return nil, err
+	}
+
+	expr := &NodeExpression{source: src, root: root}
+	nodeExprCacheMu.Lock()
+	if len(nodeExprCache) >= nodeExprCacheSize {
+		nodeExprCache = make(map[string]*NodeExpression)
+	}
+	nodeExprCache[src] = expr
+	nodeExprCacheMu.Unlock()
+	return expr, nil
+}
+
+// ========== 词法分析 ==========
+
+type exprTokenKind int
+
+const (
+	exprTokEOF exprTokenKind = iota
+	exprTokIdent
+	exprTokNumber
+	exprTokString
+	exprTokOp // 符号：( ) [ ] , = == != < <= > >= ~ !~ && || !
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string // 原文；字符串为去掉引号与转义后的内容
+	num  float64
+	pos  int
+}
+
+// keyword 判断标识符是否为指定关键字（不区分大小写）
+func (t exprToken) keyword(kw string) bool {
+	return t.kind == exprTokIdent && strings.EqualFold(t.text, kw)
+}
+
+func (t exprToken) op(op string) bool {
+	return t.kind == exprTokOp && t.text == op
+}
+
+func lexNodeExpr(src string) ([]exprToken, error) {
+	var tokens []exprToken
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '"' || r == '\'':
+			quote := r
+			start := i
+			i++
+			var sb strings.Builder
+			closed := false
+			for i < len(runes) {
+				c := runes[i]
+				if c == '\\' && i+1 < len(runes) {
+					switch runes[i+1] {
+					case 'n':
+						sb.WriteRune('\n')
+					case 't':
+						sb.WriteRune('\t')
+					default:
+						// 保留正则中的转义，例如 "\d"；引号与反斜杠本身去掉转义
+						if runes[i+1] != quote && runes[i+1] != '\\' {
+							sb.WriteRune('\\')
+						}
+						sb.WriteRune(runes[i+1])
+					}
+					i += 2
+					continue
+				}
+				if c == quote {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(c)
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("第 %d 个字符处的字符串缺少结束引号", start+1)
+			}
+			tokens = append(tokens, exprToken{kind: exprTokString, text: sb.String(), pos: start})
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]) && exprAllowsSign(tokens)):
+			start := i
+			i++
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			text := string(runes[start:i])
+			num, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 个字符处的数字无效: %s", start+1, text)
+			}
+			tokens = append(tokens, exprToken{kind: exprTokNumber, text: text, num: num, pos: start})
+		case unicode.IsLetter(r) || r == '_' || r > unicode.MaxASCII:
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '-' || runes[i] == '.' || (runes[i] > unicode.MaxASCII && !unicode.IsSpace(runes[i]))) {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: exprTokIdent, text: string(runes[start:i]), pos: start})
+		default:
+			start := i
+			two := ""
+			if i+1 < len(runes) {
+				two = string(runes[i : i+2])
+			}
+			switch two {
+			case "==", "!=", "<=", ">=", "!~", "&&", "||":
+				tokens = append(tokens, exprToken{kind: exprTokOp, text: two, pos: start})
+				i += 2
+				continue
+			}
+			switch r {
+			case '(', ')', '[', ']', ',', '=', '<', '>', '~', '!':
+				tokens = append(tokens, exprToken{kind: exprTokOp, text: string(r), pos: start})
+				i++
+			default:
+				return nil, fmt.Errorf("第 %d 个字符处存在无法识别的符号: %c", start+1, r)
+			}
+		}
+	}
+	tokens = append(tokens, exprToken{kind: exprTokEOF, pos: len(runes)})
+	return tokens, nil
+}
+
+// exprAllowsSign 负号仅在表达式开头、运算符或左括号之后视为数字符号
+func exprAllowsSign(tokens []exprToken) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	last := tokens[len(tokens)-1]
+	if last.kind == exprTokOp {
+		return last.text != ")" && last.text != "]"
+	}
+	return last.kind == exprTokIdent && isExprKeyword(last.text)
+}
+
+func isExprKeyword(s string) bool {
+	switch strings.ToLower(s) {
+	case "and", "or", "not", "in", "between", "contains", "matches":
+		return true
+	}
+	return false
+}
+
+// ========== 语法分析 ==========
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+	depth  int
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) peekAt(offset int) exprToken {
+	if p.pos+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.pos+offset]
+}
+
+func (p *exprParser) next() exprToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != exprTokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *exprParser) errorf(tok exprToken, format string, args ...interface{}) error {
+	return fmt.Errorf("第 %d 个字符附近: %s", tok.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *exprParser) enter() error {
+	p.depth++
+	if p.depth > nodeExprMaxDepth {
+		return p.errorf(p.peek(), "嵌套层级过深（最多 %d 层）", nodeExprMaxDepth)
+	}
+	return nil
+}
+
+func (p *exprParser) leave() {
+	p.depth--
+}
+
+// parseExpr expr := and { (or | "||") and }
+func (p *exprParser) parseExpr() (exprNode, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for tok := p.peek(); tok.keyword("or") || tok.op("||"); tok = p.peek() {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkBooleanOperand(left, right); err != nil {
+			return nil, err
+		}
+		left = &exprLogic{or: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseAnd and := not { (and | "&&") not }
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for tok := p.peek(); tok.keyword("and") || tok.op("&&"); tok = p.peek() {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkBooleanOperand(left, right); err != nil {
+			return nil, err
+		}
+		left = &exprLogic{left: left, right: right}
+	}
+	return left, nil
+}
+
+// parseNot not := (not | "!") not | compare
+func (p *exprParser) parseNot() (exprNode, error) {
+	if tok := p.peek(); tok.keyword("not") || tok.op("!") {
+		p.next()
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkBooleanOperand(operand); err != nil {
+			return nil, err
+		}
+		return &exprNot{operand: operand}, nil
+	}
+	return p.parseCompare()
+}
+
+// parseCompare compare := operand [ cmpOp operand | [not] in operand | [not] between operand and operand | [not] contains/matches operand ]
+func (p *exprParser) parseCompare() (exprNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	negate := false
+	if tok.keyword("not") {
+		next := p.peekAt(1)
+		if next.keyword("in") || next.keyword("between") || next.keyword("contains") || next.keyword("matches") {
+			p.next()
+			negate = true
+			tok = p.peek()
+		}
+	}
+
+	var op string
+	switch {
+	case tok.kind == exprTokOp && (tok.text == "=" || tok.text == "==" || tok.text == "!=" || tok.text == "<" || tok.text == "<=" || tok.text == ">" || tok.text == ">=" || tok.text == "~" || tok.text == "!~"):
+		op = tok.text
+	case tok.keyword("in"), tok.keyword("between"), tok.keyword("contains"), tok.keyword("matches"):
+		op = strings.ToLower(tok.text)
+	default:
+		return left, nil
+	}
+	p.next()
+
+	if word, ok := left.(*exprWord); ok {
+		return nil, p.errorf(tok, "未知字段: %s", word.text)
+	}
+
+	switch op {
+	case "in":
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &exprIn{value: left, list: right, negate: negate}, nil
+	case "between":
+		low, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if and := p.next(); !and.keyword("and") {
+			return nil, p.errorf(and, "between 需要使用 \"a and b\" 指定范围")
+		}
+		high, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &exprBetween{value: left, low: low, high: high, negate: negate}, nil
+	}
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "~", "!~", "matches":
+		pattern, ok := exprConstString(right)
+		if !ok {
+			return nil, p.errorf(tok, "正则匹配的右侧必须是字符串")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, p.errorf(tok, "正则表达式无效: %v", err)
+		}
+		return &exprMatch{value: left, re: re, negate: negate != (op == "!~")}, nil
+	case "contains":
+		return &exprContains{value: left, part: right, negate: negate}, nil
+	case "==":
+		op = "="
+	}
+	return &exprCompare{op: op, left: left, right: right}, nil
+}
+
+// parseOperand operand := number | string | list | "(" expr ")" | ident [ "(" args ")" ]
+func (p *exprParser) parseOperand() (exprNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case exprTokNumber:
+		return &exprLiteral{value: tok.num}, nil
+	case exprTokString:
+		return &exprLiteral{value: tok.text}, nil
+	case exprTokOp:
+		switch tok.text {
+		case "(":
+			inner, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if closing := p.next(); !closing.op(")") {
+				return nil, p.errorf(closing, "缺少右括号")
+			}
+			return inner, nil
+		case "[":
+			return p.parseList()
+		}
+		return nil, p.errorf(tok, "此处不应出现 %s", tok.text)
+	case exprTokIdent:
+		if isExprKeyword(tok.text) {
+			return nil, p.errorf(tok, "此处不应出现关键字 %s", tok.text)
+		}
+		lower := strings.ToLower(tok.text)
+		if p.peek().op("(") {
+			return p.parseCall(tok)
+		}
+		switch lower {
+		case "true":
+			return &exprLiteral{value: true}, nil
+		case "false":
+			return &exprLiteral{value: false}, nil
+		case "null":
+			return &exprLiteral{value: nil}, nil
+		}
+		if getter, ok := nodeExprFields[lower]; ok {
+			return &exprField{name: lower, get: getter}, nil
+		}
+		// 未知的裸词作为字符串使用，例如 country in [HK, TW]
+		return &exprWord{text: tok.text}, nil
+	}
+	return nil, p.errorf(tok, "表达式不完整")
+}
+
+func (p *exprParser) parseList() (exprNode, error) {
+	list := &exprList{}
+	if p.peek().op("]") {
+		p.next()
+		return list, nil
+	}
+	for {
+		item, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		list.items = append(list.items, item)
+		sep := p.next()
+		if sep.op("]") {
+			return list, nil
+		}
+		if !sep.op(",") {
+			return nil, p.errorf(sep, "列表元素之间需要使用逗号分隔")
+		}
+	}
+}
+
+func (p *exprParser) parseCall(name exprToken) (exprNode, error) {
+	fn, ok := nodeExprFuncs[strings.ToLower(name.text)]
+	if !ok {
+		return nil, p.errorf(name, "未知函数: %s", name.text)
+	}
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	p.next() // (
+	call := &exprCall{name: strings.ToLower(name.text), fn: fn}
+	if !p.peek().op(")") {
+		for {
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			sep := p.next()
+			if sep.op(")") {
+				break
+			}
+			if !sep.op(",") {
+				return nil, p.errorf(sep, "函数参数之间需要使用逗号分隔")
+			}
+		}
+	} else {
+		p.next()
+	}
+	if len(call.args) < fn.minArgs || (fn.maxArgs >= 0 && len(call.args) > fn.maxArgs) {
+		return nil, p.errorf(name, "函数 %s 的参数个数不正确", name.text)
+	}
+	return call, nil
+}
+
+// checkBooleanOperand 逻辑运算的操作数不能是未知的裸词（通常是拼错的字段名）
+func checkBooleanOperand(nodes ...exprNode) error {
+	for _, n := range nodes {
+		if word, ok := n.(*exprWord); ok {
+			return fmt.Errorf("未知字段或缺少比较运算: %s", word.text)
+		}
+	}
+	return nil
+}
+
+// exprConstString 取常量字符串（字符串字面量或裸词）
+func exprConstString(n exprNode) (string, bool) {
+	switch v := n.(type) {
+	case *exprLiteral:
+		s, ok := v.value.(string)
+		return s, ok
+	case *exprWord:
+		return v.text, true
+	}
+	return "", false
+}
+
+// ========== 语法树与求值 ==========
+
+type exprEnv struct {
+	node Node
+	now  time.Time
+}
+
+type exprNode interface {
+	eval(env *exprEnv) interface{}
+}
+
+type exprLiteral struct{ value interface{} }
+
+func (e *exprLiteral) eval(*exprEnv) interface{} { return e.value }
+
+// exprWord 未识别为字段的裸词，按字符串处理
+type exprWord struct{ text string }
+
+func (e *exprWord) eval(*exprEnv) interface{} { return e.text }
+
+type exprField struct {
+	name string
+	get  func(env *exprEnv) interface{}
+}
+
+func (e *exprField) eval(env *exprEnv) interface{} { return e.get(env) }
+
+type exprList struct{ items []exprNode }
+
+func (e *exprList) eval(env *exprEnv) interface{} {
+	values := make([]interface{}, len(e.items))
+	for i, item := range e.items {
+		values[i] = item.eval(env)
+	}
+	return values
+}
+
+type exprLogic struct {
+	or          bool
+	left, right exprNode
+}
+
+func (e *exprLogic) eval(env *exprEnv) interface{} {
+	l := exprTruthy(e.left.eval(env))
+	if e.or {
+		return l || exprTruthy(e.right.eval(env))
+	}
+	return l && exprTruthy(e.right.eval(env))
+}
+
+type exprNot struct{ operand exprNode }
+
+func (e *exprNot) eval(env *exprEnv) interface{} { return !exprTruthy(e.operand.eval(env)) }
+
+type exprCompare struct {
+	op          string
+	left, right exprNode
+}
+
+func (e *exprCompare) eval(env *exprEnv) interface{} {
+	l, r := e.left.eval(env), e.right.eval(env)
+	switch e.op {
+	case "=":
+		return exprEqual(l, r)
+	case "!=":
+		return !exprEqual(l, r)
+	}
+	cmp, ok := exprCompareValues(l, r)
+	if !ok {
+		return false
+	}
+	switch e.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+type exprIn struct {
+	value, list exprNode
+	negate      bool
+}
+
+func (e *exprIn) eval(env *exprEnv) interface{} {
+	v := e.value.eval(env)
+	found := false
+	for _, item := range exprAsList(e.list.eval(env)) {
+		if exprEqual(v, item) {
+			found = true
+			break
+		}
+	}
+	return found != e.negate
+}
+
+type exprBetween struct {
+	value, low, high exprNode
+	negate           bool
+}
+
+func (e *exprBetween) eval(env *exprEnv) interface{} {
+	v := e.value.eval(env)
+	lo, ok1 := exprCompareValues(v, e.low.eval(env))
+	hi, ok2 := exprCompareValues(v, e.high.eval(env))
+	if !ok1 || !ok2 {
+		return false
+	}
+	return (lo >= 0 && hi <= 0) != e.negate
+}
+
+type exprContains struct {
+	value, part exprNode
+	negate      bool
+}
+
+func (e *exprContains) eval(env *exprEnv) interface{} {
+	v, part := e.value.eval(env), e.part.eval(env)
+	var found bool
+	if list, ok := v.([]interface{}); ok {
+		for _, item := range list {
+			if exprEqual(item, part) {
+				found = true
+				break
+			}
+		}
+	} else {
+		found = strings.Contains(strings.ToLower(exprString(v)), strings.ToLower(exprString(part)))
+	}
+	return found != e.negate
+}
+
+type exprMatch struct {
+	value  exprNode
+	re     *regexp.Regexp
+	negate bool
+}
+
+func (e *exprMatch) eval(env *exprEnv) interface{} {
+	v := e.value.eval(env)
+	var found bool
+	if list, ok := v.([]interface{}); ok {
+		for _, item := range list {
+			if e.re.MatchString(exprString(item)) {
+				found = true
+				break
+			}
+		}
+	} else {
+		found = e.re.MatchString(exprString(v))
+	}
+	return found != e.negate
+}
+
+type exprCall struct {
+	name string
+	fn   nodeExprFunc
+	args []exprNode
+}
+
+func (e *exprCall) eval(env *exprEnv) interface{} {
+	args := make([]interface{}, len(e.args))
+	for i, a := range e.args {
+		args[i] = a.eval(env)
+	}
+	return e.fn.call(env, args)
+}
+
+// ========== 值语义 ==========
+
+// exprTruthy 真值判断：false、null、0、空字符串与空列表为假
+func exprTruthy(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	case float64:
+		return val != 0
+	case string:
+		return val != ""
+	case []interface{}:
+		return len(val) > 0
+	case time.Time:
+		return !val.IsZero()
+	}
+	return true
+}
+
+func exprString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case time.Time:
+		return val.Format("2006-01-02 15:04:05")
+	case []interface{}:
+		parts := make([]string, len(val))
+		for i, item := range val {
+			parts[i] = exprString(item)
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func exprAsList(v interface{}) []interface{} {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return val
+	}
+	return []interface{}{v}
+}
+
+func exprNumber(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case float64:
+		return val, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		return f, err == nil
+	case bool:
+		if val {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func exprTime(v interface{}) (time.Time, bool) {
+	switch val := v.(type) {
+	case time.Time:
+		return val, !val.IsZero()
+	case string:
+		return parseNodeExprTime(val)
+	}
+	return time.Time{}, false
+}
+
+func parseNodeExprTime(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range nodeExprTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// exprEqual 相等判断；列表与单值比较时，只要列表中任一元素相等即为真
+func exprEqual(a, b interface{}) bool {
+	if list, ok := a.([]interface{}); ok {
+		if _, isList := b.([]interface{}); !isList {
+			for _, item := range list {
+				if exprEqual(item, b) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	if list, ok := b.([]interface{}); ok {
+		if _, isList := a.([]interface{}); !isList {
+			return exprEqual(list, a)
+		}
+	}
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if cmp, ok := exprCompareValues(a, b); ok {
+		return cmp == 0
+	}
+	return exprString(a) == exprString(b)
+}
+
+// exprCompareValues 比较两个值：时间与日期字符串按时刻、数字（含数字字符串）按大小、其余按字符串
+func exprCompareValues(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if _, ok := a.([]interface{}); ok {
+		return 0, false
+	}
+	if _, ok := b.([]interface{}); ok {
+		return 0, false
+	}
+
+	_, aTime := a.(time.Time)
+	_, bTime := b.(time.Time)
+	if aTime || bTime {
+		ta, ok1 := exprTime(a)
+		tb, ok2 := exprTime(b)
+		if !ok1 || !ok2 {
+			return 0, false
+		}
+		return ta.Compare(tb), true
+	}
+
+	_, aStr := a.(string)
+	_, bStr := b.(string)
+	if !aStr || !bStr {
+		fa, ok1 := exprNumber(a)
+		fb, ok2 := exprNumber(b)
+		if !ok1 || !ok2 {
+			return 0, false
+		}
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+	return strings.Compare(a.(string), b.(string)), true
+}
+
+// ========== 字段与函数 ==========
+
+func nodeExprString(get func(n *Node) string) func(env *exprEnv) interface{} {
+	return func(env *exprEnv) interface{} { return get(&env.node) }
+}
+
+func nodeExprNumber(get func(n *Node) float64) func(env *exprEnv) interface{} {
+	return func(env *exprEnv) interface{} { return get(&env.node) }
+}
+
+// nodeExprTimeField 时间字段，未设置时为 null
+func nodeExprTimeField(get func(n *Node) time.Time) func(env *exprEnv) interface{} {
+	return func(env *exprEnv) interface{} {
+		t := get(&env.node)
+		if t.IsZero() {
+			return nil
+		}
+		return t
+	}
+}
+
+func nodeExprTimeString(get func(n *Node) string) func(env *exprEnv) interface{} {
+	return nodeExprTimeField(func(n *Node) time.Time {
+		t, _ := parseNodeExprTime(get(n))
+		return t
+	})
+}
+
+// nodeExprFields 表达式可用的节点字段（字段名不区分大小写）
+var nodeExprFields = map[string]func(env *exprEnv) interface{}{
+	"id":                nodeExprNumber(func(n *Node) float64 { return float64(n.ID) }),
+	"name":              nodeExprString(func(n *Node) string { return n.Name }),
+	"link_name":         nodeExprString(func(n *Node) string { return n.LinkName }),
+	"link_address":      nodeExprString(func(n *Node) string { return n.LinkAddress }),
+	"link_host":         nodeExprString(func(n *Node) string { return n.LinkHost }),
+	"link_port":         nodeExprString(func(n *Node) string { return n.LinkPort }),
+	"link_country":      nodeExprString(func(n *Node) string { return n.LinkCountry }),
+	"country":           nodeExprString(func(n *Node) string { return n.LinkCountry }),
+	"landing_ip":        nodeExprString(func(n *Node) string { return n.LandingIP }),
+	"landing_asn":       nodeExprString(func(n *Node) string { return n.LandingASN }),
+	"landing_isp":       nodeExprString(func(n *Node) string { return n.LandingISP }),
+	"landing_city":      nodeExprString(func(n *Node) string { return n.LandingCity }),
+	"landing_ip_type":   nodeExprString(func(n *Node) string { return n.LandingIPType }),
+	"protocol":          nodeExprString(func(n *Node) string { return n.Protocol }),
+	"source":            nodeExprString(func(n *Node) string { return n.Source }),
+	"source_id":         nodeExprNumber(func(n *Node) float64 { return float64(n.SourceID) }),
+	"group":             nodeExprString(func(n *Node) string { return n.Group }),
+	"speed":             nodeExprNumber(func(n *Node) float64 { return n.Speed }),
+	"speed_status":      nodeExprString(func(n *Node) string { return n.SpeedStatus }),
+	"delay_time":        nodeExprNumber(func(n *Node) float64 { return float64(n.DelayTime) }),
+	"delay":             nodeExprNumber(func(n *Node) float64 { return float64(n.DelayTime) }),
+	"delay_status":      nodeExprString(func(n *Node) string { return n.DelayStatus }),
+	"dialer_proxy_name": nodeExprString(func(n *Node) string { return n.DialerProxyName }),
+	"link":              nodeExprString(func(n *Node) string { return n.Link }),
+	"lifecycle_state":   nodeExprString(func(n *Node) string { return n.LifecycleState }),
+	"fail_streak":       nodeExprNumber(func(n *Node) float64 { return float64(n.FailStreak) }),
+	"script_score":      nodeExprNumber(func(n *Node) float64 { return n.ScriptScore }),
+	"created_at":        nodeExprTimeField(func(n *Node) time.Time { return n.CreatedAt }),
+	"updated_at":        nodeExprTimeField(func(n *Node) time.Time { return n.UpdatedAt }),
+	"latency_check_at":  nodeExprTimeString(func(n *Node) string { return n.LatencyCheckAt }),
+	"speed_check_at":    nodeExprTimeString(func(n *Node) string { return n.SpeedCheckAt }),
+	"quarantined_at":    nodeExprTimeString(func(n *Node) string { return n.QuarantinedAt }),
+	"tags":              nodeExprTags,
+	"tag":               nodeExprTags,
+}
+
+// nodeExprTags 节点的标签名称列表，包含各标签的祖先标签
+// 与 has_tag() 和订阅标签黑白名单一致：拥有 "香港" 的节点满足 tags contains "亚洲"
+func nodeExprTags(env *exprEnv) interface{} {
+	names := env.node.GetTagNames()
+	seen := make(map[string]bool, len(names))
+	list := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			list = append(list, name)
+		}
+	}
+	for _, name := range names {
+		for _, ancestor := range GetTagAncestors(name) {
+			if !seen[ancestor] {
+				seen[ancestor] = true
+				list = append(list, ancestor)
+			}
+		}
+	}
+	return list
+}
+
+type nodeExprFunc struct {
+	minArgs, maxArgs int // maxArgs 为 -1 表示不限
+	call             func(env *exprEnv, args []interface{}) interface{}
+}
+
+// nodeExprFuncs 表达式可用的内置函数（函数名不区分大小写）
+var nodeExprFuncs = map[string]nodeExprFunc{
+	// has_tag("a", "b")：节点拥有任一指定标签（含其子标签）
+	"has_tag": {minArgs: 1, maxArgs: -1, call: func(env *exprEnv, args []interface{}) interface{} {
+		for _, arg := range args {
+			for _, name := range exprAsList(arg) {
+				if env.node.HasTagInTree(exprString(name)) {
+					return true
+				}
+			}
+		}
+		return false
+	}},
+	// age_days()：节点创建至今的天数；age_days(time)：指定时间至今的天数，时间为空时返回 null
+	"age_days": {minArgs: 0, maxArgs: 1, call: func(env *exprEnv, args []interface{}) interface{} {
+		t := env.node.CreatedAt
+		if len(args) == 1 {
+			var ok bool
+			if t, ok = exprTime(args[0]); !ok {
+				return nil
+			}
+		}
+		if t.IsZero() {
+			return nil
+		}
+		return math.Floor(env.now.Sub(t).Hours()/24*100) / 100
+	}},
+	// now()：当前时间
+	"now": {minArgs: 0, maxArgs: 0, call: func(env *exprEnv, args []interface{}) interface{} {
+		return env.now
+	}},
+	// days_ago(n)：n 天前的时间
+	"days_ago": {minArgs: 1, maxArgs: 1, call: func(env *exprEnv, args []interface{}) interface{} {
+		days, ok := exprNumber(args[0])
+		if !ok {
+			return nil
+		}
+		return env.now.Add(-time.Duration(days * float64(24*time.Hour)))
+	}},
+	// date("2024-01-01")：解析日期字符串
+	"date": {minArgs: 1, maxArgs: 1, call: func(env *exprEnv, args []interface{}) interface{} {
+		if t, ok := exprTime(args[0]); ok {
+			return t
+		}
+		return nil
+	}},
+	"lower": {minArgs: 1, maxArgs: 1, call: func(env *exprEnv, args []interface{}) interface{} {
+		return strings.ToLower(exprString(args[0]))
+	}},
+	"upper": {minArgs: 1, maxArgs: 1, call: func(env *exprEnv, args []interface{}) interface{} {
+		return strings.ToUpper(exprString(args[0]))
+	}},
+	// len(x)：字符串长度或列表元素个数
+	"len": {minArgs: 1, maxArgs: 1, call: func(env *exprEnv, args []interface{}) interface{} {
+		if list, ok := args[0].([]interface{}); ok {
+			return float64(len(list))
+		}
+		return float64(len([]rune(exprString(args[0]))))
+	}},
+	"starts_with": {minArgs: 2, maxArgs: 2, call: func(env *exprEnv, args []interface{}) interface{} {
+		return strings.HasPrefix(exprString(args[0]), exprString(args[1]))
+	}},
+	"ends_with": {minArgs: 2, maxArgs: 2, call: func(env *exprEnv, args []interface{}) interface{} {
+		return strings.HasSuffix(exprString(args[0]), exprString(args[1]))
+	}},
+}
@@ -0,0 +1,225 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exprTestNode 表达式测试用节点
+func exprTestNode() Node {
+	return Node{
+		ID:             7,
+		Name:           "香港 IPLC 01",
+		LinkCountry:    "HK",
+		Protocol:       "vless",
+		Group:          "机场A",
+		Speed:          12.5,
+		DelayTime:      150,
+		DelayStatus:    "success",
+		Tags:           "香港,premium",
+		LatencyCheckAt: "2024-06-15 08:00:00",
+		CreatedAt:      time.Now().Add(-10 * 24 * time.Hour),
+	}
+}
+
+// withExprTestTags 在测试期间向标签缓存写入标签层级
+func withExprTestTags(t *testing.T, tags ...Tag) {
+	t.Helper()
+	for _, tag := range tags {
+		tagCache.Set(tag.Name, tag)
+	}
+	t.Cleanup(func() {
+		for _, tag := range tags {
+			tagCache.Delete(tag.Name)
+		}
+	})
+}
+
+// TestCompileNodeExpressionMatch 测试表达式求值：优先级、in/not in、between、日期比较与内置函数
+func TestCompileNodeExpressionMatch(t *testing.T) {
+	withExprTestTags(t, Tag{Name: "亚洲"}, Tag{Name: "香港", ParentName: "亚洲"}, Tag{Name: "premium"})
+	node := exprTestNode()
+
+	tests := []struct {
+		name string
+		expr string
+		want bool
+	}{
+		// 优先级：not > and > or
+		{"and 优先于 or", `country = US and delay < 100 or speed > 10`, true},
+		{"括号改变优先级", `country = US and (delay < 100 or speed > 10)`, false},
+		{"or 右侧 and", `speed > 10 or country = US and delay > 1000`, true},
+		{"not 优先于 and", `not country = US and delay < 200`, true},
+		{"not 作用于括号", `not (country = HK and delay < 200)`, false},
+		{"符号运算符", `country == "HK" && !(delay >= 200) || false`, true},
+		{"大小写不敏感关键字", `COUNTRY = HK AND Delay < 200`, true},
+
+		// in / not in
+		{"in 裸词列表", `country in [HK, TW]`, true},
+		{"in 不在列表", `country in [JP, TW]`, false},
+		{"not in", `protocol not in ["ss", "ssr"]`, true},
+		{"not in 命中", `protocol not in [vless, vmess]`, false},
+		{"数字 in", `delay in [100, 150]`, true},
+		{"标签列表 in", `"premium" in tags`, true},
+
+		// between
+		{"between 区间内", `speed between 5 and 50`, true},
+		{"between 包含边界", `delay between 150 and 200`, true},
+		{"between 区间外", `speed between 20 and 50`, false},
+		{"not between", `speed not between 20 and 50`, true},
+		{"between 后接 and", `speed between 5 and 50 and country = HK`, true},
+
+		// 日期比较
+		{"日期字符串字段", `latency_check_at >= "2024-06-01"`, true},
+		{"日期字符串字段早于", `latency_check_at < "2024-06-15"`, false},
+		{"date 函数", `latency_check_at between date("2024-06-15") and date("2024-06-16")`, true},
+		{"时间字段与 days_ago", `created_at < days_ago(5)`, true},
+		{"时间字段与 days_ago 未达到", `created_at < days_ago(30)`, false},
+		{"未设置的时间为 null", `speed_check_at >= "2000-01-01"`, false},
+		{"未设置的时间等于 null", `speed_check_at = null`, true},
+
+		// 内置函数
+		{"has_tag 直接标签", `has_tag("premium")`, true},
+		{"has_tag 父标签", `has_tag("亚洲")`, true},
+		{"has_tag 多个参数", `has_tag("欧洲", "香港")`, true},
+		{"has_tag 不存在", `has_tag("欧洲")`, false},
+		{"age_days", `age_days() between 9.9 and 10.1`, true},
+		{"age_days 指定时间", `age_days(latency_check_at) > 30`, true},
+		{"age_days 空时间为 null", `age_days(speed_check_at) > 0`, false},
+		{"字符串函数", `starts_with(lower(name), "香港") and len(name) = 10`, true},
+
+		// 标签字段包含祖先标签
+		{"tag 等于子标签", `tag = "香港"`, true},
+		{"tag 等于父标签", `tag = "亚洲"`, true},
+		{"tags contains 父标签", `tags contains "亚洲"`, true},
+		{"父标签 in tags", `"亚洲" in tags`, true},
+		{"tags 不含子标签", `tags contains "日本"`, false},
+		{"tags 展开后的数量", `len(tags) = 3`, true},
+
+		// 字符串匹配
+		{"正则匹配", `name ~ "(?i)iplc"`, true},
+		{"正则不匹配", `name !~ "IPLC"`, false},
+		{"contains 不区分大小写", `name contains "iplc"`, true},
+		{"not contains", `group not contains "机场B"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := CompileNodeExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("解析 %q 失败: %v", tt.expr, err)
+			}
+			if got := expr.Match(node); got != tt.want {
+				t.Errorf("%q = %v, 期望 %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCompileNodeExpressionErrors 测试解析错误：未知字段、未知函数、语法错误与长度限制
+func TestCompileNodeExpressionErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr string
+	}{
+		{"空表达式", `   `, "表达式为空"},
+		{"未知字段比较", `contry = HK`, "未知字段: contry"},
+		{"未知字段作为逻辑操作数", `delay < 200 and premium`, "未知字段或缺少比较运算: premium"},
+		{"单独的未知字段", `speedd`, "未知字段或缺少比较运算"},
+		{"未知函数", `has_tags("a")`, "未知函数"},
+		{"函数参数个数", `age_days(1, 2)`, "参数个数不正确"},
+		{"缺少右括号", `(delay < 200`, "缺少右括号"},
+		{"between 缺少 and", `speed between 1 or 5`, "between"},
+		{"多余内容", `delay < 200 200`, "多余内容"},
+		{"无效正则", `name ~ "("`, "正则表达式无效"},
+		{"字符串未结束", `name = "abc`, "缺少结束引号"},
+		{"无法识别的符号", `delay < 200 ; drop`, "无法识别的符号"},
+		{"表达式过长", "name = \"" + strings.Repeat("a", nodeExprMaxLength) + "\"", "表达式过长"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CompileNodeExpression(tt.expr)
+			if err == nil {
+				t.Fatalf("%q 应解析失败", tt.expr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q 的错误 = %q, 期望包含 %q", tt.expr, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestCompileNodeExpressionDepth 测试嵌套深度限制
+func TestCompileNodeExpressionDepth(t *testing.T) {
+	nested := func(n int) string {
+		return strings.Repeat("(", n) + "delay < 200" + strings.Repeat(")", n)
+	}
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{"括号未超限", nested(nodeExprMaxDepth - 1), false},
+		{"括号超限", nested(nodeExprMaxDepth), true},
+		{"not 超限", strings.Repeat("not ", nodeExprMaxDepth+1) + "delay < 200", true},
+		{"函数嵌套超限", strings.Repeat("lower(", nodeExprMaxDepth) + "name" + strings.Repeat(")", nodeExprMaxDepth) + ` = "a"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CompileNodeExpression(tt.expr)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "嵌套层级过深") {
+					t.Errorf("应返回嵌套层级过深错误, 实际: %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("不应报错: %v", err)
+			}
+		})
+	}
+}
+
+// TestCompileNodeExpressionCache 测试语法树缓存：相同文本复用、错误不缓存、超出上限时清空
+func TestCompileNodeExpressionCache(t *testing.T) {
+	const src = `country in [HK, TW] and delay < 321`
+	first, err := CompileNodeExpression(src)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if second, _ := CompileNodeExpression("  " + src + "\n"); second != first {
+		t.Error("首尾空白不同的相同表达式应命中缓存")
+	}
+	if first.Source() != src {
+		t.Errorf("Source() = %q, 期望 %q", first.Source(), src)
+	}
+
+	const bad = `contry = HK and delay < 321`
+	for i := 0; i < 2; i++ {
+		if _, err := CompileNodeExpression(bad); err == nil {
+			t.Fatal("错误的表达式不应被缓存为成功结果")
+		}
+	}
+	nodeExprCacheMu.RLock()
+	_, cachedBad := nodeExprCache[bad]
+	nodeExprCacheMu.RUnlock()
+	if cachedBad {
+		t.Error("错误的表达式不应写入缓存")
+	}
+
+	// 写满缓存后再写入新条目时整体清空
+	for i := 0; i < nodeExprCacheSize; i++ {
+		if _, err := CompileNodeExpression(fmt.Sprintf("delay < %d", 100000+i)); err != nil {
+			t.Fatalf("解析失败: %v", err)
+		}
+	}
+	nodeExprCacheMu.RLock()
+	size := len(nodeExprCache)
+	nodeExprCacheMu.RUnlock()
+	if size > nodeExprCacheSize {
+		t.Errorf("缓存条目数 %d 超过上限 %d", size, nodeExprCacheSize)
+	}
+	if again, _ := CompileNodeExpression(src); again == first {
+		t.Error("缓存清空后应重新解析")
+	}
+}
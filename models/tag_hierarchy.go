@@ -0,0 +1,151 @@
+package models
+
+import (
+	"fmt"
+	"sublink/database"
+)
+
+// tagMaxDepth 标签层级的最大深度
+const tagMaxDepth = 16
+
+// ValidateTagParent 检查父标签设置：父标签必须存在、不能是自身或自身的子孙标签，且层级不能超过上限
+func ValidateTagParent(name, parentName string) error {
+	if parentName == "" {
+		return nil
+	}
+	if parentName == name {
+		return fmt.Errorf("父标签不能是标签自身")
+	}
+	if !TagExists(parentName) {
+		return fmt.Errorf("父标签 %s 不存在", parentName)
+	}
+	for _, d := range GetTagDescendants(name) {
+		if d == parentName {
+			return fmt.Errorf("父标签 %s 是 %s 的子标签，不能形成循环", parentName, name)
+		}
+	}
+	if len(GetTagAncestors(parentName))+1+tagSubtreeDepth(name) > tagMaxDepth {
+		return fmt.Errorf("标签层级不能超过 %d 层", tagMaxDepth)
+	}
+	return nil
+}
+
+// GetTagChildren 获取直接子标签名称
+func GetTagChildren(name string) []string {
+	children := make([]string, 0)
+	for _, t := range tagCache.Filter(func(t Tag) bool { return t.ParentName == name }) {
+		children = append(children, t.Name)
+	}
+	return children
+}
+
+// GetTagDescendants 获取全部子孙标签名称（不含自身）
+func GetTagDescendants(name string) []string {
+	result := make([]string, 0)
+	visited := map[string]bool{name: true}
+	queue := []string{name}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, child := range GetTagChildren(current) {
+			if visited[child] {
+				continue
+			}
+			visited[child] = true
+			result = append(result, child)
+			queue = append(queue, child)
+		}
+	}
+	return result
+}
+
+// GetTagAncestors 获取祖先标签名称，由近及远
+func GetTagAncestors(name string) []string {
+	result := make([]string, 0)
+	visited := map[string]bool{name: true}
+	for {
+		tag, ok := tagCache.Get(name)
+		if !ok || tag.ParentName == "" || visited[tag.ParentName] {
+			return result
+		}
+		visited[tag.ParentName] = true
+		result = append(result, tag.ParentName)
+		name = tag.ParentName
+	}
+}
+
+// ExpandTagNames 将标签名称展开为包含其全部子孙标签的集合
+// 用于订阅标签黑白名单：选择 "亚洲" 即包含 "香港"、"日本" 等子标签
+func ExpandTagNames(names []string) map[string]bool {
+	result := make(map[string]bool, len(names))
+	for _, name := range names {
+		if name == "" || result[name] {
+			continue
+		}
+		result[name] = true
+		for _, d := range GetTagDescendants(name) {
+			result[d] = true
+		}
+	}
+	return result
+}
+
+// HasTagInTree 节点是否拥有指定标签或其任一子孙标签
+func (n *Node) HasTagInTree(tagName string) bool {
+	names := n.GetTagNames()
+	if len(names) == 0 {
+		return false
+	}
+	for _, name := range names {
+		if name == tagName {
+			return true
+		}
+	}
+	for _, name := range names {
+		for _, ancestor := range GetTagAncestors(name) {
+			if ancestor == tagName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tagSubtreeDepth 以指定标签为根的子树深度（仅自身时为 0）
+func tagSubtreeDepth(name string) int {
+	depth := 0
+	level := []string{name}
+	visited := map[string]bool{name: true}
+	for {
+		var next []string
+		for _, n := range level {
+			for _, child := range GetTagChildren(n) {
+				if !visited[child] {
+					visited[child] = true
+					next = append(next, child)
+				}
+			}
+		}
+		if len(next) == 0 {
+			return depth
+		}
+		depth++
+		level = next
+	}
+}
+
+// reparentChildTags 将指定标签的直接子标签改挂到新的父标签下（删除标签时调用）
+func reparentChildTags(name, newParent string) error {
+	children := tagCache.Filter(func(t Tag) bool { return t.ParentName == name })
+	if len(children) == 0 {
+		return nil
+	}
+	if err := database.DB.Model(&Tag{}).Where("parent_name = ?", name).Update("parent_name", newParent).Error; err != nil {
+		return err
+	}
+	for _, child := range children {
+		child.ParentName = newParent
+		tagCache.Set(child.Name, child)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package models
+
+import "fmt"
+
+// tagRulePreviewMaxNodes 预览结果中每个节点列表的最大条数，超出部分只计数
+const tagRulePreviewMaxNodes = 1000
+
+// TagRulePreviewNode 预览结果中的节点
+type TagRulePreviewNode struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Group  string `json:"group"`
+	Source string `json:"source"`
+}
+
+// TagRuleConflict 与同组互斥标签的冲突
+type TagRuleConflict struct {
+	TagRulePreviewNode
+	ReplacedTags   []string `json:"replacedTags"`   // 打上标签时会被移除的同组标签
+	CompetingRules []string `json:"competingRules"` // 同样匹配该节点、但给同组其他标签的启用规则
+}
+
+// TagRulePreview 标签规则试运行结果（不修改任何节点）
+type TagRulePreview struct {
+	TagName        string               `json:"tagName"`
+	GroupName      string               `json:"groupName"`
+	TotalNodes     int                  `json:"totalNodes"`
+	MatchedCount   int                  `json:"matchedCount"`
+	UnchangedCount int                  `json:"unchangedCount"` // 匹配且已有该标签的节点数
+	GainCount      int                  `json:"gainCount"`
+	LoseCount      int                  `json:"loseCount"`
+	ConflictCount  int                  `json:"conflictCount"`
+	Gain           []TagRulePreviewNode `json:"gain"`      // 将获得标签的节点
+	Lose           []TagRulePreviewNode `json:"lose"`      // 将失去标签的节点
+	Conflicts      []TagRuleConflict    `json:"conflicts"` // 与同组互斥标签冲突的节点
+	Truncated      bool                 `json:"truncated"` // 节点列表是否被截断
+}
+
+// PreviewTagRule 对给定节点评估规则，返回执行后将发生的标签变化
+// 与手动触发规则的语义一致：匹配的节点获得标签，不匹配但已有标签的节点失去标签（有有效期的规则不会移除标签）
+// rule.ID 为正在编辑的规则ID（未保存时为 0），比较同组规则时跳过它自身
+func PreviewTagRule(rule TagRule, conditions *TagConditions, nodes []Node) (*TagRulePreview, error) {
+	var tag Tag
+	if err := tag.GetByName(rule.TagName); err != nil {
+		return nil, fmt.Errorf("标签 %s 不存在", rule.TagName)
+	}
+
+	preview := &TagRulePreview{
+		TagName:    tag.Name,
+		GroupName:  tag.GroupName,
+		TotalNodes: len(nodes),
+		Gain:       []TagRulePreviewNode{},
+		Lose:       []TagRulePreviewNode{},
+		Conflicts:  []TagRuleConflict{},
+	}
+
+	sameGroup := make(map[string]bool)
+	for _, t := range GetTagsInSameGroup(tag.Name) {
+		sameGroup[t] = true
+	}
+
+	// 同组其他标签的启用规则，用于发现多个规则同时匹配同一节点而互相覆盖的情况
+	type competingRule struct {
+		name       string
+		conditions *TagConditions
+	}
+	var competitors []competingRule
+	if len(sameGroup) > 0 {
+		rules, err := rule.List()
+		if err != nil {
+			return nil, err
+		}
+		for _, other := range rules {
+			if !other.Enabled || other.ID == rule.ID || !sameGroup[other.TagName] {
+				continue
+			}
+			cond, err := ParseConditions(other.Conditions)
+			if err != nil {
+				continue
+			}
+			competitors = append(competitors, competingRule{name: fmt.Sprintf("%s (%s)", other.Name, other.TagName), conditions: cond})
+		}
+	}
+
+	for _, n := range nodes {
+		item := TagRulePreviewNode{ID: n.ID, Name: n.Name, Group: n.Group, Source: n.Source}
+		hasTag := n.HasTagName(tag.Name)
+		if !conditions.EvaluateNode(n) {
+			if hasTag && rule.ExpireMinutes <= 0 {
+				preview.LoseCount++
+				preview.Lose = appendPreviewNode(preview, preview.Lose, item)
+			}
+			continue
+		}
+
+		preview.MatchedCount++
+		if hasTag {
+			preview.UnchangedCount++
+		} else {
+			preview.GainCount++
+			preview.Gain = appendPreviewNode(preview, preview.Gain, item)
+		}
+
+		conflict := TagRuleConflict{TagRulePreviewNode: item, ReplacedTags: []string{}, CompetingRules: []string{}}
+		if !hasTag {
+			for _, t := range n.GetTagNames() {
+				if sameGroup[t] {
+					conflict.ReplacedTags = append(conflict.ReplacedTags, t)
+				}
+			}
+		}
+		for _, c := range competitors {
+			if c.conditions.EvaluateNode(n) {
+				conflict.CompetingRules = append(conflict.CompetingRules, c.name)
+			}
+		}
+		if len(conflict.ReplacedTags) > 0 || len(conflict.CompetingRules) > 0 {
+			preview.ConflictCount++
+			if len(preview.Conflicts) < tagRulePreviewMaxNodes {
+				preview.Conflicts = append(preview.Conflicts, conflict)
+			} else {
+				preview.Truncated = true
+			}
+		}
+	}
+	return preview, nil
+}
+
+// appendPreviewNode 追加节点到预览列表，超过上限时标记截断
+func appendPreviewNode(preview *TagRulePreview, list []TagRulePreviewNode, item TagRulePreviewNode) []TagRulePreviewNode {
+	if len(list) >= tagRulePreviewMaxNodes {
+		preview.Truncated = true
+		return list
+	}
+	return append(list, item)
+}
@@ -24,9 +24,10 @@ const (
 type TaskType string
 
 const (
-	TaskTypeSpeedTest TaskType = "speed_test" // 节点测速
-	TaskTypeSubUpdate TaskType = "sub_update" // 订阅更新
-	TaskTypeTagRule   TaskType = "tag_rule"   // 标签规则
+	TaskTypeSpeedTest  TaskType = "speed_test"  // 节点测速
+	TaskTypeSubUpdate  TaskType = "sub_update"  // 订阅更新
+	TaskTypeTagRule    TaskType = "tag_rule"    // 标签规则
+	TaskTypeScriptHook TaskType = "script_hook" // 脚本钩子
 )
 
 // TaskTrigger 任务触发方式
@@ -35,6 +36,7 @@ type TaskTrigger string
 const (
 	TaskTriggerManual    TaskTrigger = "manual"    // 手动触发
 	TaskTriggerScheduled TaskTrigger = "scheduled" // 定时触发
+	TaskTriggerResume    TaskTrigger = "resume"    // 断点续测
 )
 
 // Task 任务模型
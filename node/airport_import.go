@@ -0,0 +1,240 @@
+package node
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sublink/models"
+	"sublink/node/protocol"
+	"sublink/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// portRange 端口范围（闭区间）
+type portRange struct {
+	from int
+	to   int
+}
+
+// parsePortRanges 解析逗号分隔的端口列表，支持范围写法如 8000-9000
+func parsePortRanges(s string) ([]portRange, error) {
+	var ranges []portRange
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		bounds := strings.SplitN(part, "-", 2)
+		from, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return nil, fmt.Errorf("无效的端口: %s", part)
+		}
+		to := from
+		if len(bounds) == 2 {
+			if to, err = strconv.Atoi(strings.TrimSpace(bounds[1])); err != nil {
+				return nil, fmt.Errorf("无效的端口范围: %s", part)
+			}
+		}
+		if from < 1 || to > 65535 || from > to {
+			return nil, fmt.Errorf("无效的端口范围: %s", part)
+		}
+		ranges = append(ranges, portRange{from: from, to: to})
+	}
+	return ranges, nil
+}
+
+// portInRanges 端口是否在任一范围内
+func portInRanges(port int, ranges []portRange) bool {
+	for _, r := range ranges {
+		if port >= r.from && port <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// parseProtocolSet 解析逗号分隔的协议列表（小写）
+func parseProtocolSet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(strings.ToLower(p)); p != "" {
+			set[p] = true
+		}
+	}
+	return set
+}
+
+// ValidateAirportImportRules 校验机场导入规则配置
+func ValidateAirportImportRules(a *models.Airport) error {
+	if a.ImportIncludeName != "" {
+		if _, err := regexp.Compile(a.ImportIncludeName); err != nil {
+			return fmt.Errorf("名称保留规则不是有效的正则表达式: %v", err)
+		}
+	}
+	if a.ImportExcludeName != "" {
+		if _, err := regexp.Compile(a.ImportExcludeName); err != nil {
+			return fmt.Errorf("名称排除规则不是有效的正则表达式: %v", err)
+		}
+	}
+	if _, err := parsePortRanges(a.ImportIncludePorts); err != nil {
+		return fmt.Errorf("端口白名单有误: %v", err)
+	}
+	if _, err := parsePortRanges(a.ImportExcludePorts); err != nil {
+		return fmt.Errorf("端口黑名单有误: %v", err)
+	}
+	if a.ImportNamePreprocess != "" {
+		var rules []utils.PreprocessRule
+		if err := json.Unmarshal([]byte(a.ImportNamePreprocess), &rules); err != nil {
+			return fmt.Errorf("名称预处理规则格式错误: %v", err)
+		}
+	}
+	return nil
+}
+
+// AirportSyncHookFunc 执行脚本库中的机场同步钩子，返回处理后的节点
+// 由 main 注入（钩子运行记录依赖 scheduler 包的任务管理，node 包无法直接引用）
+var AirportSyncHookFunc func(airport *models.Airport, proxys []protocol.Proxy, trigger models.TaskTrigger) []protocol.Proxy
+
+// ApplyTagRulesFunc 对节点应用指定触发类型的标签规则
+// 由 main 注入（标签规则在 services 包中执行，node 包无法直接引用）
+var ApplyTagRulesFunc func(nodes []models.Node, triggerType string)
+
+// applyAirportImportRules 在节点写入数据库前应用机场导入规则
+// 执行顺序：名称/协议/端口过滤 -> 名称预处理 -> 转换脚本
+// 过滤基于机场返回的原始名称；转换脚本执行失败时返回错误，本次同步中止
+func applyAirportImportRules(airport *models.Airport, proxys []protocol.Proxy) ([]protocol.Proxy, error) {
+	if airport == nil {
+		return proxys, nil
+	}
+
+	var includeName, excludeName *regexp.Regexp
+	if airport.ImportIncludeName != "" {
+		if re, err := regexp.Compile(airport.ImportIncludeName); err == nil {
+			includeName = re
+		} else {
+			utils.Warn("机场【%s】名称保留规则无效，已忽略: %v", airport.Name, err)
+		}
+	}
+	if airport.ImportExcludeName != "" {
+		if re, err := regexp.Compile(airport.ImportExcludeName); err == nil {
+			excludeName = re
+		} else {
+			utils.Warn("机场【%s】名称排除规则无效，已忽略: %v", airport.Name, err)
+		}
+	}
+	includeProtos := parseProtocolSet(airport.ImportIncludeProtocols)
+	excludeProtos := parseProtocolSet(airport.ImportExcludeProtocols)
+	includePorts, _ := parsePortRanges(airport.ImportIncludePorts)
+	excludePorts, _ := parsePortRanges(airport.ImportExcludePorts)
+
+	result := make([]protocol.Proxy, 0, len(proxys))
+	for _, proxy := range proxys {
+		name := strings.TrimSpace(proxy.Name)
+		proto := strings.ToLower(proxy.Type)
+		port := int(proxy.Port)
+
+		// 黑名单优先
+		if excludeName != nil && excludeName.MatchString(name) {
+			continue
+		}
+		if len(excludeProtos) > 0 && excludeProtos[proto] {
+			continue
+		}
+		if len(excludePorts) > 0 && portInRanges(port, excludePorts) {
+			continue
+		}
+		// 白名单
+		if includeName != nil && !includeName.MatchString(name) {
+			continue
+		}
+		if len(includeProtos) > 0 && !includeProtos[proto] {
+			continue
+		}
+		if len(includePorts) > 0 && !portInRanges(port, includePorts) {
+			continue
+		}
+
+		// 名称预处理
+		if airport.ImportNamePreprocess != "" {
+			if renamed := utils.PreprocessNodeName(airport.ImportNamePreprocess, name); renamed != "" {
+				proxy.Name = renamed
+			}
+		}
+		result = append(result, proxy)
+	}
+
+	if filtered := len(proxys) - len(result); filtered > 0 {
+		utils.Info("机场【%s】导入规则过滤掉 %d 个节点", airport.Name, filtered)
+	}
+
+	if strings.TrimSpace(airport.ImportScript) == "" {
+		return result, nil
+	}
+	transformed, err := runAirportImportScript(airport, result)
+	if err != nil {
+		return nil, fmt.Errorf("导入转换脚本执行失败: %w", err)
+	}
+	return transformed, nil
+}
+
+// runAirportImportScript 执行导入转换脚本
+// 节点以 Clash 配置格式（与 YAML 字段名一致）传入脚本，返回值按同样格式解析
+func runAirportImportScript(airport *models.Airport, proxys []protocol.Proxy) ([]protocol.Proxy, error) {
+	proxiesJSON, err := ProxiesToScriptJSON(proxys)
+	if err != nil {
+		return nil, err
+	}
+
+	resultJSON, err := utils.RunProxyTransformScript(utils.InlineScript(airport.ImportScript, models.AirportScriptKVNamespace(airport.ID)), proxiesJSON, airport.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := ProxiesFromScriptJSON(resultJSON)
+	if err != nil {
+		return nil, err
+	}
+	utils.Info("机场【%s】导入转换脚本执行完成：%d -> %d 个节点", airport.Name, len(proxys), len(result))
+	return result, nil
+}
+
+// ProxiesToScriptJSON 将节点转换为传给脚本的 JSON（Clash 配置格式，字段名与 YAML 一致）
+func ProxiesToScriptJSON(proxys []protocol.Proxy) ([]byte, error) {
+	yamlData, err := yaml.Marshal(proxys)
+	if err != nil {
+		return nil, err
+	}
+	var proxyMaps []map[string]interface{}
+	if err := yaml.Unmarshal(yamlData, &proxyMaps); err != nil {
+		return nil, err
+	}
+	if proxyMaps == nil {
+		proxyMaps = []map[string]interface{}{}
+	}
+	return json.Marshal(proxyMaps)
+}
+
+// ProxiesFromScriptJSON 解析脚本返回的节点数组（Clash 配置格式）
+// 返回值必须是数组：脚本漏写 return 时结果为 null，按无效处理，避免被当作空列表删除全部节点
+func ProxiesFromScriptJSON(data []byte) ([]protocol.Proxy, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '[' {
+		return nil, fmt.Errorf("脚本返回值不是节点数组（是否缺少 return？）")
+	}
+	var resultMaps []map[string]interface{}
+	if err := json.Unmarshal(data, &resultMaps); err != nil {
+		return nil, fmt.Errorf("脚本返回值不是节点数组: %w", err)
+	}
+	resultYAML, err := yaml.Marshal(resultMaps)
+	if err != nil {
+		return nil, err
+	}
+	var result []protocol.Proxy
+	if err := yaml.Unmarshal(resultYAML, &result); err != nil {
+		return nil, fmt.Errorf("解析脚本返回的节点失败: %w", err)
+	}
+	return result, nil
+}
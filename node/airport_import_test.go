@@ -0,0 +1,67 @@
+package node
+
+import (
+	"strings"
+	"testing"
+
+	"sublink/models"
+	"sublink/node/protocol"
+)
+
+// TestProxiesFromScriptJSON 测试脚本返回值解析：null 与非数组视为无效，空数组有效
+func TestProxiesFromScriptJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    int
+		wantErr bool
+	}{
+		{name: "节点数组", data: `[{"name":"a","type":"ss","server":"1.1.1.1","port":443,"cipher":"aes-128-gcm","password":"p"}]`, want: 1},
+		{name: "空数组", data: ` [] `, want: 0},
+		{name: "未返回值", data: `null`, wantErr: true},
+		{name: "空结果", data: ``, wantErr: true},
+		{name: "对象", data: `{"proxies":[]}`, wantErr: true},
+		{name: "数字", data: `1`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ProxiesFromScriptJSON([]byte(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("应返回错误, 实际得到 %d 个节点", len(got))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("不应报错: %v", err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("节点数 = %d, 期望 %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+// TestApplyAirportImportRulesScriptWithoutReturn 测试转换脚本漏写 return 时中止同步，而不是返回空节点列表
+func TestApplyAirportImportRulesScriptWithoutReturn(t *testing.T) {
+	proxys := []protocol.Proxy{
+		{Name: "香港 01", Type: "ss", Server: "1.1.1.1", Port: 443, Cipher: "aes-128-gcm", Password: "p"},
+		{Name: "日本 01", Type: "ss", Server: "2.2.2.2", Port: 443, Cipher: "aes-128-gcm", Password: "p"},
+	}
+
+	airport := &models.Airport{ID: 1, Name: "测试机场", ImportScript: `function transformProxies(proxies, name) { proxies.pop(); }`}
+	if result, err := applyAirportImportRules(airport, proxys); err == nil {
+		t.Fatalf("未返回值的转换脚本应报错, 实际得到 %d 个节点", len(result))
+	} else if !strings.Contains(err.Error(), "不是节点数组") {
+		t.Errorf("错误 = %q, 期望提示返回值不是节点数组", err.Error())
+	}
+
+	airport.ImportScript = `function transformProxies(proxies, name) { return proxies.filter(p => p.name.startsWith("香港")); }`
+	result, err := applyAirportImportRules(airport, proxys)
+	if err != nil {
+		t.Fatalf("转换脚本执行失败: %v", err)
+	}
+	if len(result) != 1 || result[0].Name != "香港 01" {
+		t.Errorf("转换结果 = %+v, 期望只保留香港 01", result)
+	}
+}
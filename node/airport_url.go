@@ -0,0 +1,234 @@
+package node
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sublink/models"
+	"sublink/utils"
+	"time"
+)
+
+// LoadAirportSubscriptionWithReporter 按机场配置的多个地址拉取订阅并同步节点
+// 故障转移模式：从最近一次成功的地址开始依次尝试，成功即止，并记住成功的地址
+// 合并模式：拉取全部地址并合并去重，任一地址失败则中止本次同步，避免误删节点
+// 定时触发时发送条件请求，订阅内容未变化则跳过节点处理，返回 unchanged=true；其他触发方式强制完整处理
+// 导入规则或同步钩子脚本变化后同样强制完整处理
+func LoadAirportSubscriptionWithReporter(airport *models.Airport, reporter TaskReporter, trigger models.TaskTrigger) (usageInfo *UsageInfo, unchanged bool, err error) {
+	if reporter == nil {
+		reporter = &NoOpTaskReporter{}
+	}
+	configHash := airport.SyncConfigHash()
+	force := trigger != models.TaskTriggerScheduled || configHash != airport.FetchConfigHash
+	urls := airport.OrderedURLs()
+	if len(urls) == 0 {
+		return nil, false, fmt.Errorf("机场【%s】未配置订阅地址", airport.Name)
+	}
+
+	var result *subFetchResult
+	if airport.IsMergeURLMode() {
+		result, err = fetchAirportMergedProxies(airport, urls, force)
+	} else {
+		result, err = fetchAirportFailoverProxies(airport, urls, force)
+	}
+	if err != nil {
+		notifySubFetchFailed(airport.ID, airport.Name, err, airport.SkipTLSVerify)
+		return nil, false, err
+	}
+
+	if result.unchanged {
+		utils.Info("订阅【%s】内容未变化，跳过节点处理", airport.Name)
+		now := time.Now()
+		if err := airport.UpdateRunTime(&now, airport.NextRunTime); err != nil {
+			utils.Warn("更新机场【%s】运行时间失败: %v", airport.Name, err)
+		}
+		reporter.ReportComplete("订阅内容未变化，跳过处理", map[string]interface{}{
+			"status":    "unchanged",
+			"unchanged": true,
+		})
+		return result.usageInfo, true, nil
+	}
+
+	if err = scheduleClashToNodeLinks(airport.ID, result.proxys, airport.Name, reporter, result.usageInfo, trigger); err != nil {
+		return result.usageInfo, false, err
+	}
+	// 仅在节点处理成功后记录内容状态，保护中止或失败时下次仍会完整处理
+	// 有节点处于删除宽限期时清除状态：相同内容的下次拉取仍需完整处理，才能累计缺失次数并最终删除
+	etag, lastModified, contentHash := result.etag, result.lastModified, result.contentHash
+	if models.HasHeldDeletions(airport.ID) {
+		etag, lastModified, contentHash, configHash = "", "", "", ""
+	}
+	if err := airport.SaveFetchState(etag, lastModified, contentHash, configHash); err != nil {
+		utils.Warn("保存机场【%s】拉取状态失败: %v", airport.Name, err)
+	}
+	return result.usageInfo, false, nil
+}
+
+// fetchAirportURL 拉取单个地址并记录健康状态与拉取记录
+func fetchAirportURL(airport *models.Airport, urlStr string, cond *subFetchCondition) (*subFetchResult, error) {
+	start := time.Now()
+	result, err := fetchSubscriptionProxies(urlStr, airport.Name, airport.ProxyOptions(30*time.Second),
+		airport.UserAgent, airport.FetchUsageInfo, cond)
+	duration := time.Since(start)
+	if recordErr := models.RecordAirportURLResult(airport.ID, urlStr, err, duration); recordErr != nil {
+		utils.Warn("记录机场【%s】地址健康状态失败: %v", airport.Name, recordErr)
+	}
+
+	attempt := &models.AirportFetchAttempt{
+		AirportID:  airport.ID,
+		URL:        urlStr,
+		Success:    err == nil,
+		Unchanged:  result.unchanged,
+		StatusCode: result.statusCode,
+		Bytes:      result.bytes,
+		Duration:   duration.Milliseconds(),
+		NodeCount:  len(result.proxys),
+		Proxy:      result.proxy,
+	}
+	if err != nil {
+		attempt.ErrorClass = classifyFetchError(err, result.statusCode)
+		attempt.Error = err.Error()
+	}
+	if recordErr := models.RecordAirportFetchAttempt(attempt); recordErr != nil {
+		utils.Warn("记录机场【%s】拉取记录失败: %v", airport.Name, recordErr)
+	}
+	return result, err
+}
+
+// classifyFetchError 对拉取失败进行分类
+func classifyFetchError(err error, statusCode int) string {
+	var fe *subFetchError
+	if errors.As(err, &fe) {
+		switch fe.stage {
+		case subFetchStageParse:
+			return models.FetchErrorParse
+		case subFetchStageRead:
+			if isTimeoutError(err) {
+				return models.FetchErrorTimeout
+			}
+			return models.FetchErrorRead
+		}
+	}
+	switch {
+	case statusCode >= 500:
+		return models.FetchErrorHTTP5xx
+	case statusCode >= 400:
+		return models.FetchErrorHTTP4xx
+	}
+
+	if errors.Is(err, utils.ErrProxyUnavailable) {
+		return models.FetchErrorProxy
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return models.FetchErrorDNS
+	}
+	if isTLSError(err) {
+		return models.FetchErrorTLS
+	}
+	if isTimeoutError(err) {
+		return models.FetchErrorTimeout
+	}
+	errStr := strings.ToLower(err.Error())
+	if strings.Contains(errStr, "connection refused") || strings.Contains(errStr, "connection reset") ||
+		strings.Contains(errStr, "no route to host") || strings.Contains(errStr, "network is unreachable") ||
+		strings.Contains(errStr, "eof") {
+		return models.FetchErrorConnection
+	}
+	return models.FetchErrorOther
+}
+
+// isTimeoutError 是否为超时错误
+func isTimeoutError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return strings.Contains(strings.ToLower(err.Error()), "timeout")
+}
+
+// fetchAirportFailoverProxies 依次尝试各地址，返回第一个成功地址的结果
+// ETag/Last-Modified 只对上次成功的地址有效，内容哈希对所有地址有效
+func fetchAirportFailoverProxies(airport *models.Airport, urls []string, force bool) (*subFetchResult, error) {
+	var lastErr error
+	for i, urlStr := range urls {
+		var cond *subFetchCondition
+		if !force {
+			cond = &subFetchCondition{contentHash: airport.ContentHash}
+			if urlStr == airport.ActiveURL {
+				cond.etag = airport.FetchETag
+				cond.lastModified = airport.FetchLastModified
+			}
+		}
+		result, err := fetchAirportURL(airport, urlStr, cond)
+		if err != nil {
+			lastErr = err
+			if i < len(urls)-1 {
+				utils.Warn("机场【%s】地址 %d/%d 拉取失败，尝试下一个地址: %v", airport.Name, i+1, len(urls), err)
+			}
+			continue
+		}
+		if urlStr != airport.ActiveURL {
+			if len(urls) > 1 {
+				utils.Info("机场【%s】切换到可用地址: %s", airport.Name, urlStr)
+			}
+			if err := airport.SetActiveURL(urlStr); err != nil {
+				utils.Warn("记录机场【%s】可用地址失败: %v", airport.Name, err)
+			}
+		}
+		return result, nil
+	}
+	if len(urls) > 1 {
+		utils.Error("机场【%s】全部 %d 个地址拉取失败", airport.Name, len(urls))
+	}
+	return nil, lastErr
+}
+
+// fetchAirportMergedProxies 拉取全部地址并合并节点
+// 同一节点可能同时出现在 Clash 与 Base64 等不同格式的地址中，按协议+服务器+端口+名称去重
+// 合并模式不发送条件请求，按全部地址内容的组合哈希判断是否变化
+func fetchAirportMergedProxies(airport *models.Airport, urls []string, force bool) (*subFetchResult, error) {
+	merged := &subFetchResult{}
+	seen := make(map[string]bool)
+	hashes := make([]string, 0, len(urls))
+	for i, urlStr := range urls {
+		result, err := fetchAirportURL(airport, urlStr, nil)
+		if err != nil {
+			utils.Error("机场【%s】合并模式下地址 %d/%d 拉取失败，本次同步中止", airport.Name, i+1, len(urls))
+			return nil, err
+		}
+		hashes = append(hashes, result.contentHash)
+		// 用量信息取第一个成功解析的地址
+		if result.usageInfo != nil && (merged.usageInfo == nil || merged.usageInfo.Total == -1) {
+			merged.usageInfo = result.usageInfo
+		}
+		for _, proxy := range result.proxys {
+			key := strings.ToLower(proxy.Type) + "|" + strings.ToLower(proxy.Server) + "|" +
+				strconv.Itoa(int(proxy.Port)) + "|" + strings.TrimSpace(proxy.Name)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged.proxys = append(merged.proxys, proxy)
+		}
+	}
+	sum := sha256.Sum256([]byte(strings.Join(hashes, "\n")))
+	merged.contentHash = hex.EncodeToString(sum[:])
+	if !force && airport.ContentHash != "" && airport.ContentHash == merged.contentHash {
+		merged.proxys = nil
+		merged.unchanged = true
+		return merged, nil
+	}
+	if len(urls) > 1 {
+		utils.Info("机场【%s】合并 %d 个地址，共 %d 个节点", airport.Name, len(urls), len(merged.proxys))
+	}
+	return merged, nil
+}
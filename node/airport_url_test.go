@@ -0,0 +1,104 @@
+package node
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"sublink/models"
+)
+
+// subContentStub 返回可修改的明文链接订阅
+type subContentStub struct {
+	mu    sync.Mutex
+	links []string
+}
+
+func (s *subContentStub) set(links ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.links = links
+}
+
+func (s *subContentStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprint(w, strings.Join(s.links, "\n"))
+}
+
+// TestLoadAirportSubscriptionHeldDeletions 测试删除宽限期内相同内容的定时拉取仍完整处理，
+// 以及导入规则变化后不再按内容哈希跳过
+func TestLoadAirportSubscriptionHeldDeletions(t *testing.T) {
+	setupPanelTestDB(t)
+
+	const linkA = "trojan://pass@1.1.1.1:443#A"
+	const linkB = "trojan://pass@2.2.2.2:443#B"
+	stub := &subContentStub{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	airport := &models.Airport{Name: "宽限测试机场", URL: server.URL, DeleteGraceSyncs: 1}
+	if err := airport.Add(); err != nil {
+		t.Fatalf("添加机场失败: %v", err)
+	}
+
+	sync := func(step string) bool {
+		t.Helper()
+		a, err := models.GetAirportByID(airport.ID)
+		if err != nil {
+			t.Fatalf("%s: 获取机场失败: %v", step, err)
+		}
+		_, unchanged, err := LoadAirportSubscriptionWithReporter(a, nil, models.TaskTriggerScheduled)
+		if err != nil {
+			t.Fatalf("%s: 同步失败: %v", step, err)
+		}
+		return unchanged
+	}
+	nodeCount := func() int {
+		nodes, _ := models.ListBySourceID(airport.ID)
+		return len(nodes)
+	}
+
+	stub.set(linkA, linkB)
+	if sync("首次同步") || nodeCount() != 2 {
+		t.Fatalf("首次同步应导入 2 个节点, 实际 %d 个", nodeCount())
+	}
+
+	// B 缺失：处于宽限期，暂不删除
+	stub.set(linkA)
+	if sync("B 首次缺失") {
+		t.Fatal("内容变化时不应跳过")
+	}
+	if nodeCount() != 2 || !models.HasHeldDeletions(airport.ID) {
+		t.Fatalf("B 应处于删除宽限期, 节点数 %d", nodeCount())
+	}
+
+	// 内容相同：有暂缓删除的节点，仍需完整处理并删除超过宽限的节点
+	if sync("B 再次缺失") {
+		t.Fatal("有节点处于删除宽限期时不应按内容未变化跳过")
+	}
+	if nodeCount() != 1 || models.HasHeldDeletions(airport.ID) {
+		t.Fatalf("超过宽限次数后 B 应被删除, 节点数 %d", nodeCount())
+	}
+
+	// 没有暂缓删除的节点后，相同内容按未变化跳过
+	if !sync("内容未变化") {
+		t.Error("内容和配置均未变化时应跳过")
+	}
+
+	// 导入规则变化：内容相同也需完整处理
+	a, _ := models.GetAirportByID(airport.ID)
+	a.ImportExcludeName = "^A$"
+	if err := a.Update(); err != nil {
+		t.Fatalf("更新机场失败: %v", err)
+	}
+	if sync("导入规则变化") {
+		t.Error("导入规则变化后不应按内容未变化跳过")
+	}
+	if !models.HasHeldDeletions(airport.ID) {
+		t.Error("被新规则排除的节点应进入删除宽限期")
+	}
+}
@@ -0,0 +1,433 @@
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sublink/models"
+	"sublink/services/sse"
+	"sublink/utils"
+	"time"
+)
+
+// PanelInfo 从机场面板获取的账号信息
+type PanelInfo struct {
+	SubscribeURL string   `json:"subscribeUrl"`         // 面板返回的订阅地址
+	Candidates   []string `json:"candidates,omitempty"` // 页面中找到的全部订阅链接（SSPanel 不同客户端格式）
+	Plan         string   `json:"plan"`                 // 套餐名称
+	HasUsage     bool     `json:"hasUsage"`             // 面板是否返回了流量信息
+	Upload       int64    `json:"upload"`               // 已上传流量（字节）
+	Download     int64    `json:"download"`             // 已下载流量（字节）
+	Total        int64    `json:"total"`                // 总流量（字节）
+	Expire       int64    `json:"expire"`               // 到期时间（Unix时间戳），0 表示长期有效
+	ResetDay     int      `json:"resetDay"`             // 距离流量重置的天数，-1 表示未知
+}
+
+// errPanelUnauthorized 登录凭证失效，需要重新登录
+var errPanelUnauthorized = errors.New("面板登录已失效")
+
+// panelClient 机场面板客户端
+type panelClient interface {
+	// login 登录并返回可复用的凭证（Token 或 Cookie）
+	login(email, password string) (string, error)
+	// fetch 使用凭证获取账号信息，凭证失效时返回 errPanelUnauthorized
+	fetch(auth string) (*PanelInfo, error)
+}
+
+// panelSession 面板 HTTP 会话
+type panelSession struct {
+	baseURL   string
+	proxyOpts utils.ProxyOptions
+}
+
+// panelResponse 面板 HTTP 响应
+type panelResponse struct {
+	status   int
+	header   http.Header
+	body     []byte
+	finalURL *url.URL // 跟随跳转后的最终地址
+}
+
+// do 发送请求，form 不为空时以表单方式 POST
+func (s *panelSession) do(method, path string, form url.Values, headers map[string]string) (*panelResponse, error) {
+	target := strings.TrimRight(s.baseURL, "/") + path
+	newReq := func() (*http.Request, error) {
+		var body io.Reader
+		if form != nil {
+			body = strings.NewReader(form.Encode())
+		}
+		req, err := http.NewRequest(method, target, body)
+		if err != nil {
+			return nil, fmt.Errorf("创建请求失败: %v", err)
+		}
+		if form != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36")
+		req.Header.Set("Accept", "application/json, text/html;q=0.9, */*;q=0.8")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		return req, nil
+	}
+	resp, _, err := utils.DoWithProxyFallback(s.proxyOpts, newReq)
+	if err != nil {
+		return nil, fmt.Errorf("请求面板失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, fmt.Errorf("读取面板响应失败: %w", err)
+	}
+	return &panelResponse{status: resp.StatusCode, header: resp.Header, body: body, finalURL: resp.Request.URL}, nil
+}
+
+// panelInt 兼容面板返回的数字、数字字符串与 null
+type panelInt struct {
+	value int64
+	valid bool
+}
+
+func (p *panelInt) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(strings.TrimSpace(string(data)), `"`)
+	if s == "" || s == "null" {
+		*p = panelInt{}
+		return nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		*p = panelInt{}
+		return nil
+	}
+	*p = panelInt{value: int64(f), valid: true}
+	return nil
+}
+
+// newPanelClient 按面板类型创建客户端
+func newPanelClient(panelType, baseURL string, proxyOpts utils.ProxyOptions) (panelClient, error) {
+	u, err := url.Parse(strings.TrimSpace(baseURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("面板地址无效: %s", baseURL)
+	}
+	session := &panelSession{baseURL: strings.TrimSpace(baseURL), proxyOpts: proxyOpts}
+	switch panelType {
+	case models.AirportPanelV2Board, models.AirportPanelXboard:
+		return &v2boardPanel{session}, nil
+	case models.AirportPanelSSPanel:
+		return &sspanelPanel{session}, nil
+	}
+	return nil, fmt.Errorf("不支持的面板类型: %s", panelType)
+}
+
+// v2boardPanel V2Board / Xboard 面板
+type v2boardPanel struct {
+	*panelSession
+}
+
+// v2boardMessage 提取 V2Board 的错误信息
+func v2boardMessage(resp *panelResponse) string {
+	var body struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(resp.body, &body) == nil && body.Message != "" {
+		return body.Message
+	}
+	return fmt.Sprintf("HTTP %d", resp.status)
+}
+
+func (p *v2boardPanel) login(email, password string) (string, error) {
+	resp, err := p.do(http.MethodPost, "/api/v1/passport/auth/login", url.Values{"email": {email}, "password": {password}}, nil)
+	if err != nil {
+		return "", err
+	}
+	if resp.status != http.StatusOK {
+		return "", fmt.Errorf("面板登录失败: %s", v2boardMessage(resp))
+	}
+	var body struct {
+		Data struct {
+			AuthData string `json:"auth_data"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(resp.body, &body); err != nil || body.Data.AuthData == "" {
+		return "", fmt.Errorf("面板登录响应无效")
+	}
+	return body.Data.AuthData, nil
+}
+
+func (p *v2boardPanel) fetch(auth string) (*PanelInfo, error) {
+	resp, err := p.do(http.MethodGet, "/api/v1/user/getSubscribe", nil, map[string]string{"Authorization": auth})
+	if err != nil {
+		return nil, err
+	}
+	if resp.status == http.StatusUnauthorized || resp.status == http.StatusForbidden {
+		return nil, errPanelUnauthorized
+	}
+	if resp.status != http.StatusOK {
+		return nil, fmt.Errorf("获取订阅信息失败: %s", v2boardMessage(resp))
+	}
+	var body struct {
+		Data struct {
+			SubscribeURL   string   `json:"subscribe_url"`
+			Upload         panelInt `json:"u"`
+			Download       panelInt `json:"d"`
+			TransferEnable panelInt `json:"transfer_enable"`
+			ExpiredAt      panelInt `json:"expired_at"`
+			ResetDay       panelInt `json:"reset_day"`
+			Plan           *struct {
+				Name string `json:"name"`
+			} `json:"plan"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(resp.body, &body); err != nil {
+		return nil, fmt.Errorf("解析订阅信息失败: %v", err)
+	}
+	d := body.Data
+	info := &PanelInfo{
+		SubscribeURL: d.SubscribeURL,
+		HasUsage:     d.TransferEnable.valid,
+		Upload:       d.Upload.value,
+		Download:     d.Download.value,
+		Total:        d.TransferEnable.value,
+		Expire:       d.ExpiredAt.value,
+		ResetDay:     -1,
+	}
+	if d.ResetDay.valid {
+		info.ResetDay = int(d.ResetDay.value)
+	}
+	if d.Plan != nil {
+		info.Plan = d.Plan.Name
+	}
+	return info, nil
+}
+
+// sspanelPanel SSPanel / SSPanel-Uim 面板，登录凭证为 Cookie
+type sspanelPanel struct {
+	*panelSession
+}
+
+// sspanelLinkRe 匹配用户中心页面中的订阅链接（/link/TOKEN 或 /sub/TOKEN）
+var sspanelLinkRe = regexp.MustCompile(`https?://[^\s"'<>\\]+?/(?:link|sub)/[A-Za-z0-9]{6,}[^\s"'<>\\]*`)
+
+func (p *sspanelPanel) login(email, password string) (string, error) {
+	form := url.Values{"email": {email}, "passwd": {password}, "code": {""}, "remember_me": {"1"}}
+	resp, err := p.do(http.MethodPost, "/auth/login", form, nil)
+	if err != nil {
+		return "", err
+	}
+	var body struct {
+		Ret int    `json:"ret"`
+		Msg string `json:"msg"`
+	}
+	if err := json.Unmarshal(resp.body, &body); err != nil {
+		return "", fmt.Errorf("面板登录失败: HTTP %d", resp.status)
+	}
+	if body.Ret != 1 {
+		return "", fmt.Errorf("面板登录失败: %s", body.Msg)
+	}
+	var cookies []string
+	for _, c := range (&http.Response{Header: resp.header}).Cookies() {
+		cookies = append(cookies, c.Name+"="+c.Value)
+	}
+	if len(cookies) == 0 {
+		return "", fmt.Errorf("面板登录响应未返回 Cookie")
+	}
+	return strings.Join(cookies, "; "), nil
+}
+
+func (p *sspanelPanel) fetch(auth string) (*PanelInfo, error) {
+	headers := map[string]string{"Cookie": auth}
+	// 部分版本提供 JSON 接口，包含流量与到期信息
+	if info, err := p.fetchUserInfo(headers); err == nil || errors.Is(err, errPanelUnauthorized) {
+		return info, err
+	}
+	// 回退到解析用户中心页面中的订阅链接
+	resp, err := p.do(http.MethodGet, "/user", nil, headers)
+	if err != nil {
+		return nil, err
+	}
+	if p.isLoginPage(resp) {
+		return nil, errPanelUnauthorized
+	}
+	if resp.status != http.StatusOK {
+		return nil, fmt.Errorf("获取用户中心失败: HTTP %d", resp.status)
+	}
+	links := sspanelLinkRe.FindAllString(html.UnescapeString(string(resp.body)), -1)
+	if len(links) == 0 {
+		return nil, fmt.Errorf("用户中心页面中未找到订阅链接")
+	}
+	return &PanelInfo{SubscribeURL: links[0], Candidates: links, ResetDay: -1}, nil
+}
+
+// fetchUserInfo 调用 /getuserinfo 接口
+func (p *sspanelPanel) fetchUserInfo(headers map[string]string) (*PanelInfo, error) {
+	resp, err := p.do(http.MethodGet, "/getuserinfo", nil, headers)
+	if err != nil {
+		return nil, err
+	}
+	if p.isLoginPage(resp) {
+		return nil, errPanelUnauthorized
+	}
+	var body struct {
+		Ret  int `json:"ret"`
+		Info struct {
+			User struct {
+				Upload         panelInt `json:"u"`
+				Download       panelInt `json:"d"`
+				TransferEnable panelInt `json:"transfer_enable"`
+				Class          panelInt `json:"class"`
+				ClassExpire    string   `json:"class_expire"`
+			} `json:"user"`
+			SubURL   string `json:"subUrl"`
+			SubToken string `json:"ssrSubToken"`
+		} `json:"info"`
+	}
+	if resp.status != http.StatusOK || json.Unmarshal(resp.body, &body) != nil || body.Ret != 1 {
+		return nil, fmt.Errorf("面板不支持 /getuserinfo 接口")
+	}
+	u := body.Info.User
+	info := &PanelInfo{
+		HasUsage: u.TransferEnable.valid,
+		Upload:   u.Upload.value,
+		Download: u.Download.value,
+		Total:    u.TransferEnable.value,
+		ResetDay: -1,
+	}
+	if body.Info.SubURL != "" && body.Info.SubToken != "" {
+		info.SubscribeURL = body.Info.SubURL + body.Info.SubToken
+	}
+	if u.Class.valid {
+		info.Plan = fmt.Sprintf("Lv.%d", u.Class.value)
+	}
+	if t, err := time.ParseInLocation("2006-01-02 15:04:05", u.ClassExpire, time.Local); err == nil {
+		info.Expire = t.Unix()
+	}
+	return info, nil
+}
+
+// isLoginPage 未登录时 SSPanel 会跳转到登录页或返回 401
+func (p *sspanelPanel) isLoginPage(resp *panelResponse) bool {
+	if resp.status == http.StatusUnauthorized || resp.status == http.StatusForbidden {
+		return true
+	}
+	return resp.finalURL != nil && strings.HasPrefix(resp.finalURL.Path, "/auth/login")
+}
+
+// sspanelTokenRe 订阅链接中的 Token 段
+var sspanelTokenRe = regexp.MustCompile(`/(link|sub)/[A-Za-z0-9]+`)
+
+// pickPanelSubscribeURL 从候选链接中选出与当前地址格式一致的链接（仅 Token 不同）
+func pickPanelSubscribeURL(current string, info *PanelInfo) string {
+	shape := sspanelTokenRe.ReplaceAllString(current, "/$1/*")
+	for _, link := range info.Candidates {
+		if sspanelTokenRe.ReplaceAllString(link, "/$1/*") == shape {
+			return link
+		}
+	}
+	return info.SubscribeURL
+}
+
+// TestAirportPanel 使用给定账号登录面板并获取账号信息，不保存任何数据
+func TestAirportPanel(panelType, panelURL, email, password string, proxyOpts utils.ProxyOptions) (*PanelInfo, error) {
+	client, err := newPanelClient(panelType, panelURL, proxyOpts)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := client.login(email, password)
+	if err != nil {
+		return nil, err
+	}
+	info, err := client.fetch(auth)
+	if errors.Is(err, errPanelUnauthorized) {
+		return nil, fmt.Errorf("登录成功但获取账号信息时凭证无效")
+	}
+	return info, err
+}
+
+// FetchAirportPanelInfo 获取机场面板账号信息
+// 优先使用已保存的登录凭证，失效后重新登录并保存新凭证
+func FetchAirportPanelInfo(airport *models.Airport) (*PanelInfo, error) {
+	if !airport.HasPanel() {
+		return nil, fmt.Errorf("机场【%s】未配置面板账号", airport.Name)
+	}
+	client, err := newPanelClient(airport.PanelType, airport.PanelURL, airport.ProxyOptions(20*time.Second))
+	if err != nil {
+		return nil, err
+	}
+	if auth := airport.GetPanelAuth(); auth != "" {
+		info, err := client.fetch(auth)
+		if !errors.Is(err, errPanelUnauthorized) {
+			return info, err
+		}
+		utils.Info("机场【%s】面板登录已失效，重新登录", airport.Name)
+	}
+
+	password, err := airport.GetPanelPassword()
+	if err != nil {
+		return nil, fmt.Errorf("解密面板密码失败: %v", err)
+	}
+	auth, err := client.login(airport.PanelEmail, password)
+	if err != nil {
+		return nil, err
+	}
+	if err := airport.SavePanelAuth(auth); err != nil {
+		utils.Warn("保存机场【%s】面板登录凭证失败: %v", airport.Name, err)
+	}
+	info, err := client.fetch(auth)
+	if errors.Is(err, errPanelUnauthorized) {
+		return nil, fmt.Errorf("登录成功但获取账号信息时凭证无效")
+	}
+	return info, err
+}
+
+// SyncAirportPanel 登录机场面板，同步套餐与流量信息，并在订阅地址被重置时更新主地址
+// 开启获取用量信息时，面板返回的流量数据将保存为机场用量
+func SyncAirportPanel(airport *models.Airport) (*PanelInfo, error) {
+	info, err := FetchAirportPanelInfo(airport)
+	if err != nil {
+		if saveErr := airport.SavePanelState("", 0, err.Error()); saveErr != nil {
+			utils.Warn("保存机场【%s】面板状态失败: %v", airport.Name, saveErr)
+		}
+		return nil, err
+	}
+
+	if info.SubscribeURL != "" && !airport.PanelNoRotate {
+		subURL := pickPanelSubscribeURL(airport.URL, info)
+		if newURL, err := models.MergePanelSubscribeURL(airport.URL, subURL); err != nil {
+			utils.Warn("机场【%s】%v，保留当前主地址", airport.Name, err)
+		} else if newURL != airport.URL {
+			if err := airport.RotateURL(newURL); err != nil {
+				utils.Warn("更新机场【%s】订阅地址失败: %v", airport.Name, err)
+			} else {
+				utils.Info("机场【%s】面板订阅地址已变化，主地址已更新，原地址保留为备用地址", airport.Name)
+				sse.GetSSEBroker().BroadcastEvent("airport_url_rotated", sse.NotificationPayload{
+					Event:   "airport_url_rotated",
+					Title:   "机场订阅地址已更新",
+					Message: fmt.Sprintf("🔄机场【%s】面板中的订阅地址已重置，已自动更新主地址（原地址保留为备用地址）", airport.Name),
+					Data: map[string]interface{}{
+						"id":   airport.ID,
+						"name": airport.Name,
+					},
+				})
+			}
+		}
+	}
+
+	if airport.FetchUsageInfo && info.HasUsage {
+		usageInfo := &UsageInfo{Upload: info.Upload, Download: info.Download, Total: info.Total, Expire: info.Expire}
+		if err := SaveAirportUsageInfo(airport, usageInfo); err != nil {
+			utils.Warn("保存机场【%s】面板用量失败: %v", airport.Name, err)
+		}
+	}
+
+	if err := airport.SavePanelState(info.Plan, info.ResetDay, ""); err != nil {
+		utils.Warn("保存机场【%s】面板状态失败: %v", airport.Name, err)
+	}
+	return info, nil
+}
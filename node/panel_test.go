@@ -0,0 +1,269 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"sublink/database"
+	"sublink/models"
+	"sublink/utils"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// v2boardStub 模拟 V2Board 面板的登录与订阅接口
+type v2boardStub struct {
+	mu           sync.Mutex
+	subscribeURL string
+	logins       int
+}
+
+func (s *v2boardStub) setSubscribeURL(u string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribeURL = u
+}
+
+func (s *v2boardStub) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/passport/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.FormValue("email") != "user@example.com" || r.FormValue("password") != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"message":"邮箱或密码错误"}`)
+			return
+		}
+		s.mu.Lock()
+		s.logins++
+		s.mu.Unlock()
+		fmt.Fprint(w, `{"data":{"auth_data":"Bearer stub-token"}}`)
+	})
+	mux.HandleFunc("/api/v1/user/getSubscribe", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer stub-token" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message":"未登录或登陆已过期"}`)
+			return
+		}
+		s.mu.Lock()
+		subURL := s.subscribeURL
+		s.mu.Unlock()
+		data, _ := json.Marshal(map[string]interface{}{
+			"data": map[string]interface{}{
+				"subscribe_url":   subURL,
+				"u":               1024,
+				"d":               "2048",
+				"transfer_enable": 107374182400,
+				"expired_at":      nil,
+				"reset_day":       12,
+				"plan":            map[string]string{"name": "Pro"},
+			},
+		})
+		w.Write(data)
+	})
+	return mux
+}
+
+// sspanelStub 模拟 SSPanel 面板，userInfo 为 false 时不提供 /getuserinfo 接口
+func sspanelStub(userInfo bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			fmt.Fprint(w, "<html>login</html>")
+			return
+		}
+		if r.FormValue("email") != "user@example.com" || r.FormValue("passwd") != "secret" {
+			fmt.Fprint(w, `{"ret":0,"msg":"邮箱或者密码错误"}`)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "uid", Value: "7"})
+		http.SetCookie(w, &http.Cookie{Name: "key", Value: "stub"})
+		fmt.Fprint(w, `{"ret":1,"msg":"登录成功"}`)
+	})
+	loggedIn := func(r *http.Request) bool {
+		c, err := r.Cookie("key")
+		return err == nil && c.Value == "stub"
+	}
+	mux.HandleFunc("/getuserinfo", func(w http.ResponseWriter, r *http.Request) {
+		if !userInfo {
+			http.NotFound(w, r)
+			return
+		}
+		if !loggedIn(r) {
+			http.Redirect(w, r, "/auth/login", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, `{"ret":1,"info":{"user":{"u":10,"d":20,"transfer_enable":1000,"class":2,"class_expire":"2030-01-02 03:04:05"},"subUrl":"https://sub.example.com/link/","ssrSubToken":"Tok3nABC"}}`)
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		if !loggedIn(r) {
+			http.Redirect(w, r, "/auth/login", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, `<a data-clipboard-text="https://sub.example.com/link/AbCdEf123?sub=3">SS</a>
+<a data-clipboard-text="https://sub.example.com/link/AbCdEf123?clash=1">Clash</a>`)
+	})
+	return mux
+}
+
+// TestTestAirportPanelV2Board 测试 V2Board 面板登录与订阅信息解析
+func TestTestAirportPanelV2Board(t *testing.T) {
+	stub := &v2boardStub{subscribeURL: "https://sub.example.com/api/v1/client/subscribe?token=abc"}
+	server := httptest.NewServer(stub.handler())
+	defer server.Close()
+
+	for _, panelType := range []string{models.AirportPanelV2Board, models.AirportPanelXboard} {
+		info, err := TestAirportPanel(panelType, server.URL, "user@example.com", "secret", utils.ProxyOptions{})
+		if err != nil {
+			t.Fatalf("%s: 面板测试失败: %v", panelType, err)
+		}
+		if info.SubscribeURL != stub.subscribeURL {
+			t.Errorf("%s: SubscribeURL = %q, 期望 %q", panelType, info.SubscribeURL, stub.subscribeURL)
+		}
+		if !info.HasUsage || info.Upload != 1024 || info.Download != 2048 || info.Total != 107374182400 {
+			t.Errorf("%s: 流量解析错误: %+v", panelType, info)
+		}
+		if info.Expire != 0 || info.ResetDay != 12 || info.Plan != "Pro" {
+			t.Errorf("%s: 套餐解析错误: %+v", panelType, info)
+		}
+	}
+
+	if _, err := TestAirportPanel(models.AirportPanelV2Board, server.URL, "user@example.com", "wrong", utils.ProxyOptions{}); err == nil || !strings.Contains(err.Error(), "邮箱或密码错误") {
+		t.Errorf("错误密码应返回面板的错误信息, 实际: %v", err)
+	}
+}
+
+// TestTestAirportPanelSSPanel 测试 SSPanel 面板的 JSON 接口与用户中心页面回退
+func TestTestAirportPanelSSPanel(t *testing.T) {
+	tests := []struct {
+		name       string
+		userInfo   bool
+		wantURL    string
+		wantUsage  bool
+		candidates int
+	}{
+		{name: "getuserinfo", userInfo: true, wantURL: "https://sub.example.com/link/Tok3nABC", wantUsage: true},
+		{name: "用户中心页面", userInfo: false, wantURL: "https://sub.example.com/link/AbCdEf123?sub=3", candidates: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(sspanelStub(tt.userInfo))
+			defer server.Close()
+
+			info, err := TestAirportPanel(models.AirportPanelSSPanel, server.URL, "user@example.com", "secret", utils.ProxyOptions{})
+			if err != nil {
+				t.Fatalf("面板测试失败: %v", err)
+			}
+			if info.SubscribeURL != tt.wantURL {
+				t.Errorf("SubscribeURL = %q, 期望 %q", info.SubscribeURL, tt.wantURL)
+			}
+			if info.HasUsage != tt.wantUsage || len(info.Candidates) != tt.candidates {
+				t.Errorf("解析结果错误: %+v", info)
+			}
+			if tt.wantUsage && (info.Plan != "Lv.2" || info.Total != 1000 || info.Expire == 0) {
+				t.Errorf("套餐解析错误: %+v", info)
+			}
+		})
+	}
+
+	server := httptest.NewServer(sspanelStub(true))
+	defer server.Close()
+	if _, err := TestAirportPanel(models.AirportPanelSSPanel, server.URL, "user@example.com", "wrong", utils.ProxyOptions{}); err == nil {
+		t.Error("错误密码应登录失败")
+	}
+}
+
+// TestPickPanelSubscribeURL 测试按当前地址格式选择 SSPanel 订阅链接
+func TestPickPanelSubscribeURL(t *testing.T) {
+	info := &PanelInfo{
+		SubscribeURL: "https://sub.example.com/link/NewTok3n?sub=3",
+		Candidates:   []string{"https://sub.example.com/link/NewTok3n?sub=3", "https://sub.example.com/link/NewTok3n?clash=1"},
+	}
+	if got := pickPanelSubscribeURL("https://sub.example.com/link/OldTok3n?clash=1", info); got != info.Candidates[1] {
+		t.Errorf("应选择与当前地址格式一致的链接, 实际: %s", got)
+	}
+	if got := pickPanelSubscribeURL("https://other.example.com/api?token=x", info); got != info.SubscribeURL {
+		t.Errorf("没有格式一致的链接时应使用默认链接, 实际: %s", got)
+	}
+}
+
+// setupPanelTestDB 初始化内存数据库
+func setupPanelTestDB(t *testing.T) {
+	t.Helper()
+	t.Setenv("SUBLINK_API_ENCRYPTION_KEY", "panel-test-key")
+	db, err := gorm.Open(sqlite.Open("file:panel_test?mode=memory&cache=shared"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	database.DB = db
+	database.IsInitialized = false // 每个测试使用新的内存数据库，需要重新迁移
+	models.RunMigrations()
+	t.Cleanup(func() { sqlDB.Close() })
+}
+
+// TestSyncAirportPanelRotation 测试面板订阅地址轮换：原地址保留为备用地址，无效地址不替换
+func TestSyncAirportPanelRotation(t *testing.T) {
+	setupPanelTestDB(t)
+
+	stub := &v2boardStub{}
+	server := httptest.NewServer(stub.handler())
+	defer server.Close()
+
+	const oldURL = "https://sub.example.com/api/v1/client/subscribe?token=old&flag=clash"
+	airport := &models.Airport{
+		Name:       "面板测试机场",
+		URL:        oldURL,
+		MirrorURLs: "https://mirror.example.com/sub?token=old",
+		PanelType:  models.AirportPanelV2Board,
+		PanelURL:   server.URL,
+		PanelEmail: "user@example.com",
+	}
+	if err := airport.SetPanelPassword("secret"); err != nil {
+		t.Fatalf("加密面板密码失败: %v", err)
+	}
+	if err := airport.Add(); err != nil {
+		t.Fatalf("添加机场失败: %v", err)
+	}
+
+	// 订阅地址被重置：主地址更新并保留 flag 参数，原地址移到备用地址首位
+	stub.setSubscribeURL("https://sub.example.com/api/v1/client/subscribe?token=new")
+	if _, err := SyncAirportPanel(airport); err != nil {
+		t.Fatalf("同步面板失败: %v", err)
+	}
+	const newURL = "https://sub.example.com/api/v1/client/subscribe?flag=clash&token=new"
+	saved, err := models.GetAirportByID(airport.ID)
+	if err != nil {
+		t.Fatalf("获取机场失败: %v", err)
+	}
+	if saved.URL != newURL {
+		t.Errorf("主地址 = %q, 期望 %q", saved.URL, newURL)
+	}
+	if got := models.ParseAirportMirrorURLs(saved.MirrorURLs); len(got) != 2 || got[0] != oldURL || got[1] != "https://mirror.example.com/sub?token=old" {
+		t.Errorf("原主地址应保留为第一个备用地址, 实际: %v", got)
+	}
+	if stub.logins != 1 {
+		t.Errorf("首次同步应登录一次, 实际 %d 次", stub.logins)
+	}
+
+	// 面板返回无效地址：不替换主地址，也不改动备用地址
+	for _, invalid := range []string{"not a url", "javascript:alert(1)", "/api/v1/client/subscribe?token=x", "ftp://sub.example.com/x"} {
+		stub.setSubscribeURL(invalid)
+		if _, err := SyncAirportPanel(saved); err != nil {
+			t.Fatalf("同步面板失败: %v", err)
+		}
+		saved, _ = models.GetAirportByID(airport.ID)
+		if saved.URL != newURL || len(models.ParseAirportMirrorURLs(saved.MirrorURLs)) != 2 {
+			t.Errorf("无效地址 %q 不应替换主地址: URL=%q, MirrorURLs=%q", invalid, saved.URL, saved.MirrorURLs)
+		}
+	}
+	if stub.logins != 1 {
+		t.Errorf("已保存的登录凭证应被复用, 实际登录 %d 次", stub.logins)
+	}
+}
@@ -13,7 +13,25 @@ import (
 )
 
 func EncodeSurge(urls []string, config OutputConfig) (string, error) {
+	links := make([]Urls, 0, len(urls))
+	for _, u := range urls {
+		links = append(links, Urls{Url: u})
+	}
+	return EncodeSurgeUrls(links, config)
+}
+
+// EncodeSurgeUrls 生成 Surge 配置，节点设置了 DialerProxyName 时输出 underlying-proxy 实现链式代理
+// 前置代理必须是输出中的节点、Surge 模板中的代理组或自定义代理组，否则忽略并记录警告
+// config.CustomProxyGroups 中的自定义代理组会追加到 [Proxy Group] 末尾
+func EncodeSurgeUrls(urls []Urls, config OutputConfig) (string, error) {
 	var proxys, groups []string
+	// surgeDialer 待输出 underlying-proxy 的节点
+	type surgeDialer struct {
+		index  int    // 在 proxys 中的下标
+		name   string // 节点名称
+		dialer string // 前置代理名称
+	}
+	var dialers []surgeDialer
 
 	// 辅助函数：根据 HostMap 替换服务器地址
 	replaceHost := func(server string) string {
@@ -25,8 +43,10 @@ func EncodeSurge(urls []string, config OutputConfig) (string, error) {
 		return server
 	}
 
-	for _, link := range urls {
+	for _, u := range urls {
+		link := u.Url
 		Scheme := strings.Split(link, "://")[0]
+		proxyCount := len(proxys)
 		switch {
 		case Scheme == "ss":
 			ss, err := DecodeSSURL(link)
@@ -150,23 +170,117 @@ func EncodeSurge(urls []string, config OutputConfig) (string, error) {
 			groups = append(groups, tuic.Name)
 			proxys = append(proxys, tuicproxy)
 		}
+		// 链式代理：通过前置代理连接该节点，模板加载后再确认前置代理是否存在
+		if len(proxys) > proxyCount && u.DialerProxyName != "" {
+			dialers = append(dialers, surgeDialer{index: len(proxys) - 1, name: groups[len(groups)-1], dialer: u.DialerProxyName})
+		}
+	}
+
+	template, err := loadSurgeTemplate(config.Surge)
+	if err != nil {
+		return "", err
+	}
+	if len(dialers) > 0 {
+		known := surgeTemplateGroupNames(template)
+		for _, name := range groups {
+			known[name] = true
+		}
+		for _, cg := range config.CustomProxyGroups {
+			known[cg.Name] = true
+		}
+		for _, d := range dialers {
+			if !known[d.dialer] {
+				utils.Warn("Surge 输出中不存在前置代理 %s，节点 %s 不设置 underlying-proxy", d.dialer, d.name)
+				continue
+			}
+			proxys[d.index] = fmt.Sprintf("%s, underlying-proxy=%s", proxys[d.index], d.dialer)
+		}
 	}
-	return DecodeSurge(proxys, groups, config.Surge)
+	return renderSurge(proxys, groups, template, config.CustomProxyGroups)
 }
-func DecodeSurge(proxys, groups []string, file string) (string, error) {
+
+// SurgeTemplateGroupNames 读取 Surge 模板（远程地址或本地文件）中的代理组名称
+func SurgeTemplateGroupNames(file string) (map[string]bool, error) {
+	template, err := loadSurgeTemplate(file)
+	if err != nil {
+		return nil, err
+	}
+	return surgeTemplateGroupNames(template), nil
+}
+
+// surgeTemplateGroupNames 读取 Surge 模板 [Proxy Group] 中的代理组名称
+func surgeTemplateGroupNames(template []byte) map[string]bool {
+	names := make(map[string]bool)
+	currentSection := ""
+	for _, line := range strings.Split(string(template), "\n") {
+		trimmedLine := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmedLine, "[") && strings.HasSuffix(trimmedLine, "]") {
+			currentSection = trimmedLine
+			continue
+		}
+		if currentSection != "[Proxy Group]" || strings.HasPrefix(trimmedLine, "#") || strings.HasPrefix(trimmedLine, "//") {
+			continue
+		}
+		if name, _, ok := strings.Cut(trimmedLine, "="); ok && strings.TrimSpace(name) != "" {
+			names[strings.TrimSpace(name)] = true
+		}
+	}
+	return names
+}
+
+// surgeCustomGroupLine 将自定义代理组转换为 Surge [Proxy Group] 行
+func surgeCustomGroupLine(cg CustomProxyGroup) string {
+	groupType := cg.Type
+	if groupType == "" {
+		groupType = "select"
+	}
+	line := fmt.Sprintf("%s = %s, %s", cg.Name, groupType, strings.Join(cg.Proxies, ", "))
+	if groupType == "url-test" {
+		testURL := cg.URL
+		if testURL == "" {
+			testURL = "http://www.gstatic.com/generate_204"
+		}
+		interval := cg.Interval
+		if interval <= 0 {
+			interval = 300
+		}
+		line = fmt.Sprintf("%s, url=%s, interval=%d", line, testURL, interval)
+		if cg.Tolerance > 0 {
+			line = fmt.Sprintf("%s, tolerance=%d", line, cg.Tolerance)
+		}
+	}
+	return ensureProxyGroupHasProxies(line)
+}
+
+// DecodeSurge 将节点插入 Surge 模板
+// customGroups: 自定义代理组列表（可选，由链式代理规则生成），不追加全部节点
+func DecodeSurge(proxys, groups []string, file string, customGroups ...[]CustomProxyGroup) (string, error) {
+	surge, err := loadSurgeTemplate(file)
+	if err != nil {
+		return "", err
+	}
+	var custom []CustomProxyGroup
+	if len(customGroups) > 0 {
+		custom = customGroups[0]
+	}
+	return renderSurge(proxys, groups, surge, custom)
+}
+
+// loadSurgeTemplate 读取 Surge 模板（远程地址或本地文件）
+func loadSurgeTemplate(file string) ([]byte, error) {
 	var surge []byte
 	var err error
 	if strings.Contains(file, "://") {
 		resp, err := http.Get(file)
 		if err != nil {
 			log.Println("http.Get error", err)
-			return "", err
+			return nil, err
 		}
 		defer resp.Body.Close()
 		surge, err = io.ReadAll(resp.Body)
 		if err != nil {
 			log.Printf("error: %v", err)
-			return "", err
+			return nil, err
 		}
 	} else {
 		// 优先从缓存读取模板内容（本地文件使用缓存）
@@ -177,24 +291,50 @@ func DecodeSurge(proxys, groups []string, file string) (string, error) {
 			surge, err = os.ReadFile(file)
 			if err != nil {
 				log.Println(err)
-				return "", err
+				return nil, err
 			}
 			// 写入缓存
 			cache.SetTemplateContent(filename, string(surge))
 		}
 	}
+	return surge, nil
+}
 
+// renderSurge 将节点与自定义代理组插入 Surge 模板内容
+func renderSurge(proxys, groups []string, surge []byte, customGroups []CustomProxyGroup) (string, error) {
 	// 按行处理模板文件
 	lines := strings.Split(string(surge), "\n")
 	var result []string
 	currentSection := ""
 	grouplist := strings.Join(groups, ", ")
 
+	var customLines []string
+	for _, cg := range customGroups {
+		customLines = append(customLines, surgeCustomGroupLine(cg))
+	}
+	customInserted := len(customLines) == 0
+	// insertCustomGroups 在 [Proxy Group] 末尾（去掉尾部空行后）插入自定义代理组
+	insertCustomGroups := func() {
+		if customInserted {
+			return
+		}
+		customInserted = true
+		tail := len(result)
+		for tail > 0 && strings.TrimSpace(result[tail-1]) == "" {
+			tail--
+		}
+		trailing := append([]string{}, result[tail:]...)
+		result = append(append(result[:tail], customLines...), trailing...)
+	}
+
 	for _, line := range lines {
 		trimmedLine := strings.TrimSpace(line)
 
 		// 检测 section 标记
 		if strings.HasPrefix(trimmedLine, "[") && strings.HasSuffix(trimmedLine, "]") {
+			if currentSection == "[Proxy Group]" {
+				insertCustomGroups()
+			}
 			currentSection = trimmedLine
 			result = append(result, line)
 
@@ -225,6 +365,13 @@ func DecodeSurge(proxys, groups []string, file string) (string, error) {
 
 		result = append(result, line)
 	}
+	// 模板没有 [Proxy Group] 时新建该 section
+	if !customInserted {
+		if currentSection != "[Proxy Group]" {
+			result = append(result, "", "[Proxy Group]")
+		}
+		insertCustomGroups()
+	}
 
 	return strings.Join(result, "\n"), nil
 }
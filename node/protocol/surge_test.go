@@ -1,6 +1,9 @@
 package protocol
 
 import (
+	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -357,3 +360,63 @@ func TestAllProtocolsToProxy(t *testing.T) {
 		})
 	}
 }
+
+// TestEncodeSurgeUnderlyingProxy 测试前置代理只在节点或代理组存在时输出 underlying-proxy
+func TestEncodeSurgeUnderlyingProxy(t *testing.T) {
+	template := filepath.Join(t.TempDir(), "surge_chain_test.conf")
+	content := "[General]\nloglevel = notify\n\n[Proxy]\n\n[Proxy Group]\n🚀 节点选择 = select\n# 注释组 = select\n\n[Rule]\nFINAL,🚀 节点选择\n"
+	if err := os.WriteFile(template, []byte(content), 0644); err != nil {
+		t.Fatalf("写入模板失败: %v", err)
+	}
+
+	hy2 := func(name string) string {
+		return "hy2://password@" + name + ".example.com:443?sni=example.com#" + url.PathEscape(name)
+	}
+	urls := []Urls{
+		{Url: hy2("relay")},
+		{Url: hy2("via-node"), DialerProxyName: "relay"},
+		{Url: hy2("via-template"), DialerProxyName: "🚀 节点选择"},
+		{Url: hy2("via-custom"), DialerProxyName: "中转组"},
+		{Url: hy2("via-clash-only"), DialerProxyName: "Clash 模板专用组"},
+		{Url: hy2("via-comment"), DialerProxyName: "注释组"},
+	}
+	config := OutputConfig{
+		Surge:             template,
+		CustomProxyGroups: []CustomProxyGroup{{Name: "中转组", Type: "select", Proxies: []string{"relay"}}},
+	}
+	out, err := EncodeSurgeUrls(urls, config)
+	if err != nil {
+		t.Fatalf("生成 Surge 配置失败: %v", err)
+	}
+
+	proxyLine := func(name string) string {
+		for _, line := range strings.Split(out, "\n") {
+			if strings.HasPrefix(line, name+" = ") {
+				return line
+			}
+		}
+		t.Fatalf("输出中缺少节点 %s:\n%s", name, out)
+		return ""
+	}
+	tests := []struct {
+		name   string
+		dialer string // 为空表示不应输出 underlying-proxy
+	}{
+		{"relay", ""},
+		{"via-node", "relay"},
+		{"via-template", "🚀 节点选择"},
+		{"via-custom", "中转组"},
+		{"via-clash-only", ""},
+		{"via-comment", ""},
+	}
+	for _, tt := range tests {
+		line := proxyLine(tt.name)
+		if tt.dialer == "" {
+			if strings.Contains(line, "underlying-proxy") {
+				t.Errorf("%s 的前置代理不存在，不应输出 underlying-proxy: %s", tt.name, line)
+			}
+		} else if !strings.HasSuffix(line, ", underlying-proxy="+tt.dialer) {
+			t.Errorf("%s 应输出 underlying-proxy=%s, 实际: %s", tt.name, tt.dialer, line)
+		}
+	}
+}
@@ -1,26 +1,23 @@
 package node
 
 import (
-	"context"
-	"crypto/tls"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"math"
-	"net"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 	"sublink/models"
 	"sublink/node/protocol"
-	"sublink/services/mihomo"
 	"sublink/services/sse"
 	"sublink/utils"
 	"time"
 
-	"github.com/metacubex/mihomo/constant"
 	"gopkg.in/yaml.v3"
 )
 
@@ -140,99 +137,72 @@ func isTLSError(err error) bool {
 // proxyLink: 代理链接 (可选)
 // userAgent: 请求的 User-Agent (可选，默认 Clash)
 func LoadClashConfigFromURL(id int, urlStr string, subName string, downloadWithProxy bool, proxyLink string, userAgent string) (*UsageInfo, error) {
-	return LoadClashConfigFromURLWithReporter(id, urlStr, subName, downloadWithProxy, proxyLink, userAgent, nil, false, true)
+	return LoadClashConfigFromURLWithReporter(id, urlStr, subName, downloadWithProxy, proxyLink, userAgent, nil, false, true, models.TaskTriggerManual)
 }
 
 // LoadClashConfigFromURLWithReporter 从指定 URL 加载 Clash 配置（带任务报告器）
 // reporter: 任务进度报告器，用于TaskManager集成
 // fetchUsageInfo: 是否获取用量信息
 // skipTLSVerify: 是否跳过TLS证书验证
-func LoadClashConfigFromURLWithReporter(id int, urlStr string, subName string, downloadWithProxy bool, proxyLink string, userAgent string, reporter TaskReporter, fetchUsageInfo bool, skipTLSVerify bool) (*UsageInfo, error) {
-	// 创建 HTTP 客户端，配置 TLS
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: skipTLSVerify},
-		},
-	}
-
-	if downloadWithProxy {
-		var proxyNodeLink string
-
-		if proxyLink != "" {
-			// 使用指定的代理链接
-			proxyNodeLink = proxyLink
-			utils.Info("使用指定代理下载订阅")
-		} else {
-			// 如果没有指定代理，尝试自动选择最佳代理
-			// 获取最近测速成功的节点（延迟最低且速度大于0）
-			if bestNode, err := models.GetBestProxyNode(); err == nil && bestNode != nil {
-				utils.Info("自动选择最佳代理节点: %s 节点延迟：%dms  节点速度：%2fMB/s", bestNode.Name, bestNode.DelayTime, bestNode.Speed)
-				proxyNodeLink = bestNode.Link
-			}
-		}
-
-		if proxyNodeLink != "" {
-			// 使用 mihomo 内核创建代理适配器
-			proxyAdapter, err := mihomo.GetMihomoAdapter(proxyNodeLink)
-			if err != nil {
-				utils.Error("创建 mihomo 代理适配器失败: %v，将直接下载", err)
-			} else {
-				utils.Info("使用 mihomo 内核代理下载订阅")
-				// 创建自定义 Transport，使用 mihomo adapter 进行代理连接
-				client.Transport = &http.Transport{
-					DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-						// 解析地址获取主机和端口
-						host, portStr, splitErr := net.SplitHostPort(addr)
-						if splitErr != nil {
-							return nil, fmt.Errorf("split host port error: %v", splitErr)
-						}
-
-						portInt, atoiErr := strconv.Atoi(portStr)
-						if atoiErr != nil {
-							return nil, fmt.Errorf("invalid port: %v", atoiErr)
-						}
-
-						// 验证端口范围
-						if portInt < 0 || portInt > 65535 {
-							return nil, fmt.Errorf("port out of range: %d", portInt)
-						}
-
-						// 创建 mihomo metadata
-						metadata := &constant.Metadata{
-							Host:    host,
-							DstPort: uint16(portInt),
-							Type:    constant.HTTP,
-						}
-
-						// 使用 mihomo adapter 建立连接
-						return proxyAdapter.DialContext(ctx, metadata)
-					},
-					TLSClientConfig: &tls.Config{InsecureSkipVerify: skipTLSVerify},
-				}
-			}
-		} else {
-			utils.Warn("未找到可用代理，将直接下载")
-		}
+// trigger: 触发类型，传递给机场同步钩子
+func LoadClashConfigFromURLWithReporter(id int, urlStr string, subName string, downloadWithProxy bool, proxyLink string, userAgent string, reporter TaskReporter, fetchUsageInfo bool, skipTLSVerify bool, trigger models.TaskTrigger) (*UsageInfo, error) {
+	proxyOpts := utils.ProxyOptions{
+		UseProxy:      downloadWithProxy,
+		ProxyLink:     proxyLink,
+		AllowDirect:   true,
+		Timeout:       30 * time.Second,
+		SkipTLSVerify: skipTLSVerify,
 	}
-
-	// 创建请求并设置 User-Agent
-	req, err := http.NewRequest("GET", urlStr, nil)
+	result, err := fetchSubscriptionProxies(urlStr, subName, proxyOpts, userAgent, fetchUsageInfo, nil)
 	if err != nil {
-		utils.Error("URL %s，创建请求失败:  %v", urlStr, err)
+		notifySubFetchFailed(id, subName, err, skipTLSVerify)
 		return nil, err
 	}
 
-	// 设置 User-Agent
-	if userAgent != "" {
-		req.Header.Set("User-Agent", userAgent)
+	err = scheduleClashToNodeLinks(id, result.proxys, subName, reporter, result.usageInfo, trigger)
+	return result.usageInfo, err
+}
+
+// 订阅拉取失败的阶段
+const (
+	subFetchStageRequest = "request" // 请求失败
+	subFetchStageRead    = "read"    // 读取响应失败
+	subFetchStageParse   = "parse"   // 解析失败或未找到节点
+)
+
+// subFetchError 订阅拉取失败，记录失败阶段以生成对应的通知
+type subFetchError struct {
+	stage string
+	err   error
+}
+
+func (e *subFetchError) Error() string { return e.err.Error() }
+
+func (e *subFetchError) Unwrap() error { return e.err }
+
+// notifySubFetchFailed 发送订阅拉取失败通知
+func notifySubFetchFailed(id int, subName string, err error, skipTLSVerify bool) {
+	stage := subFetchStageRequest
+	if fe, ok := err.(*subFetchError); ok {
+		stage = fe.stage
 	}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		utils.Error("URL %s，获取Clash配置失败:  %v", urlStr, err)
+	title := "订阅更新失败"
+	var message string
+	data := map[string]interface{}{
+		"id":     id,
+		"name":   subName,
+		"status": "failed",
+		"error":  err.Error(),
+	}
+	switch stage {
+	case subFetchStageRead:
+		message = fmt.Sprintf("❌订阅【%s】读取响应失败: %v", subName, err)
+	case subFetchStageParse:
+		message = fmt.Sprintf("❌订阅【%s】解析失败或未找到节点", subName)
+		data["error"] = "解析失败或未找到节点"
+	default:
 		// 检测是否为 TLS 证书相关错误，给出更明确的提示
-		var title, message string
 		if isTLSError(err) {
 			title = "订阅更新失败 - TLS证书验证错误"
 			if skipTLSVerify {
@@ -241,31 +211,94 @@ func LoadClashConfigFromURLWithReporter(id int, urlStr string, subName string, d
 				message = fmt.Sprintf("❌订阅【%s】证书验证失败: %v\n\n💡 提示：请在机场设置中开启\"忽略证书验证\"选项后重试", subName, err)
 			}
 		} else {
-			title = "订阅更新失败"
 			message = fmt.Sprintf("❌订阅【%s】请求失败: %v", subName, err)
 		}
-		// 发送请求失败通知
-		sse.GetSSEBroker().BroadcastEvent("sub_update", sse.NotificationPayload{
-			Event:   "sub_update",
-			Title:   title,
-			Message: message,
-			Data: map[string]interface{}{
-				"id":       id,
-				"name":     subName,
-				"status":   "failed",
-				"error":    err.Error(),
-				"tlsError": isTLSError(err),
-			},
-		})
-		return nil, err
+		data["tlsError"] = isTLSError(err)
+	}
+
+	sse.GetSSEBroker().BroadcastEvent("sub_update", sse.NotificationPayload{
+		Event:   "sub_update",
+		Title:   title,
+		Message: message,
+		Data:    data,
+	})
+}
+
+// subFetchCondition 条件请求参数，来自上次成功处理的拉取结果
+type subFetchCondition struct {
+	etag         string
+	lastModified string
+	contentHash  string // 内容哈希一致时跳过解析
+}
+
+// subFetchResult 单个地址的拉取结果
+type subFetchResult struct {
+	proxys       []protocol.Proxy
+	usageInfo    *UsageInfo
+	etag         string
+	lastModified string
+	contentHash  string
+	unchanged    bool // 服务端返回 304 或内容哈希未变化，此时 proxys 为空
+
+	// 诊断信息，失败时同样返回
+	statusCode int    // HTTP 状态码，请求未完成时为 0
+	bytes      int64  // 响应大小
+	proxy      string // 使用的代理，空表示直连
+}
+
+// fetchSubscriptionProxies 从指定 URL 下载订阅并解析出代理节点
+// 支持 YAML 格式、Base64 编码和明文链接列表，失败时返回 *subFetchError，不发送通知
+// cond 不为空时发送条件请求，内容未变化则返回 unchanged 结果
+// 返回的结果总是非空，失败时也携带状态码、代理等诊断信息
+func fetchSubscriptionProxies(urlStr string, subName string, proxyOpts utils.ProxyOptions, userAgent string, fetchUsageInfo bool, cond *subFetchCondition) (*subFetchResult, error) {
+	result := &subFetchResult{}
+
+	// 创建请求并设置 User-Agent、条件请求头
+	newReq := func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", urlStr, nil)
+		if err != nil {
+			return nil, err
+		}
+		if userAgent != "" {
+			req.Header.Set("User-Agent", userAgent)
+		}
+		if cond != nil {
+			if cond.etag != "" {
+				req.Header.Set("If-None-Match", cond.etag)
+			}
+			if cond.lastModified != "" {
+				req.Header.Set("If-Modified-Since", cond.lastModified)
+			}
+		}
+		return req, nil
+	}
+
+	// 使用代理时依次尝试候选代理，未允许直连时不会回退到直连
+	resp, proxyName, err := utils.DoWithProxyFallback(proxyOpts, newReq)
+	result.proxy = proxyName
+	if err != nil {
+		utils.Error("URL %s，获取Clash配置失败:  %v", urlStr, err)
+		return result, &subFetchError{stage: subFetchStageRequest, err: err}
 	}
 	defer resp.Body.Close()
 
+	result.statusCode = resp.StatusCode
+	if resp.StatusCode >= http.StatusBadRequest {
+		utils.Error("URL %s，获取Clash配置失败: HTTP %d", urlStr, resp.StatusCode)
+		return result, &subFetchError{stage: subFetchStageRequest, err: fmt.Errorf("HTTP %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))}
+	}
+	result.etag = resp.Header.Get("ETag")
+	result.lastModified = resp.Header.Get("Last-Modified")
+	notModified := cond != nil && resp.StatusCode == http.StatusNotModified
+
 	// 解析用量信息（仅当开启获取用量信息时）
 	var usageInfo *UsageInfo
 	if fetchUsageInfo {
 		subUserInfo := resp.Header.Get("subscription-userinfo")
-		if subUserInfo != "" {
+		if subUserInfo == "" && notModified {
+			// 304 响应未携带用量信息时保留原有数据
+			utils.Debug("订阅【%s】304 响应未返回用量信息 header", subName)
+		} else if subUserInfo != "" {
 			usageInfo = ParseSubscriptionUserInfo(subUserInfo)
 			if usageInfo != nil {
 				utils.Info("订阅【%s】获取用量信息成功: 上传=%d, 下载=%d, 总量=%d, 过期=%d",
@@ -282,22 +315,29 @@ func LoadClashConfigFromURLWithReporter(id int, urlStr string, subName string, d
 		}
 	}
 
+	result.usageInfo = usageInfo
+
+	if notModified {
+		utils.Info("订阅【%s】服务端返回 304，内容未变化", subName)
+		result.etag = cond.etag
+		result.lastModified = cond.lastModified
+		result.contentHash = cond.contentHash
+		result.unchanged = true
+		return result, nil
+	}
+
 	data, err := io.ReadAll(resp.Body)
 	if err != nil {
 		utils.Error("URL %s，读取Clash配置失败:  %v", urlStr, err)
-		// 发送读取失败通知
-		sse.GetSSEBroker().BroadcastEvent("sub_update", sse.NotificationPayload{
-			Event:   "sub_update",
-			Title:   "订阅更新失败",
-			Message: fmt.Sprintf("❌订阅【%s】读取响应失败: %v", subName, err),
-			Data: map[string]interface{}{
-				"id":     id,
-				"name":   subName,
-				"status": "failed",
-				"error":  err.Error(),
-			},
-		})
-		return nil, err
+		return result, &subFetchError{stage: subFetchStageRead, err: err}
+	}
+	result.bytes = int64(len(data))
+	sum := sha256.Sum256(data)
+	result.contentHash = hex.EncodeToString(sum[:])
+	if cond != nil && cond.contentHash != "" && cond.contentHash == result.contentHash {
+		utils.Info("订阅【%s】内容哈希未变化", subName)
+		result.unchanged = true
+		return result, nil
 	}
 	var config ClashConfig
 	// 尝试解析 YAML
@@ -345,23 +385,11 @@ func LoadClashConfigFromURLWithReporter(id int, urlStr string, subName string, d
 
 	if len(config.Proxies) == 0 {
 		utils.Error("URL %s，解析失败或未找到节点 (YAML error: %v)", urlStr, errYaml)
-		// 发送解析失败通知
-		sse.GetSSEBroker().BroadcastEvent("sub_update", sse.NotificationPayload{
-			Event:   "sub_update",
-			Title:   "订阅更新失败",
-			Message: fmt.Sprintf("❌订阅【%s】解析失败或未找到节点", subName),
-			Data: map[string]interface{}{
-				"id":     id,
-				"name":   subName,
-				"status": "failed",
-				"error":  "解析失败或未找到节点",
-			},
-		})
-		return nil, fmt.Errorf("解析失败 or 未找到节点")
+		return result, &subFetchError{stage: subFetchStageParse, err: fmt.Errorf("解析失败 or 未找到节点")}
 	}
 
-	err = scheduleClashToNodeLinks(id, config.Proxies, subName, reporter, usageInfo)
-	return usageInfo, err
+	result.proxys = config.Proxies
+	return result, nil
 }
 
 // scheduleClashToNodeLinks 将 Clash 代理配置转换为节点链接并保存到数据库
@@ -369,7 +397,8 @@ func LoadClashConfigFromURLWithReporter(id int, urlStr string, subName string, d
 // proxys: 代理节点列表
 // subName: 订阅名称
 // usageInfo: 订阅用量信息 (可选)
-func scheduleClashToNodeLinks(id int, proxys []protocol.Proxy, subName string, reporter TaskReporter, usageInfo *UsageInfo) error {
+// trigger: 触发类型
+func scheduleClashToNodeLinks(id int, proxys []protocol.Proxy, subName string, reporter TaskReporter, usageInfo *UsageInfo, trigger models.TaskTrigger) error {
 	if reporter == nil {
 		reporter = &NoOpTaskReporter{}
 	}
@@ -393,6 +422,18 @@ func scheduleClashToNodeLinks(id int, proxys []protocol.Proxy, subName string, r
 		utils.Error("获取机场 %s 的Group失败:  %v", subName, err)
 	}
 
+	// 应用机场导入规则（过滤、名称预处理、转换脚本）
+	proxys, err = applyAirportImportRules(airport, proxys)
+	if err != nil {
+		utils.Error("订阅【%s】%v", subName, err)
+		return err
+	}
+
+	// 执行机场同步钩子脚本（可转换或剔除节点）
+	if airport != nil && AirportSyncHookFunc != nil {
+		proxys = AirportSyncHookFunc(airport, proxys, trigger)
+	}
+
 	// 1. 获取该订阅当前在数据库中的所有节点
 	existingNodes, err := models.ListBySourceID(id)
 	if err != nil {
@@ -818,16 +859,62 @@ func scheduleClashToNodeLinks(id int, proxys []protocol.Proxy, subName string, r
 		})
 	}
 
-	// 3. 收集需要删除的节点ID（本次订阅没有获取到但数据库中存在的节点）
-	nodeIDsToDelete := make([]int, 0)
+	// 3. 收集本次订阅没有获取到但数据库中存在的节点
+	missingNodes := make([]models.Node, 0)
+	reappearedIDs := make([]int, 0) // 之前缺失、本次重新出现的节点
 	for link, existingNode := range existingNodeMap {
 		if !currentLinks[link] {
-			// 该节点不在本次订阅中，需要删除
-			nodeIDsToDelete = append(nodeIDsToDelete, existingNode.ID)
+			missingNodes = append(missingNodes, existingNode)
+		} else if existingNode.SyncMissCount > 0 {
+			reappearedIDs = append(reappearedIDs, existingNode.ID)
 		}
 	}
 
+	// 身份匹配：链接变化但身份相同的节点原地更新，保留名称、标签、订阅关联和检测记录
+	matchedNodes, missingNodes, nodesToAdd := airport.MatchNodeIdentity(missingNodes, nodesToAdd)
+	addSuccessCount = len(nodesToAdd)
+
+	// 安全同步保护：同步结果异常（节点过少、删除比例过高）时中止，不写入任何变更
+	if reason := airport.CheckSyncGuard(len(existingNodes), len(currentLinks), len(missingNodes)); reason != "" {
+		utils.Warn("🛡️订阅【%s】触发安全同步保护，已中止同步: %s", subName, reason)
+		sse.GetSSEBroker().BroadcastEvent("sub_update", sse.NotificationPayload{
+			Event:   "sub_update",
+			Title:   "订阅同步已中止",
+			Message: fmt.Sprintf("🛡️订阅【%s】触发安全同步保护，本次同步未生效: %s", subName, reason),
+			Data: map[string]interface{}{
+				"id":       id,
+				"name":     subName,
+				"status":   "guarded",
+				"error":    reason,
+				"fetched":  len(currentLinks),
+				"existing": len(existingNodes),
+				"missing":  len(missingNodes),
+			},
+		})
+		return fmt.Errorf("触发安全同步保护: %s", reason)
+	}
+
+	// 删除宽限：连续缺失次数未超过设定值的节点暂不删除
+	nodeIDsToDelete, heldIDs := airport.SplitHeldDeletions(missingNodes)
+	if len(heldIDs) > 0 {
+		if err := models.IncrementNodeSyncMissCount(heldIDs); err != nil {
+			utils.Error("更新节点缺失次数失败：%v", err)
+		} else {
+			utils.Info("⏳订阅【%s】有 %d 个缺失节点处于删除宽限期，暂不删除", subName, len(heldIDs))
+		}
+	}
+	if err := models.ResetNodeSyncMissCount(reappearedIDs); err != nil {
+		utils.Error("重置节点缺失次数失败：%v", err)
+	}
+
 	// 4. 批量写入数据库（一次性操作，减少数据库I/O）
+	// 原地更新身份匹配的节点
+	updateCount := 0
+	if len(matchedNodes) > 0 {
+		updateCount = models.UpdateAirportNodesInPlace(matchedNodes)
+		utils.Info("🔄订阅【%s】原地更新 %d 个链接变化的节点", subName, updateCount)
+	}
+
 	// 批量添加新节点
 	if len(nodesToAdd) > 0 {
 		if err := models.BatchAddNodes(nodesToAdd); err != nil {
@@ -836,11 +923,13 @@ func scheduleClashToNodeLinks(id int, proxys []protocol.Proxy, subName string, r
 			addSuccessCount = 0
 		} else {
 			utils.Info("✅批量添加 %d 个节点成功", len(nodesToAdd))
+			applyNodeCreatedTagRules(nodesToAdd)
 		}
 	}
 
-	// 批量删除失效节点
+	// 批量删除失效节点（删除前记录其订阅关联，用于变更快照）
 	deleteCount := 0
+	removedMemberships := models.GetNodeSubscriptionMemberships(nodeIDsToDelete)
 	if len(nodeIDsToDelete) > 0 {
 		if err := models.BatchDel(nodeIDsToDelete); err != nil {
 			utils.Error("❌批量删除节点失败：%v", err)
@@ -850,14 +939,25 @@ func scheduleClashToNodeLinks(id int, proxys []protocol.Proxy, subName string, r
 		}
 	}
 
-	utils.Info("✅订阅【%s】节点同步完成，总节点【%d】个，成功处理【%d】个，新增节点【%d】个，已存在节点【%d】个，删除失效【%d】个", subName, len(proxys), addSuccessCount+skipCount, addSuccessCount, skipCount, deleteCount)
+	utils.Info("✅订阅【%s】节点同步完成，总节点【%d】个，成功处理【%d】个，新增节点【%d】个，原地更新【%d】个，已存在节点【%d】个，删除失效【%d】个", subName, len(proxys), addSuccessCount+updateCount+skipCount, addSuccessCount, updateCount, skipCount, deleteCount)
+
+	// 记录本次同步的变更（含变更后快照，用于回滚）
+	changesetID := 0
+	if currentNodes, err := models.ListBySourceID(id); err == nil {
+		changeset, err := models.RecordAirportChangeset(id, models.AirportChangeSync, existingNodes, currentNodes, removedMemberships, "")
+		if err != nil {
+			utils.Error("记录订阅【%s】变更失败: %v", subName, err)
+		} else if changeset != nil {
+			changesetID = changeset.ID
+		}
+	}
 	// 重新查找机场以获取最新信息并更新成功次数
 	airport, err = models.GetAirportByID(id)
 	if err != nil {
 		utils.Error("获取机场 %s 失败:  %v", subName, err)
 		return err
 	}
-	airport.SuccessCount = addSuccessCount + skipCount
+	airport.SuccessCount = addSuccessCount + updateCount + skipCount
 	// 当前时间
 	now := time.Now()
 	airport.LastRunTime = &now
@@ -866,10 +966,12 @@ func scheduleClashToNodeLinks(id int, proxys []protocol.Proxy, subName string, r
 		return err1
 	}
 	// 通过 reporter 报告任务完成
-	reporter.ReportComplete(fmt.Sprintf("订阅更新完成 (新增: %d, 已存在: %d, 删除: %d)", addSuccessCount, skipCount, deleteCount), map[string]interface{}{
+	reporter.ReportComplete(fmt.Sprintf("订阅更新完成 (新增: %d, 更新: %d, 已存在: %d, 删除: %d)", addSuccessCount, updateCount, skipCount, deleteCount), map[string]interface{}{
 		"added":   addSuccessCount,
+		"updated": updateCount,
 		"skipped": skipCount,
 		"deleted": deleteCount,
+		"held":    len(heldIDs),
 	})
 
 	// 触发webhook的完成事件
@@ -897,17 +999,20 @@ func scheduleClashToNodeLinks(id int, proxys []protocol.Proxy, subName string, r
 		"id":       id,
 		"name":     subName,
 		"status":   "success",
-		"success":  addSuccessCount + skipCount,
+		"success":  addSuccessCount + updateCount + skipCount,
 		"duration": duration.Milliseconds(),
 	}
 	if len(usageData) > 0 {
 		nData["usage"] = usageData
 	}
+	if changesetID > 0 {
+		nData["changesetId"] = changesetID
+	}
 
 	sse.GetSSEBroker().BroadcastEvent("sub_update", sse.NotificationPayload{
 		Event:   "sub_update",
 		Title:   "订阅更新完成",
-		Message: fmt.Sprintf("✅订阅【%s】节点同步完成，耗时 %s，总节点【%d】个，成功处理【%d】个，新增节点【%d】个，已存在节点【%d】个，删除失效【%d】个%s", subName, durationStr, len(proxys), addSuccessCount+skipCount, addSuccessCount, skipCount, deleteCount, usageText),
+		Message: fmt.Sprintf("✅订阅【%s】节点同步完成，耗时 %s，总节点【%d】个，成功处理【%d】个，新增节点【%d】个，原地更新【%d】个，已存在节点【%d】个，删除失效【%d】个%s", subName, durationStr, len(proxys), addSuccessCount+updateCount+skipCount, addSuccessCount, updateCount, skipCount, deleteCount, usageText),
 		Data:    nData,
 	})
 	return nil
@@ -924,3 +1029,19 @@ func formatDurationSub(d time.Duration) string {
 	}
 	return fmt.Sprintf("%.0f时%.0f分", d.Hours(), math.Mod(d.Minutes(), 60))
 }
+
+// applyNodeCreatedTagRules 对新入库的节点应用 node_created 标签规则（跳过因已存在而未插入的节点）
+func applyNodeCreatedTagRules(nodes []models.Node) {
+	if ApplyTagRulesFunc == nil {
+		return
+	}
+	created := make([]models.Node, 0, len(nodes))
+	for _, n := range nodes {
+		if n.ID > 0 {
+			created = append(created, n)
+		}
+	}
+	if len(created) > 0 {
+		ApplyTagRulesFunc(created, models.TagTriggerNodeCreated)
+	}
+}
@@ -1,19 +1,13 @@
 package node
 
 import (
-	"context"
-	"crypto/tls"
 	"fmt"
-	"net"
 	"net/http"
-	"strconv"
 	"sublink/models"
-	"sublink/services/mihomo"
+	"sublink/services/sse"
 	"sublink/utils"
 	"sync"
 	"time"
-
-	"github.com/metacubex/mihomo/constant"
 )
 
 // FetchAirportUsageInfo 独立获取单个机场的用量信息
@@ -27,62 +21,8 @@ func FetchAirportUsageInfo(airport *models.Airport) (*UsageInfo, error) {
 		return nil, fmt.Errorf("机场未开启用量信息获取")
 	}
 
-	client := &http.Client{
-		Timeout: 10 * time.Second, // 用量获取使用较短超时
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: airport.SkipTLSVerify},
-		},
-	}
-
-	// 配置代理（如果启用）
-	if airport.DownloadWithProxy {
-		var proxyNodeLink string
-
-		if airport.ProxyLink != "" {
-			proxyNodeLink = airport.ProxyLink
-			utils.Info("用量获取使用指定代理")
-		} else {
-			// 自动选择最佳代理
-			if bestNode, err := models.GetBestProxyNode(); err == nil && bestNode != nil {
-				utils.Info("用量获取自动选择代理节点: %s", bestNode.Name)
-				proxyNodeLink = bestNode.Link
-			}
-		}
-
-		if proxyNodeLink != "" {
-			proxyAdapter, err := mihomo.GetMihomoAdapter(proxyNodeLink)
-			if err != nil {
-				utils.Error("创建代理适配器失败: %v，将直接请求", err)
-			} else {
-				client.Transport = &http.Transport{
-					DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-						host, portStr, splitErr := net.SplitHostPort(addr)
-						if splitErr != nil {
-							return nil, fmt.Errorf("split host port error: %v", splitErr)
-						}
-
-						portInt, atoiErr := strconv.Atoi(portStr)
-						if atoiErr != nil {
-							return nil, fmt.Errorf("invalid port: %v", atoiErr)
-						}
-
-						if portInt < 0 || portInt > 65535 {
-							return nil, fmt.Errorf("port out of range: %d", portInt)
-						}
-
-						metadata := &constant.Metadata{
-							Host:    host,
-							DstPort: uint16(portInt),
-							Type:    constant.HTTP,
-						}
-
-						return proxyAdapter.DialContext(ctx, metadata)
-					},
-					TLSClientConfig: &tls.Config{InsecureSkipVerify: airport.SkipTLSVerify},
-				}
-			}
-		}
-	}
+	// 与订阅拉取使用相同的代理选项，用量获取使用较短超时
+	proxyOpts := airport.ProxyOptions(10 * time.Second)
 
 	// 设置通用 User-Agent
 	userAgent := "clash.meta"
@@ -90,15 +30,25 @@ func FetchAirportUsageInfo(airport *models.Airport) (*UsageInfo, error) {
 		userAgent = airport.UserAgent
 	}
 
-	// 优先使用 HEAD 请求，减少数据传输
-	var resp *http.Response
-	headReq, err := http.NewRequest("HEAD", airport.URL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %v", err)
+	// 多地址时使用最近一次拉取成功的地址
+	urlStr := airport.URL
+	if urls := airport.OrderedURLs(); len(urls) > 0 {
+		urlStr = urls[0]
 	}
-	headReq.Header.Set("User-Agent", userAgent)
 
-	resp, err = client.Do(headReq)
+	newReq := func(method string) func() (*http.Request, error) {
+		return func() (*http.Request, error) {
+			req, err := http.NewRequest(method, urlStr, nil)
+			if err != nil {
+				return nil, fmt.Errorf("创建请求失败: %v", err)
+			}
+			req.Header.Set("User-Agent", userAgent)
+			return req, nil
+		}
+	}
+
+	// 优先使用 HEAD 请求，减少数据传输
+	resp, _, err := utils.DoWithProxyFallback(proxyOpts, newReq("HEAD"))
 	if err != nil || resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		// HEAD 请求失败或返回非 2xx，回退到 GET 请求
 		if resp != nil {
@@ -110,13 +60,7 @@ func FetchAirportUsageInfo(airport *models.Airport) (*UsageInfo, error) {
 			utils.Debug("机场【%s】HEAD 请求返回状态码 %d，尝试 GET 请求", airport.Name, resp.StatusCode)
 		}
 
-		getReq, err := http.NewRequest("GET", airport.URL, nil)
-		if err != nil {
-			return nil, fmt.Errorf("创建请求失败: %v", err)
-		}
-		getReq.Header.Set("User-Agent", userAgent)
-
-		resp, err = client.Do(getReq)
+		resp, _, err = utils.DoWithProxyFallback(proxyOpts, newReq("GET"))
 		if err != nil {
 			return nil, fmt.Errorf("请求机场失败: %v", err)
 		}
@@ -158,6 +102,18 @@ func UpdateAirportUsageInfo(airportID int) (*UsageInfo, error) {
 		return nil, fmt.Errorf("机场【%s】未开启用量信息获取", airport.Name)
 	}
 
+	// 配置了面板账号时优先使用面板数据，失败后回退到 subscription-userinfo
+	if airport.HasPanel() {
+		info, err := SyncAirportPanel(airport)
+		if err == nil && info.HasUsage {
+			utils.Info("机场【%s】已从面板获取用量信息", airport.Name)
+			return &UsageInfo{Upload: info.Upload, Download: info.Download, Total: info.Total, Expire: info.Expire}, nil
+		}
+		if err != nil {
+			utils.Warn("机场【%s】面板同步失败，改用订阅地址获取用量: %v", airport.Name, err)
+		}
+	}
+
 	usageInfo, err := FetchAirportUsageInfo(airport)
 	if err != nil {
 		return nil, err
@@ -165,7 +121,7 @@ func UpdateAirportUsageInfo(airportID int) (*UsageInfo, error) {
 
 	// 保存到数据库
 	if usageInfo != nil {
-		if err := airport.UpdateUsageInfo(usageInfo.Upload, usageInfo.Download, usageInfo.Total, usageInfo.Expire); err != nil {
+		if err := SaveAirportUsageInfo(airport, usageInfo); err != nil {
 			utils.Error("保存机场【%s】用量信息失败: %v", airport.Name, err)
 			return usageInfo, fmt.Errorf("保存用量信息失败: %v", err)
 		}
@@ -175,6 +131,54 @@ func UpdateAirportUsageInfo(airportID int) (*UsageInfo, error) {
 	return usageInfo, nil
 }
 
+// SaveAirportUsageInfo 保存机场用量（同时记录用量历史），并检查用量告警
+func SaveAirportUsageInfo(airport *models.Airport, usageInfo *UsageInfo) error {
+	prevUsed := airport.UsageUpload + airport.UsageDownload
+	if err := airport.UpdateUsageInfo(usageInfo.Upload, usageInfo.Download, usageInfo.Total, usageInfo.Expire); err != nil {
+		return err
+	}
+	if usageInfo.Total == -1 {
+		return nil
+	}
+	checkAirportUsageAlerts(airport.ID, prevUsed)
+	return nil
+}
+
+// checkAirportUsageAlerts 计算用量预测并通过通知中心发送告警（Webhook / Telegram）
+func checkAirportUsageAlerts(airportID int, prevUsed int64) {
+	airport, err := models.GetAirportByID(airportID)
+	if err != nil {
+		return
+	}
+	forecast, err := models.GetAirportUsageForecast(airport)
+	if err != nil {
+		utils.Warn("计算机场【%s】用量预测失败: %v", airport.Name, err)
+		return
+	}
+	alerts, err := airport.CheckUsageAlerts(forecast, prevUsed)
+	if err != nil {
+		utils.Warn("保存机场【%s】用量告警状态失败: %v", airport.Name, err)
+	}
+	for _, alert := range alerts {
+		utils.Warn("机场【%s】用量告警: %s", airport.Name, alert.Message)
+		sse.GetSSEBroker().BroadcastEvent("airport_usage_alert", sse.NotificationPayload{
+			Event:   "airport_usage_alert",
+			Title:   "机场用量告警",
+			Message: fmt.Sprintf("⚠️机场【%s】%s", airport.Name, alert.Message),
+			Data: map[string]interface{}{
+				"id":          airport.ID,
+				"name":        airport.Name,
+				"type":        alert.Type,
+				"usedPercent": forecast.UsedPercent,
+				"dailyBurn":   forecast.DailyBurn,
+				"remaining":   forecast.Remaining,
+				"exhaustAt":   forecast.ExhaustAt,
+				"expireAt":    forecast.ExpireAt,
+			},
+		})
+	}
+}
+
 // UsageResult 单个机场用量获取结果
 type UsageResult struct {
 	AirportID   int
@@ -14,6 +14,7 @@ func Airport(r *gin.Engine) {
 	{
 		// 列表和详情
 		airportGroup.GET("", api.AirportList)
+		airportGroup.GET("/health", api.AirportHealthList)
 		airportGroup.GET("/:id", api.AirportGet)
 		// 增删改（演示模式下限制）
 		airportGroup.POST("", middlewares.DemoModeRestrict, api.AirportAdd)
@@ -23,5 +24,16 @@ func Airport(r *gin.Engine) {
 		airportGroup.POST("/:id/pull", middlewares.DemoModeRestrict, api.AirportPull)
 		// 刷新用量信息
 		airportGroup.POST("/:id/refresh-usage", middlewares.DemoModeRestrict, api.AirportRefreshUsage)
+		airportGroup.GET("/:id/usage-history", api.AirportUsageHistory)
+		// 同步变更记录与回滚
+		airportGroup.GET("/:id/changesets", api.AirportChangesetList)
+		airportGroup.GET("/:id/changesets/:changesetId", api.AirportChangesetGet)
+		airportGroup.POST("/:id/rollback", middlewares.DemoModeRestrict, api.AirportRollback)
+		// 拉取健康状态
+		airportGroup.GET("/:id/urls", api.AirportURLHealthList)
+		airportGroup.GET("/:id/health", api.AirportHealth)
+		// 面板账号
+		airportGroup.POST("/panel-test", middlewares.DemoModeRestrict, api.AirportPanelTest)
+		airportGroup.POST("/:id/panel-sync", middlewares.DemoModeRestrict, api.AirportPanelSync)
 	}
 }
@@ -22,5 +22,19 @@ func NodeCheck(r *gin.Engine) {
 
 		// 执行检测
 		group.POST("/run", middlewares.DemoModeRestrict, api.RunNodeCheck)
+
+		// 断点续测
+		group.GET("/interrupted", api.ListInterruptedNodeChecks)
+		group.POST("/interrupted/:taskId/resume", middlewares.DemoModeRestrict, api.ResumeNodeCheck)
+		group.DELETE("/interrupted/:taskId", middlewares.DemoModeRestrict, api.DiscardInterruptedNodeCheck)
+
+		// 节点生命周期（隔离/恢复/超期处理）
+		group.GET("/lifecycle/policy", api.GetNodeLifecyclePolicy)
+		group.PUT("/lifecycle/policy", middlewares.DemoModeRestrict, api.UpdateNodeLifecyclePolicy)
+		group.GET("/lifecycle/nodes", api.ListLifecycleNodes)
+		group.GET("/lifecycle/events", api.ListNodeLifecycleEvents)
+		group.POST("/lifecycle/revive", middlewares.DemoModeRestrict, api.ReviveNodes)
+		group.POST("/lifecycle/quarantine", middlewares.DemoModeRestrict, api.QuarantineNodes)
+		group.POST("/lifecycle/run", middlewares.DemoModeRestrict, api.RunNodeLifecycleTask)
 	}
 }
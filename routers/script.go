@@ -16,5 +16,20 @@ func Script(r *gin.Engine) {
 		ScriptGroup.DELETE("/delete", middlewares.DemoModeRestrict, api.ScriptDel)
 		ScriptGroup.POST("/update", middlewares.DemoModeRestrict, api.ScriptUpdate)
 		ScriptGroup.GET("/list", api.ScriptList)
+		// 试运行（不写入 KV 存储）
+		ScriptGroup.POST("/test", api.ScriptTest)
+		// 立即执行 cron 钩子
+		ScriptGroup.POST("/hook-run", middlewares.DemoModeRestrict, api.ScriptHookRun)
+		// 从地址安装与版本管理
+		ScriptGroup.POST("/install", middlewares.DemoModeRestrict, api.ScriptInstall)
+		ScriptGroup.POST("/check-update", middlewares.DemoModeRestrict, api.ScriptCheckUpdate)
+		ScriptGroup.GET("/update-diff", api.ScriptUpdateDiff)
+		ScriptGroup.POST("/upgrade", middlewares.DemoModeRestrict, api.ScriptUpgrade)
+		ScriptGroup.GET("/versions", api.ScriptVersions)
+		// 运行限制与 KV 存储
+		ScriptGroup.GET("/settings", api.ScriptSettingsGet)
+		ScriptGroup.POST("/settings", middlewares.DemoModeRestrict, api.ScriptSettingsUpdate)
+		ScriptGroup.GET("/kv", api.ScriptKVList)
+		ScriptGroup.DELETE("/kv", middlewares.DemoModeRestrict, api.ScriptKVClear)
 	}
 }
@@ -18,6 +18,11 @@ func Settings(r *gin.Engine) {
 		SettingsGroup.GET("/base-templates", api.GetBaseTemplates)
 		SettingsGroup.POST("/base-templates", middlewares.DemoModeRestrict, api.UpdateBaseTemplate)
 
+		// IP 信息提供商（落地IP地理信息查询）
+		SettingsGroup.GET("/ip-providers", api.GetIPInfoProviders)
+		SettingsGroup.POST("/ip-providers", middlewares.DemoModeRestrict, api.UpdateIPInfoProviders)
+		SettingsGroup.POST("/ip-providers/test", middlewares.DemoModeRestrict, api.TestIPInfoProvider)
+
 		// Telegram 机器人设置
 		SettingsGroup.GET("/telegram", api.GetTelegramConfig)
 		SettingsGroup.POST("/telegram", middlewares.DemoModeRestrict, api.UpdateTelegramConfig)
@@ -31,6 +31,11 @@ func Subcription(r *gin.Engine) {
 		SubcriptionGroup.PUT("/:id/chain-rules/:ruleId/toggle", api.ToggleChainRule) // 切换启用状态
 		SubcriptionGroup.GET("/:id/chain-options", api.GetChainOptions)              // 获取可用选项
 		SubcriptionGroup.GET("/:id/chain-rules/preview", api.PreviewChainLinks)      // 预览链路（整体）
+		SubcriptionGroup.GET("/:id/chain-rules/validate", api.ValidateChainRules)    // 校验链路拓扑
+		SubcriptionGroup.POST("/:id/chain-rules/dial-test", api.DialTestChainRule)   // 经链路拨号测试
+
+		// 订阅中脚本的版本与参数配置
+		SubcriptionGroup.PUT("/:id/scripts/:scriptId", api.SubScriptSettings)
 	}
 
 }
@@ -24,6 +24,7 @@ func Tag(r *gin.Engine) {
 		tagGroup.POST("/rules/update", api.TagRuleUpdate)
 		tagGroup.DELETE("/rules/delete", api.TagRuleDelete)
 		tagGroup.POST("/rules/trigger", api.TagRuleTrigger)
+		tagGroup.POST("/rules/preview", api.TagRulePreview)
 
 		// 节点标签操作
 		tagGroup.POST("/node/add", api.NodeAddTag)
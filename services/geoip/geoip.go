@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/netip"
 	"os"
+	"strings"
 	"sublink/config"
 	"sublink/utils"
 	"sync"
@@ -238,6 +239,94 @@ func GetCountryISOCode(ipStr string) (string, error) {
 	return "", nil
 }
 
+// LookupResult 本地数据库查询结果
+type LookupResult struct {
+	CountryCode    string
+	Country        string
+	RegionCode     string
+	RegionName     string
+	City           string
+	Lat            float64
+	Lon            float64
+	Timezone       string
+	ASN            uint   // 自治系统号（仅 ASN / Enterprise 数据库提供）
+	ASOrganization string // 自治系统组织
+	ISP            string // ISP（仅 Enterprise 数据库提供）
+	UserType       string // 用户类型，如 residential、hosting（仅 Enterprise 数据库提供）
+}
+
+// Lookup 查询 IP 的地理位置信息
+// 根据数据库类型读取尽可能多的字段：City/Country 数据库提供位置，ASN/Enterprise 数据库额外提供 ASN 和 ISP
+func Lookup(ipStr string) (*LookupResult, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if !available || geoIP == nil {
+		return nil, fmt.Errorf("GeoIP 数据库不可用")
+	}
+
+	ip, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 IP 地址: %s", ipStr)
+	}
+
+	result := &LookupResult{}
+	dbType := geoIP.Metadata().DatabaseType
+	switch {
+	case strings.Contains(dbType, "Enterprise"):
+		record, err := geoIP.Enterprise(ip)
+		if err != nil {
+			return nil, fmt.Errorf("查询数据库失败: %v", err)
+		}
+		result.CountryCode = record.Country.ISOCode
+		result.Country = record.Country.Names.SimplifiedChinese
+		result.City = record.City.Names.SimplifiedChinese
+		if len(record.Subdivisions) > 0 {
+			result.RegionCode = record.Subdivisions[0].ISOCode
+			result.RegionName = record.Subdivisions[0].Names.SimplifiedChinese
+		}
+		if record.Location.HasCoordinates() {
+			result.Lat, result.Lon = *record.Location.Latitude, *record.Location.Longitude
+		}
+		result.Timezone = record.Location.TimeZone
+		result.ASN = record.Traits.AutonomousSystemNumber
+		result.ASOrganization = record.Traits.AutonomousSystemOrganization
+		result.ISP = record.Traits.ISP
+		result.UserType = record.Traits.UserType
+	case strings.Contains(dbType, "ASN"):
+		record, err := geoIP.ASN(ip)
+		if err != nil {
+			return nil, fmt.Errorf("查询数据库失败: %v", err)
+		}
+		result.ASN = record.AutonomousSystemNumber
+		result.ASOrganization = record.AutonomousSystemOrganization
+	case strings.Contains(dbType, "City"):
+		record, err := geoIP.City(ip)
+		if err != nil {
+			return nil, fmt.Errorf("查询数据库失败: %v", err)
+		}
+		result.CountryCode = record.Country.ISOCode
+		result.Country = record.Country.Names.SimplifiedChinese
+		result.City = record.City.Names.SimplifiedChinese
+		if len(record.Subdivisions) > 0 {
+			result.RegionCode = record.Subdivisions[0].ISOCode
+			result.RegionName = record.Subdivisions[0].Names.SimplifiedChinese
+		}
+		if record.Location.HasCoordinates() {
+			result.Lat, result.Lon = *record.Location.Latitude, *record.Location.Longitude
+		}
+		result.Timezone = record.Location.TimeZone
+	default:
+		record, err := geoIP.Country(ip)
+		if err != nil {
+			return nil, fmt.Errorf("查询数据库失败: %v", err)
+		}
+		result.CountryCode = record.Country.ISOCode
+		result.Country = record.Country.Names.SimplifiedChinese
+	}
+	return result, nil
+}
+
 // Close 关闭 GeoIP reader
 func Close() error {
 	mu.Lock()
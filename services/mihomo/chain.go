@@ -0,0 +1,110 @@
+package mihomo
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/metacubex/mihomo/component/dialer"
+	"github.com/metacubex/mihomo/component/proxydialer"
+	"github.com/metacubex/mihomo/constant"
+)
+
+// MihomoChainDelayTest 经链式代理拨号并测试延迟
+// links 按流量经过的顺序排列：第一个为入口节点（直连），最后一个为落地节点，前一跳作为后一跳的前置代理
+// 返回从发起请求到收到响应头的耗时（包含每一跳的握手），与客户端通过 dialer-proxy 连接的路径一致
+func MihomoChainDelayTest(links []string, testUrl string, timeout time.Duration) (latency int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			latency = 0
+			err = fmt.Errorf("panic in MihomoChainDelayTest: %v", r)
+		}
+	}()
+
+	if len(links) == 0 {
+		return 0, fmt.Errorf("链路为空")
+	}
+	if testUrl == "" {
+		testUrl = "http://cp.cloudflare.com/generate_204"
+	}
+
+	adapters := make([]constant.Proxy, 0, len(links))
+	for i, link := range links {
+		proxyAdapter, err := GetMihomoAdapter(link)
+		if err != nil {
+			return 0, fmt.Errorf("第 %d 跳: %v", i+1, err)
+		}
+		// 入口之后的每一跳都要经前置代理建立连接
+		if i > 0 && proxyAdapter.SupportWithDialer() == constant.InvalidNet {
+			return 0, fmt.Errorf("第 %d 跳 %s 的协议 %s 不支持经前置代理拨号测试", i+1, proxyAdapter.Name(), proxyAdapter.Type())
+		}
+		adapters = append(adapters, proxyAdapter)
+	}
+
+	// 逐跳组装拨号器：入口直连，之后每一跳都通过上一跳拨号
+	var chainDialer constant.Dialer = dialer.NewDialer()
+	for _, proxyAdapter := range adapters[:len(adapters)-1] {
+		chainDialer = proxydialer.New(proxyAdapter, chainDialer, false)
+	}
+	last := adapters[len(adapters)-1]
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				md, err := addrToMetadata(addr)
+				if err != nil {
+					return nil, err
+				}
+				if len(adapters) == 1 {
+					return last.DialContext(ctx, md)
+				}
+				return last.DialContextWithDialer(ctx, chainDialer, md)
+			},
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+		},
+		Timeout: timeout,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, testUrl, nil)
+	if err != nil {
+		return 0, fmt.Errorf("create request error: %v", err)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	latency = int(time.Since(start).Milliseconds())
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return latency, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return latency, nil
+}
+
+// addrToMetadata 将 host:port 转换为 mihomo 拨号元数据
+func addrToMetadata(addr string) (*constant.Metadata, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("split host port error: %v", err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid port: %s", portStr)
+	}
+	return &constant.Metadata{
+		Host:    host,
+		DstPort: uint16(port),
+		Type:    constant.HTTP,
+	}, nil
+}
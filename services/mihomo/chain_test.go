@@ -0,0 +1,209 @@
+package mihomo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// socks5Stub 最小的 SOCKS5 入站（无认证，仅支持 CONNECT），记录每个连接请求的目标地址
+type socks5Stub struct {
+	listener net.Listener
+	mu       sync.Mutex
+	targets  []string
+}
+
+func newSocks5Stub(t *testing.T) *socks5Stub {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	s := &socks5Stub{listener: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// link 返回指向该入站的 socks5 节点链接
+func (s *socks5Stub) link(name string) string {
+	return fmt.Sprintf("socks5://%s#%s", s.listener.Addr().String(), name)
+}
+
+func (s *socks5Stub) connectTargets() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.targets...)
+}
+
+func (s *socks5Stub) serve(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	// 协商认证方式：VER NMETHODS METHODS
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(conn, head); err != nil || head[0] != 5 {
+		return
+	}
+	if _, err := io.ReadFull(conn, make([]byte, head[1])); err != nil {
+		return
+	}
+	conn.Write([]byte{5, 0})
+
+	// 请求：VER CMD RSV ATYP DST.ADDR DST.PORT
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil || req[1] != 1 {
+		return
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		ip := make([]byte, 4)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return
+		}
+		host = net.IP(ip).String()
+	case 3:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return
+		}
+		name := make([]byte, l[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return
+		}
+		host = string(name)
+	case 4:
+		ip := make([]byte, 16)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return
+		}
+		host = net.IP(ip).String()
+	default:
+		return
+	}
+	portBuf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, portBuf); err != nil {
+		return
+	}
+	target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(portBuf))))
+	s.mu.Lock()
+	s.targets = append(s.targets, target)
+	s.mu.Unlock()
+
+	upstream, err := net.DialTimeout("tcp", target, 5*time.Second)
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer upstream.Close()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	conn.SetDeadline(time.Time{})
+
+	done := make(chan struct{}, 2)
+	go func() { io.Copy(upstream, conn); done <- struct{}{} }()
+	go func() { io.Copy(conn, upstream); done <- struct{}{} }()
+	<-done
+}
+
+// TestMihomoChainDelayTest 测试经本地 SOCKS5 入站逐跳拨号到测试地址
+func TestMihomoChainDelayTest(t *testing.T) {
+	const targetDelay = 50 * time.Millisecond
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(targetDelay)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer target.Close()
+	targetAddr := strings.TrimPrefix(target.URL, "http://")
+
+	entry := newSocks5Stub(t)
+	relay := newSocks5Stub(t)
+	landing := newSocks5Stub(t)
+
+	// 三跳链路：entry -> relay -> landing -> 测试地址
+	latency, err := MihomoChainDelayTest([]string{entry.link("entry"), relay.link("relay"), landing.link("landing")}, target.URL+"/generate_204", 5*time.Second)
+	if err != nil {
+		t.Fatalf("链路测试失败: %v", err)
+	}
+	if latency < int(targetDelay.Milliseconds()) {
+		t.Errorf("延迟 %dms 应不小于测试地址的处理时间 %dms", latency, targetDelay.Milliseconds())
+	}
+
+	// 每一跳都经上一跳建立连接：入口连接 relay，relay 连接 landing，landing 连接测试地址
+	want := map[*socks5Stub]string{
+		entry:   relay.listener.Addr().String(),
+		relay:   landing.listener.Addr().String(),
+		landing: targetAddr,
+	}
+	names := map[*socks5Stub]string{entry: "entry", relay: "relay", landing: "landing"}
+	for stub, addr := range want {
+		if got := stub.connectTargets(); len(got) != 1 || got[0] != addr {
+			t.Errorf("%s 的连接目标 = %v, 期望 [%s]", names[stub], got, addr)
+		}
+	}
+
+	// 单跳：直接经入口节点连接
+	single := newSocks5Stub(t)
+	if _, err := MihomoChainDelayTest([]string{single.link("single")}, target.URL, 5*time.Second); err != nil {
+		t.Fatalf("单跳测试失败: %v", err)
+	}
+	if got := single.connectTargets(); len(got) != 1 || got[0] != targetAddr {
+		t.Errorf("单跳的连接目标 = %v, 期望 [%s]", got, targetAddr)
+	}
+}
+
+// TestMihomoChainDelayTestErrors 测试链路为空、中间节点不可达与测试地址返回错误状态
+func TestMihomoChainDelayTestErrors(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer target.Close()
+
+	// 已关闭的端口作为不可达的中转节点
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	deadLink := fmt.Sprintf("socks5://%s#dead", closed.Addr().String())
+	closed.Close()
+
+	entry := newSocks5Stub(t)
+	tests := []struct {
+		name    string
+		links   []string
+		url     string
+		wantErr string
+	}{
+		{name: "链路为空", links: nil, url: target.URL, wantErr: "链路为空"},
+		{name: "无效链接", links: []string{"unknown://x"}, url: target.URL, wantErr: "第 1 跳"},
+		{name: "中转不可达", links: []string{entry.link("entry"), deadLink}, url: target.URL},
+		{name: "错误状态码", links: []string{entry.link("entry")}, url: target.URL, wantErr: "503"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MihomoChainDelayTest(tt.links, tt.url, 3*time.Second)
+			if err == nil {
+				t.Fatal("应返回错误")
+			}
+			if tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("错误 = %q, 期望包含 %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
@@ -101,7 +101,7 @@ func MihomoDelayWithAdapter(proxyAdapter constant.Proxy, testUrl string, timeout
 // MihomoDelayTest 执行延迟测试，可选检测落地IP
 // includeHandshake: true 测量完整连接时间，false 使用 UnifiedDelay 模式排除握手
 // detectLandingIP: 是否检测落地IP
-// landingIPUrl: IP查询服务URL，空则使用默认值 https://api.ipify.org
+// landingIPUrl: IP查询服务URL（多个以逗号分隔，按顺序回退），空则使用默认值 https://api.ipify.org
 // 返回: latency(ms), landingIP(若未检测或失败则为空), error
 func MihomoDelayTest(nodeLink string, testUrl string, timeout time.Duration, includeHandshake bool, detectLandingIP bool, landingIPUrl string) (latency int, landingIP string, err error) {
 	// Recover from any panics
@@ -139,7 +139,7 @@ func MihomoDelayTest(nodeLink string, testUrl string, timeout time.Duration, inc
 
 // MihomoSpeedTest 执行速度测试，可选检测落地IP
 // detectLandingIP: 是否检测落地IP
-// landingIPUrl: IP查询服务URL，空则使用默认值 https://api.ipify.org
+// landingIPUrl: IP查询服务URL（多个以逗号分隔，按顺序回退），空则使用默认值 https://api.ipify.org
 // speedRecordMode: 速度记录模式 "average"=平均速度, "peak"=峰值速度
 // peakSampleInterval: 峰值采样间隔（毫秒），仅在peak模式下生效，范围50-200
 // 返回: speed(MB/s), latency(ms), bytesDownloaded, landingIP(若未检测或失败则为空), error
@@ -392,7 +392,8 @@ CalculateSpeed:
 }
 
 // fetchLandingIPWithAdapter 使用已有adapter获取落地IP（内部辅助函数）
-// 固定1秒超时，失败静默返回空字符串不影响主流程
+// ipUrl 支持以逗号或换行分隔的多个地址，按顺序尝试直到获取到有效IP
+// 每个地址固定3秒超时，失败静默返回空字符串不影响主流程
 func fetchLandingIPWithAdapter(proxyAdapter constant.Proxy, ipUrl string) string {
 	// Recover from any panics
 	defer func() {
@@ -401,15 +402,12 @@ func fetchLandingIPWithAdapter(proxyAdapter constant.Proxy, ipUrl string) string
 		}
 	}()
 
+	urls := splitLandingIPURLs(ipUrl)
 	// 默认IP查询接口
-	if ipUrl == "" {
-		ipUrl = "https://api.ipify.org"
+	if len(urls) == 0 {
+		urls = []string{"https://api.ipify.org"}
 	}
 
-	// 固定3秒超时（慢速节点需要更长时间）
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-
 	// 复用proxyAdapter创建HTTP client
 	client := &http.Client{
 		Transport: &http.Transport{
@@ -440,6 +438,20 @@ func fetchLandingIPWithAdapter(proxyAdapter constant.Proxy, ipUrl string) string
 		Timeout: 3 * time.Second,
 	}
 
+	for _, u := range urls {
+		if ip := fetchLandingIPOnce(client, u); ip != "" {
+			return ip
+		}
+	}
+	return ""
+}
+
+// fetchLandingIPOnce 请求单个IP查询地址，响应不是有效IP时返回空字符串
+func fetchLandingIPOnce(client *http.Client, ipUrl string) string {
+	// 固定3秒超时（慢速节点需要更长时间）
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
 	req, err := http.NewRequestWithContext(ctx, "GET", ipUrl, nil)
 	if err != nil {
 		utils.Error("落地IP检测: 创建请求失败: %v", err)
@@ -457,5 +469,21 @@ func fetchLandingIPWithAdapter(proxyAdapter constant.Proxy, ipUrl string) string
 	body := make([]byte, 64)
 	n, _ := resp.Body.Read(body)
 
-	return strings.TrimSpace(string(body[:n]))
+	ip := strings.TrimSpace(string(body[:n]))
+	if net.ParseIP(ip) == nil {
+		utils.Debug("落地IP检测: 响应不是有效IP (URL: %s)", ipUrl)
+		return ""
+	}
+	return ip
+}
+
+// splitLandingIPURLs 拆分以逗号或换行分隔的IP查询地址列表
+func splitLandingIPURLs(raw string) []string {
+	var urls []string
+	for _, u := range strings.FieldsFunc(raw, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+	return urls
 }
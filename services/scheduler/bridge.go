@@ -53,6 +53,10 @@ type TaskManagerInterface interface {
 // 当测速或订阅更新完成后，自动为节点应用匹配的标签规则
 type AutoTagRulesApplier func(nodes []models.Node, source string)
 
+// TagRuleRunner 单条标签规则执行函数类型
+// 对全部节点执行指定规则，用于 cron 触发的标签规则
+type TagRuleRunner func(ruleID int, trigger models.TaskTrigger) error
+
 // ================================================================================
 // 依赖注入存储
 // ================================================================================
@@ -70,6 +74,10 @@ var (
 	// 由 services.InitSchedulerDependencies() 注入
 	autoTagRulesApplier AutoTagRulesApplier
 
+	// tagRuleRunner 单条标签规则执行函数
+	// 由 services.InitSchedulerDependencies() 注入
+	tagRuleRunner TagRuleRunner
+
 	// latencyAdjustInterval 延迟测试并发调整检查间隔
 	// 每完成这么多个任务后检查一次是否需要调整并发数
 	// 单位：任务个数
@@ -109,17 +117,20 @@ const (
 //   - factory: 自适应并发控制器工厂函数
 //   - tmGetter: 任务管理器获取函数
 //   - tagApplier: 自动标签规则应用函数
+//   - ruleRunner: 单条标签规则执行函数
 //   - latencyInterval: 延迟测试并发调整检查间隔（单位：任务个数）
 //   - speedInterval: 速度测试并发调整检查间隔（单位：任务个数）
 func InjectDependencies(
 	factory AdaptiveConcurrencyControllerFactory,
 	tmGetter func() TaskManagerInterface,
 	tagApplier AutoTagRulesApplier,
+	ruleRunner TagRuleRunner,
 	latencyInterval, speedInterval int,
 ) {
 	adaptiveConcurrencyFactory = factory
 	taskManagerGetter = tmGetter
 	autoTagRulesApplier = tagApplier
+	tagRuleRunner = ruleRunner
 	latencyAdjustInterval = latencyInterval
 	speedAdjustInterval = speedInterval
 	// 同步更新导出变量
@@ -157,6 +168,15 @@ func applyAutoTagRules(nodes []models.Node, source string) {
 	}
 }
 
+// runTagRule 执行单条标签规则
+// 如果未注入则静默跳过
+func runTagRule(ruleID int, trigger models.TaskTrigger) error {
+	if tagRuleRunner == nil {
+		return nil
+	}
+	return tagRuleRunner(ruleID, trigger)
+}
+
 // ================================================================================
 // 导出的调整间隔变量（供 speedtest_task.go 使用）
 // ================================================================================
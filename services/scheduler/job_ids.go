@@ -9,7 +9,16 @@ const (
 	// JobIDHostCleanup Host过期清理任务ID
 	JobIDHostCleanup = -101
 
-	// 预留区间 -102 ~ -199 用于未来系统任务
+	// JobIDNodeLifecycle 隔离节点复测与超期处理任务ID
+	JobIDNodeLifecycle = -102
+
+	// JobIDScriptUpdateCheck 远程脚本更新检查任务ID
+	JobIDScriptUpdateCheck = -103
+
+	// JobIDTagExpiry 过期标签清理任务ID
+	JobIDTagExpiry = -104
+
+	// 预留区间 -105 ~ -199 用于未来系统任务
 	// 新增系统任务时按顺序递减分配ID
 )
 
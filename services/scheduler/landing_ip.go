@@ -0,0 +1,39 @@
+package scheduler
+
+import (
+	"sublink/models"
+	"sublink/utils"
+)
+
+// lookupLandingIPInfo 查询落地IP的地理信息（按配置的提供商顺序回退，结果有缓存）
+// 查询失败时返回 nil
+func lookupLandingIPInfo(landingIP string) *models.IPInfo {
+	if landingIP == "" {
+		return nil
+	}
+	info, err := models.GetIPInfo(landingIP)
+	if err != nil {
+		utils.Debug("查询落地IP %s 信息失败: %v", landingIP, err)
+		return nil
+	}
+	return info
+}
+
+// applyLandingIPInfo 将落地IP及其国家、城市、ASN、运营商、IP类型写入节点
+func applyLandingIPInfo(n *models.Node, landingIP string, info *models.IPInfo) {
+	n.LandingIP = landingIP
+	if info == nil {
+		return
+	}
+	if info.CountryCode != "" {
+		n.LinkCountry = info.CountryCode
+	}
+	n.LandingASN = info.ASNumber()
+	n.LandingISP = info.ISP
+	if n.LandingISP == "" {
+		n.LandingISP = info.Org
+	}
+	n.LandingIPType = info.IPType
+	n.LandingCity = info.City
+	utils.Debug("节点 [%s] 落地IP: %s, 国家: %s, %s %s (%s)", n.Name, landingIP, n.LinkCountry, n.LandingASN, n.LandingISP, n.LandingIPType)
+}
@@ -87,11 +87,32 @@ func (sm *SchedulerManager) LoadFromDatabase() error {
 		}
 	}
 
+	// 加载脚本 cron 钩子定时任务
+	sm.loadScriptHookJobs()
+
+	// 加载标签规则 cron 定时任务
+	sm.loadTagRuleJobs()
+
+	// 启动过期标签清理任务
+	if err := sm.StartTagExpiryTask(); err != nil {
+		utils.Error("创建过期标签清理任务失败: %v", err)
+	}
+
+	// 启动远程脚本更新检查任务
+	if err := sm.StartScriptUpdateCheckTask(); err != nil {
+		utils.Error("创建脚本更新检查任务失败: %v", err)
+	}
+
 	// 启动 Host 过期清理任务
 	if err := sm.StartHostCleanupTask(); err != nil {
 		utils.Error("创建Host过期清理任务失败: %v", err)
 	}
 
+	// 启动隔离节点复测任务
+	if err := sm.StartNodeLifecycleTask(); err != nil {
+		utils.Error("创建隔离节点复测任务失败: %v", err)
+	}
+
 	return nil
 }
 
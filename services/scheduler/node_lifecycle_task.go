@@ -0,0 +1,173 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"sublink/models"
+	"sublink/services/sse"
+	"sublink/utils"
+	"time"
+)
+
+// lifecycleNotifyMaxNames 通知消息中最多列出的节点名称数量
+const lifecycleNotifyMaxNames = 10
+
+// StartNodeLifecycleTask 启动隔离节点复测任务
+// 按生命周期策略中的 Cron 表达式执行；策略未启用时移除任务
+func (sm *SchedulerManager) StartNodeLifecycleTask() error {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	// 如果任务已存在，先删除
+	if entryID, exists := sm.jobs[JobIDNodeLifecycle]; exists {
+		sm.cron.Remove(entryID)
+		delete(sm.jobs, JobIDNodeLifecycle)
+	}
+
+	policy := models.GetNodeLifecyclePolicy()
+	if !policy.Enabled {
+		return nil
+	}
+
+	cronExpr := cleanCronExpression(policy.RecheckCron)
+	entryID, err := sm.cron.AddFunc(cronExpr, func() {
+		ExecuteNodeLifecycleTask()
+	})
+	if err != nil {
+		utils.Error("添加隔离节点复测任务失败 - Cron: %s, Error: %v", cronExpr, err)
+		return err
+	}
+
+	sm.jobs[JobIDNodeLifecycle] = entryID
+	utils.Info("成功添加隔离节点复测任务 - Cron: %s", cronExpr)
+	return nil
+}
+
+// ExecuteNodeLifecycleTask 执行隔离节点的超期处理与复测
+// 1. 隔离超过 PurgeDays 天仍未恢复的节点按策略标记或删除
+// 2. 其余隔离/标记节点重新检测，检测成功即恢复
+func ExecuteNodeLifecycleTask() {
+	policy := models.GetNodeLifecyclePolicy()
+	if !policy.Enabled {
+		return
+	}
+
+	if policy.PurgeDays > 0 {
+		purgeExpiredQuarantinedNodes(policy)
+	}
+
+	nodes := models.ListQuarantinedNodes()
+	if len(nodes) == 0 {
+		utils.Debug("没有需要复测的隔离节点")
+		return
+	}
+
+	profileName := "隔离节点复测"
+	var config *SpeedTestConfig
+	if policy.RecheckProfileID > 0 {
+		if profile, err := models.GetNodeCheckProfileByID(policy.RecheckProfileID); err == nil {
+			config = SpeedTestConfigFromProfile(profile)
+		} else {
+			utils.Warn("复测使用的检测策略 %d 不存在，使用默认配置", policy.RecheckProfileID)
+		}
+	}
+	if config == nil {
+		config = SpeedTestConfigFromProfile(&models.NodeCheckProfile{
+			Name:             profileName,
+			Mode:             "tcp",
+			Timeout:          5,
+			IncludeHandshake: true,
+		})
+	}
+
+	utils.Info("开始复测隔离节点，共 %d 个", len(nodes))
+	RunSpeedTestWithConfig(nodes, models.TaskTriggerScheduled, profileName, config)
+}
+
+// purgeExpiredQuarantinedNodes 处理隔离超期的节点
+func purgeExpiredQuarantinedNodes(policy models.NodeLifecyclePolicy) {
+	expired := models.ListNodesQuarantinedBefore(time.Now().AddDate(0, 0, -policy.PurgeDays))
+	if len(expired) == 0 {
+		return
+	}
+
+	ids := make([]int, 0, len(expired))
+	for _, n := range expired {
+		ids = append(ids, n.ID)
+	}
+	reason := fmt.Sprintf("隔离超过 %d 天未恢复", policy.PurgeDays)
+
+	if policy.PurgeAction == models.NodePurgeActionDelete {
+		// 删除成功后再按删除前的节点记录状态变更，删除失败时节点仍保持隔离状态
+		if err := models.BatchDel(ids); err != nil {
+			utils.Error("删除隔离超期节点失败: %v", err)
+			return
+		}
+		utils.Info("已删除 %d 个隔离超期节点", len(ids))
+		notifyNodeLifecycleEvents(models.RecordNodesDeleted(expired, reason))
+		return
+	}
+
+	events := models.SetNodesLifecycleState(ids, models.NodeStateFlagged, reason)
+	notifyNodeLifecycleEvents(events)
+}
+
+// notifyNodeLifecycleEvents 汇总生命周期状态变更并发送通知（SSE / Webhook / Telegram）
+func notifyNodeLifecycleEvents(events []models.NodeLifecycleEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	byState := make(map[string][]string)
+	for _, e := range events {
+		byState[e.ToState] = append(byState[e.ToState], e.NodeName)
+	}
+
+	stateLabels := []struct {
+		state string
+		label string
+	}{
+		{models.NodeStateQuarantined, "隔离"},
+		{models.NodeStateActive, "恢复"},
+		{models.NodeStateFlagged, "标记待处理"},
+		{models.NodeStateDeleted, "删除"},
+	}
+
+	var parts []string
+	counts := make(map[string]int)
+	for _, sl := range stateLabels {
+		names := byState[sl.state]
+		if len(names) == 0 {
+			continue
+		}
+		counts[sl.state] = len(names)
+		shown := names
+		if len(shown) > lifecycleNotifyMaxNames {
+			shown = shown[:lifecycleNotifyMaxNames]
+		}
+		part := fmt.Sprintf("%s %d 个: %s", sl.label, len(names), strings.Join(shown, ", "))
+		if len(names) > len(shown) {
+			part += " 等"
+		}
+		parts = append(parts, part)
+	}
+
+	sse.GetSSEBroker().BroadcastEvent("node_lifecycle", sse.NotificationPayload{
+		Event:   "node_lifecycle",
+		Title:   "节点状态变更",
+		Message: strings.Join(parts, "；"),
+		Data: map[string]interface{}{
+			"quarantined": counts[models.NodeStateQuarantined],
+			"revived":     counts[models.NodeStateActive],
+			"flagged":     counts[models.NodeStateFlagged],
+			"deleted":     counts[models.NodeStateDeleted],
+		},
+	})
+}
+
+// ChangeNodesLifecycleState 手动变更节点生命周期状态并发送通知
+func ChangeNodesLifecycleState(ids []int, state string, reason string) int {
+	events := models.SetNodesLifecycleState(ids, state, reason)
+	notifyNodeLifecycleEvents(events)
+	return len(events)
+}
@@ -0,0 +1,171 @@
+package scheduler
+
+import (
+	"context"
+	"strconv"
+	"sublink/models"
+	"sublink/services/mihomo"
+	"sync"
+)
+
+// checkPoliteness 测速礼貌限制器
+// 按来源（机场）和服务器地址限制同时进行的延迟/速度测试数量，并限制每个来源单次检测的流量
+// 避免短时间内对同一机场发起大量连接导致出口IP被标记
+type checkPoliteness struct {
+	maxPerSource      int   // 每个来源的最大并发(0=不限)
+	maxPerHost        int   // 每个服务器地址的最大并发(0=不限)
+	maxBytesPerSource int64 // 每个来源的流量上限(字节，0=不限)
+
+	mu           sync.Mutex
+	cond         *sync.Cond
+	activeSource map[string]int
+	activeHost   map[string]int
+	bytesSource  map[string]int64
+	hostCache    map[int]string // 节点ID -> 服务器地址（避免重复解析链接）
+}
+
+// newCheckPoliteness 根据测速配置创建礼貌限制器
+func newCheckPoliteness(config *SpeedTestConfig) *checkPoliteness {
+	p := &checkPoliteness{
+		maxPerSource:      config.SourceSpeedConcurrency,
+		maxPerHost:        config.HostSpeedConcurrency,
+		maxBytesPerSource: int64(config.SourceTrafficLimitMB) * 1024 * 1024,
+		activeSource:      make(map[string]int),
+		activeHost:        make(map[string]int),
+		bytesSource:       make(map[string]int64),
+		hostCache:         make(map[int]string),
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// enabled 是否配置了任何限制
+func (p *checkPoliteness) enabled() bool {
+	return p.maxPerSource > 0 || p.maxPerHost > 0 || p.maxBytesPerSource > 0
+}
+
+// budgetExhausted 来源的流量预算是否已用尽（调用方需持有 mu）
+func (p *checkPoliteness) budgetExhausted(source string) bool {
+	return p.maxBytesPerSource > 0 && p.bytesSource[source] >= p.maxBytesPerSource
+}
+
+// canAcquire 来源和服务器是否还有空闲槽位（调用方需持有 mu）
+func (p *checkPoliteness) canAcquire(source, host string) bool {
+	if p.maxPerSource > 0 && p.activeSource[source] >= p.maxPerSource {
+		return false
+	}
+	if p.maxPerHost > 0 && host != "" && p.activeHost[host] >= p.maxPerHost {
+		return false
+	}
+	return true
+}
+
+// acquireNext 从待测列表中选出下一个可执行的节点并占用其槽位
+// 优先选择排在前面且来源、服务器均有空闲槽位的节点，全部受限时阻塞等待
+// 返回值：pos 为选中节点在待测列表中的位置；exhausted 表示该节点来源流量预算已用尽（未占用槽位）；
+// ok 为 false 表示 ctx 已取消
+func (p *checkPoliteness) acquireNext(ctx context.Context, count int, nodeAt func(i int) models.Node) (pos int, exhausted bool, ok bool) {
+	if !p.enabled() {
+		return 0, false, ctx.Err() == nil
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		p.mu.Lock()
+		p.cond.Broadcast()
+		p.mu.Unlock()
+	})
+	defer stop()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		if ctx.Err() != nil {
+			return 0, false, false
+		}
+		for i := 0; i < count; i++ {
+			n := nodeAt(i)
+			source, host := sourceKey(n), p.hostKey(n)
+			if p.budgetExhausted(source) {
+				return i, true, true
+			}
+			if p.canAcquire(source, host) {
+				p.activeSource[source]++
+				if host != "" {
+					p.activeHost[host]++
+				}
+				return i, false, true
+			}
+		}
+		p.cond.Wait()
+	}
+}
+
+// release 释放节点占用的槽位并累计流量
+func (p *checkPoliteness) release(n models.Node, bytes int64) {
+	if !p.enabled() {
+		return
+	}
+	p.mu.Lock()
+	source, host := sourceKey(n), p.hostKey(n)
+	p.activeSource[source]--
+	if host != "" {
+		p.activeHost[host]--
+	}
+	if bytes > 0 {
+		p.bytesSource[source] += bytes
+	}
+	p.cond.Broadcast()
+	p.mu.Unlock()
+}
+
+// sourceKey 获取节点的来源键（机场节点按机场ID，其余按来源名称）
+func sourceKey(n models.Node) string {
+	if n.SourceID > 0 {
+		return "airport:" + strconv.Itoa(n.SourceID)
+	}
+	return "source:" + n.Source
+}
+
+// hostKey 获取节点的服务器地址（调用方需持有 mu）
+func (p *checkPoliteness) hostKey(n models.Node) string {
+	if p.maxPerHost <= 0 {
+		return ""
+	}
+	if host, ok := p.hostCache[n.ID]; ok {
+		return host
+	}
+	host := n.LinkHost
+	if host == "" {
+		host = mihomo.GetProxyServerFromLink(n.Link).Server
+	}
+	p.hostCache[n.ID] = host
+	return host
+}
+
+// interleaveNodesBySource 按来源轮询交错排列节点
+// 同一来源内保持原有顺序，避免同一机场的节点连续扎堆检测
+func interleaveNodesBySource(nodes []models.Node) []models.Node {
+	buckets := make(map[string][]models.Node)
+	order := make([]string, 0)
+	for _, n := range nodes {
+		source := sourceKey(n)
+		if _, ok := buckets[source]; !ok {
+			order = append(order, source)
+		}
+		buckets[source] = append(buckets[source], n)
+	}
+	if len(order) <= 1 {
+		return nodes
+	}
+
+	result := make([]models.Node, 0, len(nodes))
+	for len(result) < len(nodes) {
+		for _, source := range order {
+			if bucket := buckets[source]; len(bucket) > 0 {
+				result = append(result, bucket[0])
+				buckets[source] = bucket[1:]
+			}
+		}
+	}
+	return result
+}
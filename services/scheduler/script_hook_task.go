@@ -0,0 +1,274 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sublink/models"
+	"sublink/node"
+	"sublink/node/protocol"
+	"sublink/utils"
+)
+
+// scriptHookJobIDOffset 脚本 cron 钩子任务ID偏移量，用于区分机场任务和节点检测任务
+const scriptHookJobIDOffset = 2000000
+
+// scriptHookMaxOutput 记录到任务结果中的钩子返回值上限
+const scriptHookMaxOutput = 4 << 10
+
+// runScriptHook 执行脚本的钩子函数，并作为任务记录到 TaskManager
+// 返回钩子函数的返回值（JSON）；脚本未定义钩子函数或执行失败时 ok 为 false
+func runScriptHook(script models.Script, hook string, trigger models.TaskTrigger, args ...interface{}) (resultJSON []byte, ok bool) {
+	fnName := models.ScriptHookFunctions[hook]
+
+	tm := getTaskManager()
+	taskName := fmt.Sprintf("%s (%s)", script.Name, hook)
+	task, _, err := tm.CreateTask(models.TaskTypeScriptHook, taskName, trigger, 1)
+	var taskID string
+	if err != nil {
+		utils.Error("创建脚本钩子任务失败: %v，继续执行但不追踪任务", err)
+	} else {
+		taskID = task.ID
+	}
+
+	trace := &utils.ScriptTrace{}
+	resultJSON, err = utils.RunHookScript(script.Source(), fnName, trace, args...)
+	if err != nil {
+		msg := err.Error()
+		if errors.Is(err, utils.ErrScriptFunctionNotFound) {
+			msg = fmt.Sprintf("脚本启用了 %s 钩子但未定义 %s 函数", hook, fnName)
+		}
+		utils.Warn("脚本【%s】%s 钩子执行失败: %s", script.Name, hook, msg)
+		if taskID != "" {
+			tm.FailTask(taskID, msg)
+		}
+		return nil, false
+	}
+
+	if taskID != "" {
+		output := string(resultJSON)
+		if len(output) > scriptHookMaxOutput {
+			output = utils.TruncateUTF8(output, scriptHookMaxOutput) + "..."
+		}
+		tm.CompleteTask(taskID, fmt.Sprintf("执行完成，耗时 %dms", trace.Duration.Milliseconds()), map[string]interface{}{
+			"scriptId":   script.ID,
+			"hook":       hook,
+			"durationMs": trace.Duration.Milliseconds(),
+			"logs":       trace.Logs,
+			"output":     output,
+		})
+	}
+	return resultJSON, true
+}
+
+// RunAirportSyncHooks 依次执行启用了 airport_sync 钩子的脚本，trigger 为本次同步的触发类型
+// 脚本函数 onAirportSync(proxies, airport) 接收 Clash 格式的节点数组，返回要入库的节点数组
+// 单个脚本失败或返回值无效（包括未返回数组，如漏写 return）时跳过该脚本，沿用上一步的节点
+func RunAirportSyncHooks(airport *models.Airport, proxys []protocol.Proxy, trigger models.TaskTrigger) []protocol.Proxy {
+	scripts := models.ListScriptsByHook(models.ScriptHookAirportSync)
+	if len(scripts) == 0 {
+		return proxys
+	}
+	info := map[string]interface{}{
+		"id":    airport.ID,
+		"name":  airport.Name,
+		"group": airport.Group,
+	}
+
+	for _, script := range scripts {
+		proxiesJSON, err := node.ProxiesToScriptJSON(proxys)
+		if err != nil {
+			utils.Error("序列化机场【%s】节点失败: %v", airport.Name, err)
+			return proxys
+		}
+		var proxies interface{}
+		if err := json.Unmarshal(proxiesJSON, &proxies); err != nil {
+			utils.Error("序列化机场【%s】节点失败: %v", airport.Name, err)
+			return proxys
+		}
+		resultJSON, ok := runScriptHook(script, models.ScriptHookAirportSync, trigger, proxies, info)
+		if !ok {
+			continue
+		}
+		result, err := node.ProxiesFromScriptJSON(resultJSON)
+		if err != nil {
+			utils.Warn("脚本【%s】onAirportSync 返回值无效，已忽略: %v", script.Name, err)
+			continue
+		}
+		utils.Info("脚本【%s】处理机场【%s】节点：%d -> %d", script.Name, airport.Name, len(proxys), len(result))
+		proxys = result
+	}
+	return proxys
+}
+
+// nodeCheckHookResult onNodeCheck 返回数组的元素
+type nodeCheckHookResult struct {
+	ID         int      `json:"id"`
+	AddTags    []string `json:"addTags"`
+	RemoveTags []string `json:"removeTags"`
+	Score      *float64 `json:"score"`
+}
+
+// runNodeCheckHooks 依次执行启用了 node_check 钩子的脚本，trigger 为本次检测的触发类型
+// 脚本函数 onNodeCheck(nodes) 接收检测后的节点数组，返回 [{id, addTags, removeTags, score}]
+func runNodeCheckHooks(nodes []models.Node, trigger models.TaskTrigger) {
+	if len(nodes) == 0 {
+		return
+	}
+	scripts := models.ListScriptsByHook(models.ScriptHookNodeCheck)
+	if len(scripts) == 0 {
+		return
+	}
+
+	checked := make(map[int]bool, len(nodes))
+	for _, n := range nodes {
+		checked[n.ID] = true
+	}
+
+	for _, script := range scripts {
+		nodesJSON, err := json.Marshal(nodes)
+		if err != nil {
+			utils.Error("序列化节点失败: %v", err)
+			return
+		}
+		var data interface{}
+		if err := json.Unmarshal(nodesJSON, &data); err != nil {
+			utils.Error("序列化节点失败: %v", err)
+			return
+		}
+		resultJSON, ok := runScriptHook(script, models.ScriptHookNodeCheck, trigger, data)
+		if !ok || string(resultJSON) == "null" {
+			continue
+		}
+		var results []nodeCheckHookResult
+		if err := json.Unmarshal(resultJSON, &results); err != nil {
+			utils.Warn("脚本【%s】onNodeCheck 返回值无效，已忽略: %v", script.Name, err)
+			continue
+		}
+		applyNodeCheckHookResults(script.Name, results, checked)
+	}
+}
+
+// applyNodeCheckHookResults 应用 onNodeCheck 返回的标签与评分，仅处理本次检测的节点和已存在的标签
+func applyNodeCheckHookResults(scriptName string, results []nodeCheckHookResult, checked map[int]bool) {
+	addTags := make(map[string][]int)
+	removeTags := make(map[string][]int)
+	scores := make(map[int]float64)
+	for _, r := range results {
+		if !checked[r.ID] {
+			continue
+		}
+		for _, t := range r.AddTags {
+			addTags[t] = append(addTags[t], r.ID)
+		}
+		for _, t := range r.RemoveTags {
+			removeTags[t] = append(removeTags[t], r.ID)
+		}
+		if r.Score != nil {
+			scores[r.ID] = *r.Score
+		}
+	}
+
+	tagExists := func(name string) bool {
+		var tag models.Tag
+		if err := tag.GetByName(name); err != nil {
+			utils.Warn("脚本【%s】使用了不存在的标签【%s】，已忽略", scriptName, name)
+			return false
+		}
+		return true
+	}
+	for name, ids := range addTags {
+		if tagExists(name) {
+			if err := models.BatchAddTagToNodes(ids, name); err != nil {
+				utils.Error("脚本【%s】批量打标签失败: %v", scriptName, err)
+			}
+		}
+	}
+	for name, ids := range removeTags {
+		if tagExists(name) {
+			if err := models.BatchRemoveTagFromNodes(ids, name); err != nil {
+				utils.Error("脚本【%s】批量移除标签失败: %v", scriptName, err)
+			}
+		}
+	}
+	if len(scores) > 0 {
+		if err := models.UpdateNodeScriptScores(scores); err != nil {
+			utils.Error("脚本【%s】更新节点评分失败: %v", scriptName, err)
+		}
+	}
+}
+
+// ExecuteScriptCronHook 执行脚本的 cron 钩子 onCron()
+func ExecuteScriptCronHook(scriptID int, trigger models.TaskTrigger) {
+	script, err := models.GetScriptByID(scriptID)
+	if err != nil {
+		utils.Error("获取脚本失败 - ID: %d, Error: %v", scriptID, err)
+		return
+	}
+	runScriptHook(*script, models.ScriptHookCron, trigger)
+}
+
+// AddScriptHookJob 添加脚本 cron 钩子定时任务
+func (sm *SchedulerManager) AddScriptHookJob(scriptID int, cronExpr string) error {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	jobID := scriptHookJobIDOffset + scriptID
+
+	cleanCronExpr := cleanCronExpression(cronExpr)
+	if cleanCronExpr == "" {
+		return nil
+	}
+
+	// 如果任务已存在，先删除
+	if entryID, exists := sm.jobs[jobID]; exists {
+		sm.cron.Remove(entryID)
+		delete(sm.jobs, jobID)
+	}
+
+	entryID, err := sm.cron.AddFunc(cleanCronExpr, func() {
+		ExecuteScriptCronHook(scriptID, models.TaskTriggerScheduled)
+	})
+	if err != nil {
+		utils.Error("添加脚本定时任务失败 - ScriptID: %d, Cron: %s, Error: %v", scriptID, cleanCronExpr, err)
+		return err
+	}
+	sm.jobs[jobID] = entryID
+
+	utils.Info("成功添加脚本定时任务 - ScriptID: %d, Cron: %s, 下次运行: %v", scriptID, cleanCronExpr, sm.getNextRunTime(cleanCronExpr))
+	return nil
+}
+
+// RemoveScriptHookJob 删除脚本 cron 钩子定时任务
+func (sm *SchedulerManager) RemoveScriptHookJob(scriptID int) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	jobID := scriptHookJobIDOffset + scriptID
+	if entryID, exists := sm.jobs[jobID]; exists {
+		sm.cron.Remove(entryID)
+		delete(sm.jobs, jobID)
+		utils.Info("成功删除脚本定时任务 - ScriptID: %d", scriptID)
+	}
+}
+
+// UpdateScriptHookJob 按脚本的钩子配置更新 cron 钩子定时任务
+func (sm *SchedulerManager) UpdateScriptHookJob(script *models.Script) error {
+	sm.RemoveScriptHookJob(script.ID)
+	if script.HasHook(models.ScriptHookCron) && script.CronExpr != "" {
+		return sm.AddScriptHookJob(script.ID, script.CronExpr)
+	}
+	return nil
+}
+
+// loadScriptHookJobs 加载所有启用了 cron 钩子的脚本
+func (sm *SchedulerManager) loadScriptHookJobs() {
+	for _, script := range models.ListScriptsByHook(models.ScriptHookCron) {
+		if script.CronExpr == "" {
+			continue
+		}
+		if err := sm.AddScriptHookJob(script.ID, script.CronExpr); err != nil {
+			utils.Error("添加脚本定时任务失败 - ID: %d, Error: %v", script.ID, err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"fmt"
+	"sublink/models"
+	"sublink/services/sse"
+	"sublink/utils"
+)
+
+// StartScriptUpdateCheckTask 启动远程脚本更新检查任务
+// 每6小时检查一次从地址安装的脚本是否有新版本
+func (sm *SchedulerManager) StartScriptUpdateCheckTask() error {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	const scriptUpdateCheckCron = "23 */6 * * *" // 每6小时执行一次
+
+	// 如果任务已存在，先删除
+	if entryID, exists := sm.jobs[JobIDScriptUpdateCheck]; exists {
+		sm.cron.Remove(entryID)
+		delete(sm.jobs, JobIDScriptUpdateCheck)
+	}
+
+	entryID, err := sm.cron.AddFunc(scriptUpdateCheckCron, func() {
+		ExecuteScriptUpdateCheck()
+	})
+	if err != nil {
+		utils.Error("添加脚本更新检查任务失败 - Cron: %s, Error: %v", scriptUpdateCheckCron, err)
+		return err
+	}
+
+	sm.jobs[JobIDScriptUpdateCheck] = entryID
+	utils.Info("成功添加脚本更新检查任务 - Cron: %s", scriptUpdateCheckCron)
+	return nil
+}
+
+// ExecuteScriptUpdateCheck 检查所有从地址安装的脚本，发现新版本时通知（不会自动升级）
+func ExecuteScriptUpdateCheck() {
+	for _, script := range models.ListScriptsWithSource() {
+		previous := script.PendingVersion
+		available, err := script.CheckSourceUpdate()
+		if err != nil {
+			utils.Warn("检查脚本【%s】更新失败: %v", script.Name, err)
+			continue
+		}
+		// 同一新版本只通知一次
+		if !available || script.PendingVersion == previous {
+			continue
+		}
+		utils.Info("脚本【%s】有新版本: %s -> %s", script.Name, script.Version, script.PendingVersion)
+		sse.GetSSEBroker().BroadcastEvent("script_update", sse.NotificationPayload{
+			Event:   "script_update",
+			Title:   "脚本有新版本",
+			Message: fmt.Sprintf("脚本【%s】有新版本 %s（当前 %s），请查看差异后升级", script.Name, script.PendingVersion, script.Version),
+			Data: map[string]interface{}{
+				"id":             script.ID,
+				"name":           script.Name,
+				"version":        script.Version,
+				"pendingVersion": script.PendingVersion,
+			},
+		})
+	}
+}
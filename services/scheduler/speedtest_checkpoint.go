@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"fmt"
+	"sublink/models"
+	"sublink/utils"
+	"time"
+)
+
+// checkpointFlushSize 测速结果累计多少条后写入数据库并更新断点
+const checkpointFlushSize = 100
+
+// ResumeNodeCheckTask 从断点继续执行中断的节点检测任务
+// 仅检测原任务中尚未完成的节点，使用原策略的最新配置
+func ResumeNodeCheckTask(taskID string) error {
+	summary, err := models.GetInterruptedNodeCheck(taskID)
+	if err != nil {
+		return fmt.Errorf("断点记录不存在")
+	}
+
+	profile, err := models.GetNodeCheckProfileByID(summary.ProfileID)
+	if err != nil {
+		return fmt.Errorf("检测策略已不存在，无法续测")
+	}
+
+	pendingIDs, err := models.GetPendingCheckpointNodeIDs(taskID)
+	if err != nil {
+		return fmt.Errorf("读取断点记录失败: %v", err)
+	}
+
+	// 已删除的节点直接跳过
+	nodes, err := models.GetNodesByIDs(pendingIDs)
+	if err != nil {
+		return fmt.Errorf("获取待检测节点失败: %v", err)
+	}
+
+	// 新任务会写入自己的断点记录，旧记录不再需要
+	if err := models.DeleteNodeCheckCheckpoints(taskID); err != nil {
+		utils.Warn("清理旧断点记录失败: %v", err)
+	}
+
+	if len(nodes) == 0 {
+		utils.Info("中断任务 %s 没有剩余可检测的节点", taskID)
+		return nil
+	}
+
+	utils.Info("从断点继续检测: 原任务 %s, 策略 %s, 已完成 %d/%d, 剩余 %d 个节点",
+		taskID, profile.Name, summary.Completed, summary.Total, len(nodes))
+
+	go func() {
+		RunSpeedTestWithConfig(nodes, models.TaskTriggerResume, profile.Name, SpeedTestConfigFromProfile(profile))
+		now := time.Now()
+		if err := profile.UpdateLastRunTime(&now); err != nil {
+			utils.Warn("更新策略执行时间失败: %v", err)
+		}
+	}()
+	return nil
+}
+
+// filterIncrementalNodes 增量检测节点筛选
+// 保留以下节点：从未检测过、上次检测时间早于 IncrementalMinutes 分钟、策略上次执行后新增
+func filterIncrementalNodes(nodes []models.Node, profile *models.NodeCheckProfile) []models.Node {
+	minutes := profile.IncrementalMinutes
+	if minutes <= 0 {
+		minutes = 60
+	}
+	staleBefore := time.Now().Add(-time.Duration(minutes) * time.Minute)
+
+	result := make([]models.Node, 0, len(nodes))
+	for _, n := range nodes {
+		if profile.LastRunTime != nil && n.CreatedAt.After(*profile.LastRunTime) {
+			result = append(result, n)
+			continue
+		}
+		lastCheck, ok := nodeLastCheckTime(n)
+		if !ok || lastCheck.Before(staleBefore) {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// nodeLastCheckTime 获取节点最近一次检测时间（延迟或速度检测中较晚者）
+func nodeLastCheckTime(n models.Node) (time.Time, bool) {
+	var latest time.Time
+	found := false
+	for _, s := range []string{n.LatencyCheckAt, n.SpeedCheckAt} {
+		if s == "" {
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.Local)
+		if err != nil {
+			continue
+		}
+		if !found || t.After(latest) {
+			latest = t
+			found = true
+		}
+	}
+	return latest, found
+}
@@ -8,6 +8,9 @@ import (
 // SpeedTestConfig 测速任务配置（隔离各任务参数，避免并发覆盖）
 // 每个检测任务拥有独立的配置实例，完全避免共享状态
 type SpeedTestConfig struct {
+	// 所属策略（用于断点续测）
+	ProfileID int // 检测策略ID
+
 	// 测速URL配置
 	SpeedTestURL   string        // 速度测试URL
 	LatencyTestURL string        // 延迟测试URL
@@ -32,6 +35,11 @@ type SpeedTestConfig struct {
 	TrafficByGroup  bool // 按分组统计流量
 	TrafficBySource bool // 按来源统计流量
 	TrafficByNode   bool // 按节点统计流量
+
+	// 礼貌限制（并发限制作用于延迟与速度测试阶段，流量限制作用于速度测试阶段）
+	SourceSpeedConcurrency int // 每个来源(机场)的最大检测并发(0=不限)
+	HostSpeedConcurrency   int // 每个服务器地址的最大检测并发(0=不限)
+	SourceTrafficLimitMB   int // 每个来源单次检测的流量上限(MB，0=不限)
 }
 
 // SpeedTestConfigFromProfile 从策略构建配置（并发安全）
@@ -68,6 +76,7 @@ func SpeedTestConfigFromProfile(profile *models.NodeCheckProfile) *SpeedTestConf
 	}
 
 	return &SpeedTestConfig{
+		ProfileID:          profile.ID,
 		SpeedTestURL:       profile.TestURL,
 		LatencyTestURL:     latencyURL,
 		Timeout:            timeout,
@@ -83,5 +92,9 @@ func SpeedTestConfigFromProfile(profile *models.NodeCheckProfile) *SpeedTestConf
 		TrafficByGroup:     profile.TrafficByGroup,
 		TrafficBySource:    profile.TrafficBySource,
 		TrafficByNode:      profile.TrafficByNode,
+
+		SourceSpeedConcurrency: profile.SourceSpeedConcurrency,
+		HostSpeedConcurrency:   profile.HostSpeedConcurrency,
+		SourceTrafficLimitMB:   profile.SourceTrafficLimitMB,
 	}
 }
@@ -5,7 +5,6 @@ import (
 	"sublink/constants"
 	"sublink/models"
 	"sublink/node"
-	"sublink/services/geoip"
 	"sublink/services/mihomo"
 	"sublink/services/sse"
 	"sublink/utils"
@@ -23,6 +22,10 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 		return
 	}
 
+	// 按来源交错排列，避免同一机场的节点集中检测
+	nodes = interleaveNodesBySource(nodes)
+	politeness := newCheckPoliteness(config)
+
 	totalNodes := len(nodes)
 	utils.Info("开始执行节点检测，总节点数: %d, 触发类型: %s, 策略: %s", totalNodes, trigger, profileName)
 
@@ -49,6 +52,18 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 		return
 	}
 
+	// 写入断点记录，服务中断后可从未完成的节点继续检测
+	// 续测依赖原策略的配置，未关联策略的检测（手动选择节点等）不写断点
+	if config.ProfileID > 0 {
+		checkpointNodeIDs := make([]int, 0, len(nodes))
+		for _, n := range nodes {
+			checkpointNodeIDs = append(checkpointNodeIDs, n.ID)
+		}
+		if err := models.CreateNodeCheckCheckpoints(taskID, config.ProfileID, profileName, checkpointNodeIDs); err != nil {
+			utils.Warn("写入检测断点记录失败: %v", err)
+		}
+	}
+
 	// 从配置对象读取参数（并发安全，不再访问全局Settings）
 	speedTestUrl := config.SpeedTestURL
 	latencyTestUrl := config.LatencyTestURL
@@ -131,7 +146,7 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 	}
 
 	// 结果统计
-	var successCount, failCount int32
+	var successCount, failCount, skippedCount int32
 	var completedCount int32
 	var cancelled bool
 	var mu sync.Mutex
@@ -164,8 +179,52 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 	}
 	nodeResults := make([]nodeResult, len(nodes))
 
-	// 批量收集：测速结果列表（任务完成后批量写入数据库）
-	speedTestResults := make([]models.SpeedTestResult, 0, len(nodes))
+	// 批量收集：测速结果列表（每累计 checkpointFlushSize 条取出一批写入数据库并更新断点）
+	// 收集时需持有 mu；写入数据库在释放 mu 后进行，避免阻塞其他节点的检测
+	speedTestResults := make([]models.SpeedTestResult, 0, checkpointFlushSize)
+	var lifecycleEvents []models.NodeLifecycleEvent // 本次检测产生的节点生命周期状态变更
+	var flushMu sync.Mutex                          // 串行写入各批结果，同时保护 lifecycleEvents
+	// takeSpeedTestResults 取出待写入的结果，all 为 false 时未满一批不取出；调用方需持有 mu
+	takeSpeedTestResults := func(all bool) []models.SpeedTestResult {
+		if len(speedTestResults) == 0 || (!all && len(speedTestResults) < checkpointFlushSize) {
+			return nil
+		}
+		batch := speedTestResults
+		speedTestResults = make([]models.SpeedTestResult, 0, checkpointFlushSize)
+		return batch
+	}
+	// writeSpeedTestResults 写入一批结果并更新断点，调用方不能持有 mu
+	writeSpeedTestResults := func(batch []models.SpeedTestResult) {
+		if len(batch) == 0 {
+			return
+		}
+		flushMu.Lock()
+		defer flushMu.Unlock()
+		if err := models.BatchUpdateSpeedResults(batch); err != nil {
+			utils.Error("批量更新测速结果失败: %v", err)
+			return
+		}
+		doneIDs := make([]int, 0, len(batch))
+		for _, r := range batch {
+			doneIDs = append(doneIDs, r.NodeID)
+		}
+		if err := models.MarkNodeCheckCheckpointsDone(taskID, doneIDs); err != nil {
+			utils.Warn("更新检测断点记录失败: %v", err)
+		}
+		lifecycleEvents = append(lifecycleEvents, models.ApplyNodeCheckResults(batch)...)
+	}
+	// recordSpeedTestResult 收集一条结果，满一批时返回需要写入的结果；调用方需持有 mu
+	recordSpeedTestResult := func(r models.SpeedTestResult) []models.SpeedTestResult {
+		speedTestResults = append(speedTestResults, r)
+		return takeSpeedTestResults(false)
+	}
+	// flushSpeedTestResults 写入剩余的全部结果，任务结束或取消时调用
+	flushSpeedTestResults := func() {
+		mu.Lock()
+		batch := takeSpeedTestResults(true)
+		mu.Unlock()
+		writeSpeedTestResults(batch)
+	}
 
 	// 批量收集：Host映射信息（测速成功时收集，任务完成后批量保存）
 	hostMappings := make([]models.HostMappingInfo, 0)
@@ -181,7 +240,12 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 	}
 	var latencyWg sync.WaitGroup
 
-	for i, node := range nodes {
+	// 延迟测试同样受来源/服务器并发限制，避免集中连接同一机场
+	latencyPending := make([]int, len(nodes))
+	for i := range nodes {
+		latencyPending[i] = i
+	}
+	for len(latencyPending) > 0 {
 		// 检查任务是否被取消
 		select {
 		case <-ctx.Done():
@@ -197,6 +261,18 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 			break
 		}
 
+		// 按来源/服务器限制选出下一个可测节点（未配置限制时按顺序取）
+		pos, _, ok := politeness.acquireNext(ctx, len(latencyPending), func(i int) models.Node { return nodes[latencyPending[i]] })
+		if !ok {
+			mu.Lock()
+			cancelled = true
+			mu.Unlock()
+			break
+		}
+		i := latencyPending[pos]
+		node := nodes[i]
+		latencyPending = append(latencyPending[:pos], latencyPending[pos+1:]...)
+
 		latencyWg.Add(1)
 
 		// 根据是否使用动态并发选择不同的获取方式
@@ -216,6 +292,7 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 					<-latencySem
 				}
 			}()
+			defer politeness.release(n, 0)
 
 			// 在 goroutine 内再次检查取消状态
 			select {
@@ -228,7 +305,12 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 			// TCP模式下需要检测IP（因为没有速度测试阶段），mihomo模式在速度阶段检测
 			detectIPInLatency := detectCountry && speedTestMode == "tcp"
 			latency, landingIP, err := mihomo.MihomoDelayTest(n.Link, latencyTestUrl, speedTestTimeout, includeHandshake, detectIPInLatency, landingIPUrl)
+			// 落地IP地理信息可能需要请求在线接口，在加锁前完成
+			landingInfo := lookupLandingIPInfo(landingIP)
 
+			// 满一批的结果在释放 mu 后写入
+			var batch []models.SpeedTestResult
+			defer func() { writeSpeedTestResults(batch) }()
 			mu.Lock()
 			defer mu.Unlock()
 
@@ -273,12 +355,7 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 
 					// TCP模式下处理落地IP检测结果
 					if landingIP != "" {
-						n.LandingIP = landingIP
-						countryCode, geoErr := geoip.GetCountryISOCode(landingIP)
-						if geoErr == nil && countryCode != "" {
-							n.LinkCountry = countryCode
-							utils.Debug("节点 [%s] 落地IP: %s, 国家: %s", n.Name, landingIP, countryCode)
-						}
+						applyLandingIPInfo(&n, landingIP, landingInfo)
 					}
 
 					// 持久化Host：测速成功时从 link 解析服务器地址并解析DNS
@@ -303,7 +380,7 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 				}
 				n.LatencyCheckAt = time.Now().Format("2006-01-02 15:04:05")
 				// 收集结果到批量更新列表（不再立即写数据库）
-				speedTestResults = append(speedTestResults, models.SpeedTestResult{
+				batch = recordSpeedTestResult(models.SpeedTestResult{
 					NodeID:         n.ID,
 					Speed:          n.Speed,
 					SpeedStatus:    n.SpeedStatus,
@@ -313,6 +390,10 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 					SpeedCheckAt:   "",
 					LinkCountry:    n.LinkCountry,
 					LandingIP:      n.LandingIP,
+					LandingASN:     n.LandingASN,
+					LandingISP:     n.LandingISP,
+					LandingIPType:  n.LandingIPType,
+					LandingCity:    n.LandingCity,
 				})
 			}
 
@@ -352,6 +433,8 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 		utils.Info("任务被取消，跳过阶段二 (已完成: %d/%d)", completedCount, totalNodes)
 		tm.UpdateProgress(taskID, int(completedCount), "已取消", nil)
 		// 任务已被 CancelTask 标记为取消，无需再次更新
+		// 先写入已完成的检测结果，再清理断点记录
+		flushSpeedTestResults()
 		goto applyTags
 	}
 
@@ -369,7 +452,44 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 		}
 		var speedWg sync.WaitGroup
 
+		// 延迟测试失败的节点直接记录结果，其余节点进入待测列表
+		pending := make([]*nodeResult, 0, len(nodeResults))
 		for i := range nodeResults {
+			nr := &nodeResults[i]
+			// 跳过延迟测试失败的节点
+			if nr.err != nil {
+				mu.Lock()
+				failCount++
+				completedCount++
+				nr.node.Speed = -1
+				nr.node.SpeedStatus = constants.StatusError // 因延迟失败无法测速
+				nr.node.DelayTime = -1
+				nr.node.DelayStatus = constants.StatusTimeout
+				nr.node.LatencyCheckAt = time.Now().Format("2006-01-02 15:04:05")
+				// 收集结果到批量更新列表（不再立即写数据库）
+				batch := recordSpeedTestResult(models.SpeedTestResult{
+					NodeID:         nr.node.ID,
+					Speed:          nr.node.Speed,
+					SpeedStatus:    nr.node.SpeedStatus,
+					DelayTime:      nr.node.DelayTime,
+					DelayStatus:    nr.node.DelayStatus,
+					LatencyCheckAt: nr.node.LatencyCheckAt,
+					SpeedCheckAt:   "",
+					LinkCountry:    nr.node.LinkCountry,
+					LandingIP:      nr.node.LandingIP,
+					LandingASN:     nr.node.LandingASN,
+					LandingISP:     nr.node.LandingISP,
+					LandingIPType:  nr.node.LandingIPType,
+					LandingCity:    nr.node.LandingCity,
+				})
+				mu.Unlock()
+				writeSpeedTestResults(batch)
+				continue
+			}
+			pending = append(pending, nr)
+		}
+
+		for len(pending) > 0 {
 			// 检查任务是否被取消
 			select {
 			case <-ctx.Done():
@@ -385,30 +505,52 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 				break
 			}
 
-			nr := &nodeResults[i]
-			// 跳过延迟测试失败的节点
-			if nr.err != nil {
+			// 按来源/服务器限制选出下一个可测节点（未配置限制时按顺序取）
+			pos, exhausted, ok := politeness.acquireNext(ctx, len(pending), func(i int) models.Node { return pending[i].node })
+			if !ok {
 				mu.Lock()
-				failCount++
-				completedCount++
-				nr.node.Speed = -1
-				nr.node.SpeedStatus = constants.StatusError // 因延迟失败无法测速
-				nr.node.DelayTime = -1
-				nr.node.DelayStatus = constants.StatusTimeout
+				cancelled = true
+				mu.Unlock()
+				break
+			}
+			nr := pending[pos]
+			pending = append(pending[:pos], pending[pos+1:]...)
+
+			// 来源流量预算已用尽：跳过下载测速，记录本次的延迟结果，速度保留原有结果
+			if exhausted {
+				atomic.AddInt32(&skippedCount, 1)
+				currentCompleted := int(atomic.AddInt32(&completedCount, 1))
+				mu.Lock()
+				nr.node.DelayTime = nr.latency
+				nr.node.DelayStatus = constants.StatusSuccess
+				// 本次未测速，原有的失败结果不再作为本次检测失败计入（同仅测延迟模式）
+				if nr.node.SpeedStatus != constants.StatusSuccess {
+					nr.node.SpeedStatus = constants.StatusUntested
+				}
 				nr.node.LatencyCheckAt = time.Now().Format("2006-01-02 15:04:05")
-				// 收集结果到批量更新列表（不再立即写数据库）
-				speedTestResults = append(speedTestResults, models.SpeedTestResult{
+				batch := recordSpeedTestResult(models.SpeedTestResult{
 					NodeID:         nr.node.ID,
 					Speed:          nr.node.Speed,
 					SpeedStatus:    nr.node.SpeedStatus,
 					DelayTime:      nr.node.DelayTime,
 					DelayStatus:    nr.node.DelayStatus,
 					LatencyCheckAt: nr.node.LatencyCheckAt,
-					SpeedCheckAt:   "",
+					SpeedCheckAt:   nr.node.SpeedCheckAt,
 					LinkCountry:    nr.node.LinkCountry,
 					LandingIP:      nr.node.LandingIP,
+					LandingASN:     nr.node.LandingASN,
+					LandingISP:     nr.node.LandingISP,
+					LandingIPType:  nr.node.LandingIPType,
+					LandingCity:    nr.node.LandingCity,
 				})
 				mu.Unlock()
+				writeSpeedTestResults(batch)
+				tm.UpdateProgress(taskID, totalNodes+currentCompleted, formatNodeDisplayItem(nr.node.Name, nr.node.Group, nr.node.Source), map[string]interface{}{
+					"status":  "skipped",
+					"phase":   "speed",
+					"latency": nr.latency,
+					"reason":  "来源流量预算已用尽",
+				})
 				continue
 			}
 
@@ -423,6 +565,7 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 			}
 
 			go func(result *nodeResult) {
+				var bytesUsed int64
 				defer speedWg.Done()
 				defer func() {
 					if useAdaptiveSpeed && speedController != nil {
@@ -431,6 +574,9 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 						<-speedSem
 					}
 				}()
+				defer func() {
+					politeness.release(result.node, bytesUsed)
+				}()
 
 				// 在 goroutine 内检查取消状态
 				select {
@@ -441,7 +587,16 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 
 				// 速度测试（延迟已在阶段一获取，同时可选检测落地IP）
 				speed, _, bytesDownloaded, landingIP, err := mihomo.MihomoSpeedTest(result.node.Link, speedTestUrl, speedTestTimeout, detectCountry, landingIPUrl, speedRecordMode, peakSampleInterval)
+				bytesUsed = bytesDownloaded
+				// 落地IP地理信息可能需要请求在线接口，在加锁前完成
+				var landingInfo *models.IPInfo
+				if err == nil {
+					landingInfo = lookupLandingIPInfo(landingIP)
+				}
 
+				// 满一批的结果在释放 mu 后写入
+				var batch []models.SpeedTestResult
+				defer func() { writeSpeedTestResults(batch) }()
 				mu.Lock()
 				defer mu.Unlock()
 
@@ -526,12 +681,7 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 
 					// 处理落地IP检测结果（已由MihomoSpeedTest内部完成）
 					if landingIP != "" {
-						result.node.LandingIP = landingIP
-						countryCode, geoErr := geoip.GetCountryISOCode(landingIP)
-						if geoErr == nil && countryCode != "" {
-							result.node.LinkCountry = countryCode
-							utils.Debug("节点 [%s] 落地IP: %s, 国家: %s", result.node.Name, landingIP, countryCode)
-						}
+						applyLandingIPInfo(&result.node, landingIP, landingInfo)
 					}
 
 					// 持久化Host：测速成功时从 link 解析服务器地址并解析DNS
@@ -558,7 +708,7 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 				result.node.LatencyCheckAt = time.Now().Format("2006-01-02 15:04:05")
 				result.node.SpeedCheckAt = time.Now().Format("2006-01-02 15:04:05")
 				// 收集结果到批量更新列表（不再立即写数据库）
-				speedTestResults = append(speedTestResults, models.SpeedTestResult{
+				batch = recordSpeedTestResult(models.SpeedTestResult{
 					NodeID:         result.node.ID,
 					Speed:          result.node.Speed,
 					SpeedStatus:    result.node.SpeedStatus,
@@ -568,6 +718,10 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 					SpeedCheckAt:   result.node.SpeedCheckAt,
 					LinkCountry:    result.node.LinkCountry,
 					LandingIP:      result.node.LandingIP,
+					LandingASN:     result.node.LandingASN,
+					LandingISP:     result.node.LandingISP,
+					LandingIPType:  result.node.LandingIPType,
+					LandingCity:    result.node.LandingCity,
 				})
 
 				// 获取当前流量统计（用于实时显示）
@@ -599,17 +753,12 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 	// 检查最终是否被取消
 	if cancelled || ctx.Err() != nil {
 		utils.Info("任务被取消")
+		flushSpeedTestResults()
 		goto applyTags
 	}
 
-	// 批量写入所有测速结果到数据库（一次性操作，减少数据库I/O）
-	if len(speedTestResults) > 0 {
-		if err := models.BatchUpdateSpeedResults(speedTestResults); err != nil {
-			utils.Error("批量更新测速结果失败: %v", err)
-		} else {
-			utils.Debug("批量更新测速结果成功，共 %d 条记录", len(speedTestResults))
-		}
-	}
+	// 批量写入剩余的测速结果到数据库
+	flushSpeedTestResults()
 
 	// 批量保存Host映射到数据库（如果开启了持久化）
 	if persistHost && len(hostMappings) > 0 {
@@ -681,6 +830,10 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 			"total":   totalNodes,
 			"traffic": trafficData,
 		}
+		if skippedCount > 0 {
+			resultData["skipped"] = skippedCount
+			utils.Info("测速任务中有 %d 个节点因来源流量预算用尽被跳过", skippedCount)
+		}
 		utils.Info("测速任务完成 - 总计: %d, 成功: %d, 失败: %d, 流量: %s", totalNodes, successCount, failCount, formatBytes(trafficTotal))
 		tm.CompleteTask(taskID, fmt.Sprintf("测速完成 (成功: %d, 失败: %d, 流量: %s)", successCount, failCount, formatBytes(trafficTotal)), resultData)
 
@@ -693,6 +846,7 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 				"status":  "success",
 				"success": successCount,
 				"fail":    failCount,
+				"skipped": skippedCount,
 				"total":   totalNodes,
 				"traffic": formatBytes(trafficTotal),
 			},
@@ -700,6 +854,14 @@ func RunSpeedTestWithConfig(nodes []models.Node, trigger models.TaskTrigger, pro
 	}
 
 applyTags:
+	// 任务已正常结束或被用户取消，不再需要续测
+	if err := models.DeleteNodeCheckCheckpoints(taskID); err != nil {
+		utils.Warn("清理检测断点记录失败: %v", err)
+	}
+
+	// 通知本次检测引起的节点生命周期变更（隔离/恢复）
+	notifyNodeLifecycleEvents(lifecycleEvents)
+
 	// 应用自动标签规则 - 测速完成后触发
 	// 重新获取已测速节点的最新数据（包含更新后的速度/延迟值）
 	go func() {
@@ -713,11 +875,29 @@ applyTags:
 		updatedNodes, err := models.GetNodesByIDs(testedNodeIDs)
 		if err != nil {
 			utils.Warn("获取测速节点最新数据失败: %v, 使用原始数据", err)
-			applyAutoTagRules(nodes, "speed_test")
-			return
+			updatedNodes = nodes
 		}
 
 		applyAutoTagRules(updatedNodes, "speed_test")
+
+		// 落地IP发生变化的节点（此前已有落地IP）
+		previousIPs := make(map[int]string, len(nodes))
+		for _, n := range nodes {
+			previousIPs[n.ID] = n.LandingIP
+		}
+		ipChangedNodes := make([]models.Node, 0)
+		for _, n := range updatedNodes {
+			if prev := previousIPs[n.ID]; prev != "" && n.LandingIP != "" && n.LandingIP != prev {
+				ipChangedNodes = append(ipChangedNodes, n)
+			}
+		}
+		if len(ipChangedNodes) > 0 {
+			utils.Info("检测到 %d 个节点落地IP变化", len(ipChangedNodes))
+			applyAutoTagRules(ipChangedNodes, models.TagTriggerLandingIPChange)
+		}
+
+		// 执行节点检测钩子脚本（自定义标签与评分）
+		runNodeCheckHooks(updatedNodes, trigger)
 	}()
 
 	// 测速完成后后台静默刷新机场用量信息（测速会消耗流量）
@@ -782,11 +962,34 @@ func ExecuteNodeCheckWithProfile(profileID int, nodeIDs []int) {
 		}
 	}
 
+	// 按策略的条件表达式进一步筛选
+	if len(nodeIDs) == 0 {
+		nodes, err = profile.FilterNodes(nodes)
+		if err != nil {
+			utils.Error("策略条件表达式无效: %v", err)
+			return
+		}
+	}
+
 	if len(nodes) == 0 {
 		utils.Warn("没有符合条件的节点")
 		return
 	}
 
+	// 增量检测：按策略范围执行时，仅保留检测结果已过期或上次执行后新增的节点
+	if len(nodeIDs) == 0 && profile.Incremental {
+		totalInScope := len(nodes)
+		nodes = filterIncrementalNodes(nodes, profile)
+		utils.Info("增量检测：范围内节点 %d 个，需要检测 %d 个", totalInScope, len(nodes))
+		if len(nodes) == 0 {
+			now := time.Now()
+			if err := profile.UpdateLastRunTime(&now); err != nil {
+				utils.Warn("更新策略执行时间失败: %v", err)
+			}
+			return
+		}
+	}
+
 	// 从策略构建独立的配置对象（并发安全，完全避免全局状态共享）
 	config := SpeedTestConfigFromProfile(profile)
 
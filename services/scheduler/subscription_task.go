@@ -47,7 +47,23 @@ func ExecuteSubscriptionTaskWithTrigger(id int, url string, subName string, trig
 		reporter = NewTaskManagerReporter(tm, task.ID)
 	}
 
-	usageInfo, err := node.LoadClashConfigFromURLWithReporter(id, url, subName, downloadWithProxy, proxyLink, userAgent, reporter, fetchUsageInfo, skipTLSVerify)
+	var usageInfo *node.UsageInfo
+	unchanged := false
+	panelUsage := false
+	if airport != nil && airport.HasPanel() {
+		// 先登录面板同步套餐与流量，面板重置订阅地址时自动更新主地址；失败不影响本次拉取
+		if info, panelErr := node.SyncAirportPanel(airport); panelErr != nil {
+			utils.Warn("同步机场【%s】面板信息失败: %v", subName, panelErr)
+		} else {
+			panelUsage = info.HasUsage
+		}
+	}
+	if airport != nil {
+		// 按机场配置的主地址与备用地址拉取，定时任务使用条件拉取，手动触发强制完整处理
+		usageInfo, unchanged, err = node.LoadAirportSubscriptionWithReporter(airport, reporter, trigger)
+	} else {
+		usageInfo, err = node.LoadClashConfigFromURLWithReporter(id, url, subName, downloadWithProxy, proxyLink, userAgent, reporter, fetchUsageInfo, skipTLSVerify, trigger)
+	}
 	if err != nil {
 		// 仅在失败时发送通知，成功通知由 node/sub.go 中的 scheduleClashToNodeLinks 发送
 		// 这样可以避免重复通知，且成功通知包含更详细的节点统计信息
@@ -67,15 +83,20 @@ func ExecuteSubscriptionTaskWithTrigger(id int, url string, subName string, trig
 		return
 	}
 
-	// 更新用量信息（如果开启了获取用量信息且成功获取到）
-	if fetchUsageInfo && usageInfo != nil && airport != nil {
-		if updateErr := airport.UpdateUsageInfo(usageInfo.Upload, usageInfo.Download, usageInfo.Total, usageInfo.Expire); updateErr != nil {
+	// 更新用量信息（如果开启了获取用量信息且成功获取到），面板已提供用量时以面板为准
+	if fetchUsageInfo && usageInfo != nil && airport != nil && !panelUsage {
+		if updateErr := node.SaveAirportUsageInfo(airport, usageInfo); updateErr != nil {
 			utils.Warn("更新机场用量信息失败 ID: %d: %v", id, updateErr)
 		} else {
 			utils.Info("成功更新机场 [%s] 用量信息", subName)
 		}
 	}
 
+	// 内容未变化时节点没有改动，无需重新应用标签规则
+	if unchanged {
+		return
+	}
+
 	// 订阅更新成功后，应用自动标签规则
 	go func() {
 		updatedNodes, err := models.ListBySourceID(id)
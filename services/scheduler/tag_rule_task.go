@@ -0,0 +1,114 @@
+package scheduler
+
+import (
+	"sublink/models"
+	"sublink/utils"
+)
+
+// tagRuleJobIDOffset 标签规则 cron 任务ID偏移量，用于区分机场任务、节点检测任务和脚本任务
+const tagRuleJobIDOffset = 3000000
+
+// AddTagRuleJob 添加标签规则定时任务
+func (sm *SchedulerManager) AddTagRuleJob(ruleID int, cronExpr string) error {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	jobID := tagRuleJobIDOffset + ruleID
+
+	cleanCronExpr := cleanCronExpression(cronExpr)
+	if cleanCronExpr == "" {
+		return nil
+	}
+
+	// 如果任务已存在，先删除
+	if entryID, exists := sm.jobs[jobID]; exists {
+		sm.cron.Remove(entryID)
+		delete(sm.jobs, jobID)
+	}
+
+	entryID, err := sm.cron.AddFunc(cleanCronExpr, func() {
+		if err := runTagRule(ruleID, models.TaskTriggerScheduled); err != nil {
+			utils.Error("执行标签规则定时任务失败 - RuleID: %d, Error: %v", ruleID, err)
+		}
+	})
+	if err != nil {
+		utils.Error("添加标签规则定时任务失败 - RuleID: %d, Cron: %s, Error: %v", ruleID, cleanCronExpr, err)
+		return err
+	}
+	sm.jobs[jobID] = entryID
+
+	utils.Info("成功添加标签规则定时任务 - RuleID: %d, Cron: %s, 下次运行: %v", ruleID, cleanCronExpr, sm.getNextRunTime(cleanCronExpr))
+	return nil
+}
+
+// RemoveTagRuleJob 删除标签规则定时任务
+func (sm *SchedulerManager) RemoveTagRuleJob(ruleID int) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	jobID := tagRuleJobIDOffset + ruleID
+	if entryID, exists := sm.jobs[jobID]; exists {
+		sm.cron.Remove(entryID)
+		delete(sm.jobs, jobID)
+		utils.Info("成功删除标签规则定时任务 - RuleID: %d", ruleID)
+	}
+}
+
+// UpdateTagRuleJob 按规则的触发类型与启用状态更新定时任务
+func (sm *SchedulerManager) UpdateTagRuleJob(rule *models.TagRule) error {
+	sm.RemoveTagRuleJob(rule.ID)
+	if rule.Enabled && rule.TriggerType == models.TagTriggerCron && rule.CronExpr != "" {
+		return sm.AddTagRuleJob(rule.ID, rule.CronExpr)
+	}
+	return nil
+}
+
+// loadTagRuleJobs 加载所有启用的 cron 标签规则
+func (sm *SchedulerManager) loadTagRuleJobs() {
+	for _, rule := range models.ListByTriggerType(models.TagTriggerCron) {
+		if rule.CronExpr == "" {
+			continue
+		}
+		if err := sm.AddTagRuleJob(rule.ID, rule.CronExpr); err != nil {
+			utils.Error("添加标签规则定时任务失败 - ID: %d, Error: %v", rule.ID, err)
+		}
+	}
+}
+
+// StartTagExpiryTask 启动过期标签清理任务
+// 每分钟移除一次已到期的节点标签
+func (sm *SchedulerManager) StartTagExpiryTask() error {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	const tagExpiryCron = "* * * * *" // 每分钟执行一次
+
+	// 如果任务已存在，先删除
+	if entryID, exists := sm.jobs[JobIDTagExpiry]; exists {
+		sm.cron.Remove(entryID)
+		delete(sm.jobs, JobIDTagExpiry)
+	}
+
+	entryID, err := sm.cron.AddFunc(tagExpiryCron, func() {
+		ExecuteTagExpiry()
+	})
+	if err != nil {
+		utils.Error("添加过期标签清理任务失败 - Cron: %s, Error: %v", tagExpiryCron, err)
+		return err
+	}
+
+	sm.jobs[JobIDTagExpiry] = entryID
+	utils.Info("成功添加过期标签清理任务 - Cron: %s", tagExpiryCron)
+	return nil
+}
+
+// ExecuteTagExpiry 移除已到期的节点标签
+func ExecuteTagExpiry() {
+	removed, err := models.ExpireNodeTags()
+	if err != nil {
+		utils.Error("清理过期标签失败: %v", err)
+	}
+	if removed > 0 {
+		utils.Info("已移除 %d 个到期的节点标签", removed)
+	}
+}
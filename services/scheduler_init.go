@@ -30,6 +30,7 @@ func InitSchedulerDependencies() {
 		factory,
 		tmGetter,
 		ApplyAutoTagRules,
+		RunTagRule,
 		LatencyAdjustCheckInterval,
 		SpeedAdjustCheckInterval,
 	)
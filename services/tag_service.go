@@ -5,6 +5,7 @@ import (
 	"sublink/models"
 	"sublink/services/sse"
 	"sublink/utils"
+	"time"
 )
 
 // ApplyAutoTagRules 对节点应用自动标签规则
@@ -53,8 +54,8 @@ func ApplyAutoTagRules(nodes []models.Node, triggerType string) {
 		for _, node := range nodes {
 			if conditions.EvaluateNode(node) {
 				matchedNodeIDs = append(matchedNodeIDs, node.ID)
-			} else if node.HasTagName(rule.TagName) {
-				// 节点不满足条件但有此标签，需要移除
+			} else if rule.ExpireMinutes <= 0 && node.HasTagName(rule.TagName) {
+				// 节点不满足条件但有此标签，需要移除（有有效期的标签只在到期时移除）
 				unmatchedNodeIDs = append(unmatchedNodeIDs, node.ID)
 			}
 		}
@@ -66,6 +67,7 @@ func ApplyAutoTagRules(nodes []models.Node, triggerType string) {
 				utils.Error("批量打标签失败: %v", err)
 			} else {
 				taggedCount += len(matchedNodeIDs)
+				updateTagExpiry(rule, matchedNodeIDs)
 			}
 		}
 
@@ -119,8 +121,24 @@ func ApplyAutoTagRules(nodes []models.Node, triggerType string) {
 	}
 }
 
+// updateTagExpiry 按规则的有效期设置（或刷新）节点标签的过期时间
+func updateTagExpiry(rule models.TagRule, nodeIDs []int) {
+	if rule.ExpireMinutes <= 0 {
+		return
+	}
+	expiresAt := time.Now().Add(time.Duration(rule.ExpireMinutes) * time.Minute)
+	if err := models.SetNodeTagExpiry(nodeIDs, rule.TagName, expiresAt); err != nil {
+		utils.Error("设置标签 %s 过期时间失败: %v", rule.TagName, err)
+	}
+}
+
 // TriggerTagRule 手动触发指定规则
 func TriggerTagRule(ruleID int) error {
+	return RunTagRule(ruleID, models.TaskTriggerManual)
+}
+
+// RunTagRule 对全部节点执行指定规则（手动触发或 cron 定时触发）
+func RunTagRule(ruleID int, trigger models.TaskTrigger) error {
 	var rule models.TagRule
 	if err := rule.GetByID(ruleID); err != nil {
 		return err
@@ -140,7 +158,7 @@ func TriggerTagRule(ruleID int) error {
 
 	// 使用 TaskManager 创建任务
 	tm := GetTaskManager()
-	task, _, createErr := tm.CreateTask(models.TaskTypeTagRule, rule.Name, models.TaskTriggerManual, totalNodes)
+	task, _, createErr := tm.CreateTask(models.TaskTypeTagRule, rule.Name, trigger, totalNodes)
 	if createErr != nil {
 		utils.Error("创建标签规则任务失败: %v", createErr)
 		return createErr
@@ -158,16 +176,20 @@ func TriggerTagRule(ruleID int) error {
 	// 评估节点并收集需要操作的节点ID（稍后批量写入）
 	matchedNodeIDs := make([]int, 0)
 	unmatchedNodeIDs := make([]int, 0)
+	expiringNodeIDs := make([]int, 0) // 有有效期的规则：所有匹配节点都需要刷新过期时间
 
 	for i, n := range nodes {
 		matched := conditions.EvaluateNode(n)
 		resultStatus := "skipped"
 
+		if matched && rule.ExpireMinutes > 0 {
+			expiringNodeIDs = append(expiringNodeIDs, n.ID)
+		}
 		if matched && !n.HasTagName(rule.TagName) {
 			// 匹配条件但没有此标签，需要添加
 			matchedNodeIDs = append(matchedNodeIDs, n.ID)
 			resultStatus = "tagged"
-		} else if !matched && n.HasTagName(rule.TagName) {
+		} else if !matched && rule.ExpireMinutes <= 0 && n.HasTagName(rule.TagName) {
 			// 不满足条件但有此标签，需要移除
 			unmatchedNodeIDs = append(unmatchedNodeIDs, n.ID)
 			resultStatus = "untagged"
@@ -193,6 +215,7 @@ func TriggerTagRule(ruleID int) error {
 			utils.Info("✅批量添加标签到 %d 个节点", matchedCount)
 		}
 	}
+	updateTagExpiry(rule, expiringNodeIDs)
 
 	if len(unmatchedNodeIDs) > 0 {
 		if err := models.BatchRemoveTagFromNodes(unmatchedNodeIDs, rule.TagName); err != nil {
@@ -412,6 +412,19 @@ func InitTaskManager() {
 		} else if affected > 0 {
 			utils.Info("已清理 %d 个过期任务", affected)
 		}
+		// 断点记录仅保留7天，超过后不再支持续测
+		sevenDaysAgo := time.Now().AddDate(0, 0, -7)
+		if affected, err := models.CleanupNodeCheckCheckpoints(sevenDaysAgo); err != nil {
+			utils.Error("清理过期检测断点记录失败: %v", err)
+		} else if affected > 0 {
+			utils.Info("已清理 %d 条过期检测断点记录", affected)
+		}
+		// 节点生命周期记录与任务保留时间一致
+		if affected, err := models.CleanupNodeLifecycleEvents(thirtyDaysAgo); err != nil {
+			utils.Error("清理过期节点生命周期记录失败: %v", err)
+		} else if affected > 0 {
+			utils.Info("已清理 %d 条过期节点生命周期记录", affected)
+		}
 	}()
 
 	_ = tm // 确保初始化
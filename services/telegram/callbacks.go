@@ -223,13 +223,25 @@ func handleProfileDetailCallback(bot *TelegramBot, callback *CallbackQuery, para
 	// 范围过滤
 	groups := profile.GetGroups()
 	tags := profile.GetTags()
-	if len(groups) > 0 || len(tags) > 0 {
+	if len(groups) > 0 || len(tags) > 0 || profile.NodeFilter != "" {
 		text.WriteString(fmt.Sprintf("\n*检测范围*\n"))
+		var scopeLines []string
 		if len(groups) > 0 {
-			text.WriteString(fmt.Sprintf("├ 分组: %s\n", strings.Join(groups, ", ")))
+			scopeLines = append(scopeLines, fmt.Sprintf("分组: %s", strings.Join(groups, ", ")))
 		}
 		if len(tags) > 0 {
-			text.WriteString(fmt.Sprintf("└ 标签: %s\n", strings.Join(tags, ", ")))
+			scopeLines = append(scopeLines, fmt.Sprintf("标签: %s", strings.Join(tags, ", ")))
+		}
+		if profile.NodeFilter != "" {
+			scopeLines = append(scopeLines, fmt.Sprintf("条件: `%s`", truncateName(profile.NodeFilter, 60)))
+		}
+		for i, line := range scopeLines {
+			// 最后一行使用 └ 收尾
+			glyph := "├"
+			if i == len(scopeLines)-1 {
+				glyph = "└"
+			}
+			text.WriteString(fmt.Sprintf("%s %s\n", glyph, line))
 		}
 	} else {
 		text.WriteString("\n*检测范围*: 全部节点\n")
@@ -24,6 +24,8 @@ func SendNotification(event string, payload sse.NotificationPayload) {
 		text = formatSubUpdateNotification(payload)
 	case "tag_rule_applied":
 		text = formatTagRuleNotification(payload)
+	case "airport_usage_alert":
+		text = formatAirportUsageAlertNotification(payload)
 	case "task_complete":
 		text = formatTaskCompleteNotification(payload)
 	case "task_error":
@@ -78,6 +80,8 @@ func formatSubUpdateNotification(payload sse.NotificationPayload) string {
 		icon = "❌"
 	} else if status == "success" {
 		icon = "✅"
+	} else if status == "guarded" {
+		icon = "🛡️"
 	}
 
 	return fmt.Sprintf(`%s *订阅更新*
@@ -86,6 +90,19 @@ func formatSubUpdateNotification(payload sse.NotificationPayload) string {
 %s`, icon, name, payload.Message)
 }
 
+// formatAirportUsageAlertNotification 格式化机场用量告警通知
+func formatAirportUsageAlertNotification(payload sse.NotificationPayload) string {
+	data, ok := payload.Data.(map[string]interface{})
+	if !ok {
+		return fmt.Sprintf("⚠️ *机场用量告警*\n\n%s", payload.Message)
+	}
+
+	return fmt.Sprintf(`⚠️ *机场用量告警*
+
+*机场*: %s
+%s`, getStringFromData(data, "name"), payload.Message)
+}
+
 // formatTagRuleNotification 格式化标签规则通知
 func formatTagRuleNotification(payload sse.NotificationPayload) string {
 	return fmt.Sprintf("🏷️ *标签规则执行完成*\n\n%s", payload.Message)
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+// TestTruncateUTF8 测试按字节截断时不切断多字节字符
+func TestTruncateUTF8(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		maxBytes int
+		want     string
+	}{
+		{"未超出", "香港", 6, "香港"},
+		{"ASCII", "abcdef", 3, "abc"},
+		{"字符边界", "香港节点", 6, "香港"},
+		{"切在字符中间", "香港节点", 7, "香港"},
+		{"切在首个字符中间", "香港", 2, ""},
+		{"混合字符", "a香b", 3, "a"},
+		{"四字节字符", "🇭🇰HK", 5, "🇭"},
+		{"上限为零", "abc", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := TruncateUTF8(tt.s, tt.maxBytes)
+			if got != tt.want {
+				t.Errorf("TruncateUTF8(%q, %d) = %q, 期望 %q", tt.s, tt.maxBytes, got, tt.want)
+			}
+			if !utf8.ValidString(got) || len(got) > tt.maxBytes {
+				t.Errorf("结果 %q 不是有效的 UTF-8 或超出上限", got)
+			}
+		})
+	}
+}
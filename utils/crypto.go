@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// EncryptString 使用 AES-256-GCM 加密字符串，返回 Base64 编码的密文（含随机 nonce）
+// 密钥由 key 经 SHA256 派生，空字符串原样返回
+func EncryptString(plain string, key []byte) (string, error) {
+	if plain == "" {
+		return "", nil
+	}
+	gcm, err := newStringCipher(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("生成随机数失败: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptString 解密 EncryptString 生成的密文
+func DecryptString(encrypted string, key []byte) (string, error) {
+	if encrypted == "" {
+		return "", nil
+	}
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("Base64解码失败: %w", err)
+	}
+	gcm, err := newStringCipher(key)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("密文长度无效")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("解密失败（密钥可能已变更）: %w", err)
+	}
+	return string(plain), nil
+}
+
+// newStringCipher 由任意长度的密钥派生 AES-256-GCM
+func newStringCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) == 0 {
+		return nil, errors.New("加密密钥为空")
+	}
+	derived := sha256.Sum256(key)
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
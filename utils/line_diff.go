@@ -0,0 +1,80 @@
+package utils
+
+import "strings"
+
+// diffMaxLines 参与逐行比较的最大行数，超出时按整体替换处理
+const diffMaxLines = 5000
+
+// DiffLine 逐行差异中的一行
+// Op: " " 未变化, "-" 删除, "+" 新增
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// DiffLines 计算两段文本的逐行差异（基于最长公共子序列）
+func DiffLines(oldText, newText string) []DiffLine {
+	a := splitDiffLines(oldText)
+	b := splitDiffLines(newText)
+
+	if len(a) > diffMaxLines || len(b) > diffMaxLines {
+		result := make([]DiffLine, 0, len(a)+len(b))
+		for _, line := range a {
+			result = append(result, DiffLine{Op: "-", Text: line})
+		}
+		for _, line := range b {
+			result = append(result, DiffLine{Op: "+", Text: line})
+		}
+		return result
+	}
+
+	// lcs[i][j] 为 a[i:] 与 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	result := make([]DiffLine, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			result = append(result, DiffLine{Op: " ", Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, DiffLine{Op: "-", Text: a[i]})
+			i++
+		default:
+			result = append(result, DiffLine{Op: "+", Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		result = append(result, DiffLine{Op: "-", Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		result = append(result, DiffLine{Op: "+", Text: b[j]})
+	}
+	return result
+}
+
+// splitDiffLines 按行拆分文本，统一换行符
+func splitDiffLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
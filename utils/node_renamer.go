@@ -20,6 +20,9 @@ type NodeInfo struct {
 	Index       int     // 序号 (从1开始)
 	Protocol    string  // 协议类型
 	Tags        string  // 节点标签（逗号分隔）
+	LandingASN  string  // 落地IP所属AS号（如 AS13335）
+	LandingISP  string  // 落地IP运营商
+	LandingCity string  // 落地IP所在城市
 }
 
 // PreprocessRule 原名预处理规则结构体
@@ -211,6 +214,11 @@ func RenameNode(rule string, info NodeInfo) string {
 		}
 	}
 
+	// 落地信息为空时使用"未知"
+	landingASN := unknownIfEmpty(info.LandingASN)
+	landingISP := unknownIfEmpty(info.LandingISP)
+	landingCity := unknownIfEmpty(info.LandingCity)
+
 	// 替换所有支持的变量
 	// 使用有序切片代替 map，确保长变量名优先替换
 	// 这避免了如 $Tag 先于 $Tags 替换导致的问题
@@ -222,6 +230,9 @@ func RenameNode(rule string, info NodeInfo) string {
 	replacements := []replacement{
 		{"$LinkCountry", linkCountry},
 		{"$LinkName", info.LinkName},
+		{"$City", landingCity},
+		{"$ASN", landingASN},
+		{"$ISP", landingISP},
 		{"$Protocol", info.Protocol},
 		{"$Source", linkSource},
 		{"$Speed", FormatSpeed(info.Speed)},
@@ -249,6 +260,14 @@ func RenameNode(rule string, info NodeInfo) string {
 	return result
 }
 
+// unknownIfEmpty 空值返回"未知"
+func unknownIfEmpty(v string) string {
+	if v == "" {
+		return "未知"
+	}
+	return v
+}
+
 // FormatSpeed 格式化速度显示
 // speed: 速度值 (MB/s)
 // 返回格式化字符串，如 "1.50MB/s" 或 "N/A"
@@ -3,6 +3,7 @@ package utils
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -21,6 +22,162 @@ var GetMihomoAdapterFunc func(nodeLink string) (constant.Proxy, error)
 // 需要在 main.go 或 init 阶段设置
 var GetBestProxyNodeFunc func() (link string, name string, err error)
 
+// GetProxyCandidatesFunc 按代理来源获取候选代理节点的函数类型
+// source 格式为 tag:标签名 或 group:分组名，返回按优先级排序的候选
+// 需要在 main.go 或 init 阶段设置
+var GetProxyCandidatesFunc func(source string) []ProxyCandidate
+
+// ProxyCandidate 候选代理节点
+type ProxyCandidate struct {
+	Name string
+	Link string
+}
+
+// ProxyOptions 代理下载选项
+type ProxyOptions struct {
+	UseProxy      bool          // 是否使用代理
+	ProxyLink     string        // 指定代理节点链接，优先尝试
+	ProxySource   string        // 代理来源: tag:标签名 / group:分组名
+	MaxAttempts   int           // 最多尝试的代理数，0 表示默认 3 个
+	AllowDirect   bool          // 全部代理失败（或没有可用代理）后是否允许直连
+	Timeout       time.Duration // 单次请求超时
+	SkipTLSVerify bool          // 是否跳过TLS证书验证
+}
+
+// ErrProxyUnavailable 使用代理时没有可用代理（且未允许直连）
+var ErrProxyUnavailable = errors.New("代理不可用")
+
+// defaultProxyMaxAttempts 默认最多尝试的代理数
+const defaultProxyMaxAttempts = 3
+
+// newAdapterTransport 创建通过 mihomo 适配器建立连接的 Transport
+func newAdapterTransport(proxyAdapter constant.Proxy, skipTLSVerify bool) *http.Transport {
+	return &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			// 解析地址获取主机和端口
+			host, portStr, splitErr := net.SplitHostPort(addr)
+			if splitErr != nil {
+				return nil, fmt.Errorf("split host port error: %v", splitErr)
+			}
+
+			portInt, atoiErr := strconv.Atoi(portStr)
+			if atoiErr != nil {
+				return nil, fmt.Errorf("invalid port: %v", atoiErr)
+			}
+
+			// 验证端口范围
+			if portInt < 0 || portInt > 65535 {
+				return nil, fmt.Errorf("port out of range: %d", portInt)
+			}
+
+			// 创建 mihomo metadata
+			metadata := &constant.Metadata{
+				Host:    host,
+				DstPort: uint16(portInt),
+				Type:    constant.HTTP,
+			}
+
+			// 使用 mihomo adapter 建立连接
+			return proxyAdapter.DialContext(ctx, metadata)
+		},
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: skipTLSVerify},
+	}
+}
+
+// NewProxyHTTPClient 使用指定代理节点链接创建HTTP客户端
+func NewProxyHTTPClient(proxyLink string, timeout time.Duration, skipTLSVerify bool) (*http.Client, error) {
+	if GetMihomoAdapterFunc == nil {
+		return nil, fmt.Errorf("mihomo 适配器未初始化")
+	}
+	proxyAdapter, err := GetMihomoAdapterFunc(proxyLink)
+	if err != nil {
+		return nil, fmt.Errorf("创建 mihomo 代理适配器失败: %v", err)
+	}
+	return &http.Client{Timeout: timeout, Transport: newAdapterTransport(proxyAdapter, skipTLSVerify)}, nil
+}
+
+// resolveProxyCandidates 按选项确定候选代理：指定代理 -> 代理来源 -> 自动选择最佳节点
+func resolveProxyCandidates(opts ProxyOptions) []ProxyCandidate {
+	var candidates []ProxyCandidate
+	if opts.ProxyLink != "" {
+		candidates = append(candidates, ProxyCandidate{Name: "指定代理", Link: opts.ProxyLink})
+	}
+	if opts.ProxySource != "" && GetProxyCandidatesFunc != nil {
+		candidates = append(candidates, GetProxyCandidatesFunc(opts.ProxySource)...)
+	}
+	if len(candidates) == 0 && GetBestProxyNodeFunc != nil {
+		if link, name, err := GetBestProxyNodeFunc(); err == nil && link != "" {
+			candidates = append(candidates, ProxyCandidate{Name: name, Link: link})
+		}
+	}
+
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultProxyMaxAttempts
+	}
+	if len(candidates) > maxAttempts {
+		candidates = candidates[:maxAttempts]
+	}
+	return candidates
+}
+
+// DoWithProxyFallback 按代理选项发送请求，代理连接失败时依次尝试下一个候选代理
+// newReq 每次尝试都会调用以创建新的请求
+// 仅在请求未完成（连接、握手、超时等）时重试，服务端返回的任何状态码都视为请求完成
+// 返回响应、实际使用的代理名称（直连为空）和错误
+func DoWithProxyFallback(opts ProxyOptions, newReq func() (*http.Request, error)) (*http.Response, string, error) {
+	direct := func() (*http.Response, string, error) {
+		req, err := newReq()
+		if err != nil {
+			return nil, "", err
+		}
+		client := &http.Client{
+			Timeout:   opts.Timeout,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: opts.SkipTLSVerify}},
+		}
+		resp, err := client.Do(req)
+		return resp, "", err
+	}
+	if !opts.UseProxy {
+		return direct()
+	}
+
+	candidates := resolveProxyCandidates(opts)
+	var lastErr error
+	for i, candidate := range candidates {
+		client, err := NewProxyHTTPClient(candidate.Link, opts.Timeout, opts.SkipTLSVerify)
+		if err != nil {
+			Warn("代理 [%s] 不可用: %v", candidate.Name, err)
+			lastErr = err
+			continue
+		}
+		req, err := newReq()
+		if err != nil {
+			return nil, "", err
+		}
+		resp, err := client.Do(req)
+		if err == nil {
+			Info("通过代理 [%s] 请求成功", candidate.Name)
+			return resp, candidate.Name, nil
+		}
+		lastErr = err
+		Warn("通过代理 [%s] 请求失败(%d/%d): %v", candidate.Name, i+1, len(candidates), err)
+	}
+
+	if opts.AllowDirect {
+		if len(candidates) == 0 {
+			Warn("未找到可用代理，将直接下载")
+		} else {
+			Warn("全部 %d 个代理请求失败，将直接下载", len(candidates))
+		}
+		return direct()
+	}
+	if lastErr == nil {
+		return nil, "", fmt.Errorf("%w: 未找到可用代理，且未允许直连", ErrProxyUnavailable)
+	}
+	return nil, "", fmt.Errorf("%w: 全部 %d 个代理请求失败，且未允许直连: %w", ErrProxyUnavailable, len(candidates), lastErr)
+}
+
 // CreateProxyHTTPClient 创建带代理的HTTP客户端
 // useProxy: 是否使用代理
 // proxyLink: 代理节点链接，为空时自动选择最佳代理
@@ -68,37 +225,7 @@ func CreateProxyHTTPClient(useProxy bool, proxyLink string, timeout time.Duratio
 	}
 
 	Info("使用 mihomo 内核代理下载")
-	// 创建自定义 Transport，使用 mihomo adapter 进行代理连接
-	client.Transport = &http.Transport{
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			// 解析地址获取主机和端口
-			host, portStr, splitErr := net.SplitHostPort(addr)
-			if splitErr != nil {
-				return nil, fmt.Errorf("split host port error: %v", splitErr)
-			}
-
-			portInt, atoiErr := strconv.Atoi(portStr)
-			if atoiErr != nil {
-				return nil, fmt.Errorf("invalid port: %v", atoiErr)
-			}
-
-			// 验证端口范围
-			if portInt < 0 || portInt > 65535 {
-				return nil, fmt.Errorf("port out of range: %d", portInt)
-			}
-
-			// 创建 mihomo metadata
-			metadata := &constant.Metadata{
-				Host:    host,
-				DstPort: uint16(portInt),
-				Type:    constant.HTTP,
-			}
-
-			// 使用 mihomo adapter 建立连接
-			return proxyAdapter.DialContext(ctx, metadata)
-		},
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
+	client.Transport = newAdapterTransport(proxyAdapter, true)
 
 	return client, proxyNodeLink, nil
 }
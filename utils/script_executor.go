@@ -8,98 +8,117 @@ import (
 )
 
 // RunScript executes a JavaScript script with the given input and client type.
-// The script is expected to define a function `main(node, clientType)` that returns a string.
-func RunScript(scriptContent string, input string, clientType string) (string, error) {
-	vm := goja.New()
+// The script is expected to define a function `subMod(input, clientType)` that returns a string.
+// Execution is sandboxed: see runSandboxed for the timeout, memory and output limits.
+func RunScript(src ScriptSource, input string, clientType string) (string, error) {
+	return RunScriptTraced(src, input, clientType, nil)
+}
 
-	// Inject console object
-	vm.Set("console", map[string]interface{}{
-		"log":   fmt.Println,
-		"info":  fmt.Println,
-		"warn":  fmt.Println,
-		"error": fmt.Println,
+// RunScriptTraced is RunScript with an optional trace that captures console output and timing (dry-run).
+func RunScriptTraced(src ScriptSource, input string, clientType string, trace *ScriptTrace) (string, error) {
+	var output string
+	err := runSandboxed(src, trace, func(vm *goja.Runtime) error {
+		mainFn, ok := goja.AssertFunction(vm.Get("subMod"))
+		if !ok {
+			return fmt.Errorf("subMod %w", ErrScriptFunctionNotFound)
+		}
+		result, err := mainFn(goja.Undefined(), vm.ToValue(input), vm.ToValue(clientType))
+		if err != nil {
+			return fmt.Errorf("script execution error: %w", err)
+		}
+		output = result.String()
+		return nil
 	})
-
-	// Inject polyfills
-	_, err := vm.RunString(polyfills)
-	if err != nil {
-		return "", fmt.Errorf("polyfill injection error: %w", err)
-	}
-
-	// Execute the script to load definitions
-	_, err = vm.RunString(scriptContent)
 	if err != nil {
-		return "", fmt.Errorf("script compilation error: %w", err)
-	}
-
-	// Get the main function
-	mainFn, ok := goja.AssertFunction(vm.Get("subMod"))
-	if !ok {
-		return "", fmt.Errorf("subMod function not found in script")
+		return "", err
 	}
-
-	// Call the main function
-	result, err := mainFn(goja.Undefined(), vm.ToValue(input), vm.ToValue(clientType))
-	if err != nil {
-		return "", fmt.Errorf("script execution error: %w", err)
+	if err := checkScriptOutput(len(output)); err != nil {
+		return "", err
 	}
-
-	return result.String(), nil
+	return output, nil
 }
 
 // RunNodeFilterScript executes a JavaScript script to filter nodes.
 // The script is expected to define a function `filterNode(nodes, clientType)` that returns a modified nodes array.
-func RunNodeFilterScript(scriptContent string, nodesJSON []byte, clientType string) ([]byte, error) {
-	vm := goja.New()
-
-	// Inject console object
-	vm.Set("console", map[string]interface{}{
-		"log":   fmt.Println,
-		"info":  fmt.Println,
-		"warn":  fmt.Println,
-		"error": fmt.Println,
-	})
-
-	// Inject polyfills
-	_, err := vm.RunString(polyfills)
-	if err != nil {
-		return nil, fmt.Errorf("polyfill injection error: %w", err)
-	}
-
-	// Execute the script to load definitions
-	_, err = vm.RunString(scriptContent)
-	if err != nil {
-		return nil, fmt.Errorf("script compilation error: %w", err)
-	}
-
-	// Get the filterNode function
-	filterFn, ok := goja.AssertFunction(vm.Get("filterNode"))
-	if !ok {
-		// If function not found, return original nodes (or error? Plan said error, but maybe better to just return nil error and original nodes if we want to be lenient.
-		// However, explicit error is better for debugging.
-		return nil, fmt.Errorf("filterNode function not found in script")
-	}
+func RunNodeFilterScript(src ScriptSource, nodesJSON []byte, clientType string) ([]byte, error) {
+	return RunNodeFilterScriptTraced(src, nodesJSON, clientType, nil)
+}
 
+// RunNodeFilterScriptTraced is RunNodeFilterScript with an optional trace (dry-run).
+func RunNodeFilterScriptTraced(src ScriptSource, nodesJSON []byte, clientType string, trace *ScriptTrace) ([]byte, error) {
 	// Unmarshal nodes
 	var nodes interface{}
 	if err := json.Unmarshal(nodesJSON, &nodes); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal nodes: %w", err)
 	}
+	return runJSONScript(src, "filterNode", nodes, clientType, trace)
+}
 
-	// Call the function
-	result, err := filterFn(goja.Undefined(), vm.ToValue(nodes), vm.ToValue(clientType))
-	if err != nil {
-		return nil, fmt.Errorf("script execution error: %w", err)
+// RunProxyTransformScript executes a JavaScript script to transform proxies imported from an airport.
+// The script is expected to define a function `transformProxies(proxies, airportName)` that returns the proxies array
+// (in Clash format) to be imported.
+func RunProxyTransformScript(src ScriptSource, proxiesJSON []byte, airportName string) ([]byte, error) {
+	var proxies interface{}
+	if err := json.Unmarshal(proxiesJSON, &proxies); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal proxies: %w", err)
 	}
+	return runJSONScript(src, "transformProxies", proxies, airportName, nil)
+}
 
-	// Marshal result back to JSON
-	// The result should be the modified nodes array
-	resNodes := result.Export()
-	newJSON, err := json.Marshal(resNodes)
+// RunHookScript calls fnName(args...) for a lifecycle hook and returns the result marshalled to JSON
+// (null when the function returns nothing).
+func RunHookScript(src ScriptSource, fnName string, trace *ScriptTrace, args ...interface{}) ([]byte, error) {
+	var resultJSON []byte
+	err := runSandboxed(src, trace, func(vm *goja.Runtime) error {
+		fn, ok := goja.AssertFunction(vm.Get(fnName))
+		if !ok {
+			return fmt.Errorf("%s %w", fnName, ErrScriptFunctionNotFound)
+		}
+		values := make([]goja.Value, 0, len(args))
+		for _, arg := range args {
+			values = append(values, vm.ToValue(arg))
+		}
+		result, err := fn(goja.Undefined(), values...)
+		if err != nil {
+			return fmt.Errorf("script execution error: %w", err)
+		}
+		if resultJSON, err = json.Marshal(result.Export()); err != nil {
+			return fmt.Errorf("failed to marshal result: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal result: %w", err)
+		return nil, err
+	}
+	if err := checkScriptOutput(len(resultJSON)); err != nil {
+		return nil, err
 	}
+	return resultJSON, nil
+}
 
+// runJSONScript calls fnName(data, arg) and marshals the returned value back to JSON.
+func runJSONScript(src ScriptSource, fnName string, data interface{}, arg string, trace *ScriptTrace) ([]byte, error) {
+	var newJSON []byte
+	err := runSandboxed(src, trace, func(vm *goja.Runtime) error {
+		fn, ok := goja.AssertFunction(vm.Get(fnName))
+		if !ok {
+			return fmt.Errorf("%s %w", fnName, ErrScriptFunctionNotFound)
+		}
+		result, err := fn(goja.Undefined(), vm.ToValue(data), vm.ToValue(arg))
+		if err != nil {
+			return fmt.Errorf("script execution error: %w", err)
+		}
+		if newJSON, err = json.Marshal(result.Export()); err != nil {
+			return fmt.Errorf("failed to marshal result: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := checkScriptOutput(len(newJSON)); err != nil {
+		return nil, err
+	}
 	return newJSON, nil
 }
 
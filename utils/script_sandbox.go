@@ -0,0 +1,548 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"runtime/metrics"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// 脚本运行限制的默认值
+const (
+	DefaultScriptTimeout   = 10 * time.Second
+	DefaultScriptMaxMemory = 512 << 20 // 执行期间进程存活堆增长上限（进程级软限制）
+	DefaultScriptMaxOutput = 32 << 20  // 返回结果上限
+	DefaultScriptMaxLogs   = 200       // 每次执行记录的 console 输出行数
+
+	scriptMaxCallStack     = 4096
+	scriptMaxLogLineLen    = 1024
+	scriptMemoryCheckEvery = 20 * time.Millisecond
+	scriptProgramCacheSize = 256
+	scriptFetchMaxBody     = 2 << 20
+	scriptFetchMaxCalls    = 20
+	scriptFetchTimeout     = 10 * time.Second
+)
+
+// 脚本被中止的原因
+var (
+	ErrScriptFunctionNotFound = errors.New("function not found in script")
+	ErrScriptTimeout          = errors.New("script execution timed out")
+	ErrScriptMemoryLimit      = errors.New("script memory limit exceeded")
+	ErrScriptOutputLimit      = errors.New("script output limit exceeded")
+)
+
+// ScriptSource 待执行的脚本
+type ScriptSource struct {
+	ID        int    // 脚本ID，0 表示内联脚本（如机场导入脚本）
+	Version   string // 脚本版本，与 ID 共同作为编译缓存的键
+	Content   string // 脚本内容
+	Namespace string // KV 存储命名空间，为空表示不提供 KV 存储
+
+	// 参数
+	Params map[string]interface{} // 脚本参数，以 $params 提供给脚本
+}
+
+// InlineScript 构造不在脚本库中的内联脚本，namespace 为空表示不提供 KV 存储
+func InlineScript(content, namespace string) ScriptSource {
+	return ScriptSource{Content: content, Namespace: namespace}
+}
+
+// displayName 用于日志与错误堆栈的脚本名称
+func (s ScriptSource) displayName() string {
+	if s.ID > 0 {
+		return fmt.Sprintf("script-%d@%s.js", s.ID, s.Version)
+	}
+	if s.Namespace != "" {
+		return s.Namespace + ".js"
+	}
+	return "inline.js"
+}
+
+// ScriptLimits 脚本运行限制
+type ScriptLimits struct {
+	Timeout        time.Duration // 单次执行超时（含脚本加载）
+	MaxMemory      int64         // 执行期间进程存活堆增长上限（字节），0 表示不限制；进程级软限制，见 startScriptGuard
+	MaxOutput      int           // 返回结果上限（字节），0 表示不限制
+	MaxLogs        int           // 每次执行记录的 console 输出行数
+	FetchAllowlist []string      // $http.get 允许访问的域名，*.example.com 匹配子域名，为空则禁用
+}
+
+// DefaultScriptLimits 默认的脚本运行限制
+func DefaultScriptLimits() ScriptLimits {
+	return ScriptLimits{
+		Timeout:   DefaultScriptTimeout,
+		MaxMemory: DefaultScriptMaxMemory,
+		MaxOutput: DefaultScriptMaxOutput,
+		MaxLogs:   DefaultScriptMaxLogs,
+	}
+}
+
+// ScriptTag 提供给脚本的标签信息
+type ScriptTag struct {
+	Name        string `json:"name"`
+	Group       string `json:"group"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+}
+
+// 脚本宿主能力，由 main 注入（utils 不能依赖 models / geoip）
+var (
+	ScriptLimitsFunc   func() ScriptLimits
+	ScriptGeoIPFunc    func(ip string) (map[string]interface{}, error)
+	ScriptTagsFunc     func() []ScriptTag
+	ScriptTagNodesFunc func(tag string) []string
+	ScriptKVGetFunc    func(namespace, key string) (string, bool, error)
+	ScriptKVSetFunc    func(namespace, key, value string) error
+	ScriptKVDeleteFunc func(namespace, key string) error
+)
+
+// currentScriptLimits 获取当前生效的运行限制
+func currentScriptLimits() ScriptLimits {
+	limits := DefaultScriptLimits()
+	if ScriptLimitsFunc != nil {
+		limits = ScriptLimitsFunc()
+	}
+	if limits.Timeout <= 0 {
+		limits.Timeout = DefaultScriptTimeout
+	}
+	if limits.MaxLogs <= 0 {
+		limits.MaxLogs = DefaultScriptMaxLogs
+	}
+	return limits
+}
+
+// polyfillProgram 预编译的 polyfill
+var polyfillProgram = goja.MustCompile("polyfills.js", polyfills, false)
+
+// scriptProgramEntry 编译缓存条目，hash 用于发现同版本内容被修改的情况
+type scriptProgramEntry struct {
+	hash    string
+	program *goja.Program
+}
+
+var (
+	scriptProgramMu    sync.Mutex
+	scriptProgramCache = make(map[string]scriptProgramEntry)
+)
+
+// compileScript 编译脚本，脚本库中的脚本按 ID+版本 缓存，内联脚本按内容哈希缓存
+func compileScript(src ScriptSource) (*goja.Program, error) {
+	sum := sha256.Sum256([]byte(src.Content))
+	hash := hex.EncodeToString(sum[:])
+	key := "inline:" + hash
+	if src.ID > 0 {
+		key = fmt.Sprintf("id:%d@%s", src.ID, src.Version)
+	}
+
+	scriptProgramMu.Lock()
+	defer scriptProgramMu.Unlock()
+	if entry, ok := scriptProgramCache[key]; ok && entry.hash == hash {
+		return entry.program, nil
+	}
+	program, err := goja.Compile(src.displayName(), src.Content, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(scriptProgramCache) >= scriptProgramCacheSize {
+		clear(scriptProgramCache)
+	}
+	scriptProgramCache[key] = scriptProgramEntry{hash: hash, program: program}
+	return program, nil
+}
+
+// InvalidateScriptProgram 清除脚本的编译缓存（脚本更新或删除时调用）
+func InvalidateScriptProgram(id int) {
+	prefix := fmt.Sprintf("id:%d@", id)
+	scriptProgramMu.Lock()
+	defer scriptProgramMu.Unlock()
+	for key := range scriptProgramCache {
+		if strings.HasPrefix(key, prefix) {
+			delete(scriptProgramCache, key)
+		}
+	}
+}
+
+// scriptGuard 监控单次执行的耗时与内存，超限时中断 VM
+type scriptGuard struct {
+	deadline time.Time
+	timer    *time.Timer
+	done     chan struct{}
+}
+
+// startScriptGuard 启动超时定时器与内存检查
+// goja 无法按 VM 统计内存分配，内存限制比较的是整个进程的存活堆（上一次 GC 标记的存活对象，不含未回收的垃圾）
+// 相对执行开始时的增长量。并发的测速或其他脚本也会计入增长，因此这是一个进程级软限制，
+// 用于拦截失控的脚本，阈值应明显高于正常负载下的堆波动
+func startScriptGuard(vm *goja.Runtime, limits ScriptLimits) *scriptGuard {
+	g := &scriptGuard{
+		deadline: time.Now().Add(limits.Timeout),
+		done:     make(chan struct{}),
+	}
+	g.timer = time.AfterFunc(limits.Timeout, func() { vm.Interrupt(ErrScriptTimeout) })
+	if limits.MaxMemory > 0 {
+		base := liveHeapBytes()
+		go func() {
+			ticker := time.NewTicker(scriptMemoryCheckEvery)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-g.done:
+					return
+				case <-ticker.C:
+					if liveHeapBytes()-base > limits.MaxMemory {
+						vm.Interrupt(ErrScriptMemoryLimit)
+						return
+					}
+				}
+			}
+		}()
+	}
+	return g
+}
+
+func (g *scriptGuard) stop() {
+	g.timer.Stop()
+	close(g.done)
+}
+
+// remaining 距离超时的剩余时间
+func (g *scriptGuard) remaining() time.Duration {
+	return time.Until(g.deadline)
+}
+
+// liveHeapBytes 上一次 GC 标记的存活堆字节数（不含未回收的垃圾，不触发 STW）
+func liveHeapBytes() int64 {
+	sample := []metrics.Sample{{Name: "/gc/heap/live:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return int64(sample[0].Value.Uint64())
+}
+
+// interruptCause 将 VM 中断转换为对应的错误
+func interruptCause(err error) error {
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		if cause, ok := interrupted.Value().(error); ok {
+			return cause
+		}
+	}
+	return nil
+}
+
+// ScriptTrace 收集单次执行的 console 输出与耗时，用于脚本试运行
+// 传入 trace 时 $store 的写入只记录在 KVWrites 中，不会持久化
+type ScriptTrace struct {
+	Logs     []string           `json:"logs"`
+	Duration time.Duration      `json:"-"`
+	KVWrites map[string]*string `json:"kvWrites,omitempty"` // 值为 nil 表示删除
+}
+
+// runSandboxed 在受限的 VM 中加载脚本并执行 fn
+// 超时与内存中断覆盖脚本加载和 fn 的全部过程；trace 不为空时收集 console 输出与耗时
+func runSandboxed(src ScriptSource, trace *ScriptTrace, fn func(vm *goja.Runtime) error) error {
+	if trace != nil {
+		start := time.Now()
+		defer func() { trace.Duration += time.Since(start) }()
+	}
+	program, err := compileScript(src)
+	if err != nil {
+		return fmt.Errorf("script compilation error: %w", err)
+	}
+
+	limits := currentScriptLimits()
+	vm := goja.New()
+	vm.SetMaxCallStackSize(scriptMaxCallStack)
+	guard := startScriptGuard(vm, limits)
+	defer guard.stop()
+
+	installScriptHost(vm, src, limits, guard, trace)
+	if _, err := vm.RunProgram(polyfillProgram); err != nil {
+		return fmt.Errorf("polyfill injection error: %w", err)
+	}
+
+	err = func() error {
+		if _, err := vm.RunProgram(program); err != nil {
+			return fmt.Errorf("script compilation error: %w", err)
+		}
+		return fn(vm)
+	}()
+	if cause := interruptCause(err); cause != nil {
+		if cause == ErrScriptTimeout {
+			return fmt.Errorf("%w (%s)", ErrScriptTimeout, limits.Timeout)
+		}
+		return cause
+	}
+	return err
+}
+
+// checkScriptOutput 检查返回结果大小
+func checkScriptOutput(size int) error {
+	if limit := currentScriptLimits().MaxOutput; limit > 0 && size > limit {
+		return fmt.Errorf("%w (%s > %s)", ErrScriptOutputLimit, FormatBytes(int64(size)), FormatBytes(int64(limit)))
+	}
+	return nil
+}
+
+// installScriptHost 注入 console 与宿主 API
+func installScriptHost(vm *goja.Runtime, src ScriptSource, limits ScriptLimits, guard *scriptGuard, trace *ScriptTrace) {
+	name := src.displayName()
+	logCount := 0
+	logFn := func(level string) func(goja.FunctionCall) goja.Value {
+		return func(call goja.FunctionCall) goja.Value {
+			if logCount >= limits.MaxLogs {
+				return goja.Undefined()
+			}
+			logCount++
+			parts := make([]string, 0, len(call.Arguments))
+			for _, arg := range call.Arguments {
+				parts = append(parts, formatScriptLogArg(arg))
+			}
+			line := strings.Join(parts, " ")
+			if len(line) > scriptMaxLogLineLen {
+				line = line[:scriptMaxLogLineLen] + "..."
+			}
+			if logCount == limits.MaxLogs {
+				line += " (后续输出已省略)"
+			}
+			Debug("[脚本 %s] %s: %s", name, level, line)
+			if trace != nil {
+				trace.Logs = append(trace.Logs, "["+level+"] "+line)
+			}
+			return goja.Undefined()
+		}
+	}
+	console := vm.NewObject()
+	for _, level := range []string{"log", "info", "warn", "error", "debug"} {
+		_ = console.Set(level, logFn(level))
+	}
+	vm.Set("console", console)
+
+	installScriptHTTP(vm, limits, guard)
+
+	vm.Set("$geoip", func(ip string) interface{} {
+		if ScriptGeoIPFunc == nil {
+			return nil
+		}
+		result, err := ScriptGeoIPFunc(ip)
+		if err != nil {
+			return nil
+		}
+		return result
+	})
+
+	tags := vm.NewObject()
+	_ = tags.Set("list", func() []ScriptTag {
+		if ScriptTagsFunc == nil {
+			return []ScriptTag{}
+		}
+		return ScriptTagsFunc()
+	})
+	_ = tags.Set("get", func(tagName string) interface{} {
+		if ScriptTagsFunc == nil {
+			return nil
+		}
+		for _, t := range ScriptTagsFunc() {
+			if t.Name == tagName {
+				return t
+			}
+		}
+		return nil
+	})
+	_ = tags.Set("nodes", func(tagName string) []string {
+		if ScriptTagNodesFunc == nil {
+			return []string{}
+		}
+		return ScriptTagNodesFunc(tagName)
+	})
+	vm.Set("$tags", tags)
+
+	namespace := src.Namespace
+	store := vm.NewObject()
+	requireStore := func() {
+		if namespace == "" || ScriptKVGetFunc == nil {
+			panic(vm.NewGoError(errors.New("KV 存储不可用")))
+		}
+	}
+	// 试运行时写入只记录在 trace 中，读取优先返回本次写入的值
+	write := func(key string, value *string) {
+		if trace.KVWrites == nil {
+			trace.KVWrites = make(map[string]*string)
+		}
+		trace.KVWrites[key] = value
+	}
+	_ = store.Set("get", func(key string) interface{} {
+		requireStore()
+		if trace != nil {
+			if value, ok := trace.KVWrites[key]; ok {
+				if value == nil {
+					return nil
+				}
+				return *value
+			}
+		}
+		value, ok, err := ScriptKVGetFunc(namespace, key)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		if !ok {
+			return nil
+		}
+		return value
+	})
+	_ = store.Set("set", func(key string, value goja.Value) {
+		requireStore()
+		if trace != nil {
+			v := value.String()
+			write(key, &v)
+			return
+		}
+		if err := ScriptKVSetFunc(namespace, key, value.String()); err != nil {
+			panic(vm.NewGoError(err))
+		}
+	})
+	_ = store.Set("delete", func(key string) {
+		requireStore()
+		if trace != nil {
+			write(key, nil)
+			return
+		}
+		if err := ScriptKVDeleteFunc(namespace, key); err != nil {
+			panic(vm.NewGoError(err))
+		}
+	})
+	vm.Set("$store", store)
+
+	// $params：脚本头部声明的参数（订阅中配置的值覆盖默认值）
+	params := src.Params
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	vm.Set("$params", params)
+}
+
+// formatScriptLogArg 格式化 console 参数，对象输出为 JSON
+func formatScriptLogArg(arg goja.Value) string {
+	if obj, ok := arg.(*goja.Object); ok {
+		if _, isFn := goja.AssertFunction(obj); !isFn {
+			if data, err := json.Marshal(obj.Export()); err == nil {
+				return string(data)
+			}
+		}
+	}
+	return arg.String()
+}
+
+// installScriptHTTP 注入只读的 $http.get，仅允许访问白名单中的域名
+func installScriptHTTP(vm *goja.Runtime, limits ScriptLimits, guard *scriptGuard) {
+	calls := 0
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("重定向次数过多")
+			}
+			if !scriptFetchAllowed(req.URL, limits.FetchAllowlist) {
+				return fmt.Errorf("重定向目标 %s 不在允许列表中", req.URL.Hostname())
+			}
+			return nil
+		},
+	}
+
+	httpObj := vm.NewObject()
+	_ = httpObj.Set("get", func(rawURL string, opts map[string]interface{}) map[string]interface{} {
+		u, err := url.Parse(rawURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			panic(vm.NewTypeError("无效的地址: %s", rawURL))
+		}
+		if !scriptFetchAllowed(u, limits.FetchAllowlist) {
+			panic(vm.NewGoError(fmt.Errorf("域名 %s 不在允许列表中", u.Hostname())))
+		}
+		if calls >= scriptFetchMaxCalls {
+			panic(vm.NewGoError(fmt.Errorf("单次执行最多请求 %d 次", scriptFetchMaxCalls)))
+		}
+		calls++
+
+		timeout := min(scriptFetchTimeout, guard.remaining())
+		switch t := opts["timeout"].(type) {
+		case int64:
+			if t > 0 {
+				timeout = min(time.Duration(t)*time.Millisecond, timeout)
+			}
+		case float64:
+			if t > 0 {
+				timeout = min(time.Duration(t*float64(time.Millisecond)), timeout)
+			}
+		}
+		if timeout <= 0 {
+			panic(vm.NewGoError(ErrScriptTimeout))
+		}
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		if headers, ok := opts["headers"].(map[string]interface{}); ok {
+			for k, v := range headers {
+				req.Header.Set(k, fmt.Sprint(v))
+			}
+		}
+		client.Timeout = timeout
+		resp, err := client.Do(req)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(io.LimitReader(resp.Body, scriptFetchMaxBody+1))
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		if len(body) > scriptFetchMaxBody {
+			panic(vm.NewGoError(fmt.Errorf("响应超过 %s", FormatBytes(scriptFetchMaxBody))))
+		}
+		headers := make(map[string]interface{}, len(resp.Header))
+		for k := range resp.Header {
+			headers[strings.ToLower(k)] = resp.Header.Get(k)
+		}
+		return map[string]interface{}{
+			"status":  resp.StatusCode,
+			"ok":      resp.StatusCode >= 200 && resp.StatusCode < 300,
+			"headers": headers,
+			"body":    string(body),
+		}
+	})
+	vm.Set("$http", httpObj)
+}
+
+// scriptFetchAllowed 检查域名是否在白名单中
+func scriptFetchAllowed(u *url.URL, allowlist []string) bool {
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return false
+	}
+	for _, entry := range allowlist {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if suffix, ok := strings.CutPrefix(entry, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == entry {
+			return true
+		}
+	}
+	return false
+}
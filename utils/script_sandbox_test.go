@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withScriptLimits 在测试期间替换脚本运行限制
+func withScriptLimits(t *testing.T, limits ScriptLimits) {
+	t.Helper()
+	prev := ScriptLimitsFunc
+	ScriptLimitsFunc = func() ScriptLimits { return limits }
+	t.Cleanup(func() { ScriptLimitsFunc = prev })
+}
+
+// TestScriptTimeoutInterruptsInfiniteLoop 测试死循环在超时后被中断
+func TestScriptTimeoutInterruptsInfiniteLoop(t *testing.T) {
+	withScriptLimits(t, ScriptLimits{Timeout: 200 * time.Millisecond})
+
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{name: "函数内死循环", script: `function subMod(input, clientType) { while (true) {} }`},
+		{name: "加载时死循环", script: `for (;;) {} function subMod(input) { return input }`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			_, err := RunScript(InlineScript(tt.script, ""), "input", "clash")
+			elapsed := time.Since(start)
+			if !errors.Is(err, ErrScriptTimeout) {
+				t.Fatalf("期望 ErrScriptTimeout, 实际: %v", err)
+			}
+			if elapsed > 2*time.Second {
+				t.Errorf("中断耗时过长: %s", elapsed)
+			}
+		})
+	}
+}
+
+// TestScriptMemoryLimit 测试持续分配内存的脚本被中断
+func TestScriptMemoryLimit(t *testing.T) {
+	withScriptLimits(t, ScriptLimits{Timeout: 20 * time.Second, MaxMemory: 32 << 20})
+
+	script := `function subMod(input) {
+		const keep = [];
+		while (true) { keep.push(new Array(100000).fill(input)); }
+	}`
+	_, err := RunScript(InlineScript(script, ""), "x", "clash")
+	if !errors.Is(err, ErrScriptMemoryLimit) {
+		t.Fatalf("期望 ErrScriptMemoryLimit, 实际: %v", err)
+	}
+}
+
+// TestScriptOutputLimit 测试返回结果大小限制
+func TestScriptOutputLimit(t *testing.T) {
+	withScriptLimits(t, ScriptLimits{Timeout: 5 * time.Second, MaxOutput: 1024})
+
+	_, err := RunScript(InlineScript(`function subMod(input) { return input.repeat(2048) }`, ""), "x", "clash")
+	if !errors.Is(err, ErrScriptOutputLimit) {
+		t.Fatalf("期望 ErrScriptOutputLimit, 实际: %v", err)
+	}
+	out, err := RunScript(InlineScript(`function subMod(input) { return input.repeat(10) }`, ""), "x", "clash")
+	if err != nil || out != strings.Repeat("x", 10) {
+		t.Fatalf("未超限的结果应正常返回: %q, %v", out, err)
+	}
+}
+
+// TestCompileScriptCache 测试编译缓存按 ID+版本 命中，版本或内容变化时重新编译
+func TestCompileScriptCache(t *testing.T) {
+	const id = 987654
+	t.Cleanup(func() { InvalidateScriptProgram(id) })
+
+	v1 := ScriptSource{ID: id, Version: "1.0.0", Content: `function subMod() { return "v1" }`}
+	p1, err := compileScript(v1)
+	if err != nil {
+		t.Fatalf("编译失败: %v", err)
+	}
+	if p, _ := compileScript(v1); p != p1 {
+		t.Error("相同 ID 与版本应命中缓存")
+	}
+
+	v2 := ScriptSource{ID: id, Version: "1.0.1", Content: `function subMod() { return "v2" }`}
+	p2, err := compileScript(v2)
+	if err != nil {
+		t.Fatalf("编译失败: %v", err)
+	}
+	if p2 == p1 {
+		t.Error("版本变化后应重新编译")
+	}
+	if out, err := RunScript(v2, "", "clash"); err != nil || out != "v2" {
+		t.Errorf("应执行新版本脚本, 实际: %q, %v", out, err)
+	}
+
+	// 同一版本内容被修改（未升级版本号）时按内容哈希发现变化
+	edited := ScriptSource{ID: id, Version: "1.0.1", Content: `function subMod() { return "edited" }`}
+	if p, _ := compileScript(edited); p == p2 {
+		t.Error("同版本内容变化后应重新编译")
+	}
+	if out, _ := RunScript(edited, "", "clash"); out != "edited" {
+		t.Errorf("应执行修改后的脚本, 实际: %q", out)
+	}
+
+	InvalidateScriptProgram(id)
+	if p, _ := compileScript(edited); p == nil {
+		t.Fatal("清除缓存后应能重新编译")
+	}
+	scriptProgramMu.Lock()
+	count := 0
+	for key := range scriptProgramCache {
+		if strings.HasPrefix(key, "id:987654@") {
+			count++
+		}
+	}
+	scriptProgramMu.Unlock()
+	if count != 1 {
+		t.Errorf("清除缓存后应只剩重新编译的条目, 实际 %d 条", count)
+	}
+}
+
+// TestScriptFetchAllowed 测试 $http.get 域名白名单匹配
+func TestScriptFetchAllowed(t *testing.T) {
+	allowlist := []string{"api.example.com", "*.cdn.example.org"}
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://api.example.com/x", true},
+		{"https://API.example.com/x", true},
+		{"https://evil-api.example.com/x", false},
+		{"https://a.cdn.example.org/x", true},
+		{"https://cdn.example.org/x", false},
+		{"https://example.com/x", false},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if got := scriptFetchAllowed(u, allowlist); got != tt.want {
+			t.Errorf("scriptFetchAllowed(%s) = %v, 期望 %v", tt.url, got, tt.want)
+		}
+	}
+}